@@ -71,6 +71,8 @@ type Region struct {
 	STSEndpoint            string
 	CloudFormationEndpoint string
 	ElastiCacheEndpoint    string
+
+	DynamoDBStreamsEndpoint string
 }
 
 var Regions = map[string]Region{
@@ -22,6 +22,7 @@ var USGovWest = Region{
 	"https://sts.amazonaws.com",
 	"https://cloudformation.us-gov-west-1.amazonaws.com",
 	"",
+	"https://streams.dynamodb.us-gov-west-1.amazonaws.com",
 }
 
 var USEast = Region{
@@ -46,6 +47,7 @@ var USEast = Region{
 	"https://sts.amazonaws.com",
 	"https://cloudformation.us-east-1.amazonaws.com",
 	"https://elasticache.us-east-1.amazonaws.com",
+	"https://streams.dynamodb.us-east-1.amazonaws.com",
 }
 
 var USWest = Region{
@@ -70,6 +72,7 @@ var USWest = Region{
 	"https://sts.amazonaws.com",
 	"https://cloudformation.us-west-1.amazonaws.com",
 	"https://elasticache.us-west-1.amazonaws.com",
+	"https://streams.dynamodb.us-west-1.amazonaws.com",
 }
 
 var USWest2 = Region{
@@ -94,6 +97,7 @@ var USWest2 = Region{
 	"https://sts.amazonaws.com",
 	"https://cloudformation.us-west-2.amazonaws.com",
 	"https://elasticache.us-west-2.amazonaws.com",
+	"https://streams.dynamodb.us-west-2.amazonaws.com",
 }
 
 var EUWest = Region{
@@ -118,6 +122,7 @@ var EUWest = Region{
 	"https://sts.amazonaws.com",
 	"https://cloudformation.eu-west-1.amazonaws.com",
 	"https://elasticache.eu-west-1.amazonaws.com",
+	"https://streams.dynamodb.eu-west-1.amazonaws.com",
 }
 
 var EUCentral = Region{
@@ -142,6 +147,7 @@ var EUCentral = Region{
 	"https://sts.amazonaws.com",
 	"https://cloudformation.eu-central-1.amazonaws.com",
 	"",
+	"https://streams.dynamodb.eu-central-1.amazonaws.com",
 }
 
 var APSoutheast = Region{
@@ -166,6 +172,7 @@ var APSoutheast = Region{
 	"https://sts.amazonaws.com",
 	"https://cloudformation.ap-southeast-1.amazonaws.com",
 	"https://elasticache.ap-southeast-1.amazonaws.com",
+	"https://streams.dynamodb.ap-southeast-1.amazonaws.com",
 }
 
 var APSoutheast2 = Region{
@@ -190,6 +197,7 @@ var APSoutheast2 = Region{
 	"https://sts.amazonaws.com",
 	"https://cloudformation.ap-southeast-2.amazonaws.com",
 	"https://elasticache.ap-southeast-2.amazonaws.com",
+	"https://streams.dynamodb.ap-southeast-2.amazonaws.com",
 }
 
 var APSouth = Region{
@@ -214,6 +222,7 @@ var APSouth = Region{
 	"https://sts.amazonaws.com",
 	"https://cloudformation.ap-south-1.amazonaws.com",
 	"https://elasticache.ap-south-1.amazonaws.com",
+	"https://streams.dynamodb.ap-south-1.amazonaws.com",
 }
 
 var APNortheast = Region{
@@ -238,6 +247,7 @@ var APNortheast = Region{
 	"https://sts.amazonaws.com",
 	"https://cloudformation.ap-northeast-1.amazonaws.com",
 	"https://elasticache.ap-northeast-1.amazonaws.com",
+	"https://streams.dynamodb.ap-northeast-1.amazonaws.com",
 }
 
 var APNortheast2 = Region{
@@ -262,6 +272,7 @@ var APNortheast2 = Region{
 	"https://sts.amazonaws.com",
 	"https://cloudformation.ap-northeast-2.amazonaws.com",
 	"https://elasticache.ap-northeast-2.amazonaws.com",
+	"https://streams.dynamodb.ap-northeast-2.amazonaws.com",
 }
 
 var SAEast = Region{
@@ -286,6 +297,7 @@ var SAEast = Region{
 	"https://sts.amazonaws.com",
 	"https://cloudformation.sa-east-1.amazonaws.com",
 	"https://elasticache.sa-east-1.amazonaws.com",
+	"https://streams.dynamodb.sa-east-1.amazonaws.com",
 }
 
 var CNNorth1 = Region{
@@ -310,4 +322,5 @@ var CNNorth1 = Region{
 	"https://sts.cn-north-1.amazonaws.com.cn",
 	"",
 	"",
+	"https://streams.dynamodb.cn-north-1.amazonaws.com.cn",
 }
@@ -445,6 +445,23 @@ func (s *V4Signer) derivedKey(t time.Time) []byte {
 	return h
 }
 
+/*
+Credential returns the value of the x-amz-credential field or parameter for
+requests signed at time t: the access key followed by the credential scope.
+*/
+func (s *V4Signer) Credential(t time.Time) string {
+	return s.auth.AccessKey + "/" + s.credentialScope(t)
+}
+
+/*
+SignPolicy calculates the signature of a browser-based POST upload policy
+according to the AWS Signature Version 4 Signing Process. The base64-encoded
+policy document is the string to sign. (http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-post-example.html)
+*/
+func (s *V4Signer) SignPolicy(t time.Time, policy64 string) string {
+	return s.signature(t, policy64)
+}
+
 /*
 authorization method generates the authorization header value.
 */
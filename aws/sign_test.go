@@ -1,6 +1,9 @@
 package aws_test
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/AdRoll/goamz/aws"
 	"gopkg.in/check.v1"
@@ -557,6 +560,25 @@ func (s *V4SignerSuite) TestCases(c *check.C) {
 	}
 }
 
+func (s *V4SignerSuite) TestSignPolicy(c *check.C) {
+	signer := aws.NewV4Signer(s.auth, "s3", s.region)
+	t := time.Date(2015, 12, 29, 0, 0, 0, 0, time.UTC)
+	policy64 := "eyAiZXhwaXJhdGlvbiI6ICIyMDE1LTEyLTMwVDEyOjAwOjAwLjAwMFoiIH0="
+
+	c.Assert(signer.Credential(t), check.Equals, "AKIDEXAMPLE/20151229/us-east-1/s3/aws4_request")
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	key := mac([]byte("AWS4"+s.auth.SecretKey), "20151229")
+	key = mac(key, "us-east-1")
+	key = mac(key, "s3")
+	key = mac(key, "aws4_request")
+	c.Assert(signer.SignPolicy(t, policy64), check.Equals, hex.EncodeToString(mac(key, policy64)))
+}
+
 func ExampleV4Signer() {
 	// Get auth from env vars
 	auth, err := aws.EnvAuth()
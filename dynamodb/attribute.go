@@ -213,6 +213,8 @@ func (a Attribute) valueMsi() msi {
 			c[i] = nestedAttr.valueMsi()
 		}
 		return msi{a.Type: c}
+	case a.Type == TYPE_BOOL || a.Type == TYPE_NULL:
+		return msi{a.Type: a.Value == "true"}
 
 	default:
 		return msi{a.Type: a.Value}
@@ -421,3 +421,49 @@ func (s *BatchSuite) TestBatchPutDocumentSizeExceeded(c *check.C) {
 		c.Assert(err.Error(), check.Equals, "Cannot add item, max batch size (25) exceeded")
 	}
 }
+
+func (s *BatchSuite) TestBatchWriter(c *check.C) {
+	numKeys := 2*MaxPutBatchSize + 5
+	keys := make([]*Key, 0, numKeys)
+	w := s.server.NewBatchWriter(2)
+	for i := 0; i < numKeys; i++ {
+		k := &Key{HashKey: "NewHashKeyVal" + strconv.Itoa(i)}
+		if s.WithRange {
+			k.RangeKey = strconv.Itoa(12 + i)
+		}
+		keys = append(keys, k)
+
+		var err error
+		if i%2 == 0 {
+			err = w.Put(s.table, k, []Attribute{*NewStringAttribute("Attr1", "Attr1Val"+strconv.Itoa(i))})
+		} else {
+			err = w.PutDocument(s.table, k, map[string]interface{}{"Attr1": "Attr1Val" + strconv.Itoa(i)})
+		}
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(w.Flush(), check.HasLen, 0)
+
+	for i, k := range keys {
+		item, err := s.table.GetItem(k)
+		c.Assert(err, check.IsNil)
+		c.Check(item["Attr1"].Value, check.Equals, "Attr1Val"+strconv.Itoa(i))
+	}
+
+	// The second write of an item is in another batch, which follows the
+	// first one with a single request at once.
+	w = s.server.NewBatchWriter(1)
+	c.Assert(w.Put(s.table, keys[0], []Attribute{*NewStringAttribute("Attr1", "Changed")}), check.IsNil)
+	for _, k := range keys {
+		c.Assert(w.Delete(s.table, k), check.IsNil)
+	}
+	c.Assert(w.Close(), check.HasLen, 0)
+	for _, k := range keys {
+		_, err := s.table.GetItem(k)
+		c.Assert(err, check.Equals, ErrNotFound)
+	}
+
+	w = s.server.NewBatchWriter(1)
+	err := w.Delete(s.table, &Key{})
+	c.Assert(err, check.ErrorMatches, "HashKey is always required")
+	c.Assert(w.Close(), check.HasLen, 0)
+}
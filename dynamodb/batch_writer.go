@@ -0,0 +1,303 @@
+package dynamodb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/AdRoll/goamz/dynamodb/dynamizer"
+)
+
+// A BatchWriteFailure is a put or delete of a BatchWriter that failed.
+type BatchWriteFailure struct {
+	Table  *Table
+	Key    *Key
+	Delete bool // Whether the write is a delete, or a put.
+
+	// Err is the error of the BatchWriteItem request that held the write,
+	// or ErrNotProcessed if the item was still unprocessed once the
+	// retries were exhausted.
+	Err error
+}
+
+// A BatchWriter writes items to any number of tables, in BatchWriteItem
+// requests of up to MaxPutBatchSize items, of which it sends several at
+// once. The items DynamoDB leaves unprocessed are written again as the
+// RetryPolicy of the server says.
+//
+// Writes are queued by Put, PutDocument and Delete, which block while all
+// the requests the BatchWriter may send at once are in progress. Flush
+// waits for the queued writes to be done, and returns those that failed.
+// Writes of the same item that are not separated by a Flush may be done
+// in any order, unless the concurrency of the BatchWriter is 1.
+//
+// A BatchWriter must not be used concurrently, and must be closed once
+// done with.
+type BatchWriter struct {
+	Server *Server
+
+	pending  []*batchWrite
+	ids      map[string]bool // of the pending writes.
+	batches  chan []*batchWrite
+	inFlight sync.WaitGroup // of the batches sent.
+	workers  sync.WaitGroup
+
+	mu       sync.Mutex
+	failures []BatchWriteFailure
+}
+
+// batchWrite is a write queued by a BatchWriter.
+type batchWrite struct {
+	table   *Table
+	key     *Key
+	delete  bool
+	id      string // the table name and the encoded key.
+	request msi    // the PutRequest or DeleteRequest.
+}
+
+// NewBatchWriter returns a BatchWriter that sends up to concurrency
+// requests at once, or one if it is less than 1.
+func (s *Server) NewBatchWriter(concurrency int) *BatchWriter {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	w := &BatchWriter{
+		Server:  s,
+		ids:     make(map[string]bool),
+		batches: make(chan []*batchWrite),
+	}
+	w.workers.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer w.workers.Done()
+			for batch := range w.batches {
+				w.write(batch)
+				w.inFlight.Done()
+			}
+		}()
+	}
+	return w
+}
+
+// Put queues the put of the item with the given key and attributes.
+func (w *BatchWriter) Put(t *Table, key *Key, attributes []Attribute) error {
+	item := msi{}
+	for _, a := range attributes {
+		item[a.Name] = a.valueMsi()
+	}
+	return w.put(t, key, item)
+}
+
+// PutDocument queues the put of the item with the given key and the
+// attributes of v, as dynamizer.ToDynamo converts them.
+func (w *BatchWriter) PutDocument(t *Table, key *Key, v interface{}) error {
+	attributes, err := dynamizer.ToDynamo(v)
+	if err != nil {
+		return err
+	}
+	item := msi{}
+	for name, a := range attributes {
+		item[name] = a
+	}
+	return w.put(t, key, item)
+}
+
+func (w *BatchWriter) put(t *Table, key *Key, item msi) error {
+	keys, err := buildKeyMap(t, key)
+	if err != nil {
+		return err
+	}
+	for name, a := range keys {
+		item[name] = a
+	}
+	return w.queue(&batchWrite{
+		table:   t,
+		key:     key,
+		request: msi{"PutRequest": msi{"Item": item}},
+	}, keys)
+}
+
+// Delete queues the delete of the item with the given key.
+func (w *BatchWriter) Delete(t *Table, key *Key) error {
+	keys, err := buildKeyMap(t, key)
+	if err != nil {
+		return err
+	}
+	return w.queue(&batchWrite{
+		table:   t,
+		key:     key,
+		delete:  true,
+		request: msi{"DeleteRequest": msi{"Key": keys}},
+	}, keys)
+}
+
+// queue adds a write to the pending batch, which is sent once full.
+// Batches cannot hold several writes of an item, so a write of an item
+// that is pending sends the batch first.
+func (w *BatchWriter) queue(write *batchWrite, keys dynamizer.DynamoItem) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	var rawKeys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rawKeys); err != nil {
+		return err
+	}
+	write.id = batchWriteID(write.table, rawKeys)
+
+	if w.ids[write.id] {
+		w.send()
+	}
+	w.pending = append(w.pending, write)
+	w.ids[write.id] = true
+	if len(w.pending) == MaxPutBatchSize {
+		w.send()
+	}
+	return nil
+}
+
+// batchWriteID identifies the item of a table whose key attributes are
+// keys, encoded as JSON. Numbers are identified by their value, which
+// DynamoDB may format differently.
+func batchWriteID(t *Table, keys map[string]json.RawMessage) string {
+	id := fmt.Sprintf("%q %s", t.Name, keyID(keys[t.Key.KeyAttribute.Name]))
+	if t.Key.HasRange() {
+		id += " " + keyID(keys[t.Key.RangeAttribute.Name])
+	}
+	return id
+}
+
+func keyID(raw json.RawMessage) string {
+	var a dynamizer.DynamoAttribute
+	if err := json.Unmarshal(raw, &a); err == nil && a.N != "" {
+		if r, ok := new(big.Rat).SetString(a.N); ok {
+			return "N:" + r.RatString()
+		}
+	}
+	return string(raw)
+}
+
+// send sends the pending batch, if any.
+func (w *BatchWriter) send() {
+	if len(w.pending) == 0 {
+		return
+	}
+	w.inFlight.Add(1)
+	w.batches <- w.pending
+	w.pending = nil
+	w.ids = make(map[string]bool)
+}
+
+// Flush sends the pending writes, waits for all the queued writes to be
+// done, and returns those that failed since the last Flush.
+func (w *BatchWriter) Flush() []BatchWriteFailure {
+	w.send()
+	w.inFlight.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	failures := w.failures
+	w.failures = nil
+	return failures
+}
+
+// Close flushes the BatchWriter, and stops its requests.
+func (w *BatchWriter) Close() []BatchWriteFailure {
+	failures := w.Flush()
+	close(w.batches)
+	w.workers.Wait()
+	return failures
+}
+
+func (w *BatchWriter) fail(batch []*batchWrite, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, write := range batch {
+		w.failures = append(w.failures, BatchWriteFailure{write.table, write.key, write.delete, err})
+	}
+}
+
+type batchWriteQuery struct {
+	RequestItems map[string][]msi
+}
+
+func (q *batchWriteQuery) Marshal() ([]byte, error) {
+	return json.Marshal(q)
+}
+
+type batchWriteResponse struct {
+	UnprocessedItems map[string][]struct {
+		PutRequest *struct {
+			Item map[string]json.RawMessage
+		}
+		DeleteRequest *struct {
+			Key map[string]json.RawMessage
+		}
+	}
+}
+
+// write writes a batch, until its items are all processed or the retries
+// are exhausted.
+func (w *BatchWriter) write(batch []*batchWrite) {
+	numRetries := 0
+	target := target("BatchWriteItem")
+	for {
+		q := &batchWriteQuery{make(map[string][]msi)}
+		tables := make(map[string]*Table)
+		for _, write := range batch {
+			q.RequestItems[write.table.Name] = append(q.RequestItems[write.table.Name], write.request)
+			tables[write.table.Name] = write.table
+		}
+
+		jsonResponse, err := w.Server.queryServer(target, q)
+		if err != nil {
+			w.fail(batch, err)
+			return
+		}
+
+		var response batchWriteResponse
+		err = json.Unmarshal(jsonResponse, &response)
+		if err != nil {
+			w.fail(batch, err)
+			return
+		}
+
+		unprocessed := make(map[string]bool)
+		for name, requests := range response.UnprocessedItems {
+			t := tables[name]
+			if t == nil {
+				continue
+			}
+			for _, r := range requests {
+				switch {
+				case r.PutRequest != nil:
+					unprocessed[batchWriteID(t, r.PutRequest.Item)] = true
+				case r.DeleteRequest != nil:
+					unprocessed[batchWriteID(t, r.DeleteRequest.Key)] = true
+				}
+			}
+		}
+		var remaining []*batchWrite
+		for _, write := range batch {
+			if unprocessed[write.id] {
+				remaining = append(remaining, write)
+			}
+		}
+
+		if len(remaining) == 0 {
+			return
+		}
+		if !w.Server.RetryPolicy.ShouldRetry(target, nil, errProvisionedThroughputExceeded, numRetries) {
+			w.fail(remaining, ErrNotProcessed)
+			return
+		}
+
+		// Sleep according to the retry strategy and then attempt again with the
+		// remaining items.
+		time.Sleep(w.Server.RetryPolicy.Delay(target, nil, errProvisionedThroughputExceeded, numRetries))
+		numRetries++
+		batch = remaining
+	}
+}
@@ -0,0 +1,340 @@
+package dynamizer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnmarshalAttribute decodes a DynamoDB attribute into the value v points
+// to, the way FromDynamo decodes the attributes of an item.
+func UnmarshalAttribute(a *DynamoAttribute, v interface{}) (err error) {
+	defer catch(&err)
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("v must be a non-nil pointer")
+	}
+	return decode(a, rv.Elem())
+}
+
+// decode decodes a into v, which must be settable.
+//
+// Besides what encode produces, it accepts what encodeLegacy stores:
+// numbers for booleans, base64 strings for []byte, and JSON strings
+// for time.Time, structs, maps and slices.
+func decode(a *DynamoAttribute, v reflect.Value) error {
+	if a == nil || a.NULL {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	u, ju, v := indirect(v)
+	if u != nil {
+		return u.UnmarshalDynamo(a)
+	}
+	if ju != nil {
+		return decodeJSON(a, ju)
+	}
+
+	t := v.Type()
+	if t == timeType {
+		return decodeTime(a, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return typeError(a, t)
+		}
+		v.Set(reflect.ValueOf(undynamize(a)))
+
+	case reflect.Bool:
+		switch {
+		case a.BOOL != nil:
+			v.SetBool(*a.BOOL)
+		case a.N != "":
+			n, err := strconv.ParseInt(a.N, 10, 64)
+			if err != nil {
+				return err
+			}
+			v.SetBool(n != 0)
+		default:
+			return typeError(a, t)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if a.N == "" {
+			return typeError(a, t)
+		}
+		n, err := strconv.ParseInt(a.N, 10, 64)
+		if err != nil || v.OverflowInt(n) {
+			return fmt.Errorf("the number %s does not fit in %s", a.N, t.String())
+		}
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if a.N == "" {
+			return typeError(a, t)
+		}
+		n, err := strconv.ParseUint(a.N, 10, 64)
+		if err != nil || v.OverflowUint(n) {
+			return fmt.Errorf("the number %s does not fit in %s", a.N, t.String())
+		}
+		v.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		if a.N == "" {
+			return typeError(a, t)
+		}
+		n, err := strconv.ParseFloat(a.N, t.Bits())
+		if err != nil || v.OverflowFloat(n) {
+			return fmt.Errorf("the number %s does not fit in %s", a.N, t.String())
+		}
+		v.SetFloat(n)
+
+	case reflect.String:
+		switch {
+		case a.S != nil:
+			v.SetString(*a.S)
+		case a.N != "" && t == numberType:
+			v.SetString(a.N)
+		default:
+			return typeError(a, t)
+		}
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 && (a.B != nil || a.S != nil) {
+			b, err := decodeBytes(a)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(b)
+			break
+		}
+		if a.S != nil {
+			return decodeJSONString(*a.S, v)
+		}
+		list, ok := listOf(a)
+		if !ok {
+			return typeError(a, t)
+		}
+		s := reflect.MakeSlice(t, len(list), len(list))
+		for i, e := range list {
+			if err := decode(e, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && (a.B != nil || a.S != nil) {
+			b, err := decodeBytes(a)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.Zero(t))
+			reflect.Copy(v, reflect.ValueOf(b))
+			break
+		}
+		if a.S != nil {
+			return decodeJSONString(*a.S, v)
+		}
+		list, ok := listOf(a)
+		if !ok {
+			return typeError(a, t)
+		}
+		for i := 0; i < v.Len(); i++ {
+			if i < len(list) {
+				if err := decode(list[i], v.Index(i)); err != nil {
+					return err
+				}
+			} else {
+				v.Index(i).Set(reflect.Zero(t.Elem()))
+			}
+		}
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return typeError(a, t)
+		}
+		if a.S != nil {
+			return decodeJSONString(*a.S, v)
+		}
+		if a.M == nil {
+			return typeError(a, t)
+		}
+		m := reflect.MakeMap(t)
+		for k, e := range a.M {
+			ev := reflect.New(t.Elem()).Elem()
+			if err := decode(e, ev); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), ev)
+		}
+		v.Set(m)
+
+	case reflect.Struct:
+		if a.S != nil {
+			return decodeJSONString(*a.S, v)
+		}
+		if a.M == nil {
+			return typeError(a, t)
+		}
+		fields := cachedTypeFields(t)
+		for name, e := range a.M {
+			f := fieldNamed(fields, name)
+			if f == nil {
+				continue
+			}
+			fv := fieldByIndexAlloc(v, f.index)
+			if !fv.IsValid() {
+				continue
+			}
+			if err := decode(e, fv); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return typeError(a, t)
+	}
+
+	return nil
+}
+
+// indirect walks down v, allocating the nil pointers, until it gets to a
+// value that is not a pointer, or that implements Unmarshaler or, save
+// for time.Time, json.Unmarshaler.
+func indirect(v reflect.Value) (Unmarshaler, json.Unmarshaler, reflect.Value) {
+	if v.Kind() != reflect.Ptr && v.Type().Name() != "" && v.CanAddr() {
+		v = v.Addr()
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		if v.Type().Implements(unmarshalerType) {
+			return v.Interface().(Unmarshaler), nil, reflect.Value{}
+		}
+		if v.Type().Elem() != timeType && v.Type().Implements(jsonUnmarshalerType) {
+			return nil, v.Interface().(json.Unmarshaler), reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return nil, nil, v
+}
+
+// fieldNamed returns the field with the given name or, failing that, the
+// first whose name matches it regardless of case, as encoding/json does.
+func fieldNamed(fields []field, name string) *field {
+	var match *field
+	for i := range fields {
+		f := &fields[i]
+		if f.name == name {
+			return f
+		}
+		if match == nil && strings.EqualFold(f.name, name) {
+			match = f
+		}
+	}
+	return match
+}
+
+// listOf returns the elements of a list or set.
+func listOf(a *DynamoAttribute) ([]*DynamoAttribute, bool) {
+	switch {
+	case a.L != nil:
+		return a.L, true
+	case a.SS != nil:
+		list := make([]*DynamoAttribute, len(a.SS))
+		for i := range a.SS {
+			list[i] = &DynamoAttribute{S: &a.SS[i]}
+		}
+		return list, true
+	case a.NS != nil:
+		list := make([]*DynamoAttribute, len(a.NS))
+		for i, n := range a.NS {
+			list[i] = &DynamoAttribute{N: n}
+		}
+		return list, true
+	case a.BS != nil:
+		list := make([]*DynamoAttribute, len(a.BS))
+		for i, b := range a.BS {
+			list[i] = &DynamoAttribute{B: b}
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+func decodeBytes(a *DynamoAttribute) ([]byte, error) {
+	if a.B != nil {
+		return append([]byte{}, a.B...), nil
+	}
+	return base64.StdEncoding.DecodeString(*a.S)
+}
+
+// decodeTime decodes a RFC 3339 string, a number of seconds since the Unix
+// epoch, or a time encoded as JSON.
+func decodeTime(a *DynamoAttribute, v reflect.Value) error {
+	var t time.Time
+	switch {
+	case a.N != "":
+		if n, err := strconv.ParseInt(a.N, 10, 64); err == nil {
+			t = time.Unix(n, 0).UTC()
+			break
+		}
+		f, err := strconv.ParseFloat(a.N, 64)
+		if err != nil {
+			return err
+		}
+		sec := int64(f)
+		t = time.Unix(sec, int64((f-float64(sec))*1e9)).UTC()
+	case a.S != nil && strings.HasPrefix(*a.S, `"`):
+		if err := json.Unmarshal([]byte(*a.S), &t); err != nil {
+			return err
+		}
+	case a.S != nil:
+		var err error
+		t, err = time.Parse(time.RFC3339Nano, *a.S)
+		if err != nil {
+			return err
+		}
+	default:
+		return typeError(a, v.Type())
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// decodeJSON decodes the types that implement json.Unmarshaler from the
+// JSON representation of a or, failing that, from the JSON string a holds.
+func decodeJSON(a *DynamoAttribute, u json.Unmarshaler) error {
+	b, err := json.Marshal(undynamize(a))
+	if err == nil {
+		if err = u.UnmarshalJSON(b); err == nil {
+			return nil
+		}
+	}
+	if a.S != nil && u.UnmarshalJSON([]byte(*a.S)) == nil {
+		return nil
+	}
+	return err
+}
+
+func decodeJSONString(s string, v reflect.Value) error {
+	p := reflect.New(v.Type())
+	if err := json.Unmarshal([]byte(s), p.Interface()); err != nil {
+		return err
+	}
+	v.Set(p.Elem())
+	return nil
+}
+
+func typeError(a *DynamoAttribute, t reflect.Type) error {
+	return fmt.Errorf("cannot decode a %s attribute into %s", a.typeName(), t.String())
+}
@@ -1,96 +1,167 @@
 package dynamizer
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
 	"runtime"
 	"strconv"
-
-	"github.com/cbroglie/mapstructure"
 )
 
 // A DynamoAttribute represents the union of possible DynamoDB attribute values.
 // See http://docs.aws.amazon.com/amazondynamodb/latest/APIReference/API_AttributeValue.html
-//
-// Since the intention is to use this with JSON documents (or structs
-// representing JSON documents), the binary and set types have been omitted.
 type DynamoAttribute struct {
 	S    *string                     `json:",omitempty"` // pointer so we can represent the zero-value
 	N    string                      `json:",omitempty"`
+	B    []byte                      `json:",omitempty"`
 	BOOL *bool                       `json:",omitempty"` // pointer so we can represent the zero-value
 	NULL bool                        `json:",omitempty"`
 	M    map[string]*DynamoAttribute `json:",omitempty"`
 	L    []*DynamoAttribute          `json:",omitempty"`
+	SS   []string                    `json:",omitempty"`
+	NS   []string                    `json:",omitempty"`
+	BS   [][]byte                    `json:",omitempty"`
+}
+
+type dynamoAttribute DynamoAttribute
+
+// MarshalJSON encodes the attribute, keeping the empty lists, maps and
+// binaries that the omitempty options would leave out.
+func (a *DynamoAttribute) MarshalJSON() ([]byte, error) {
+	switch {
+	case a.L != nil && len(a.L) == 0:
+		return []byte(`{"L":[]}`), nil
+	case a.M != nil && len(a.M) == 0:
+		return []byte(`{"M":{}}`), nil
+	case a.B != nil && len(a.B) == 0:
+		return []byte(`{"B":""}`), nil
+	}
+	return json.Marshal((*dynamoAttribute)(a))
+}
+
+// typeName returns the type of the attribute, as DynamoDB names it.
+func (a *DynamoAttribute) typeName() string {
+	switch {
+	case a.S != nil:
+		return "S"
+	case a.N != "":
+		return "N"
+	case a.B != nil:
+		return "B"
+	case a.BOOL != nil:
+		return "BOOL"
+	case a.NULL:
+		return "NULL"
+	case a.M != nil:
+		return "M"
+	case a.L != nil:
+		return "L"
+	case a.SS != nil:
+		return "SS"
+	case a.NS != nil:
+		return "NS"
+	case a.BS != nil:
+		return "BS"
+	}
+	return "empty"
 }
 
 // A DynamoItem represents a the top level item stored in DyanmoDB.
 type DynamoItem map[string]*DynamoAttribute
 
-// ToDynamo accepts a map or struct in basic JSON format and converts it to a
-// map which can be JSON-encoded into the DynamoDB format.
+// ToDynamo accepts a map or struct and converts it to a map which can be
+// JSON-encoded into the DynamoDB format.
+//
+// Booleans, numbers and strings are encoded as BOOL, N and S attributes,
+// []byte as a base64 S attribute, as in JSON, slices and arrays as L, and
+// maps and structs as M. Nil pointers, slices, maps and interfaces are
+// NULL. A time.Time is encoded as a RFC 3339 string. The types that implement Marshaler encode
+// themselves, and the other types that implement json.Marshaler are
+// encoded as their JSON representation.
+//
+// The fields of structs are named and embedded as by encoding/json, and
+// can be tagged with "dynamodbav" instead of "json":
 //
-// If in is a struct, we first JSON encode/decode it to get the data as a map.
-// This can/should be optimized later.
+//	// The field is named "name", and left out if empty.
+//	Field string `dynamodbav:"name,omitempty"`
+//
+//	// The field is left out.
+//	Field int `dynamodbav:"-"`
+//
+//	// The field is encoded as a SS, NS or BS set, and left out if empty
+//	// since DynamoDB does not store empty sets.
+//	Field []string `dynamodbav:",stringset"`
+//	Field []int    `dynamodbav:",numberset"`
+//	Field [][]byte `dynamodbav:",binaryset"`
+//
+//	// The []byte, or the []byte in the slices and maps, are encoded as
+//	// B attributes.
+//	Field []byte `dynamodbav:",binary"`
+//
+//	// The time is encoded as a number of seconds since the Unix epoch,
+//	// as the time to live of tables expects.
+//	Field time.Time `dynamodbav:",unixtime"`
+//
+//	// The integer is the version of the item, for optimistic locking
+//	// (see Version).
+//	Field int64 `dynamodbav:",version"`
+//
+//	// The field is encoded as dynamodb.MarshalAttributes encoded all the
+//	// fields before it used ToDynamo, for the tables that hold such
+//	// items: booleans as 1 or 0 numbers, the slices of strings and
+//	// numbers as SS and NS sets, []byte as base64 strings, and the other
+//	// values, such as time.Time and structs, as JSON strings. The field
+//	// is left out if it is an empty string, slice or map, or nil.
+//	Field bool `dynamodbav:",legacy"`
 func ToDynamo(in interface{}) (item DynamoItem, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			if e, ok := r.(runtime.Error); ok {
-				err = e
-			} else if s, ok := r.(string); ok {
-				err = errors.New(s)
-			} else {
-				err = r.(error)
-			}
-			item = nil
-		}
-	}()
+	defer catch(&err)
 
 	v := reflect.ValueOf(in)
 	switch v.Kind() {
 	case reflect.Struct:
-		item = dynamizeStruct(in)
 	case reflect.Map:
 		if v.Type().Key().Kind() != reflect.String {
 			return nil, errors.New("item must be a map[string]interface{} or struct (or a non-nil pointer to one), got " + v.Type().String())
 		}
-		item = dynamizeMap(in)
 	case reflect.Ptr:
 		if v.IsNil() {
 			return nil, errors.New("item must not be nil")
 		}
-		return ToDynamo(v.Elem().Interface())
+		switch v.Elem().Kind() {
+		case reflect.Struct, reflect.Map:
+			return ToDynamo(v.Elem().Interface())
+		}
+		fallthrough
 	default:
 		return nil, errors.New("item must be a map[string]interface{} or struct (or a non-nil pointer to one), got " + v.Type().String())
 	}
-	return item, nil
+
+	// Encode an addressable copy, for the methods of its fields with pointer
+	// receivers.
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	a, err := encode(c, options{})
+	if err != nil {
+		return nil, err
+	}
+	if a.NULL {
+		return DynamoItem{}, nil
+	}
+	if a.M == nil {
+		return nil, fmt.Errorf("%s must be encoded as a map, got a %s attribute", v.Type().String(), a.typeName())
+	}
+	return a.M, nil
 }
 
 // FromDynamo takes a map of DynamoDB attributes and converts it into a map or
-// struct in basic JSON format.
+// struct, as ToDynamo encodes them, with or without the legacy option.
 //
-// If v points to a struct, we first convert it to a basic map, then JSON
-// encode/decode it to convert to a struct. This can/should be optimized later.
+// Sets are decoded into slices, and the attributes decoded into interface{}
+// values are converted to basic JSON types: the numbers to int, uint or
+// float64, the B attributes to []byte and the sets to []interface{}.
 func FromDynamo(item DynamoItem, v interface{}) (err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			if e, ok := r.(runtime.Error); ok {
-				err = e
-			} else if s, ok := r.(string); ok {
-				err = errors.New(s)
-			} else {
-				err = r.(error)
-			}
-			item = nil
-		}
-	}()
-
-	m := make(map[string]interface{})
-	for k, v := range item {
-		m[k] = undynamize(v)
-	}
+	defer catch(&err)
 
 	// Handle the case where v is already a reflect.Value object representing a
 	// struct or map.
@@ -116,103 +187,31 @@ func FromDynamo(item DynamoItem, v interface{}) (err error) {
 
 	switch rv.Kind() {
 	case reflect.Struct:
-		config := &mapstructure.DecoderConfig{
-			TagName: "json",
-			Result:  v,
-		}
-		decoder, err := mapstructure.NewDecoder(config)
-		if err != nil {
-			return err
-		}
-		return decoder.Decode(m)
 	case reflect.Map:
 		if rv.Type().Key().Kind() != reflect.String {
 			return fmt.Errorf("v must be a non-nil pointer to a map[string]interface{} or struct (or an addressable reflect.Value), got %s", rt.String())
 		}
-		rv.Set(reflect.ValueOf(m))
 	default:
 		return fmt.Errorf("v must be a non-nil pointer to a map[string]interface{} or struct (or an addressable reflect.Value), got %s", rt.String())
 	}
 
-	return nil
-}
-
-func dynamizeStruct(in interface{}) DynamoItem {
-	// TODO: We convert structs into basic maps by JSON encoding/decoding. This
-	// can be made more efficient by recursing over the struct directly.
-	b, err := json.Marshal(in)
-	if err != nil {
-		panic(err)
-	}
-
-	var m map[string]interface{}
-	decoder := json.NewDecoder(bytes.NewReader(b))
-	decoder.UseNumber()
-	err = decoder.Decode(&m)
-	if err != nil {
-		panic(err)
+	if item == nil {
+		item = DynamoItem{}
 	}
-
-	return dynamizeMap(m)
-}
-
-func dynamizeMap(in interface{}) DynamoItem {
-	item := make(DynamoItem)
-	m := in.(map[string]interface{})
-	for k, v := range m {
-		item[k] = dynamize(v)
-	}
-	return item
+	return decode(&DynamoAttribute{M: item}, rv)
 }
 
-func dynamize(in interface{}) *DynamoAttribute {
-	a := &DynamoAttribute{}
-
-	if in == nil {
-		a.NULL = true
-		return a
-	}
-
-	if m, ok := in.(map[string]interface{}); ok {
-		a.M = make(map[string]*DynamoAttribute)
-		for k, v := range m {
-			a.M[k] = dynamize(v)
-		}
-		return a
-	}
-
-	if l, ok := in.([]interface{}); ok {
-		a.L = make([]*DynamoAttribute, len(l))
-		for index, v := range l {
-			a.L[index] = dynamize(v)
-		}
-		return a
-	}
-
-	// Only primitive types should remain.
-	v := reflect.ValueOf(in)
-	switch v.Kind() {
-	case reflect.Bool:
-		a.BOOL = new(bool)
-		*a.BOOL = v.Bool()
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		a.N = strconv.FormatInt(v.Int(), 10)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		a.N = strconv.FormatUint(v.Uint(), 10)
-	case reflect.Float32, reflect.Float64:
-		a.N = strconv.FormatFloat(v.Float(), 'f', -1, 64)
-	case reflect.String:
-		if n, ok := in.(json.Number); ok {
-			a.N = n.String()
+// catch turns the panics of the functions it is deferred in into errors.
+func catch(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(runtime.Error); ok {
+			*err = e
+		} else if s, ok := r.(string); ok {
+			*err = errors.New(s)
 		} else {
-			a.S = new(string)
-			*a.S = v.String()
+			*err = r.(error)
 		}
-	default:
-		panic(fmt.Sprintf(`the type %s is not supported`, v.Type().String()))
 	}
-
-	return a
 }
 
 func undynamize(a *DynamoAttribute) interface{} {
@@ -221,19 +220,11 @@ func undynamize(a *DynamoAttribute) interface{} {
 	}
 
 	if a.N != "" {
-		// Number is tricky b/c we don't know which numeric type to use. Here we
-		// simply try the different types from most to least restrictive.
-		if n, err := strconv.ParseInt(a.N, 10, 64); err == nil {
-			return int(n)
-		}
-		if n, err := strconv.ParseUint(a.N, 10, 64); err == nil {
-			return uint(n)
-		}
-		n, err := strconv.ParseFloat(a.N, 64)
-		if err != nil {
-			panic(err)
-		}
-		return n
+		return undynamizeNumber(a.N)
+	}
+
+	if a.B != nil {
+		return a.B
 	}
 
 	if a.BOOL != nil {
@@ -260,5 +251,45 @@ func undynamize(a *DynamoAttribute) interface{} {
 		return l
 	}
 
+	if a.SS != nil {
+		l := make([]interface{}, len(a.SS))
+		for index, s := range a.SS {
+			l[index] = s
+		}
+		return l
+	}
+
+	if a.NS != nil {
+		l := make([]interface{}, len(a.NS))
+		for index, n := range a.NS {
+			l[index] = undynamizeNumber(n)
+		}
+		return l
+	}
+
+	if a.BS != nil {
+		l := make([]interface{}, len(a.BS))
+		for index, b := range a.BS {
+			l[index] = b
+		}
+		return l
+	}
+
 	panic(fmt.Sprintf("unsupported dynamo attribute %#v", a))
 }
+
+func undynamizeNumber(s string) interface{} {
+	// Number is tricky b/c we don't know which numeric type to use. Here we
+	// simply try the different types from most to least restrictive.
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return int(n)
+	}
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return uint(n)
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
@@ -120,6 +120,69 @@ func testFromDynamo(t *testing.T, inputString string, expected interface{}) {
 	compareObjects(t, expected, actual)
 }
 
+// TestFromDynamoSets tests that sets are decoded into lists.
+func TestFromDynamoSets(t *testing.T) {
+	testFromDynamo(t,
+		`{"ss":{"SS":["a","b"]},"ns":{"NS":["1","2.5"]},"b":{"B":"AQI="},"bs":{"BS":["AQ=="]}}`,
+		map[string]interface{}{
+			"ss": []interface{}{"a", "b"},
+			"ns": []interface{}{1, 2.5},
+			"b":  []byte{1, 2},
+			"bs": []interface{}{[]byte{1}},
+		})
+}
+
+// TestEmpty tests that empty lists, maps and byte slices are kept.
+func TestEmpty(t *testing.T) {
+	in := map[string]interface{}{"l": []int{}, "m": map[string]int{}, "b": []byte{}}
+	item, err := ToDynamo(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"b":{"S":""},"l":{"L":[]},"m":{"M":{}}}` {
+		t.Fatalf("Unexpected encoding %s", b)
+	}
+	var actual struct {
+		L []int
+		M map[string]int
+		B []byte
+	}
+	if err := json.Unmarshal(b, &item); err != nil {
+		t.Fatal(err)
+	}
+	if err := FromDynamo(item, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if actual.L == nil || actual.M == nil || actual.B == nil {
+		t.Fatalf("Expected empty values, got %#v", actual)
+	}
+}
+
+// TestBytes tests that []byte is a base64 string, as in the JSON the
+// Document APIs used to send, unless the binary option asks for B.
+func TestBytes(t *testing.T) {
+	in := struct {
+		Bytes  []byte
+		Binary []byte   `dynamodbav:",binary"`
+		List   [][]byte `dynamodbav:",binary"`
+	}{[]byte("bytes"), []byte("binary"), [][]byte{[]byte("list")}}
+	item, err := ToDynamo(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"Binary":{"B":"YmluYXJ5"},"Bytes":{"S":"Ynl0ZXM="},"List":{"L":[{"B":"bGlzdA=="}]}}` {
+		t.Fatalf("Unexpected encoding %s", b)
+	}
+}
+
 // TestStruct tests that we get a typed struct back
 func TestStruct(t *testing.T) {
 	expected := mySimpleStruct{String: "this is a string", Int: 1000000, Uint: 18446744073709551615, Float64: 3.14}
@@ -185,6 +248,39 @@ func TestBadInput(t *testing.T) {
 	}
 }
 
+// TestVersion tests that the version field is found and set.
+func TestVersion(t *testing.T) {
+	type Embedded struct {
+		Rev uint32 `dynamodbav:"rev,version"`
+	}
+	var v struct {
+		Name string
+		*Embedded
+	}
+	name, version, err := Version(v)
+	if err != nil || name != "rev" || version != 0 {
+		t.Fatalf("Unexpected version %q %d %v", name, version, err)
+	}
+	if err := SetVersion(&v, 3); err != nil {
+		t.Fatal(err)
+	}
+	if name, version, err = Version(&v); err != nil || name != "rev" || version != 3 {
+		t.Fatalf("Unexpected version %q %d %v", name, version, err)
+	}
+	if err := SetVersion(&v, -1); err == nil {
+		t.Fatal("Expected error")
+	}
+	if name, _, err = Version(mySimpleStruct{}); err != nil || name != "" {
+		t.Fatalf("Unexpected version %q %v", name, err)
+	}
+	var bad struct {
+		Version string `dynamodbav:",version"`
+	}
+	if _, _, err = Version(bad); err == nil || err.Error() != "the version field Version must be an integer" {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
+
 // What we're trying to do here is compare the JSON encoded values, but we can't
 // to a simple encode + string compare since JSON encoding is not ordered. So
 // what we do is JSON encode, then JSON decode into untyped maps, and then
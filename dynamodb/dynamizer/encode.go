@@ -0,0 +1,234 @@
+package dynamizer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Marshaler is implemented by the types that encode themselves as a
+// DynamoDB attribute.
+type Marshaler interface {
+	MarshalDynamo() (*DynamoAttribute, error)
+}
+
+// Unmarshaler is implemented by the types that decode themselves from a
+// DynamoDB attribute. NULL attributes set them to their zero value
+// instead.
+type Unmarshaler interface {
+	UnmarshalDynamo(*DynamoAttribute) error
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	numberType          = reflect.TypeOf(json.Number(""))
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// MarshalAttribute encodes v as a DynamoDB attribute, the way ToDynamo
+// encodes the values of a map or struct.
+func MarshalAttribute(v interface{}) (a *DynamoAttribute, err error) {
+	defer catch(&err)
+	return encode(reflect.ValueOf(v), options{})
+}
+
+// encode returns the attribute of v, or nil if v is an empty set, which
+// DynamoDB does not store, or is left out by the legacy option.
+func encode(v reflect.Value, o options) (*DynamoAttribute, error) {
+	if o.legacy {
+		return encodeLegacy(v)
+	}
+	if !v.IsValid() || (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return &DynamoAttribute{NULL: true}, nil
+	}
+
+	t := v.Type()
+	if m, ok := implements(v, marshalerType); ok {
+		return m.Interface().(Marshaler).MarshalDynamo()
+	}
+	if t == timeType {
+		return encodeTime(v.Interface().(time.Time), o), nil
+	}
+	if m, ok := implements(v, jsonMarshalerType); ok && t != reflect.PtrTo(timeType) {
+		return encodeJSON(m.Interface().(json.Marshaler))
+	}
+
+	a := &DynamoAttribute{}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		return encode(v.Elem(), o)
+
+	case reflect.Bool:
+		a.BOOL = new(bool)
+		*a.BOOL = v.Bool()
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		a.N = strconv.FormatInt(v.Int(), 10)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		a.N = strconv.FormatUint(v.Uint(), 10)
+
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, fmt.Errorf("the value %v is not supported", f)
+		}
+		a.N = strconv.FormatFloat(f, 'f', -1, t.Bits())
+
+	case reflect.String:
+		if t == numberType {
+			a.N = v.String()
+		} else {
+			a.S = new(string)
+			*a.S = v.String()
+		}
+
+	case reflect.Slice:
+		if v.IsNil() {
+			a.NULL = true
+			break
+		}
+		fallthrough
+	case reflect.Array:
+		// []byte is a base64 string, as in JSON, unless the binary option
+		// asks for a B attribute.
+		if t.Elem().Kind() == reflect.Uint8 && o.set == "" && (o.binary || v.Kind() == reflect.Slice) {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			if o.binary {
+				a.B = b
+			} else {
+				a.S = new(string)
+				*a.S = base64.StdEncoding.EncodeToString(b)
+			}
+			break
+		}
+		if o.set != "" {
+			return encodeSet(v, o.set)
+		}
+		a.L = make([]*DynamoAttribute, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e, err := encode(v.Index(i), options{unixTime: o.unixTime, binary: o.binary})
+			if err != nil {
+				return nil, err
+			}
+			if e != nil {
+				a.L = append(a.L, e)
+			}
+		}
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("the type %s is not supported", t.String())
+		}
+		if v.IsNil() {
+			a.NULL = true
+			break
+		}
+		a.M = make(map[string]*DynamoAttribute)
+		for _, k := range v.MapKeys() {
+			e, err := encode(v.MapIndex(k), options{unixTime: o.unixTime, binary: o.binary})
+			if err != nil {
+				return nil, err
+			}
+			if e != nil {
+				a.M[k.String()] = e
+			}
+		}
+
+	case reflect.Struct:
+		a.M = make(map[string]*DynamoAttribute)
+		for _, f := range cachedTypeFields(t) {
+			fv := fieldByIndex(v, f.index)
+			if !fv.IsValid() || f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			e, err := encode(fv, f.options)
+			if err != nil {
+				return nil, err
+			}
+			if e != nil {
+				a.M[f.name] = e
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("the type %s is not supported", t.String())
+	}
+
+	return a, nil
+}
+
+// implements returns v, or its address, if it implements the interface
+// it. The methods of the values that are not addressable, such as map
+// values, with pointer receivers are not used, as by encoding/json.
+func implements(v reflect.Value, it reflect.Type) (reflect.Value, bool) {
+	if v.Type().Implements(it) {
+		return v, true
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(v.Type()).Implements(it) {
+		return v.Addr(), true
+	}
+	return v, false
+}
+
+// encodeTime encodes t as a RFC 3339 string, or with the unixtime option
+// as the number of seconds since the Unix epoch, which the time to live of
+// tables expects.
+func encodeTime(t time.Time, o options) *DynamoAttribute {
+	if o.unixTime {
+		return &DynamoAttribute{N: strconv.FormatInt(t.Unix(), 10)}
+	}
+	s := t.Format(time.RFC3339Nano)
+	return &DynamoAttribute{S: &s}
+}
+
+// encodeJSON encodes the types that implement json.Marshaler as their
+// JSON representation, as ToDynamo always did.
+func encodeJSON(m json.Marshaler) (*DynamoAttribute, error) {
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var x interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&x); err != nil {
+		return nil, err
+	}
+	return encode(reflect.ValueOf(x), options{})
+}
+
+// encodeSet encodes the slice or array v as a set of the given type, or
+// returns nil if it is empty.
+func encodeSet(v reflect.Value, set string) (*DynamoAttribute, error) {
+	if v.Len() == 0 {
+		return nil, nil
+	}
+	a := &DynamoAttribute{}
+	for i := 0; i < v.Len(); i++ {
+		e, err := encode(v.Index(i), options{binary: set == "BS"})
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case set == "SS" && e.S != nil:
+			a.SS = append(a.SS, *e.S)
+		case set == "NS" && e.N != "":
+			a.NS = append(a.NS, e.N)
+		case set == "BS" && e.B != nil:
+			a.BS = append(a.BS, e.B)
+		default:
+			return nil, fmt.Errorf("the type %s cannot be encoded as a %s", v.Type().Elem().String(), set)
+		}
+	}
+	return a, nil
+}
@@ -0,0 +1,99 @@
+package dynamizer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// encodeLegacy returns the attribute of v as dynamodb.MarshalAttributes
+// encoded it before it used encode, for the fields with the legacy option,
+// or nil if v is left out:
+//
+//   - booleans and numbers are N attributes, with 1 and 0 for booleans;
+//   - strings are S attributes;
+//   - []byte is a base64 S attribute;
+//   - the slices of strings are SS sets, and of booleans and numbers NS
+//     sets;
+//   - the other values, such as time.Time and structs, are their JSON
+//     representation in a S attribute;
+//   - the empty strings, slices and maps and the nil pointers are left
+//     out.
+func encodeLegacy(v reflect.Value) (*DynamoAttribute, error) {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String, reflect.Interface, reflect.Ptr:
+		if isEmptyValue(v) {
+			return nil, nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64:
+		n, err := legacyNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		return &DynamoAttribute{N: n}, nil
+
+	case reflect.String:
+		s := v.String()
+		return &DynamoAttribute{S: &s}, nil
+
+	case reflect.Slice:
+		switch v.Type().Elem().Kind() {
+		case reflect.Uint8:
+			s := base64.StdEncoding.EncodeToString(v.Bytes())
+			return &DynamoAttribute{S: &s}, nil
+
+		case reflect.String:
+			a := &DynamoAttribute{SS: make([]string, v.Len())}
+			for i := range a.SS {
+				a.SS[i] = v.Index(i).String()
+			}
+			return a, nil
+
+		case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64:
+			a := &DynamoAttribute{NS: make([]string, v.Len())}
+			for i := range a.NS {
+				n, err := legacyNumber(v.Index(i))
+				if err != nil {
+					return nil, err
+				}
+				a.NS[i] = n
+			}
+			return a, nil
+		}
+	}
+
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	s := string(b)
+	return &DynamoAttribute{S: &s}, nil
+}
+
+// legacyNumber formats the boolean or number v as encodeLegacy stores it.
+func legacyNumber(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return "1", nil
+		}
+		return "0", nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	}
+
+	f := v.Float()
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("the value %v is not supported", f)
+	}
+	return strconv.FormatFloat(f, 'g', -1, v.Type().Bits()), nil
+}
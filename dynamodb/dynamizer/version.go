@@ -0,0 +1,101 @@
+package dynamizer
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Version returns the name of the version attribute of the struct v is or
+// points to, and its version, or "" if it has none. The version attribute
+// is the one of the integer field tagged with the version option:
+//
+//	Version int64 `dynamodbav:"version,version"`
+//
+// The dynamodb package uses it for optimistic locking: the documents are
+// written only if the version of the stored item is the same, and their
+// version is then incremented. The version of new documents is 0.
+func Version(v interface{}) (name string, version int64, err error) {
+	fv, f, err := versionField(reflect.ValueOf(v), false)
+	switch {
+	case f == nil || err != nil:
+		return "", 0, err
+	case !fv.IsValid():
+		return f.name, 0, nil
+	case isInt(fv.Kind()):
+		return f.name, fv.Int(), nil
+	}
+	return f.name, int64(fv.Uint()), nil
+}
+
+// SetVersion sets the version of the struct v points to, if it has a
+// version attribute.
+func SetVersion(v interface{}, version int64) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("v must be a non-nil pointer, got %s", rv.Kind())
+	}
+	fv, f, err := versionField(rv, true)
+	switch {
+	case f == nil || err != nil:
+		return err
+	case !fv.IsValid():
+		return fmt.Errorf("the version field %s cannot be set", f.name)
+	case isInt(fv.Kind()):
+		if fv.OverflowInt(version) {
+			return fmt.Errorf("the version %d does not fit in %s", version, fv.Type().String())
+		}
+		fv.SetInt(version)
+	default:
+		if version < 0 || fv.OverflowUint(uint64(version)) {
+			return fmt.Errorf("the version %d does not fit in %s", version, fv.Type().String())
+		}
+		fv.SetUint(uint64(version))
+	}
+	return nil
+}
+
+// versionField returns the version field of the struct v is or points to,
+// or a nil field if it has none. The field Value is invalid if it is in a
+// nil embedded struct, unless alloc allocates it.
+func versionField(v reflect.Value, alloc bool) (reflect.Value, *field, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, nil, nil
+	}
+	fields := cachedTypeFields(v.Type())
+	for i := range fields {
+		f := &fields[i]
+		if !f.options.version {
+			continue
+		}
+		if k := f.typ.Kind(); !isInt(k) && !isUint(k) || f.typ != v.Type().FieldByIndex(f.index).Type {
+			return reflect.Value{}, nil, fmt.Errorf("the version field %s must be an integer", f.name)
+		}
+		if alloc {
+			return fieldByIndexAlloc(v, f.index), f, nil
+		}
+		return fieldByIndex(v, f.index), f, nil
+	}
+	return reflect.Value{}, nil, nil
+}
+
+func isInt(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
@@ -25,7 +25,12 @@ type DynamoQuery struct {
 	ConsistentRead bool                 `json:",omitempty"`
 	Item           dynamizer.DynamoItem `json:",omitempty"`
 	Key            dynamizer.DynamoItem `json:",omitempty"`
-	table          *Table
+
+	ConditionExpression       string            `json:",omitempty"`
+	ExpressionAttributeNames  map[string]string `json:",omitempty"`
+	ExpressionAttributeValues msi               `json:",omitempty"`
+
+	table *Table
 }
 
 type DynamoResponse struct {
@@ -149,6 +154,18 @@ func (q *DynamoQuery) AddItem(key *Key, item dynamizer.DynamoItem) error {
 	return nil
 }
 
+// AddConditionExpression adds a condition expression, as
+// UntypedQuery.AddConditionExpression does.
+func (q *DynamoQuery) AddConditionExpression(e *Expression) {
+	q.ConditionExpression = e.Text
+	if len(e.AttributeNames) > 0 {
+		q.ExpressionAttributeNames = e.AttributeNames
+	}
+	if len(e.AttributeValues) > 0 {
+		q.ExpressionAttributeValues = attributeList(e.AttributeValues)
+	}
+}
+
 func (q *DynamoQuery) SetConsistentRead(consistent bool) error {
 	q.ConsistentRead = consistent
 	return nil
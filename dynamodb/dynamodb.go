@@ -26,6 +26,14 @@ func New(auth aws.Auth, region aws.Region) *Server {
 var ErrNotFound = errors.New("Item not found")
 var ErrNotProcessed = errors.New("Key was not processed in the batch request, should retry")
 
+// ErrVersionConflict is returned by PutDocument, UpdateDocument and
+// DeleteVersionedDocument when the version of the stored item is not the
+// version of the document (see dynamizer.Version). These are the only
+// methods that check versions: DeleteDocument, UpdateAttributes and the
+// other methods writing attributes overwrite the items whatever their
+// version.
+var ErrVersionConflict = errors.New("Item version conflict, the item was modified concurrently")
+
 // Error represents an error in an operation with Dynamodb (following goamz/s3)
 type Error struct {
 	StatusCode int // HTTP status code (200, 403, ...)
@@ -71,6 +79,10 @@ func buildError(r *http.Response, jsonBody []byte) error {
 	}
 	ddbError.Code = codeStr
 
+	if codeStr == "TransactionCanceledException" {
+		return buildTransactionCanceledError(json, &ddbError)
+	}
+
 	return &ddbError
 }
 
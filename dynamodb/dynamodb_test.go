@@ -3,7 +3,9 @@ package dynamodb
 import (
 	"flag"
 	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/dynamodb/dynamodbtest"
 	"gopkg.in/check.v1"
+	"sync"
 	"testing"
 	"time"
 )
@@ -16,6 +18,14 @@ var local = flag.Bool("local", true, "Use DynamoDB local on 8080 instead of real
 var dynamodb_region aws.Region
 var dynamodb_auth aws.Auth
 
+// fakeServer is the fake DynamoDB the tests run against unless -amazon is
+// given. It is shared by all of the suites.
+var fakeServer struct {
+	once sync.Once
+	srv  *dynamodbtest.Server
+	err  error
+}
+
 type DynamoDBTest struct {
 	server            *Server
 	aws.Region        // Exports Region
@@ -130,9 +140,16 @@ func (s *DynamoDBTest) WaitUntilStatus(c *check.C, status string) {
 
 func setUpAuth(c *check.C) {
 	if !*amazon {
-		c.Skip("Test against amazon not enabled.")
-	}
-	if *local {
+		fakeServer.once.Do(func() {
+			fakeServer.srv, fakeServer.err = dynamodbtest.NewServer(nil)
+		})
+		if fakeServer.err != nil {
+			c.Fatal(fakeServer.err)
+		}
+		c.Log("Using fake server")
+		dynamodb_region = aws.Region{DynamoDBEndpoint: fakeServer.srv.URL()}
+		dynamodb_auth = aws.Auth{AccessKey: "DUMMY_KEY", SecretKey: "DUMMY_SECRET"}
+	} else if *local {
 		c.Log("Using local server")
 		dynamodb_region = aws.Region{DynamoDBEndpoint: "http://127.0.0.1:8000"}
 		dynamodb_auth = aws.Auth{AccessKey: "DUMMY_KEY", SecretKey: "DUMMY_SECRET"}
@@ -0,0 +1,299 @@
+// Package dynamodbstreams is a client of DynamoDB Streams, which records
+// the changes made to the items of DynamoDB tables.
+//
+// The stream of a table is enabled by the StreamSpecification of its
+// dynamodb.TableDescriptionT, which also holds the ARN of the stream.
+// The items of the records are dynamizer.DynamoItem values, which
+// dynamizer.FromDynamo converts to maps or structs. A Reader reads all
+// the records of a stream, shard after shard.
+//
+// See http://docs.aws.amazon.com/amazondynamodb/latest/developerguide/Streams.html
+package dynamodbstreams
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/dynamodb"
+	"github.com/AdRoll/goamz/dynamodb/dynamizer"
+)
+
+type Server struct {
+	Auth        aws.Auth
+	Region      aws.Region
+	RetryPolicy aws.RetryPolicy
+}
+
+func New(auth aws.Auth, region aws.Region) *Server {
+	return &Server{auth, region, aws.DynamoDBRetryPolicy{}}
+}
+
+type ShardIteratorType string
+
+const (
+	// Start reading exactly from the position denoted by a specific sequence number.
+	ShardIteratorAtSequenceNumber ShardIteratorType = "AT_SEQUENCE_NUMBER"
+
+	// Start reading right after the position denoted by a specific sequence number.
+	ShardIteratorAfterSequenceNumber ShardIteratorType = "AFTER_SEQUENCE_NUMBER"
+
+	// Start reading at the oldest record in the shard, records being kept
+	// for 24 hours.
+	ShardIteratorTrimHorizon ShardIteratorType = "TRIM_HORIZON"
+
+	// Start reading just after the most recent record in the shard.
+	ShardIteratorLatest ShardIteratorType = "LATEST"
+)
+
+// Error represents an error in an operation with DynamoDB Streams.
+type Error struct {
+	StatusCode int // HTTP status code (200, 403, ...)
+	Status     string
+	Code       string // Error code ("ExpiredIteratorException", ...)
+	Message    string // The human-oriented error message
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Code + ": " + e.Message
+	}
+	return e.Code
+}
+
+func (e *Error) ErrorCode() string {
+	return e.Code
+}
+
+func buildError(r *http.Response, jsonBody []byte) error {
+	var body struct {
+		Type         string `json:"__type"`
+		Message      string `json:"message"`
+		UpperMessage string `json:"Message"`
+	}
+	if err := json.Unmarshal(jsonBody, &body); err != nil {
+		return err
+	}
+
+	streamsError := &Error{
+		StatusCode: r.StatusCode,
+		Status:     r.Status,
+		Code:       body.Type,
+		Message:    body.Message,
+	}
+	if streamsError.Message == "" {
+		streamsError.Message = body.UpperMessage
+	}
+	// Of the form: com.amazonaws.dynamodb.v20120810#ExpiredIteratorException
+	if i := strings.Index(streamsError.Code, "#"); i >= 0 {
+		streamsError.Code = streamsError.Code[i+1:]
+	}
+	return streamsError
+}
+
+// A Stream identifies the stream of a table. Tables whose stream is
+// disabled and enabled again have several streams, with distinct labels.
+type Stream struct {
+	StreamArn   string
+	StreamLabel string
+	TableName   string
+}
+
+type ListStreamsResponse struct {
+	Streams                []Stream
+	LastEvaluatedStreamArn string
+}
+
+// The range of the sequence numbers of the records of a shard. There is no
+// EndingSequenceNumber until the shard is closed.
+type SequenceNumberRange struct {
+	StartingSequenceNumber string
+	EndingSequenceNumber   string
+}
+
+// A Shard holds some of the records of a stream. Once closed, the records
+// that follow are in its child shards, whose ParentShardId is its
+// ShardId.
+type Shard struct {
+	ParentShardId       string
+	SequenceNumberRange SequenceNumberRange
+	ShardId             string
+}
+
+// Closed reports whether no record is added to the shard anymore.
+func (s *Shard) Closed() bool {
+	return s.SequenceNumberRange.EndingSequenceNumber != ""
+}
+
+type StreamDescription struct {
+	CreationRequestDateTime float64
+	KeySchema               []dynamodb.KeySchemaT
+	LastEvaluatedShardId    string
+	Shards                  []Shard
+	StreamArn               string
+	StreamLabel             string
+	StreamStatus            string // "ENABLING", "ENABLED", "DISABLING" or "DISABLED".
+	StreamViewType          string
+	TableName               string
+}
+
+type describeStreamResponse struct {
+	StreamDescription StreamDescription
+}
+
+// A Record is a change of an item: its EventName is "INSERT", "MODIFY" or
+// "REMOVE".
+type Record struct {
+	AwsRegion    string       `json:"awsRegion"`
+	Dynamodb     StreamRecord `json:"dynamodb"`
+	EventID      string       `json:"eventID"`
+	EventName    string       `json:"eventName"`
+	EventSource  string       `json:"eventSource"`
+	EventVersion string       `json:"eventVersion"`
+}
+
+// A StreamRecord holds the key of the changed item, and its images before
+// and after the change, as the StreamViewType of the stream says.
+type StreamRecord struct {
+	ApproximateCreationDateTime float64
+	Keys                        dynamizer.DynamoItem
+	NewImage                    dynamizer.DynamoItem
+	OldImage                    dynamizer.DynamoItem
+	SequenceNumber              string
+	SizeBytes                   int64
+	StreamViewType              string
+}
+
+type GetRecordsResponse struct {
+	NextShardIterator string // "" once the end of a closed shard is reached.
+	Records           []Record
+}
+
+type getShardIteratorResponse struct {
+	ShardIterator string
+}
+
+type listStreamsQuery struct {
+	TableName               string `json:",omitempty"`
+	Limit                   int    `json:",omitempty"`
+	ExclusiveStartStreamArn string `json:",omitempty"`
+}
+
+type describeStreamQuery struct {
+	StreamArn             string
+	Limit                 int    `json:",omitempty"`
+	ExclusiveStartShardId string `json:",omitempty"`
+}
+
+type getShardIteratorQuery struct {
+	StreamArn         string
+	ShardId           string
+	ShardIteratorType ShardIteratorType
+	SequenceNumber    string `json:",omitempty"`
+}
+
+type getRecordsQuery struct {
+	ShardIterator string
+	Limit         int `json:",omitempty"`
+}
+
+// ListStreams lists the streams of the table tableName, or of all tables
+// if it is "". limit is 100 if it is 0. The listing continues after
+// exclusiveStartStreamArn unless it is "".
+func (s *Server) ListStreams(tableName string, limit int, exclusiveStartStreamArn string) (*ListStreamsResponse, error) {
+	resp := &ListStreamsResponse{}
+	err := s.query("ListStreams", &listStreamsQuery{tableName, limit, exclusiveStartStreamArn}, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// DescribeStream describes a stream, and up to limit of its shards, or
+// 100 if it is 0, continuing after exclusiveStartShardId unless it is "".
+func (s *Server) DescribeStream(streamArn string, limit int, exclusiveStartShardId string) (*StreamDescription, error) {
+	resp := &describeStreamResponse{}
+	err := s.query("DescribeStream", &describeStreamQuery{streamArn, limit, exclusiveStartShardId}, resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.StreamDescription, nil
+}
+
+// GetShardIterator returns an iterator of the records of a shard, from
+// the position iteratorType says. sequenceNumber is only used by
+// ShardIteratorAtSequenceNumber and ShardIteratorAfterSequenceNumber.
+// Iterators expire after 15 minutes.
+func (s *Server) GetShardIterator(streamArn, shardId string, iteratorType ShardIteratorType, sequenceNumber string) (string, error) {
+	resp := &getShardIteratorResponse{}
+	err := s.query("GetShardIterator", &getShardIteratorQuery{streamArn, shardId, iteratorType, sequenceNumber}, resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.ShardIterator, nil
+}
+
+// GetRecords returns up to limit records from the position of
+// shardIterator, or 1000 if it is 0, and the iterator of the records
+// that follow.
+func (s *Server) GetRecords(shardIterator string, limit int) (*GetRecordsResponse, error) {
+	resp := &GetRecordsResponse{}
+	err := s.query("GetRecords", &getRecordsQuery{shardIterator, limit}, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *Server) query(name string, query, resp interface{}) error {
+	target := "DynamoDBStreams_20120810." + name
+	qs, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+
+	numRetries := 0
+	for {
+		hreq, err := http.NewRequest("POST", s.Region.DynamoDBStreamsEndpoint+"/", bytes.NewReader(qs))
+		if err != nil {
+			return err
+		}
+
+		hreq.Header.Set("Content-Type", "application/x-amz-json-1.0")
+		hreq.Header.Set("X-Amz-Date", time.Now().UTC().Format(aws.ISO8601BasicFormat))
+		hreq.Header.Set("X-Amz-Target", target)
+
+		token := s.Auth.Token()
+		if token != "" {
+			hreq.Header.Set("X-Amz-Security-Token", token)
+		}
+
+		// DynamoDB Streams requests are signed for DynamoDB.
+		signer := aws.NewV4Signer(s.Auth, "dynamodb", s.Region)
+		signer.Sign(hreq)
+
+		hresp, err := http.DefaultClient.Do(hreq)
+		var body []byte
+		if err == nil {
+			body, err = ioutil.ReadAll(hresp.Body)
+			hresp.Body.Close()
+			if err == nil && hresp.StatusCode != 200 {
+				err = buildError(hresp, body)
+			}
+		}
+		if err != nil {
+			if s.RetryPolicy.ShouldRetry(target, hresp, err, numRetries) {
+				time.Sleep(s.RetryPolicy.Delay(target, hresp, err, numRetries))
+				numRetries++
+				continue
+			}
+			return err
+		}
+
+		return json.Unmarshal(body, resp)
+	}
+}
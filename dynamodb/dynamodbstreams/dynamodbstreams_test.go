@@ -0,0 +1,218 @@
+package dynamodbstreams_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/dynamodb"
+	"github.com/AdRoll/goamz/dynamodb/dynamizer"
+	"github.com/AdRoll/goamz/dynamodb/dynamodbstreams"
+	"github.com/AdRoll/goamz/dynamodb/dynamodbtest"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type S struct {
+	srv       *dynamodbtest.Server
+	table     *dynamodb.Table
+	streams   *dynamodbstreams.Server
+	streamArn string
+}
+
+var _ = check.Suite(&S{})
+
+var tableDescription = dynamodb.TableDescriptionT{
+	TableName:            "Users",
+	AttributeDefinitions: []dynamodb.AttributeDefinitionT{{Name: "Name", Type: "S"}},
+	KeySchema:            []dynamodb.KeySchemaT{{AttributeName: "Name", KeyType: "HASH"}},
+	ProvisionedThroughput: dynamodb.ProvisionedThroughputT{
+		ReadCapacityUnits:  1,
+		WriteCapacityUnits: 1,
+	},
+	StreamSpecification: &dynamodb.StreamSpecificationT{
+		StreamEnabled:  true,
+		StreamViewType: "NEW_AND_OLD_IMAGES",
+	},
+}
+
+// SetUpTest starts a server whose shards hold three records each.
+func (s *S) SetUpTest(c *check.C) {
+	srv, err := dynamodbtest.NewServer(&dynamodbtest.Config{StreamShardSize: 3})
+	c.Assert(err, check.IsNil)
+	s.srv = srv
+	region := aws.Region{DynamoDBEndpoint: srv.URL(), DynamoDBStreamsEndpoint: srv.URL()}
+	auth := aws.Auth{AccessKey: "key", SecretKey: "secret"}
+	server := dynamodb.New(auth, region)
+	_, err = server.CreateTable(tableDescription)
+	c.Assert(err, check.IsNil)
+	pk, err := tableDescription.BuildPrimaryKey()
+	c.Assert(err, check.IsNil)
+	s.table = server.NewTable(tableDescription.TableName, pk)
+	desc, err := s.table.DescribeTable()
+	c.Assert(err, check.IsNil)
+	c.Assert(desc.StreamSpecification, check.DeepEquals, tableDescription.StreamSpecification)
+	s.streamArn = desc.LatestStreamArn
+	s.streams = dynamodbstreams.New(auth, region)
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	s.srv.Quit()
+}
+
+func (s *S) put(c *check.C, name string, n int) {
+	ok, err := s.table.PutItem(name, "", []dynamodb.Attribute{*dynamodb.NewNumericAttribute("N", fmt.Sprint(n))})
+	c.Assert(ok, check.Equals, true)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestListAndDescribeStreams(c *check.C) {
+	resp, err := s.streams.ListStreams("Users", 0, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Streams, check.HasLen, 1)
+	c.Assert(resp.Streams[0].StreamArn, check.Equals, s.streamArn)
+	c.Assert(resp.LastEvaluatedStreamArn, check.Equals, "")
+
+	for i := 0; i < 4; i++ {
+		s.put(c, fmt.Sprint("user", i), i)
+	}
+	desc, err := s.streams.DescribeStream(s.streamArn, 0, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(desc.StreamStatus, check.Equals, "ENABLED")
+	c.Assert(desc.TableName, check.Equals, "Users")
+	c.Assert(desc.KeySchema, check.DeepEquals, tableDescription.KeySchema)
+	c.Assert(desc.Shards, check.HasLen, 2)
+	c.Assert(desc.Shards[0].Closed(), check.Equals, true)
+	c.Assert(desc.Shards[1].ParentShardId, check.Equals, desc.Shards[0].ShardId)
+	c.Assert(desc.Shards[1].Closed(), check.Equals, false)
+
+	_, err = s.streams.DescribeStream(s.streamArn+"x", 0, "")
+	c.Assert(err, check.ErrorMatches, "ResourceNotFoundException: .*")
+	c.Assert(err.(*dynamodbstreams.Error).StatusCode, check.Equals, 400)
+}
+
+func (s *S) TestGetRecords(c *check.C) {
+	s.put(c, "alice", 1)
+	s.put(c, "alice", 1) // Changes nothing.
+	s.put(c, "alice", 2)
+	_, err := s.table.DeleteItem(&dynamodb.Key{HashKey: "alice"})
+	c.Assert(err, check.IsNil)
+
+	desc, err := s.streams.DescribeStream(s.streamArn, 0, "")
+	c.Assert(err, check.IsNil)
+	iterator, err := s.streams.GetShardIterator(s.streamArn, desc.Shards[0].ShardId, dynamodbstreams.ShardIteratorTrimHorizon, "")
+	c.Assert(err, check.IsNil)
+	resp, err := s.streams.GetRecords(iterator, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Records, check.HasLen, 3)
+	c.Assert(resp.NextShardIterator, check.Equals, "") // The shard is full.
+
+	var events []string
+	for _, r := range resp.Records {
+		events = append(events, r.EventName)
+	}
+	c.Assert(events, check.DeepEquals, []string{"INSERT", "MODIFY", "REMOVE"})
+	modify := resp.Records[1].Dynamodb
+	c.Assert(*modify.Keys["Name"].S, check.Equals, "alice")
+	var old, new map[string]interface{}
+	c.Assert(dynamizer.FromDynamo(modify.OldImage, &old), check.IsNil)
+	c.Assert(dynamizer.FromDynamo(modify.NewImage, &new), check.IsNil)
+	c.Assert(old, check.DeepEquals, map[string]interface{}{"Name": "alice", "N": 1})
+	c.Assert(new, check.DeepEquals, map[string]interface{}{"Name": "alice", "N": 2})
+	c.Assert(resp.Records[2].Dynamodb.NewImage, check.IsNil)
+
+	iterator, err = s.streams.GetShardIterator(s.streamArn, desc.Shards[0].ShardId, dynamodbstreams.ShardIteratorAfterSequenceNumber, modify.SequenceNumber)
+	c.Assert(err, check.IsNil)
+	resp, err = s.streams.GetRecords(iterator, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Records, check.HasLen, 1)
+	c.Assert(resp.Records[0].EventName, check.Equals, "REMOVE")
+}
+
+// collect returns a handler that appends the N attribute of the records it
+// gets to ns.
+func collect(ns *[]string) func(shardId string, records []dynamodbstreams.Record) error {
+	return func(shardId string, records []dynamodbstreams.Record) error {
+		for _, r := range records {
+			*ns = append(*ns, r.Dynamodb.NewImage["N"].N)
+		}
+		return nil
+	}
+}
+
+func (s *S) TestReader(c *check.C) {
+	for i := 0; i < 7; i++ {
+		s.put(c, fmt.Sprint("user", i), i)
+	}
+	store := dynamodbstreams.NewMemoryCheckpointStore()
+	r := dynamodbstreams.NewReader(s.streams, s.streamArn, store)
+	var ns []string
+	c.Assert(r.Read(collect(&ns)), check.IsNil)
+	c.Assert(ns, check.DeepEquals, []string{"0", "1", "2", "3", "4", "5", "6"})
+
+	// The open shard is read from where the last Read stopped, and the
+	// shard that follows it once it is closed.
+	ns = nil
+	c.Assert(r.Read(collect(&ns)), check.IsNil)
+	c.Assert(ns, check.HasLen, 0)
+	for i := 7; i < 10; i++ {
+		s.put(c, fmt.Sprint("user", i), i)
+	}
+	c.Assert(r.Read(collect(&ns)), check.IsNil)
+	c.Assert(ns, check.DeepEquals, []string{"7", "8", "9"})
+
+	// Another reader carries on from the checkpoints.
+	s.put(c, "user10", 10)
+	ns = nil
+	r = dynamodbstreams.NewReader(s.streams, s.streamArn, store)
+	c.Assert(r.Read(collect(&ns)), check.IsNil)
+	c.Assert(ns, check.DeepEquals, []string{"10"})
+
+	desc, err := s.streams.DescribeStream(s.streamArn, 0, "")
+	c.Assert(err, check.IsNil)
+	checkpoint, err := store.Checkpoint(s.streamArn, desc.Shards[0].ShardId)
+	c.Assert(err, check.IsNil)
+	c.Assert(checkpoint, check.Equals, dynamodbstreams.ShardEnd)
+}
+
+func (s *S) TestReaderFailure(c *check.C) {
+	for i := 0; i < 5; i++ {
+		s.put(c, fmt.Sprint("user", i), i)
+	}
+	store := dynamodbstreams.NewMemoryCheckpointStore()
+	r := dynamodbstreams.NewReader(s.streams, s.streamArn, store)
+	r.Limit = 2
+	var ns []string
+	err := r.Read(func(shardId string, records []dynamodbstreams.Record) error {
+		if len(ns) == 2 {
+			return fmt.Errorf("failed")
+		}
+		return collect(&ns)(shardId, records)
+	})
+	c.Assert(err, check.ErrorMatches, "failed")
+	c.Assert(ns, check.DeepEquals, []string{"0", "1"})
+
+	// The failed records are read again.
+	c.Assert(r.Read(collect(&ns)), check.IsNil)
+	c.Assert(ns, check.DeepEquals, []string{"0", "1", "2", "3", "4"})
+}
+
+func (s *S) TestReaderLatest(c *check.C) {
+	for i := 0; i < 4; i++ {
+		s.put(c, fmt.Sprint("user", i), i)
+	}
+	r := dynamodbstreams.NewReader(s.streams, s.streamArn, dynamodbstreams.NewMemoryCheckpointStore())
+	r.StartAt = dynamodbstreams.ShardIteratorLatest
+	var ns []string
+	c.Assert(r.Read(collect(&ns)), check.IsNil)
+	c.Assert(ns, check.HasLen, 0)
+
+	for i := 4; i < 7; i++ {
+		s.put(c, fmt.Sprint("user", i), i)
+	}
+	c.Assert(r.Read(collect(&ns)), check.IsNil)
+	c.Assert(ns, check.DeepEquals, []string{"4", "5", "6"})
+}
@@ -0,0 +1,195 @@
+package dynamodbstreams
+
+import (
+	"sync"
+)
+
+// ShardEnd is the checkpoint of the shards whose records were all
+// processed.
+const ShardEnd = "SHARD_END"
+
+// A CheckpointStore stores how far a Reader got in the shards of a
+// stream, so that another Reader, after a restart, carries on from there.
+type CheckpointStore interface {
+	// Checkpoint returns the sequence number of the last record
+	// processed in a shard, ShardEnd if they all were, or "" if none
+	// was.
+	Checkpoint(streamArn, shardId string) (string, error)
+
+	// SetCheckpoint stores the checkpoint of a shard.
+	SetCheckpoint(streamArn, shardId, checkpoint string) error
+}
+
+// MemoryCheckpointStore is a CheckpointStore that keeps checkpoints in
+// memory, for Readers that start over on restart.
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]string
+}
+
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: make(map[string]string)}
+}
+
+func (m *MemoryCheckpointStore) Checkpoint(streamArn, shardId string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.checkpoints[streamArn+" "+shardId], nil
+}
+
+func (m *MemoryCheckpointStore) SetCheckpoint(streamArn, shardId, checkpoint string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checkpoints[streamArn+" "+shardId] = checkpoint
+	return nil
+}
+
+// A Reader reads the records of a stream, following the lineage of its
+// shards: the records of a shard are read only once the ones of its
+// parent all were, so that the changes of an item are read in order.
+//
+// The records are passed to a handler, shard after shard, and the
+// sequence number of the last one is checkpointed once the handler
+// returns. Records may be handled twice after a failure, but are never
+// skipped.
+//
+// A Reader must not be used concurrently.
+type Reader struct {
+	Server    *Server
+	StreamArn string
+	Store     CheckpointStore
+
+	// StartAt is where the shards without a checkpoint are read from:
+	// ShardIteratorTrimHorizon, the default, or ShardIteratorLatest. With
+	// ShardIteratorLatest, closed shards are skipped, and only the
+	// records added to open shards from then on are read. The child
+	// shards of the shards that were read are always read from their
+	// first record.
+	StartAt ShardIteratorType
+
+	// Limit is the number of records read at once, up to 1000, the
+	// default.
+	Limit int
+
+	iterators map[string]string // of open shards, by shard ID.
+	skipped   map[string]bool   // closed shards skipped by StartAt.
+}
+
+func NewReader(server *Server, streamArn string, store CheckpointStore) *Reader {
+	return &Reader{
+		Server:    server,
+		StreamArn: streamArn,
+		Store:     store,
+		StartAt:   ShardIteratorTrimHorizon,
+		iterators: make(map[string]string),
+		skipped:   make(map[string]bool),
+	}
+}
+
+// Read reads the records of the stream that are available, calling
+// handle with them, in batches of records of a shard. It returns once
+// closed shards are read to their end, and open shards to their latest
+// record, or if handle fails. Read is called again to read the records
+// added since.
+func (r *Reader) Read(handle func(shardId string, records []Record) error) error {
+	shards, err := r.shards()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+	for _, shard := range shards {
+		known[shard.ShardId] = true
+	}
+	visited := make(map[string]bool)
+	ended := make(map[string]bool)
+	for progress := true; progress; {
+		progress = false
+		for i := range shards {
+			shard := &shards[i]
+			parent := shard.ParentShardId
+			if visited[shard.ShardId] || known[parent] && !ended[parent] {
+				continue
+			}
+			visited[shard.ShardId] = true
+			progress = true
+			ended[shard.ShardId], err = r.readShard(shard, known[parent], handle)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// shards returns all the shards of the stream, parents first.
+func (r *Reader) shards() ([]Shard, error) {
+	var shards []Shard
+	var start string
+	for {
+		desc, err := r.Server.DescribeStream(r.StreamArn, 0, start)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, desc.Shards...)
+		if desc.LastEvaluatedShardId == "" {
+			return shards, nil
+		}
+		start = desc.LastEvaluatedShardId
+	}
+}
+
+// readShard reads the records of a shard, and reports whether its end was
+// reached. hasParent is whether its parent is still in the stream.
+func (r *Reader) readShard(shard *Shard, hasParent bool, handle func(shardId string, records []Record) error) (bool, error) {
+	id := shard.ShardId
+	checkpoint, err := r.Store.Checkpoint(r.StreamArn, id)
+	if err != nil || checkpoint == ShardEnd {
+		return checkpoint == ShardEnd, err
+	}
+
+	iterator := r.iterators[id]
+	delete(r.iterators, id)
+	if iterator == "" {
+		switch {
+		case checkpoint != "":
+			iterator, err = r.Server.GetShardIterator(r.StreamArn, id, ShardIteratorAfterSequenceNumber, checkpoint)
+		case hasParent && !r.skipped[shard.ParentShardId] || r.StartAt != ShardIteratorLatest:
+			iterator, err = r.Server.GetShardIterator(r.StreamArn, id, ShardIteratorTrimHorizon, "")
+		case shard.Closed():
+			r.skipped[id] = true
+			return true, r.Store.SetCheckpoint(r.StreamArn, id, ShardEnd)
+		default:
+			iterator, err = r.Server.GetShardIterator(r.StreamArn, id, ShardIteratorLatest, "")
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	for {
+		resp, err := r.Server.GetRecords(iterator, r.Limit)
+		if err != nil {
+			return false, err
+		}
+		if n := len(resp.Records); n > 0 {
+			if err := handle(id, resp.Records); err != nil {
+				return false, err
+			}
+			err = r.Store.SetCheckpoint(r.StreamArn, id, resp.Records[n-1].Dynamodb.SequenceNumber)
+			if err != nil {
+				return false, err
+			}
+		}
+		if resp.NextShardIterator == "" {
+			return true, r.Store.SetCheckpoint(r.StreamArn, id, ShardEnd)
+		}
+		iterator = resp.NextShardIterator
+		if len(resp.Records) == 0 && !shard.Closed() {
+			// Caught up with an open shard, whose new records are read
+			// from there by the next Read.
+			r.iterators[id] = iterator
+			return false, nil
+		}
+	}
+}
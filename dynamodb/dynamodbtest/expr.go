@@ -0,0 +1,808 @@
+package dynamodbtest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// This file implements the expressions of DynamoDB: condition, filter, key
+// condition, projection and update expressions.
+// See http://docs.aws.amazon.com/amazondynamodb/latest/developerguide/Expressions.html
+
+// pathElem is an element of a document path: an attribute name, or an
+// index in a list.
+type pathElem struct {
+	name  string
+	index int // used if name is "".
+}
+
+type path []pathElem
+
+func (p path) String() string {
+	var b strings.Builder
+	for i, e := range p {
+		switch {
+		case e.name == "":
+			fmt.Fprintf(&b, "[%d]", e.index)
+		case i > 0:
+			b.WriteString("." + e.name)
+		default:
+			b.WriteString(e.name)
+		}
+	}
+	return b.String()
+}
+
+// get returns the value at p in it, or nil.
+func (p path) get(it item) *value {
+	v := it[p[0].name]
+	for _, e := range p[1:] {
+		switch {
+		case v == nil:
+			return nil
+		case e.name != "" && v.typ == "M":
+			v = v.m[e.name]
+		case e.name == "" && v.typ == "L" && e.index < len(v.l):
+			v = v.l[e.index]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// parent returns the value holding the last element of p, or nil if it
+// does not exist or cannot hold it.
+func (p path) parent(it item) *value {
+	if len(p) == 1 {
+		return nil
+	}
+	parent := p[:len(p)-1].get(it)
+	last := p[len(p)-1]
+	if parent == nil || last.name != "" && parent.typ != "M" || last.name == "" && parent.typ != "L" {
+		return nil
+	}
+	return parent
+}
+
+// set sets the value at p in it. Setting an index past the end of a list
+// appends to it.
+func (p path) set(it item, v *value) error {
+	if len(p) == 1 {
+		it[p[0].name] = v
+		return nil
+	}
+	parent := p.parent(it)
+	if parent == nil {
+		return fmt.Errorf("The document path provided in the update expression is invalid for update")
+	}
+	last := p[len(p)-1]
+	switch {
+	case last.name != "":
+		parent.m[last.name] = v
+	case last.index < len(parent.l):
+		parent.l[last.index] = v
+	default:
+		parent.l = append(parent.l, v)
+	}
+	return nil
+}
+
+// remove removes the value at p from it, if there is one.
+func (p path) remove(it item) {
+	if len(p) == 1 {
+		delete(it, p[0].name)
+		return
+	}
+	parent := p.parent(it)
+	if parent == nil {
+		return
+	}
+	last := p[len(p)-1]
+	switch {
+	case last.name != "":
+		delete(parent.m, last.name)
+	case last.index < len(parent.l):
+		parent.l = append(parent.l[:last.index], parent.l[last.index+1:]...)
+	}
+}
+
+// operand is a value in an expression.
+type operand interface {
+	eval(it item) (*value, error)
+}
+
+type pathOperand struct{ p path }
+
+func (o pathOperand) eval(it item) (*value, error) { return o.p.get(it), nil }
+
+type valueOperand struct{ v *value }
+
+func (o valueOperand) eval(it item) (*value, error) { return o.v, nil }
+
+type sizeOperand struct{ p path }
+
+func (o sizeOperand) eval(it item) (*value, error) {
+	v := o.p.get(it)
+	if v == nil {
+		return nil, nil
+	}
+	n, ok := size(v)
+	if !ok {
+		return nil, nil
+	}
+	return &value{typ: "N", s: strconv.Itoa(n)}, nil
+}
+
+type ifNotExistsOperand struct {
+	p path
+	v operand
+}
+
+func (o ifNotExistsOperand) eval(it item) (*value, error) {
+	if v := o.p.get(it); v != nil {
+		return v, nil
+	}
+	return o.v.eval(it)
+}
+
+type listAppendOperand struct{ a, b operand }
+
+func (o listAppendOperand) eval(it item) (*value, error) {
+	a, err := o.a.eval(it)
+	if err != nil {
+		return nil, err
+	}
+	b, err := o.b.eval(it)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil || b == nil || a.typ != "L" || b.typ != "L" {
+		return nil, fmt.Errorf("An operand in the update expression has an incorrect data type")
+	}
+	l := &value{typ: "L", l: append(append([]*value(nil), a.l...), b.l...)}
+	return copyValue(l), nil
+}
+
+type arithOperand struct {
+	op   string // "+" or "-"
+	a, b operand
+}
+
+func (o arithOperand) eval(it item) (*value, error) {
+	a, err := o.a.eval(it)
+	if err != nil {
+		return nil, err
+	}
+	b, err := o.b.eval(it)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil || b == nil {
+		return nil, fmt.Errorf("The provided expression refers to an attribute that does not exist in the item")
+	}
+	if o.op == "-" {
+		return subtract(a, b)
+	}
+	if a.typ != "N" || b.typ != "N" {
+		return nil, fmt.Errorf("An operand in the update expression has an incorrect data type")
+	}
+	return add(a, b)
+}
+
+// condition is a condition, filter or key condition expression.
+type condition interface {
+	eval(it item) bool
+}
+
+type andCondition struct{ a, b condition }
+
+func (c andCondition) eval(it item) bool { return c.a.eval(it) && c.b.eval(it) }
+
+type orCondition struct{ a, b condition }
+
+func (c orCondition) eval(it item) bool { return c.a.eval(it) || c.b.eval(it) }
+
+type notCondition struct{ c condition }
+
+func (c notCondition) eval(it item) bool { return !c.c.eval(it) }
+
+type comparison struct {
+	op   string // "=", "<>", "<", "<=", ">", ">=", "BETWEEN" or "IN".
+	a    operand
+	args []operand
+}
+
+func (c comparison) eval(it item) bool {
+	a, _ := c.a.eval(it)
+	args := make([]*value, len(c.args))
+	for i, o := range c.args {
+		args[i], _ = o.eval(it)
+	}
+	return compareValues(c.op, a, args)
+}
+
+// compareValues applies the comparison operator op to a and args. It
+// implements both comparison expressions and legacy comparison operators.
+func compareValues(op string, a *value, args []*value) bool {
+	switch op {
+	case "=", "EQ":
+		return equal(a, args[0])
+	case "<>", "NE":
+		return !equal(a, args[0])
+	case "IN":
+		for _, b := range args {
+			if equal(a, b) {
+				return true
+			}
+		}
+		return false
+	case "BETWEEN":
+		lo, ok1 := compare(a, args[0])
+		hi, ok2 := compare(a, args[1])
+		return ok1 && ok2 && lo >= 0 && hi <= 0
+	case "BEGINS_WITH":
+		return beginsWith(a, args[0])
+	case "CONTAINS":
+		return contains(a, args[0])
+	case "NOT_CONTAINS":
+		return a != nil && !contains(a, args[0])
+	case "NULL":
+		return a == nil
+	case "NOT_NULL":
+		return a != nil
+	}
+	cmp, ok := compare(a, args[0])
+	if !ok {
+		return false
+	}
+	switch op {
+	case "<", "LT":
+		return cmp < 0
+	case "<=", "LE":
+		return cmp <= 0
+	case ">", "GT":
+		return cmp > 0
+	case ">=", "GE":
+		return cmp >= 0
+	}
+	return false
+}
+
+type functionCondition struct {
+	name string
+	p    path
+	arg  operand
+}
+
+func (c functionCondition) eval(it item) bool {
+	v := c.p.get(it)
+	var arg *value
+	if c.arg != nil {
+		arg, _ = c.arg.eval(it)
+	}
+	switch c.name {
+	case "attribute_exists":
+		return v != nil
+	case "attribute_not_exists":
+		return v == nil
+	case "attribute_type":
+		return v != nil && arg != nil && arg.typ == "S" && v.typ == arg.s
+	case "begins_with":
+		return beginsWith(v, arg)
+	case "contains":
+		return contains(v, arg)
+	}
+	return false
+}
+
+// updateAction is an action of an update expression.
+type updateAction struct {
+	kind string // "SET", "REMOVE", "ADD" or "DELETE".
+	p    path
+	v    operand
+}
+
+type token struct {
+	kind byte // 'i'dentifier, '#'name, ':'value, '0' number, 'p'unctuation or 0 at the end.
+	s    string
+}
+
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' || c == ':' || c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			kind := byte(c)
+			if c != '#' && c != ':' {
+				kind = 'i'
+				if unicode.IsDigit(c) {
+					kind = '0'
+				}
+			}
+			if j == i+1 && (c == '#' || c == ':') {
+				return nil, fmt.Errorf("Syntax error; token: %q", string(c))
+			}
+			toks = append(toks, token{kind, s[i:j]})
+			i = j
+		case strings.HasPrefix(s[i:], "<>"), strings.HasPrefix(s[i:], "<="), strings.HasPrefix(s[i:], ">="):
+			toks = append(toks, token{'p', s[i : i+2]})
+			i += 2
+		case strings.ContainsRune("=<>(),.[]+-", c):
+			toks = append(toks, token{'p', s[i : i+1]})
+			i++
+		default:
+			return nil, fmt.Errorf("Invalid character encountered; character: %q", string(c))
+		}
+	}
+	return append(toks, token{}), nil
+}
+
+// exprContext holds the expression attribute names and values of a
+// request, and tracks which ones its expressions use.
+type exprContext struct {
+	names      map[string]string
+	values     map[string]*value
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+func newExprContext(names map[string]string, values map[string]*value) *exprContext {
+	return &exprContext{names, values, make(map[string]bool), make(map[string]bool)}
+}
+
+// checkUnused returns an error if some names or values were not used by
+// the expressions of the request.
+func (ctx *exprContext) checkUnused() error {
+	var unused []string
+	for k := range ctx.names {
+		if !ctx.usedNames[k] {
+			unused = append(unused, k)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return fmt.Errorf("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", strings.Join(unused, ", "))
+	}
+	for k := range ctx.values {
+		if !ctx.usedValues[k] {
+			unused = append(unused, k)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return fmt.Errorf("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", strings.Join(unused, ", "))
+	}
+	return nil
+}
+
+// exprError is the error of an invalid expression, used to unwind the
+// parser.
+type exprError struct{ err error }
+
+type parser struct {
+	ctx  *exprContext
+	kind string // "ConditionExpression", "UpdateExpression"...
+	toks []token
+	pos  int
+}
+
+func (ctx *exprContext) parse(kind, s string, parse func(p *parser) interface{}) (result interface{}, err error) {
+	toks, err := tokenize(s)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s: %v", kind, err)
+	}
+	if len(toks) == 1 {
+		return nil, fmt.Errorf("Invalid %s: The expression can not be empty;", kind)
+	}
+	p := &parser{ctx: ctx, kind: kind, toks: toks}
+	defer func() {
+		switch e := recover().(type) {
+		case exprError:
+			result, err = nil, e.err
+		case nil:
+		default:
+			panic(e)
+		}
+	}()
+	result = parse(p)
+	if p.peek().kind != 0 {
+		p.fail()
+	}
+	return result, nil
+}
+
+func (ctx *exprContext) parseCondition(kind, s string) (condition, error) {
+	c, err := ctx.parse(kind, s, func(p *parser) interface{} { return p.condition() })
+	if err != nil {
+		return nil, err
+	}
+	return c.(condition), nil
+}
+
+func (ctx *exprContext) parseUpdate(s string) ([]updateAction, error) {
+	u, err := ctx.parse("UpdateExpression", s, func(p *parser) interface{} { return p.update() })
+	if err != nil {
+		return nil, err
+	}
+	return u.([]updateAction), nil
+}
+
+func (ctx *exprContext) parseProjection(s string) ([]path, error) {
+	u, err := ctx.parse("ProjectionExpression", s, func(p *parser) interface{} {
+		paths := []path{p.path()}
+		for p.accept(",") {
+			paths = append(paths, p.path())
+		}
+		return paths
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u.([]path), nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != 0 {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(format string, args ...interface{}) {
+	panic(exprError{fmt.Errorf("Invalid %s: "+format, append([]interface{}{p.kind}, args...)...)})
+}
+
+func (p *parser) fail() {
+	t := p.peek()
+	if t.kind == 0 {
+		p.errorf("Syntax error; token: <EOF>")
+	}
+	p.errorf("Syntax error; token: %q", t.s)
+}
+
+// accept consumes the next token if it is the punctuation or keyword s.
+func (p *parser) accept(s string) bool {
+	t := p.peek()
+	if (t.kind == 'p' || t.kind == 'i') && strings.EqualFold(t.s, s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) {
+	if !p.accept(s) {
+		p.fail()
+	}
+}
+
+func (p *parser) isFunction() bool {
+	return p.peek().kind == 'i' && p.toks[p.pos+1].s == "("
+}
+
+func (p *parser) name() string {
+	t := p.next()
+	switch t.kind {
+	case 'i':
+		return t.s
+	case '#':
+		name, ok := p.ctx.names[t.s]
+		if !ok {
+			p.errorf("An expression attribute name used in the document path is not defined; attribute name: %s", t.s)
+		}
+		p.ctx.usedNames[t.s] = true
+		return name
+	}
+	p.pos--
+	p.fail()
+	return ""
+}
+
+func (p *parser) path() path {
+	pa := path{{name: p.name()}}
+	for {
+		switch {
+		case p.accept("."):
+			pa = append(pa, pathElem{name: p.name()})
+		case p.accept("["):
+			t := p.next()
+			n, err := strconv.Atoi(t.s)
+			if t.kind != '0' || err != nil {
+				p.pos--
+				p.fail()
+			}
+			p.expect("]")
+			pa = append(pa, pathElem{index: n})
+		default:
+			return pa
+		}
+	}
+}
+
+func (p *parser) value() *value {
+	t := p.next()
+	v, ok := p.ctx.values[t.s]
+	if !ok {
+		p.errorf("An expression attribute value used in expression is not defined; attribute value: %s", t.s)
+	}
+	p.ctx.usedValues[t.s] = true
+	return v
+}
+
+// operand parses a path, a value or a size function.
+func (p *parser) operand() operand {
+	switch t := p.peek(); {
+	case t.kind == ':':
+		return valueOperand{p.value()}
+	case t.kind == 'i' && p.isFunction():
+		if t.s != "size" {
+			p.errorf("The function is not allowed to be used this way in an expression; function: %s", t.s)
+		}
+		p.pos += 2
+		pa := p.path()
+		p.expect(")")
+		return sizeOperand{pa}
+	}
+	return pathOperand{p.path()}
+}
+
+func (p *parser) condition() condition {
+	c := p.andCondition()
+	for p.accept("OR") {
+		c = orCondition{c, p.andCondition()}
+	}
+	return c
+}
+
+func (p *parser) andCondition() condition {
+	c := p.notCondition()
+	for p.accept("AND") {
+		c = andCondition{c, p.notCondition()}
+	}
+	return c
+}
+
+func (p *parser) notCondition() condition {
+	if p.accept("NOT") {
+		return notCondition{p.notCondition()}
+	}
+	return p.primaryCondition()
+}
+
+func (p *parser) primaryCondition() condition {
+	if p.accept("(") {
+		c := p.condition()
+		p.expect(")")
+		return c
+	}
+	if t := p.peek(); p.isFunction() && t.s != "size" {
+		p.pos += 2
+		c := functionCondition{name: t.s, p: p.path()}
+		switch t.s {
+		case "attribute_exists", "attribute_not_exists":
+		case "attribute_type", "begins_with", "contains":
+			p.expect(",")
+			c.arg = p.operand()
+		default:
+			p.errorf("Invalid function name; function: %s", t.s)
+		}
+		p.expect(")")
+		return c
+	}
+	a := p.operand()
+	t := p.next()
+	switch op := strings.ToUpper(t.s); {
+	case t.kind == 'p' && (op == "=" || op == "<>" || op == "<" || op == "<=" || op == ">" || op == ">="):
+		return comparison{op: op, a: a, args: []operand{p.operand()}}
+	case t.kind == 'i' && op == "BETWEEN":
+		lo := p.operand()
+		p.expect("AND")
+		return comparison{op: op, a: a, args: []operand{lo, p.operand()}}
+	case t.kind == 'i' && op == "IN":
+		p.expect("(")
+		args := []operand{p.operand()}
+		for p.accept(",") {
+			args = append(args, p.operand())
+		}
+		p.expect(")")
+		return comparison{op: op, a: a, args: args}
+	}
+	p.pos--
+	p.fail()
+	return nil
+}
+
+func (p *parser) update() []updateAction {
+	var actions []updateAction
+	seen := make(map[string]bool)
+	for p.peek().kind != 0 {
+		kind := strings.ToUpper(p.next().s)
+		if seen[kind] {
+			p.errorf("The \"%s\" section can only be used once in an update expression;", kind)
+		}
+		seen[kind] = true
+		for {
+			a := updateAction{kind: kind}
+			switch kind {
+			case "SET":
+				a.p = p.path()
+				p.expect("=")
+				a.v = p.setValue()
+			case "REMOVE":
+				a.p = p.path()
+			case "ADD", "DELETE":
+				a.p = p.path()
+				a.v = valueOperand{p.value()}
+			default:
+				p.pos--
+				p.fail()
+			}
+			actions = append(actions, a)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	return actions
+}
+
+func (p *parser) setValue() operand {
+	a := p.setOperand()
+	for _, op := range []string{"+", "-"} {
+		if p.accept(op) {
+			return arithOperand{op, a, p.setOperand()}
+		}
+	}
+	return a
+}
+
+func (p *parser) setOperand() operand {
+	t := p.peek()
+	if t.kind == ':' {
+		return valueOperand{p.value()}
+	}
+	if !p.isFunction() {
+		return pathOperand{p.path()}
+	}
+	p.pos += 2
+	var o operand
+	switch t.s {
+	case "if_not_exists":
+		pa := p.path()
+		p.expect(",")
+		o = ifNotExistsOperand{pa, p.setOperand()}
+	case "list_append":
+		a := p.setOperand()
+		p.expect(",")
+		o = listAppendOperand{a, p.setOperand()}
+	default:
+		p.errorf("Invalid function name; function: %s", t.s)
+	}
+	p.expect(")")
+	return o
+}
+
+// applyUpdate applies the actions of an update expression to it. The
+// values of SET actions are all evaluated against it before any action is
+// applied.
+func applyUpdate(it item, actions []updateAction) error {
+	values := make([]*value, len(actions))
+	for i, a := range actions {
+		if a.v == nil {
+			continue
+		}
+		v, err := a.v.eval(it)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			return fmt.Errorf("The provided expression refers to an attribute that does not exist in the item")
+		}
+		isSet := v.typ == "SS" || v.typ == "NS" || v.typ == "BS"
+		if a.kind == "ADD" && v.typ != "N" && !isSet || a.kind == "DELETE" && !isSet {
+			return fmt.Errorf("Incorrect operand type for operator or function; operator: %s, operand type: %s", a.kind, v.typ)
+		}
+		values[i] = copyValue(v)
+	}
+	// Removing elements from a list shifts the following ones, so
+	// remove them from the last.
+	sort.SliceStable(actions, func(i, j int) bool {
+		a, b := actions[i], actions[j]
+		if a.kind != "REMOVE" || b.kind != "REMOVE" || len(a.p) != len(b.p) {
+			return false
+		}
+		last := len(a.p) - 1
+		return a.p[last].name == "" && b.p[last].name == "" && a.p[last].index > b.p[last].index
+	})
+	for i, a := range actions {
+		var err error
+		switch a.kind {
+		case "SET":
+			err = a.p.set(it, values[i])
+		case "REMOVE":
+			a.p.remove(it)
+		case "ADD":
+			var sum *value
+			if sum, err = add(a.p.get(it), values[i]); err == nil {
+				err = a.p.set(it, sum)
+			}
+		case "DELETE":
+			var rest *value
+			if rest, err = deleteFromSet(a.p.get(it), values[i]); err == nil {
+				if rest == nil {
+					a.p.remove(it)
+				} else {
+					err = a.p.set(it, rest)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// project returns the attributes of it at paths.
+func project(it item, paths []path) item {
+	out := make(item)
+	for _, p := range paths {
+		v := p.get(it)
+		if v == nil {
+			continue
+		}
+		// Build the containers of v in out as needed.
+		parent := out[p[0].name]
+		if len(p) == 1 {
+			out[p[0].name] = copyValue(v)
+			continue
+		}
+		if parent == nil {
+			parent = newContainer(p[1])
+			out[p[0].name] = parent
+		}
+		for i, e := range p[1:] {
+			var child *value
+			if e.name != "" {
+				child = parent.m[e.name]
+			} else if len(parent.l) > 0 {
+				child = parent.l[len(parent.l)-1]
+			}
+			if i == len(p)-2 {
+				child = copyValue(v)
+			} else if child == nil {
+				child = newContainer(p[i+2])
+			}
+			if e.name != "" {
+				parent.m[e.name] = child
+			} else {
+				parent.l = append(parent.l, child)
+			}
+			parent = child
+		}
+	}
+	return out
+}
+
+func newContainer(e pathElem) *value {
+	if e.name != "" {
+		return &value{typ: "M", m: make(map[string]*value)}
+	}
+	return &value{typ: "L"}
+}
@@ -0,0 +1,1130 @@
+package dynamodbtest
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// keyOf returns the encoded primary key of it, or aborts the request if
+// its key attributes are missing or invalid.
+func (t *table) keyOf(it item) string {
+	var key string
+	for _, name := range []string{t.hashKey, t.rangeKey} {
+		if name == "" {
+			continue
+		}
+		v := it[name]
+		if v == nil {
+			validationf("One or more parameter values were invalid: Missing the key %s in the item", name)
+		}
+		if v.typ != t.attrTypes[name] {
+			validationf("One or more parameter values were invalid: Type mismatch for key %s expected: %s actual: %s", name, t.attrTypes[name], v.typ)
+		}
+		if v.s == "" {
+			validationf("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: %s", name)
+		}
+		s := keyString(v)
+		key += fmt.Sprintf("%d:%s", len(s), s)
+	}
+	return key
+}
+
+// checkKey checks that key holds the primary key of t and nothing else,
+// and returns its encoding.
+func (t *table) checkKey(key item) string {
+	names := 1
+	if t.rangeKey != "" {
+		names = 2
+	}
+	if len(key) != names || key[t.hashKey] == nil || t.rangeKey != "" && key[t.rangeKey] == nil {
+		validationf("The provided key element does not match the schema")
+	}
+	return t.keyOf(key)
+}
+
+// checkItem checks the key attributes of it, including the ones of
+// indexes, and returns the encoding of its primary key.
+func (t *table) checkItem(it item) string {
+	key := t.keyOf(it)
+	for _, idx := range t.indexes {
+		for _, name := range []string{idx.hashKey, idx.rangeKey} {
+			if v := it[name]; name != "" && v != nil && (v.typ != t.attrTypes[name] || v.s == "") {
+				validationf("One or more parameter values were invalid: Type mismatch for Index Key %s Expected: %s Actual: %s IndexName: %s", name, t.attrTypes[name], v.typ, idx.name)
+			}
+		}
+	}
+	return key
+}
+
+// contains reports whether items with the attributes of it are in the
+// index.
+func (idx *index) contains(it item) bool {
+	return it[idx.hashKey] != nil && (idx.rangeKey == "" || it[idx.rangeKey] != nil)
+}
+
+// project returns the attributes of it projected into the index.
+func (idx *index) project(t *table, it item) item {
+	if idx.projection.ProjectionType == "ALL" {
+		return it
+	}
+	names := []string{t.hashKey, t.rangeKey, idx.hashKey, idx.rangeKey}
+	if idx.projection.ProjectionType == "INCLUDE" {
+		names = append(names, idx.projection.NonKeyAttributes...)
+	}
+	out := make(item)
+	for _, name := range names {
+		if v := it[name]; v != nil {
+			out[name] = v
+		}
+	}
+	return out
+}
+
+// expressionParams are the parameters of the requests that take
+// expressions.
+type expressionParams struct {
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]*value
+}
+
+func (p *expressionParams) context() *exprContext {
+	return newExprContext(p.ExpressionAttributeNames, p.ExpressionAttributeValues)
+}
+
+// checkUnused aborts the request if some expression attribute names or
+// values were not used by its expressions.
+func checkUnused(ctx *exprContext) {
+	if err := ctx.checkUnused(); err != nil {
+		validationf("%v", err)
+	}
+}
+
+// comparisonParams is a condition on an attribute, in the legacy
+// KeyConditions, QueryFilter and ScanFilter parameters.
+type comparisonParams struct {
+	AttributeValueList []*value
+	ComparisonOperator string
+}
+
+// expectedParams is a condition on an attribute, in the legacy Expected
+// parameter.
+type expectedParams struct {
+	Value              *value
+	Exists             *flexBool
+	ComparisonOperator string
+	AttributeValueList []*value
+}
+
+// conditionParams are the parameters of the requests that write items
+// under a condition.
+type conditionParams struct {
+	Expected            map[string]expectedParams
+	ConditionalOperator string
+	ConditionExpression string
+}
+
+// legacyComparison applies a legacy comparison operator to an attribute.
+type legacyComparison struct {
+	name string
+	op   string
+	args []*value
+}
+
+// legacyCondition is the condition of a legacy conditional parameter.
+type legacyCondition struct {
+	comparisons []legacyComparison
+	or          bool
+}
+
+func (c legacyCondition) eval(it item) bool {
+	for _, cmp := range c.comparisons {
+		if ok := compareValues(cmp.op, it[cmp.name], cmp.args); ok == c.or {
+			return ok
+		}
+	}
+	return !c.or
+}
+
+// argCounts holds the number of arguments of the legacy comparison
+// operators; -1 stands for one or more.
+var argCounts = map[string]int{
+	"EQ": 1, "NE": 1, "LE": 1, "LT": 1, "GE": 1, "GT": 1,
+	"NOT_NULL": 0, "NULL": 0, "CONTAINS": 1, "NOT_CONTAINS": 1,
+	"BEGINS_WITH": 1, "IN": -1, "BETWEEN": 2,
+}
+
+func newLegacyComparison(param, name, op string, args []*value) legacyComparison {
+	n, ok := argCounts[op]
+	if !ok {
+		validationf("1 validation error detected: Value '%s' at '%s.%s.member.comparisonOperator' failed to satisfy constraint: Member must satisfy enum value set", op, param, name)
+	}
+	if n >= 0 && len(args) != n || n < 0 && len(args) == 0 {
+		validationf("One or more parameter values were invalid: Invalid number of argument(s) for the %s ComparisonOperator", op)
+	}
+	for _, v := range args {
+		if v == nil {
+			validationf("One or more parameter values were invalid: AttributeValue may not be null in %s", param)
+		}
+	}
+	return legacyComparison{name, op, args}
+}
+
+// newLegacyCondition returns the condition of the legacy parameter
+// param, or nil if it is empty.
+func newLegacyCondition(param string, m map[string]comparisonParams, op string) condition {
+	if len(m) == 0 {
+		return nil
+	}
+	c := legacyCondition{or: checkConditionalOperator(op)}
+	for _, name := range sortedParams(m) {
+		p := m[name]
+		c.comparisons = append(c.comparisons, newLegacyComparison(param, name, p.ComparisonOperator, p.AttributeValueList))
+	}
+	return c
+}
+
+func checkConditionalOperator(op string) (or bool) {
+	switch op {
+	case "", "AND":
+		return false
+	case "OR":
+		return true
+	}
+	validationf("1 validation error detected: Value '%s' at 'conditionalOperator' failed to satisfy constraint: Member must satisfy enum value set: [AND, OR]", op)
+	return false
+}
+
+func sortedParams(m map[string]comparisonParams) []string {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parameters returns which legacy and expression parameters of a write
+// request are set, for checkParameterMix.
+func (p *conditionParams) parameters() (legacy, expressions map[string]bool) {
+	legacy = map[string]bool{
+		"Expected":            len(p.Expected) > 0,
+		"ConditionalOperator": p.ConditionalOperator != "",
+	}
+	expressions = map[string]bool{
+		"ConditionExpression": p.ConditionExpression != "",
+	}
+	return legacy, expressions
+}
+
+// checkParameterMix aborts a request that sets both legacy parameters and
+// expression parameters, which DynamoDB rejects whichever they are. The
+// maps tell which parameters of each kind are set.
+func checkParameterMix(legacy, expressions map[string]bool) {
+	set := func(params map[string]bool) []string {
+		var names []string
+		for name, ok := range params {
+			if ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+	l, e := set(legacy), set(expressions)
+	if len(l) > 0 && len(e) > 0 {
+		validationf("Can not use both expression and non-expression parameters in the same request: Non-expression parameters: {%s} Expression parameters: {%s}", strings.Join(l, ", "), strings.Join(e, ", "))
+	}
+}
+
+// condition returns the condition a write request is made under, or nil
+// if it is unconditional.
+func (p *conditionParams) condition(ctx *exprContext) condition {
+	if p.ConditionExpression != "" {
+		c, err := ctx.parseCondition("ConditionExpression", p.ConditionExpression)
+		if err != nil {
+			validationf("%v", err)
+		}
+		return c
+	}
+	if len(p.Expected) == 0 {
+		return nil
+	}
+	c := legacyCondition{or: checkConditionalOperator(p.ConditionalOperator)}
+	names := make([]string, 0, len(p.Expected))
+	for name := range p.Expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e := p.Expected[name]
+		switch {
+		case e.ComparisonOperator != "":
+			if e.Value != nil || e.Exists != nil {
+				validationf("One or more parameter values were invalid: Value or Exists cannot be used with ComparisonOperator for Attribute: %s", name)
+			}
+			c.comparisons = append(c.comparisons, newLegacyComparison("expected", name, e.ComparisonOperator, e.AttributeValueList))
+		case e.Exists != nil && !bool(*e.Exists):
+			if e.Value != nil {
+				validationf("One or more parameter values were invalid: Value cannot be used when Exists is false for Attribute: %s", name)
+			}
+			c.comparisons = append(c.comparisons, legacyComparison{name, "NULL", nil})
+		default:
+			if e.Value == nil {
+				validationf("One or more parameter values were invalid: Value must be provided when Exists is true for Attribute: %s", name)
+			}
+			c.comparisons = append(c.comparisons, legacyComparison{name, "EQ", []*value{e.Value}})
+		}
+	}
+	return c
+}
+
+// checkCondition aborts the request if the item it is about to write does
+// not meet c. old is nil if there is no such item yet.
+func checkCondition(c condition, old item) {
+	if c != nil && !c.eval(old) {
+		fatalf(400, "ConditionalCheckFailedException", "The conditional request failed")
+	}
+}
+
+func checkReturnValues(rv string, allowed ...string) {
+	if rv == "" {
+		return
+	}
+	for _, a := range allowed {
+		if rv == a {
+			return
+		}
+	}
+	validationf("1 validation error detected: Value '%s' at 'returnValues' failed to satisfy constraint: Member must satisfy enum value set: %v", rv, allowed)
+}
+
+// projection returns the paths items are projected on for a request, or
+// nil if it wants all of their attributes.
+func projectionPaths(ctx *exprContext, attributesToGet []string, expr string) []path {
+	if expr == "" {
+		var paths []path
+		for _, name := range attributesToGet {
+			paths = append(paths, path{{name: name}})
+		}
+		return paths
+	}
+	paths, err := ctx.parseProjection(expr)
+	if err != nil {
+		validationf("%v", err)
+	}
+	return paths
+}
+
+type putItemRequest struct {
+	TableName    string
+	Item         item
+	ReturnValues string
+	conditionParams
+	expressionParams
+}
+
+// write is a write of an item, checked and ready to be made.
+type write struct {
+	t    *table
+	key  string
+	cond condition // nil if the write is unconditional.
+
+	// newItem returns the item that replaces old, which is nil if there
+	// is none, or nil to delete it. It is nil for condition checks,
+	// which do not write anything.
+	newItem func(old item) item
+}
+
+// old returns the item the write is about, or nil if there is none.
+func (w *write) old() item {
+	return w.t.items[w.key]
+}
+
+// apply replaces the item the write is about with it, or deletes it if
+// it is nil.
+func (w *write) apply(it item) {
+	w.t.setItem(w.key, it)
+}
+
+func (req *putItemRequest) write(srv *Server) *write {
+	t := srv.table(req.TableName)
+	key := t.checkItem(req.Item)
+	checkParameterMix(req.parameters())
+	ctx := req.context()
+	cond := req.condition(ctx)
+	checkUnused(ctx)
+	return &write{t, key, cond, func(old item) item {
+		return copyItem(req.Item)
+	}}
+}
+
+func (srv *Server) putItem(body []byte) interface{} {
+	var req putItemRequest
+	decode(body, &req)
+	w := req.write(srv)
+	checkReturnValues(req.ReturnValues, "NONE", "ALL_OLD")
+
+	old := w.old()
+	checkCondition(w.cond, old)
+	w.apply(w.newItem(old))
+	resp := map[string]interface{}{}
+	if req.ReturnValues == "ALL_OLD" && old != nil {
+		resp["Attributes"] = old
+	}
+	return resp
+}
+
+type getItemRequest struct {
+	TableName            string
+	Key                  item
+	AttributesToGet      []string
+	ProjectionExpression string
+	ConsistentRead       flexBool
+	expressionParams
+}
+
+// read returns the item the request is about, projected as requested,
+// or nil if there is none.
+func (req *getItemRequest) read(srv *Server) item {
+	t := srv.table(req.TableName)
+	key := t.checkKey(req.Key)
+	checkParameterMix(
+		map[string]bool{"AttributesToGet": len(req.AttributesToGet) > 0},
+		map[string]bool{"ProjectionExpression": req.ProjectionExpression != ""},
+	)
+	ctx := req.context()
+	paths := projectionPaths(ctx, req.AttributesToGet, req.ProjectionExpression)
+	checkUnused(ctx)
+
+	it := t.items[key]
+	if it != nil && paths != nil {
+		it = project(it, paths)
+	}
+	return it
+}
+
+func (srv *Server) getItem(body []byte) interface{} {
+	var req getItemRequest
+	decode(body, &req)
+	resp := map[string]interface{}{}
+	if it := req.read(srv); it != nil {
+		resp["Item"] = it
+	}
+	return resp
+}
+
+type deleteItemRequest struct {
+	TableName    string
+	Key          item
+	ReturnValues string
+	conditionParams
+	expressionParams
+}
+
+func (req *deleteItemRequest) write(srv *Server) *write {
+	t := srv.table(req.TableName)
+	key := t.checkKey(req.Key)
+	checkParameterMix(req.parameters())
+	ctx := req.context()
+	cond := req.condition(ctx)
+	checkUnused(ctx)
+	return &write{t, key, cond, func(old item) item {
+		return nil
+	}}
+}
+
+func (srv *Server) deleteItem(body []byte) interface{} {
+	var req deleteItemRequest
+	decode(body, &req)
+	w := req.write(srv)
+	checkReturnValues(req.ReturnValues, "NONE", "ALL_OLD")
+
+	old := w.old()
+	checkCondition(w.cond, old)
+	w.apply(w.newItem(old))
+	resp := map[string]interface{}{}
+	if req.ReturnValues == "ALL_OLD" && old != nil {
+		resp["Attributes"] = old
+	}
+	return resp
+}
+
+// attributeUpdate is an update of an attribute, in the legacy
+// AttributeUpdates parameter.
+type attributeUpdate struct {
+	Value  *value
+	Action string
+}
+
+type updateItemRequest struct {
+	TableName        string
+	Key              item
+	AttributeUpdates map[string]attributeUpdate
+	UpdateExpression string
+	ReturnValues     string
+	conditionParams
+	expressionParams
+}
+
+// write returns the write of the request, and the names of the
+// attributes it updates.
+func (req *updateItemRequest) write(srv *Server) (*write, map[string]bool) {
+	t := srv.table(req.TableName)
+	key := t.checkKey(req.Key)
+	legacy, expressions := req.parameters()
+	legacy["AttributeUpdates"] = len(req.AttributeUpdates) > 0
+	expressions["UpdateExpression"] = req.UpdateExpression != ""
+	checkParameterMix(legacy, expressions)
+	ctx := req.context()
+	cond := req.condition(ctx)
+	actions, prefix := req.actions(ctx)
+	checkUnused(ctx)
+	updated := make(map[string]bool)
+	for _, a := range actions {
+		name := a.p[0].name
+		if name == t.hashKey || name == t.rangeKey {
+			validationf("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of the key", name)
+		}
+		updated[name] = true
+	}
+	return &write{t, key, cond, func(old item) item {
+		it := copyItem(req.Key)
+		if old != nil {
+			it = copyItem(old)
+		}
+		if err := applyUpdate(it, actions); err != nil {
+			validationf("%s%v", prefix, err)
+		}
+		t.checkItem(it)
+		return it
+	}}, updated
+}
+
+func (srv *Server) updateItem(body []byte) interface{} {
+	var req updateItemRequest
+	decode(body, &req)
+	w, updated := req.write(srv)
+	checkReturnValues(req.ReturnValues, "NONE", "ALL_OLD", "UPDATED_OLD", "ALL_NEW", "UPDATED_NEW")
+
+	old := w.old()
+	checkCondition(w.cond, old)
+	it := w.newItem(old)
+	w.apply(it)
+
+	var attrs item
+	switch req.ReturnValues {
+	case "ALL_OLD":
+		attrs = old
+	case "ALL_NEW":
+		attrs = it
+	case "UPDATED_OLD", "UPDATED_NEW":
+		from := old
+		if req.ReturnValues == "UPDATED_NEW" {
+			from = it
+		}
+		attrs = make(item)
+		for name := range updated {
+			if v := from[name]; v != nil {
+				attrs[name] = v
+			}
+		}
+	}
+	resp := map[string]interface{}{}
+	if len(attrs) > 0 {
+		resp["Attributes"] = attrs
+	}
+	return resp
+}
+
+// actions returns the update actions of the request, and the prefix of
+// the messages of the errors they fail with.
+func (req *updateItemRequest) actions(ctx *exprContext) ([]updateAction, string) {
+	if req.UpdateExpression != "" {
+		actions, err := ctx.parseUpdate(req.UpdateExpression)
+		if err != nil {
+			validationf("%v", err)
+		}
+		return actions, "Invalid UpdateExpression: "
+	}
+	var actions []updateAction
+	names := make([]string, 0, len(req.AttributeUpdates))
+	for name := range req.AttributeUpdates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		u := req.AttributeUpdates[name]
+		a := updateAction{p: path{{name: name}}}
+		if u.Value != nil {
+			a.v = valueOperand{u.Value}
+		}
+		switch u.Action {
+		case "", "PUT":
+			a.kind = "SET"
+		case "ADD":
+			a.kind = "ADD"
+		case "DELETE":
+			a.kind = "DELETE"
+			if u.Value == nil {
+				a.kind = "REMOVE"
+			}
+		default:
+			validationf("1 validation error detected: Value '%s' at 'attributeUpdates.%s.member.action' failed to satisfy constraint: Member must satisfy enum value set: [ADD, PUT, DELETE]", u.Action, name)
+		}
+		if a.v == nil && a.kind != "REMOVE" {
+			validationf("One or more parameter values were invalid: Only DELETE action is allowed when no attribute value is specified")
+		}
+		actions = append(actions, a)
+	}
+	return actions, "One or more parameter values were invalid: "
+}
+
+// source is what a Query or a Scan reads: a table or one of its indexes.
+type source struct {
+	t                 *table
+	idx               *index // nil for the table itself.
+	hashKey, rangeKey string
+}
+
+func (t *table) source(indexName string) *source {
+	if indexName == "" {
+		return &source{t: t, hashKey: t.hashKey, rangeKey: t.rangeKey}
+	}
+	idx := t.index(indexName)
+	if idx == nil {
+		validationf("The table does not have the specified index: %s", indexName)
+	}
+	return &source{t, idx, idx.hashKey, idx.rangeKey}
+}
+
+// compare orders the items of the source: by hash key, then by range
+// key, then, in indexes, where keys need not be unique, by primary key.
+func (s *source) compare(a, b item) int {
+	names := []string{s.hashKey, s.rangeKey}
+	if s.idx != nil {
+		names = append(names, s.t.hashKey, s.t.rangeKey)
+	}
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		va, vb := a[name], b[name]
+		var cmp int
+		if i == 1 || i == 3 {
+			cmp, _ = compare(va, vb)
+		} else if ka, kb := keyString(va), keyString(vb); ka < kb {
+			cmp = -1
+		} else if ka > kb {
+			cmp = 1
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+// key returns the attributes of it that identify it in the source, as
+// LastEvaluatedKey.
+func (s *source) key(it item) item {
+	key := make(item)
+	for _, name := range []string{s.t.hashKey, s.t.rangeKey, s.hashKey, s.rangeKey} {
+		if name != "" {
+			key[name] = it[name]
+		}
+	}
+	return key
+}
+
+// checkStartKey checks that key is a key of the source, as
+// ExclusiveStartKey.
+func (s *source) checkStartKey(key item) {
+	names := make(map[string]bool)
+	for _, name := range []string{s.t.hashKey, s.t.rangeKey, s.hashKey, s.rangeKey} {
+		if name == "" || names[name] {
+			continue
+		}
+		names[name] = true
+		if v := key[name]; v == nil || v.typ != s.t.attrTypes[name] {
+			validationf("The provided starting key is invalid: The provided key element does not match the schema")
+		}
+	}
+	if len(key) != len(names) {
+		validationf("The provided starting key is invalid: The provided key element does not match the schema")
+	}
+}
+
+// readParams are the parameters shared by Query and Scan requests.
+type readParams struct {
+	TableName            string
+	IndexName            string
+	Select               string
+	AttributesToGet      []string
+	ProjectionExpression string
+	FilterExpression     string
+	ConditionalOperator  string
+	Limit                int
+	ExclusiveStartKey    item
+	ConsistentRead       flexBool
+
+	ReturnConsumedCapacity string
+	expressionParams
+}
+
+// parameters returns which legacy and expression parameters of a read
+// request are set, for checkParameterMix.
+func (p *readParams) parameters() (legacy, expressions map[string]bool) {
+	legacy = map[string]bool{
+		"AttributesToGet":     len(p.AttributesToGet) > 0,
+		"ConditionalOperator": p.ConditionalOperator != "",
+	}
+	expressions = map[string]bool{
+		"ProjectionExpression": p.ProjectionExpression != "",
+		"FilterExpression":     p.FilterExpression != "",
+	}
+	return legacy, expressions
+}
+
+// filter returns the filter of the request, given in filterParam for
+// legacy requests, or nil if it has none.
+func (p *readParams) filter(ctx *exprContext, param string, legacy map[string]comparisonParams) condition {
+	if p.FilterExpression == "" {
+		return newLegacyCondition(param, legacy, p.ConditionalOperator)
+	}
+	c, err := ctx.parseCondition("FilterExpression", p.FilterExpression)
+	if err != nil {
+		validationf("%v", err)
+	}
+	return c
+}
+
+// read implements Query and Scan requests: it reads the items of s that
+// match, in order, and applies the parameters of the request to them.
+func (p *readParams) read(s *source, ctx *exprContext, match func(item) bool, filter condition, forward bool) interface{} {
+	paths := projectionPaths(ctx, p.AttributesToGet, p.ProjectionExpression)
+	checkUnused(ctx)
+	sel := p.Select
+	switch {
+	case sel == "" && paths != nil:
+		sel = "SPECIFIC_ATTRIBUTES"
+	case sel == "" && s.idx != nil:
+		sel = "ALL_PROJECTED_ATTRIBUTES"
+	case sel == "":
+		sel = "ALL_ATTRIBUTES"
+	case sel == "SPECIFIC_ATTRIBUTES" && paths == nil:
+		validationf("Select type SPECIFIC_ATTRIBUTES requires AttributesToGet or ProjectionExpression")
+	case sel != "SPECIFIC_ATTRIBUTES" && paths != nil:
+		validationf("Cannot specify the AttributesToGet or ProjectionExpression when choosing to get %s", sel)
+	case sel == "ALL_PROJECTED_ATTRIBUTES" && s.idx == nil:
+		validationf("ALL_PROJECTED_ATTRIBUTES can be used only when Querying using an IndexName")
+	case sel == "ALL_ATTRIBUTES" && s.idx != nil && s.idx.global && s.idx.projection.ProjectionType != "ALL":
+		validationf("One or more parameter values were invalid: Select type ALL_ATTRIBUTES is not supported for global secondary index %s because its projection type is not ALL", s.idx.name)
+	case sel != "ALL_ATTRIBUTES" && sel != "ALL_PROJECTED_ATTRIBUTES" && sel != "SPECIFIC_ATTRIBUTES" && sel != "COUNT":
+		validationf("1 validation error detected: Value '%s' at 'select' failed to satisfy constraint: Member must satisfy enum value set: [SPECIFIC_ATTRIBUTES, COUNT, ALL_ATTRIBUTES, ALL_PROJECTED_ATTRIBUTES]", sel)
+	}
+	if p.Limit < 0 {
+		validationf("1 validation error detected: Value '%d' at 'limit' failed to satisfy constraint: Member must have value greater than or equal to 1", p.Limit)
+	}
+	if p.ExclusiveStartKey != nil {
+		s.checkStartKey(p.ExclusiveStartKey)
+	}
+
+	var items []item
+	for _, it := range s.t.items {
+		if (s.idx == nil || s.idx.contains(it)) && (match == nil || match(it)) {
+			items = append(items, it)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		cmp := s.compare(items[i], items[j])
+		if forward {
+			return cmp < 0
+		}
+		return cmp > 0
+	})
+	if p.ExclusiveStartKey != nil {
+		i := sort.Search(len(items), func(i int) bool {
+			cmp := s.compare(items[i], p.ExclusiveStartKey)
+			return forward && cmp > 0 || !forward && cmp < 0
+		})
+		items = items[i:]
+	}
+
+	resp := map[string]interface{}{}
+	results := []item{}
+	scanned := 0
+	var size int64
+	for _, it := range items {
+		scanned++
+		size += itemSize(it)
+		if filter == nil || filter.eval(it) {
+			out := it
+			switch {
+			case sel == "ALL_PROJECTED_ATTRIBUTES":
+				out = s.idx.project(s.t, it)
+			case sel == "SPECIFIC_ATTRIBUTES":
+				if s.idx != nil && s.idx.global {
+					out = s.idx.project(s.t, it)
+				}
+				out = project(out, paths)
+			}
+			results = append(results, out)
+		}
+		if scanned == p.Limit {
+			resp["LastEvaluatedKey"] = s.key(it)
+			break
+		}
+	}
+	resp["Count"] = len(results)
+	resp["ScannedCount"] = scanned
+	if sel != "COUNT" {
+		resp["Items"] = results
+	}
+	if c := s.consumedCapacity(p.ReturnConsumedCapacity, size, bool(p.ConsistentRead)); c != nil {
+		resp["ConsumedCapacity"] = c
+	}
+	return resp
+}
+
+// consumedCapacity returns the ConsumedCapacity of a read of size bytes
+// of s, or nil if the request did not ask for it. Reads consume a unit
+// per 4 KB, rounded up, or half as much if eventually consistent.
+func (s *source) consumedCapacity(returnConsumedCapacity string, size int64, consistent bool) interface{} {
+	switch returnConsumedCapacity {
+	case "", "NONE":
+		return nil
+	case "TOTAL", "INDEXES":
+	default:
+		validationf("1 validation error detected: Value '%s' at 'returnConsumedCapacity' failed to satisfy constraint: Member must satisfy enum value set: [INDEXES, TOTAL, NONE]", returnConsumedCapacity)
+	}
+	units := float64((size + 4095) / 4096)
+	if units == 0 {
+		units = 1
+	}
+	if !consistent {
+		units /= 2
+	}
+	return map[string]interface{}{
+		"TableName":     s.t.name,
+		"CapacityUnits": units,
+	}
+}
+
+type queryRequest struct {
+	readParams
+	KeyConditions          map[string]comparisonParams
+	KeyConditionExpression string
+	QueryFilter            map[string]comparisonParams
+	ScanIndexForward       *flexBool
+}
+
+func (srv *Server) query(body []byte) interface{} {
+	var req queryRequest
+	decode(body, &req)
+	s := srv.table(req.TableName).source(req.IndexName)
+	legacy, expressions := req.parameters()
+	legacy["KeyConditions"] = len(req.KeyConditions) > 0
+	legacy["QueryFilter"] = len(req.QueryFilter) > 0
+	expressions["KeyConditionExpression"] = req.KeyConditionExpression != ""
+	checkParameterMix(legacy, expressions)
+	ctx := req.context()
+	match := s.keyCondition(ctx, &req)
+	filter := req.filter(ctx, "QueryFilter", req.QueryFilter)
+	forward := req.ScanIndexForward == nil || bool(*req.ScanIndexForward)
+	return req.read(s, ctx, match.eval, filter, forward)
+}
+
+// keyCondition returns the condition on the keys of s of a Query request.
+func (s *source) keyCondition(ctx *exprContext, req *queryRequest) condition {
+	var conds []condition
+	var names []string
+	switch {
+	case req.KeyConditionExpression != "":
+		c, err := ctx.parseCondition("KeyConditionExpression", req.KeyConditionExpression)
+		if err != nil {
+			validationf("%v", err)
+		}
+		for _, c := range flattenAnd(c, nil) {
+			conds = append(conds, c)
+			names = append(names, s.keyConditionName(c))
+		}
+	case len(req.KeyConditions) > 0:
+		for _, name := range sortedParams(req.KeyConditions) {
+			p := req.KeyConditions[name]
+			cmp := newLegacyComparison("keyConditions", name, p.ComparisonOperator, p.AttributeValueList)
+			switch cmp.op {
+			case "EQ", "LE", "LT", "GE", "GT", "BEGINS_WITH", "BETWEEN":
+			default:
+				validationf("Attempted conditional constraint is not an indexable operation")
+			}
+			conds = append(conds, legacyCondition{comparisons: []legacyComparison{cmp}})
+			names = append(names, name)
+			if name == s.hashKey && cmp.op != "EQ" {
+				names[len(names)-1] = ""
+			}
+		}
+	default:
+		validationf("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request.")
+	}
+
+	var hash, rng int
+	for _, name := range names {
+		switch name {
+		case s.hashKey:
+			hash++
+		case s.rangeKey:
+			rng++
+		default:
+			validationf("Query key condition not supported")
+		}
+	}
+	if hash != 1 || rng > 1 {
+		validationf("Query condition missed key schema element: %s", s.hashKey)
+	}
+	return conjunction(conds)
+}
+
+// keyConditionName returns the name of the key attribute c is a condition
+// on, or "" if it is not a valid key condition. Only equality is valid on
+// hash keys.
+func (s *source) keyConditionName(c condition) string {
+	var p path
+	var args []operand
+	switch c := c.(type) {
+	case comparison:
+		if c.op == "<>" || c.op == "IN" {
+			return ""
+		}
+		a, ok := c.a.(pathOperand)
+		if !ok {
+			return ""
+		}
+		p, args = a.p, c.args
+		if p[0].name == s.hashKey && c.op != "=" {
+			return ""
+		}
+	case functionCondition:
+		if c.name != "begins_with" {
+			return ""
+		}
+		p, args = c.p, []operand{c.arg}
+	default:
+		return ""
+	}
+	for _, a := range args {
+		if _, ok := a.(valueOperand); !ok {
+			return ""
+		}
+	}
+	if len(p) != 1 || p[0].name == s.hashKey && len(args) != 1 {
+		return ""
+	}
+	return p[0].name
+}
+
+// flattenAnd appends the operands of the AND conditions of c to conds.
+func flattenAnd(c condition, conds []condition) []condition {
+	if and, ok := c.(andCondition); ok {
+		return flattenAnd(and.b, flattenAnd(and.a, conds))
+	}
+	return append(conds, c)
+}
+
+// conjunction returns the conjunction of conds.
+func conjunction(conds []condition) condition {
+	c := conds[0]
+	for _, d := range conds[1:] {
+		c = andCondition{c, d}
+	}
+	return c
+}
+
+type scanRequest struct {
+	readParams
+	ScanFilter    map[string]comparisonParams
+	Segment       *int
+	TotalSegments int
+}
+
+func (srv *Server) scan(body []byte) interface{} {
+	var req scanRequest
+	decode(body, &req)
+	s := srv.table(req.TableName).source(req.IndexName)
+	legacy, expressions := req.parameters()
+	legacy["ScanFilter"] = len(req.ScanFilter) > 0
+	checkParameterMix(legacy, expressions)
+	ctx := req.context()
+	filter := req.filter(ctx, "ScanFilter", req.ScanFilter)
+	var match func(item) bool
+	switch {
+	case req.Segment == nil && req.TotalSegments == 0:
+	case req.Segment == nil || req.TotalSegments == 0:
+		validationf("The Segment parameter is required but was not present in the request when parameter TotalSegments is present")
+	case req.TotalSegments < 1 || req.TotalSegments > 1000000:
+		validationf("1 validation error detected: Value '%d' at 'totalSegments' failed to satisfy constraint: Member must have value less than or equal to 1000000", req.TotalSegments)
+	case *req.Segment < 0 || *req.Segment >= req.TotalSegments:
+		validationf("The Segment parameter is zero-based and must be less than parameter TotalSegments: Segment: %d is not less than TotalSegments: %d", *req.Segment, req.TotalSegments)
+	default:
+		match = func(it item) bool {
+			h := fnv.New32a()
+			h.Write([]byte(keyString(it[s.hashKey])))
+			return int(h.Sum32()%uint32(req.TotalSegments)) == *req.Segment
+		}
+	}
+	return req.read(s, ctx, match, filter, true)
+}
+
+// keysAndAttributes are the keys of a table read by a BatchGetItem
+// request.
+type keysAndAttributes struct {
+	Keys                     []item
+	AttributesToGet          []string          `json:",omitempty"`
+	ProjectionExpression     string            `json:",omitempty"`
+	ConsistentRead           flexBool          `json:",omitempty"`
+	ExpressionAttributeNames map[string]string `json:",omitempty"`
+}
+
+type batchGetItemRequest struct {
+	RequestItems map[string]*keysAndAttributes
+}
+
+func (srv *Server) batchGetItem(body []byte) interface{} {
+	var req batchGetItemRequest
+	decode(body, &req)
+	names := make([]string, 0, len(req.RequestItems))
+	count := 0
+	for name, r := range req.RequestItems {
+		names = append(names, name)
+		if r == nil || len(r.Keys) == 0 {
+			validationf("1 validation error detected: Value at 'requestItems.%s.member.keys' failed to satisfy constraint: Member must have length greater than or equal to 1", name)
+		}
+		count += len(r.Keys)
+	}
+	if count == 0 {
+		validationf("1 validation error detected: Value at 'requestItems' failed to satisfy constraint: Member must have length greater than or equal to 1")
+	}
+	if count > 100 {
+		validationf("Too many items requested for the BatchGetItem call")
+	}
+	sort.Strings(names)
+
+	responses := make(map[string][]item)
+	unprocessed := make(map[string]*keysAndAttributes)
+	processed := 0
+	for _, name := range names {
+		t := srv.table(name)
+		r := req.RequestItems[name]
+		checkParameterMix(
+			map[string]bool{"AttributesToGet": len(r.AttributesToGet) > 0},
+			map[string]bool{"ProjectionExpression": r.ProjectionExpression != ""},
+		)
+		ctx := newExprContext(r.ExpressionAttributeNames, nil)
+		paths := projectionPaths(ctx, r.AttributesToGet, r.ProjectionExpression)
+		checkUnused(ctx)
+		keys := make([]string, len(r.Keys))
+		seen := make(map[string]bool)
+		for i, key := range r.Keys {
+			keys[i] = t.checkKey(key)
+			if seen[keys[i]] {
+				validationf("Provided list of item keys contains duplicates")
+			}
+			seen[keys[i]] = true
+		}
+		responses[name] = []item{}
+		for i, key := range keys {
+			if srv.config.BatchLimit > 0 && processed == srv.config.BatchLimit {
+				u := *r
+				u.Keys = r.Keys[i:]
+				unprocessed[name] = &u
+				break
+			}
+			processed++
+			if it := t.items[key]; it != nil {
+				if paths != nil {
+					it = project(it, paths)
+				}
+				responses[name] = append(responses[name], it)
+			}
+		}
+	}
+	return map[string]interface{}{
+		"Responses":       responses,
+		"UnprocessedKeys": unprocessed,
+	}
+}
+
+type writeRequest struct {
+	PutRequest *struct {
+		Item item
+	} `json:",omitempty"`
+	DeleteRequest *struct {
+		Key item
+	} `json:",omitempty"`
+}
+
+type batchWriteItemRequest struct {
+	RequestItems map[string][]writeRequest
+}
+
+func (srv *Server) batchWriteItem(body []byte) interface{} {
+	var req batchWriteItemRequest
+	decode(body, &req)
+	names := make([]string, 0, len(req.RequestItems))
+	count := 0
+	for name, writes := range req.RequestItems {
+		names = append(names, name)
+		count += len(writes)
+	}
+	if count == 0 {
+		validationf("1 validation error detected: Value at 'requestItems' failed to satisfy constraint: Member must have length greater than or equal to 1")
+	}
+	if count > 25 {
+		validationf("1 validation error detected: Value at 'requestItems' failed to satisfy constraint: Map value must satisfy constraint: Member must have length less than or equal to 25")
+	}
+	sort.Strings(names)
+
+	// Check all of the requests before writing anything.
+	keys := make(map[string][]string)
+	for _, name := range names {
+		t := srv.table(name)
+		seen := make(map[string]bool)
+		for _, w := range req.RequestItems[name] {
+			var key string
+			switch {
+			case w.PutRequest != nil && w.DeleteRequest == nil:
+				key = t.checkItem(w.PutRequest.Item)
+			case w.DeleteRequest != nil && w.PutRequest == nil:
+				key = t.checkKey(w.DeleteRequest.Key)
+			default:
+				validationf("Supplied AttributeValue has more than one datatypes set, must contain exactly one of the supported datatypes")
+			}
+			if seen[key] {
+				validationf("Provided list of item keys contains duplicates")
+			}
+			seen[key] = true
+			keys[name] = append(keys[name], key)
+		}
+	}
+
+	unprocessed := make(map[string][]writeRequest)
+	processed := 0
+	for _, name := range names {
+		t := srv.tables[name]
+		writes := req.RequestItems[name]
+		for i, w := range writes {
+			if srv.config.BatchLimit > 0 && processed == srv.config.BatchLimit {
+				unprocessed[name] = writes[i:]
+				break
+			}
+			processed++
+			if w.PutRequest != nil {
+				t.setItem(keys[name][i], copyItem(w.PutRequest.Item))
+			} else {
+				t.setItem(keys[name][i], nil)
+			}
+		}
+	}
+	return map[string]interface{}{"UnprocessedItems": unprocessed}
+}
@@ -0,0 +1,802 @@
+// Package dynamodbtest implements a fake DynamoDB server, for testing
+// code that uses the dynamodb package without an actual DynamoDB, or a
+// local DynamoDB, to talk to.
+//
+// The server keeps its tables in memory, and speaks the JSON 1.0 protocol
+// of the DynamoDB API version 2012-08-10. Tables and indexes are active
+// as soon as they are created, and reads are always consistent. The same
+// URL serves the DynamoDB Streams API, for the streams of the tables.
+package dynamodbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const debug = false
+
+const (
+	targetPrefix        = "DynamoDB_20120810."
+	streamsTargetPrefix = "DynamoDBStreams_20120810."
+	errorPrefix         = "com.amazonaws.dynamodb.v20120810#"
+
+	validationException = "com.amazon.coral.validate#ValidationException"
+)
+
+// Config controls the internal behaviour of the Server. A nil config is
+// the default and behaves as if all configurations assume their default
+// behaviour. Once passed to NewServer, the configuration must not be
+// modified.
+type Config struct {
+	// Address on which to listen. By default, a random port is assigned by the
+	// operating system and the server listens on localhost.
+	ListenAddress string
+
+	// BatchLimit is the number of keys a BatchGetItem request, or of
+	// items a BatchWriteItem request, processes at most. The others are
+	// returned as UnprocessedKeys or UnprocessedItems, as DynamoDB does
+	// when requests exceed the provisioned throughput. By default, all
+	// of them are processed.
+	BatchLimit int
+
+	// StreamShardSize is the number of records a shard of a table stream
+	// holds. Once full, the shard is closed, and a child shard receives
+	// the next records, as if DynamoDB had split it. By default, each
+	// stream has a single shard.
+	StreamShardSize int
+}
+
+// Server is a fake DynamoDB server for testing purposes.
+// All of the data for the server is kept in memory.
+type Server struct {
+	url      string
+	listener net.Listener
+	mu       sync.Mutex
+	tables   map[string]*table
+	tokens   map[string]clientToken // by client request token.
+	streams  map[string]*stream     // by ARN, including disabled ones.
+	config   *Config
+	closed   bool
+}
+
+type table struct {
+	name        string
+	ctime       time.Time
+	attrTypes   map[string]string // by attribute name.
+	hashKey     string
+	rangeKey    string // "" if the table has no range key.
+	billingMode string
+	throughput  provisionedThroughput // Zero when billed per request.
+	indexes     []*index
+	items       map[string]item // by encoded primary key.
+	stream      *stream         // nil if the table has no enabled stream.
+
+	// payPerRequestTime is when the table was last switched to be
+	// billed per request.
+	payPerRequestTime time.Time
+
+	// ttlAttribute is the attribute holding the expiration time of the
+	// items, or "" if the time to live of the table is disabled.
+	ttlAttribute string
+
+	tags map[string]string // by key.
+}
+
+// index is a local or global secondary index.
+type index struct {
+	name       string
+	global     bool
+	hashKey    string
+	rangeKey   string
+	projection projection
+	throughput provisionedThroughput // Global indexes only.
+}
+
+type attributeDefinition struct {
+	AttributeName string
+	AttributeType string
+}
+
+type keySchemaElement struct {
+	AttributeName string
+	KeyType       string
+}
+
+type projection struct {
+	ProjectionType   string
+	NonKeyAttributes []string `json:",omitempty"`
+}
+
+type provisionedThroughput struct {
+	NumberOfDecreasesToday int64
+	ReadCapacityUnits      int64
+	WriteCapacityUnits     int64
+}
+
+type indexDefinition struct {
+	IndexName             string
+	KeySchema             []keySchemaElement
+	Projection            projection
+	ProvisionedThroughput *provisionedThroughput
+}
+
+type billingModeSummary struct {
+	BillingMode                       string
+	LastUpdateToPayPerRequestDateTime float64 `json:",omitempty"`
+}
+
+type tableDescription struct {
+	AttributeDefinitions   []attributeDefinition
+	BillingModeSummary     billingModeSummary
+	CreationDateTime       float64
+	ItemCount              int64
+	KeySchema              []keySchemaElement
+	LocalSecondaryIndexes  []indexDescription `json:",omitempty"`
+	GlobalSecondaryIndexes []indexDescription `json:",omitempty"`
+	ProvisionedThroughput  provisionedThroughput
+	StreamSpecification    *streamSpecification `json:",omitempty"`
+	LatestStreamArn        string               `json:",omitempty"`
+	LatestStreamLabel      string               `json:",omitempty"`
+	TableArn               string
+	TableName              string
+	TableSizeBytes         int64
+	TableStatus            string
+}
+
+type indexDescription struct {
+	IndexArn              string
+	IndexName             string
+	IndexSizeBytes        int64
+	IndexStatus           string `json:",omitempty"` // Global indexes only.
+	ItemCount             int64
+	KeySchema             []keySchemaElement
+	Projection            projection
+	ProvisionedThroughput *provisionedThroughput `json:",omitempty"`
+}
+
+// dynamoError is the error of a request, sent to the client as a JSON
+// document.
+type dynamoError struct {
+	statusCode int
+	Type       string `json:"__type"`
+	Message    string `json:"message"`
+
+	// CancellationReasons holds the reason for each action of a
+	// canceled transaction.
+	CancellationReasons []cancellationReason `json:",omitempty"`
+}
+
+// fatalf aborts the request being served with an error of the given type,
+// which is qualified with the DynamoDB namespace unless it already is.
+func fatalf(code int, typ string, errf string, a ...interface{}) {
+	if !strings.Contains(typ, "#") {
+		typ = errorPrefix + typ
+	}
+	panic(&dynamoError{
+		statusCode: code,
+		Type:       typ,
+		Message:    fmt.Sprintf(errf, a...),
+	})
+}
+
+func validationf(errf string, a ...interface{}) {
+	fatalf(400, validationException, errf, a...)
+}
+
+// NewServer starts and returns a new server with the given configuration.
+func NewServer(config *Config) (*Server, error) {
+	listenAddress := "localhost:0"
+
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.ListenAddress != "" {
+		listenAddress = config.ListenAddress
+	}
+
+	l, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on localhost: %v", err)
+	}
+	srv := &Server{
+		listener: l,
+		url:      "http://" + l.Addr().String(),
+		tables:   make(map[string]*table),
+		tokens:   make(map[string]clientToken),
+		streams:  make(map[string]*stream),
+		config:   config,
+	}
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		srv.serveHTTP(w, req)
+	}))
+	return srv, nil
+}
+
+// Quit closes down the server.
+func (srv *Server) Quit() {
+	srv.mu.Lock()
+	srv.closed = true
+	srv.mu.Unlock()
+
+	srv.listener.Close()
+}
+
+// URL returns a URL for the server, to be used as the DynamoDBEndpoint of
+// an aws.Region.
+func (srv *Server) URL() string {
+	return srv.url
+}
+
+// handlers holds the operations of the server, by name. Each of them
+// decodes its request from the body, and returns the response.
+var handlers = map[string]func(srv *Server, body []byte) interface{}{
+	"CreateTable":    (*Server).createTable,
+	"DeleteTable":    (*Server).deleteTable,
+	"DescribeTable":  (*Server).describeTable,
+	"ListTables":     (*Server).listTables,
+	"UpdateTable":    (*Server).updateTable,
+	"PutItem":        (*Server).putItem,
+	"GetItem":        (*Server).getItem,
+	"UpdateItem":     (*Server).updateItem,
+	"DeleteItem":     (*Server).deleteItem,
+	"Query":          (*Server).query,
+	"Scan":           (*Server).scan,
+	"BatchGetItem":   (*Server).batchGetItem,
+	"BatchWriteItem": (*Server).batchWriteItem,
+
+	"TransactWriteItems": (*Server).transactWriteItems,
+	"TransactGetItems":   (*Server).transactGetItems,
+
+	"UpdateTimeToLive":   (*Server).updateTimeToLive,
+	"DescribeTimeToLive": (*Server).describeTimeToLive,
+	"TagResource":        (*Server).tagResource,
+	"UntagResource":      (*Server).untagResource,
+	"ListTagsOfResource": (*Server).listTagsOfResource,
+}
+
+// streamsHandlers holds the operations of the DynamoDB Streams API, which
+// the server serves too.
+var streamsHandlers = map[string]func(srv *Server, body []byte) interface{}{
+	"ListStreams":      (*Server).listStreams,
+	"DescribeStream":   (*Server).describeStream,
+	"GetShardIterator": (*Server).getShardIterator,
+	"GetRecords":       (*Server).getRecords,
+}
+
+// serveHTTP serves the DynamoDB protocol.
+func (srv *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if srv.closed {
+		hj := w.(http.Hijacker)
+		conn, _, _ := hj.Hijack()
+		conn.Close()
+		return
+	}
+
+	target := req.Header.Get("X-Amz-Target")
+	if debug {
+		log.Printf("dynamodbtest %q", target)
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	defer func() {
+		switch err := recover().(type) {
+		case *dynamoError:
+			w.WriteHeader(err.statusCode)
+			jsonMarshal(w, err)
+		case nil:
+		default:
+			panic(err)
+		}
+	}()
+
+	if req.Method != "POST" {
+		fatalf(400, "UnknownOperationException", "unknown http request method %q", req.Method)
+	}
+	var handler func(srv *Server, body []byte) interface{}
+	switch {
+	case strings.HasPrefix(target, targetPrefix):
+		handler = handlers[strings.TrimPrefix(target, targetPrefix)]
+	case strings.HasPrefix(target, streamsTargetPrefix):
+		handler = streamsHandlers[strings.TrimPrefix(target, streamsTargetPrefix)]
+	}
+	if handler == nil {
+		fatalf(400, "UnknownOperationException", "unknown operation %q", target)
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		fatalf(400, "SerializationException", "cannot read request: %v", err)
+	}
+	resp := handler(srv, body)
+	jsonMarshal(w, resp)
+}
+
+// jsonMarshal is the same as json.Marshal except that it panics on error.
+// The marshalling should not fail, but we want to know if it does.
+func jsonMarshal(w http.ResponseWriter, x interface{}) {
+	data, err := json.Marshal(x)
+	if err != nil {
+		panic(fmt.Errorf("error marshalling %#v: %v", x, err))
+	}
+	w.Write(data)
+}
+
+// decode decodes the request body into req.
+func decode(body []byte, req interface{}) {
+	if err := json.Unmarshal(body, req); err != nil {
+		if strings.HasPrefix(err.Error(), "json:") {
+			fatalf(400, "SerializationException", "%v", err)
+		}
+		// Attribute values fail with the message DynamoDB would send.
+		validationf("%v", err)
+	}
+}
+
+// table returns the table with the given name, or aborts the request.
+func (srv *Server) table(name string) *table {
+	if name == "" {
+		validationf("1 validation error detected: Value null at 'tableName' failed to satisfy constraint: Member must not be null")
+	}
+	t := srv.tables[name]
+	if t == nil {
+		fatalf(400, "ResourceNotFoundException", "Requested resource not found: Table: %s not found", name)
+	}
+	return t
+}
+
+type createTableRequest struct {
+	TableName              string
+	AttributeDefinitions   []attributeDefinition
+	KeySchema              []keySchemaElement
+	BillingMode            string
+	ProvisionedThroughput  *provisionedThroughput
+	LocalSecondaryIndexes  []indexDefinition
+	GlobalSecondaryIndexes []indexDefinition
+	StreamSpecification    *streamSpecification
+	Tags                   []tag
+}
+
+func (srv *Server) createTable(body []byte) interface{} {
+	var req createTableRequest
+	decode(body, &req)
+	if req.TableName == "" {
+		validationf("1 validation error detected: Value null at 'tableName' failed to satisfy constraint: Member must not be null")
+	}
+	if srv.tables[req.TableName] != nil {
+		fatalf(400, "ResourceInUseException", "Table already exists: %s", req.TableName)
+	}
+	t := &table{
+		name:      req.TableName,
+		ctime:     time.Now(),
+		attrTypes: make(map[string]string),
+		items:     make(map[string]item),
+		tags:      make(map[string]string),
+	}
+	t.setBillingMode(req.BillingMode, req.ProvisionedThroughput)
+	t.defineAttributes(req.AttributeDefinitions)
+	used := make(map[string]bool)
+	t.hashKey, t.rangeKey = t.keySchema("table", req.KeySchema, used)
+	for _, def := range req.LocalSecondaryIndexes {
+		idx := t.newIndex(def, false, used)
+		if idx.hashKey != t.hashKey || idx.rangeKey == "" {
+			validationf("One or more parameter values were invalid: Index KeySchema does not have the same leading hash key as table KeySchema for index: %s", idx.name)
+		}
+		t.indexes = append(t.indexes, idx)
+	}
+	for _, def := range req.GlobalSecondaryIndexes {
+		t.indexes = append(t.indexes, t.newGlobalIndex(def, used))
+	}
+	if len(used) != len(t.attrTypes) {
+		validationf("One or more parameter values were invalid: Number of attributes in KeySchema does not exactly match number of attributes defined in AttributeDefinitions")
+	}
+	for _, tag := range req.Tags {
+		t.tag(tag)
+	}
+	if spec := req.StreamSpecification; spec != nil && spec.StreamEnabled {
+		t.stream = srv.newStream(t, spec.StreamViewType)
+	}
+	srv.tables[t.name] = t
+	return map[string]interface{}{"TableDescription": t.description("ACTIVE")}
+}
+
+// setBillingMode sets how the table is billed, and its throughput, which
+// only tables billed for their provisioned throughput have.
+func (t *table) setBillingMode(mode string, throughput *provisionedThroughput) {
+	switch mode {
+	case "", "PROVISIONED":
+		if throughput == nil {
+			validationf("One or more parameter values were invalid: ReadCapacityUnits and WriteCapacityUnits must both be specified when BillingMode is PROVISIONED")
+		}
+		t.billingMode = "PROVISIONED"
+		t.setThroughput(*throughput)
+	case "PAY_PER_REQUEST":
+		if throughput != nil {
+			validationf("One or more parameter values were invalid: Neither ReadCapacityUnits nor WriteCapacityUnits can be specified when BillingMode is PAY_PER_REQUEST")
+		}
+		if t.billingMode != mode {
+			t.payPerRequestTime = time.Now()
+		}
+		t.billingMode = mode
+		t.throughput = provisionedThroughput{}
+	default:
+		validationf("1 validation error detected: Value '%s' at 'billingMode' failed to satisfy constraint: Member must satisfy enum value set: [PROVISIONED, PAY_PER_REQUEST]", mode)
+	}
+}
+
+// setThroughput sets the provisioned throughput of the table, counting
+// its decreases.
+func (t *table) setThroughput(throughput provisionedThroughput) {
+	if throughput.ReadCapacityUnits < 1 || throughput.WriteCapacityUnits < 1 {
+		validationf("One or more parameter values were invalid: Provisioned throughput must be at least 1 for both ReadCapacityUnits and WriteCapacityUnits")
+	}
+	throughput.NumberOfDecreasesToday = t.throughput.NumberOfDecreasesToday
+	if t.throughput.ReadCapacityUnits > throughput.ReadCapacityUnits || t.throughput.WriteCapacityUnits > throughput.WriteCapacityUnits {
+		throughput.NumberOfDecreasesToday++
+	}
+	t.throughput = throughput
+}
+
+// defineAttributes adds attribute definitions to the table. Attributes
+// that are already defined must keep their type.
+func (t *table) defineAttributes(defs []attributeDefinition) {
+	for _, def := range defs {
+		switch def.AttributeType {
+		case "S", "N", "B":
+		default:
+			validationf("Member must satisfy enum value set: [B, N, S]; value: %q", def.AttributeType)
+		}
+		if typ := t.attrTypes[def.AttributeName]; typ != "" && typ != def.AttributeType {
+			validationf("One or more parameter values were invalid: Cannot change the type of attribute %s from %s to %s", def.AttributeName, typ, def.AttributeType)
+		}
+		t.attrTypes[def.AttributeName] = def.AttributeType
+	}
+}
+
+// keySchema returns the hash and range keys of a key schema, adding them
+// to used.
+func (t *table) keySchema(what string, schema []keySchemaElement, used map[string]bool) (hashKey, rangeKey string) {
+	if len(schema) == 0 || len(schema) > 2 {
+		validationf("1 validation error detected: Value at 'keySchema' failed to satisfy constraint: Member must have length less than or equal to 2")
+	}
+	for i, k := range schema {
+		if t.attrTypes[k.AttributeName] == "" {
+			validationf("One or more parameter values were invalid: Some index key attributes are not defined in AttributeDefinitions. Keys: [%s], AttributeDefinitions: %v", k.AttributeName, sortedKeys(t.attrTypes))
+		}
+		used[k.AttributeName] = true
+		switch {
+		case i == 0 && k.KeyType == "HASH":
+			hashKey = k.AttributeName
+		case i == 1 && k.KeyType == "RANGE":
+			rangeKey = k.AttributeName
+		default:
+			validationf("Invalid KeySchema of %s: the first KeySchemaElement must be a HASH key, and the second a RANGE key", what)
+		}
+	}
+	return hashKey, rangeKey
+}
+
+func (t *table) newIndex(def indexDefinition, global bool, used map[string]bool) *index {
+	if def.IndexName == "" || t.index(def.IndexName) != nil {
+		validationf("One or more parameter values were invalid: Duplicate index name: %s", def.IndexName)
+	}
+	idx := &index{name: def.IndexName, global: global, projection: def.Projection}
+	idx.hashKey, idx.rangeKey = t.keySchema("index "+def.IndexName, def.KeySchema, used)
+	switch def.Projection.ProjectionType {
+	case "ALL", "KEYS_ONLY":
+		if len(def.Projection.NonKeyAttributes) > 0 {
+			validationf("One or more parameter values were invalid: ProjectionType is %s, but NonKeyAttributes is specified", def.Projection.ProjectionType)
+		}
+	case "INCLUDE":
+	default:
+		validationf("One or more parameter values were invalid: Unknown ProjectionType: %q", def.Projection.ProjectionType)
+	}
+	return idx
+}
+
+// newGlobalIndex returns a global index, whose throughput is provisioned
+// unless the table is billed per request.
+func (t *table) newGlobalIndex(def indexDefinition, used map[string]bool) *index {
+	idx := t.newIndex(def, true, used)
+	switch {
+	case t.billingMode == "PAY_PER_REQUEST" && def.ProvisionedThroughput != nil:
+		validationf("One or more parameter values were invalid: ProvisionedThroughput should not be specified for index: %s when BillingMode is PAY_PER_REQUEST", idx.name)
+	case t.billingMode == "PROVISIONED" && def.ProvisionedThroughput == nil:
+		validationf("One or more parameter values were invalid: ProvisionedThroughput must be specified for index: %s", idx.name)
+	case def.ProvisionedThroughput != nil:
+		idx.throughput = *def.ProvisionedThroughput
+	}
+	return idx
+}
+
+// index returns the index with the given name, or nil.
+func (t *table) index(name string) *index {
+	for _, idx := range t.indexes {
+		if idx.name == name {
+			return idx
+		}
+	}
+	return nil
+}
+
+func (t *table) arn() string {
+	return "arn:aws:dynamodb:us-east-1:000000000000:table/" + t.name
+}
+
+func (t *table) description(status string) *tableDescription {
+	d := &tableDescription{
+		CreationDateTime:      float64(t.ctime.UnixNano()) / float64(time.Second),
+		ItemCount:             int64(len(t.items)),
+		KeySchema:             keySchema(t.hashKey, t.rangeKey),
+		ProvisionedThroughput: t.throughput,
+		TableArn:              t.arn(),
+		TableName:             t.name,
+		TableStatus:           status,
+	}
+	d.BillingModeSummary.BillingMode = t.billingMode
+	if !t.payPerRequestTime.IsZero() {
+		d.BillingModeSummary.LastUpdateToPayPerRequestDateTime = float64(t.payPerRequestTime.UnixNano()) / float64(time.Second)
+	}
+	if t.stream != nil {
+		d.StreamSpecification = &streamSpecification{true, t.stream.viewType}
+		d.LatestStreamArn = t.stream.arn
+		d.LatestStreamLabel = t.stream.label
+	}
+	for _, name := range sortedKeys(t.attrTypes) {
+		d.AttributeDefinitions = append(d.AttributeDefinitions, attributeDefinition{name, t.attrTypes[name]})
+	}
+	for _, it := range t.items {
+		d.TableSizeBytes += itemSize(it)
+	}
+	for _, idx := range t.indexes {
+		id := indexDescription{
+			IndexArn:   t.arn() + "/index/" + idx.name,
+			IndexName:  idx.name,
+			KeySchema:  keySchema(idx.hashKey, idx.rangeKey),
+			Projection: idx.projection,
+		}
+		for _, it := range t.items {
+			if idx.contains(it) {
+				id.ItemCount++
+				id.IndexSizeBytes += itemSize(idx.project(t, it))
+			}
+		}
+		if idx.global {
+			id.IndexStatus = "ACTIVE"
+			throughput := idx.throughput
+			id.ProvisionedThroughput = &throughput
+			d.GlobalSecondaryIndexes = append(d.GlobalSecondaryIndexes, id)
+		} else {
+			d.LocalSecondaryIndexes = append(d.LocalSecondaryIndexes, id)
+		}
+	}
+	return d
+}
+
+func keySchema(hashKey, rangeKey string) []keySchemaElement {
+	schema := []keySchemaElement{{hashKey, "HASH"}}
+	if rangeKey != "" {
+		schema = append(schema, keySchemaElement{rangeKey, "RANGE"})
+	}
+	return schema
+}
+
+// itemSize approximates the size of it as DynamoDB counts it: the lengths
+// of its attribute names and values.
+func itemSize(it item) int64 {
+	var n int64
+	for name, v := range it {
+		n += int64(len(name)) + valueSize(v)
+	}
+	return n
+}
+
+func valueSize(v *value) int64 {
+	n := int64(len(v.s)) + 1
+	for _, s := range v.set {
+		n += int64(len(s))
+	}
+	for name, e := range v.m {
+		n += int64(len(name)) + valueSize(e)
+	}
+	for _, e := range v.l {
+		n += valueSize(e)
+	}
+	return n
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type tableRequest struct {
+	TableName string
+}
+
+func (srv *Server) deleteTable(body []byte) interface{} {
+	var req tableRequest
+	decode(body, &req)
+	t := srv.table(req.TableName)
+	delete(srv.tables, t.name)
+	if t.stream != nil {
+		t.stream.disable()
+	}
+	return map[string]interface{}{"TableDescription": t.description("DELETING")}
+}
+
+func (srv *Server) describeTable(body []byte) interface{} {
+	var req tableRequest
+	decode(body, &req)
+	return map[string]interface{}{"Table": srv.table(req.TableName).description("ACTIVE")}
+}
+
+type updateTableRequest struct {
+	TableName                   string
+	AttributeDefinitions        []attributeDefinition
+	BillingMode                 string
+	ProvisionedThroughput       *provisionedThroughput
+	GlobalSecondaryIndexUpdates []globalSecondaryIndexUpdate
+	StreamSpecification         *streamSpecification
+}
+
+type globalSecondaryIndexUpdate struct {
+	Create *indexDefinition
+	Update *indexDefinition
+	Delete *indexDefinition
+}
+
+// updateTable makes the changes to a copy of the table, which replaces it
+// once they are all valid. The changes are made at once, and the table
+// stays ACTIVE.
+func (srv *Server) updateTable(body []byte) interface{} {
+	var req updateTableRequest
+	decode(body, &req)
+	t := srv.table(req.TableName)
+	if req.BillingMode == "" && req.ProvisionedThroughput == nil && len(req.GlobalSecondaryIndexUpdates) == 0 && req.StreamSpecification == nil {
+		validationf("At least one of ProvisionedThroughput, BillingMode, UpdateStreamEnabled, GlobalSecondaryIndexUpdates or SSESpecification or ReplicaUpdates is required")
+	}
+
+	u := *t
+	u.attrTypes = make(map[string]string)
+	for name, typ := range t.attrTypes {
+		u.attrTypes[name] = typ
+	}
+	u.defineAttributes(req.AttributeDefinitions)
+	u.indexes = nil
+	for _, idx := range t.indexes {
+		c := *idx
+		u.indexes = append(u.indexes, &c)
+	}
+
+	switch {
+	case req.BillingMode != "" && req.BillingMode != t.billingMode:
+		u.setBillingMode(req.BillingMode, req.ProvisionedThroughput)
+		for _, idx := range u.indexes {
+			idx.throughput = provisionedThroughput{}
+		}
+	case req.ProvisionedThroughput != nil:
+		if u.billingMode == "PAY_PER_REQUEST" {
+			validationf("One or more parameter values were invalid: Neither ReadCapacityUnits nor WriteCapacityUnits can be specified when BillingMode is PAY_PER_REQUEST")
+		}
+		u.setThroughput(*req.ProvisionedThroughput)
+	}
+
+	for _, update := range req.GlobalSecondaryIndexUpdates {
+		switch {
+		case update.Create != nil && update.Update == nil && update.Delete == nil:
+			u.indexes = append(u.indexes, u.newGlobalIndex(*update.Create, make(map[string]bool)))
+		case update.Update != nil && update.Create == nil && update.Delete == nil:
+			idx := u.globalIndex(update.Update.IndexName)
+			switch {
+			case u.billingMode == "PAY_PER_REQUEST":
+				validationf("One or more parameter values were invalid: ProvisionedThroughput should not be specified for index: %s when BillingMode is PAY_PER_REQUEST", idx.name)
+			case update.Update.ProvisionedThroughput == nil:
+				validationf("One or more parameter values were invalid: ProvisionedThroughput must be specified for index: %s", idx.name)
+			}
+			idx.throughput = *update.Update.ProvisionedThroughput
+		case update.Delete != nil && update.Create == nil && update.Update == nil:
+			idx := u.globalIndex(update.Delete.IndexName)
+			for i := range u.indexes {
+				if u.indexes[i] == idx {
+					u.indexes = append(u.indexes[:i], u.indexes[i+1:]...)
+					break
+				}
+			}
+		default:
+			validationf("One or more parameter values were invalid: One of GlobalSecondaryIndexUpdate.Create, GlobalSecondaryIndexUpdate.Update, or GlobalSecondaryIndexUpdate.Delete must be specified")
+		}
+	}
+	if u.billingMode == "PROVISIONED" {
+		for _, idx := range u.indexes {
+			if idx.global && idx.throughput.ReadCapacityUnits == 0 {
+				validationf("One or more parameter values were invalid: ProvisionedThroughput must be specified for index: %s", idx.name)
+			}
+		}
+	}
+	u.pruneAttributes()
+
+	if spec := req.StreamSpecification; spec != nil {
+		switch {
+		case spec.StreamEnabled && t.stream != nil:
+			validationf("Table already has an enabled stream: TableName: %s", t.name)
+		case spec.StreamEnabled:
+			u.stream = srv.newStream(&u, spec.StreamViewType)
+		case t.stream == nil:
+			validationf("Table already has no enabled stream: TableName: %s", t.name)
+		default:
+			t.stream.disable()
+			u.stream = nil
+		}
+	}
+	*t = u
+	return map[string]interface{}{"TableDescription": t.description("ACTIVE")}
+}
+
+// globalIndex returns the global index with the given name, or aborts the
+// request.
+func (t *table) globalIndex(name string) *index {
+	idx := t.index(name)
+	if idx == nil || !idx.global {
+		fatalf(400, "ResourceNotFoundException", "Requested resource not found: Index: %s not found for table: %s", name, t.name)
+	}
+	return idx
+}
+
+// pruneAttributes removes the definitions of the attributes that are no
+// longer keys of the table or of its indexes.
+func (t *table) pruneAttributes() {
+	used := map[string]bool{t.hashKey: true, t.rangeKey: true}
+	for _, idx := range t.indexes {
+		used[idx.hashKey] = true
+		used[idx.rangeKey] = true
+	}
+	for name := range t.attrTypes {
+		if !used[name] {
+			delete(t.attrTypes, name)
+		}
+	}
+}
+
+type listTablesRequest struct {
+	ExclusiveStartTableName string
+	Limit                   int
+}
+
+func (srv *Server) listTables(body []byte) interface{} {
+	var req listTablesRequest
+	decode(body, &req)
+	if req.Limit < 0 || req.Limit > 100 {
+		validationf("1 validation error detected: Value '%d' at 'limit' failed to satisfy constraint: Member must have value less than or equal to 100", req.Limit)
+	}
+	if req.Limit == 0 {
+		req.Limit = 100
+	}
+	names := []string{}
+	for name := range srv.tables {
+		if name > req.ExclusiveStartTableName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	resp := map[string]interface{}{}
+	if len(names) > req.Limit {
+		names = names[:req.Limit]
+		resp["LastEvaluatedTableName"] = names[len(names)-1]
+	}
+	resp["TableNames"] = names
+	return resp
+}
@@ -0,0 +1,629 @@
+package dynamodbtest_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/dynamodb"
+	"github.com/AdRoll/goamz/dynamodb/dynamodbtest"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type S struct {
+	srv    *dynamodbtest.Server
+	server *dynamodb.Server
+	table  *dynamodb.Table
+}
+
+var _ = check.Suite(&S{})
+
+var tableDescription = dynamodb.TableDescriptionT{
+	TableName: "Events",
+	AttributeDefinitions: []dynamodb.AttributeDefinitionT{
+		{Name: "User", Type: "S"},
+		{Name: "Time", Type: "N"},
+		{Name: "Kind", Type: "S"},
+	},
+	KeySchema: []dynamodb.KeySchemaT{
+		{AttributeName: "User", KeyType: "HASH"},
+		{AttributeName: "Time", KeyType: "RANGE"},
+	},
+	LocalSecondaryIndexes: []dynamodb.LocalSecondaryIndexT{{
+		IndexName: "ByUserKind",
+		KeySchema: []dynamodb.KeySchemaT{
+			{AttributeName: "User", KeyType: "HASH"},
+			{AttributeName: "Kind", KeyType: "RANGE"},
+		},
+		Projection: dynamodb.ProjectionT{ProjectionType: "KEYS_ONLY"},
+	}},
+	GlobalSecondaryIndexes: []dynamodb.GlobalSecondaryIndexT{{
+		IndexName: "ByKind",
+		KeySchema: []dynamodb.KeySchemaT{
+			{AttributeName: "Kind", KeyType: "HASH"},
+			{AttributeName: "Time", KeyType: "RANGE"},
+		},
+		Projection:            dynamodb.ProjectionT{ProjectionType: "ALL"},
+		ProvisionedThroughput: dynamodb.ProvisionedThroughputT{ReadCapacityUnits: 1, WriteCapacityUnits: 1},
+	}},
+	ProvisionedThroughput: dynamodb.ProvisionedThroughputT{ReadCapacityUnits: 1, WriteCapacityUnits: 1},
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	srv, err := dynamodbtest.NewServer(&dynamodbtest.Config{BatchLimit: 3})
+	c.Assert(err, check.IsNil)
+	s.srv = srv
+	region := aws.Region{DynamoDBEndpoint: srv.URL()}
+	s.server = dynamodb.New(aws.Auth{AccessKey: "key", SecretKey: "secret"}, region)
+	status, err := s.server.CreateTable(tableDescription)
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, "ACTIVE")
+	pk, err := tableDescription.BuildPrimaryKey()
+	c.Assert(err, check.IsNil)
+	s.table = s.server.NewTable(tableDescription.TableName, pk)
+
+	// Two users, with ten events each, of alternating kinds; events
+	// without a kind are in no index.
+	for _, user := range []string{"alice", "bob"} {
+		for i := 0; i < 10; i++ {
+			attrs := []dynamodb.Attribute{*dynamodb.NewStringAttribute("Note", fmt.Sprintf("%s %d", user, i))}
+			if i < 8 {
+				kind := []string{"click", "view"}[i%2]
+				attrs = append(attrs, *dynamodb.NewStringAttribute("Kind", kind))
+			}
+			ok, err := s.table.PutItem(user, fmt.Sprint(i), attrs)
+			c.Assert(ok, check.Equals, true)
+			c.Assert(err, check.IsNil)
+		}
+	}
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	s.srv.Quit()
+}
+
+func notes(items []map[string]*dynamodb.Attribute) []string {
+	var notes []string
+	for _, it := range items {
+		if a := it["Note"]; a != nil {
+			notes = append(notes, a.Value)
+		} else {
+			notes = append(notes, "-")
+		}
+	}
+	return notes
+}
+
+func (s *S) TestDescribeTable(c *check.C) {
+	desc, err := s.table.DescribeTable()
+	c.Assert(err, check.IsNil)
+	c.Assert(desc.TableStatus, check.Equals, "ACTIVE")
+	c.Assert(desc.ItemCount, check.Equals, int64(20))
+	c.Assert(desc.KeySchema, check.DeepEquals, tableDescription.KeySchema)
+	c.Assert(desc.GlobalSecondaryIndexes, check.HasLen, 1)
+	c.Assert(desc.GlobalSecondaryIndexes[0].ItemCount, check.Equals, int64(16))
+
+	_, err = s.server.DescribeTable("Missing")
+	c.Assert(err, check.ErrorMatches, "ResourceNotFoundException: .*")
+
+	_, err = s.server.CreateTable(tableDescription)
+	c.Assert(err, check.ErrorMatches, "ResourceInUseException: .*")
+}
+
+func (s *S) TestQuery(c *check.C) {
+	q := dynamodb.NewQuery(s.table)
+	q.AddKeyConditions([]dynamodb.AttributeComparison{
+		*dynamodb.NewEqualStringAttributeComparison("User", "alice"),
+		*dynamodb.NewNumericAttributeComparison("Time", dynamodb.COMPARISON_GREATER_THAN_OR_EQUAL, 7),
+	})
+	q.AddScanIndexForward(false)
+	items, lastKey, err := s.table.QueryTable(q)
+	c.Assert(err, check.IsNil)
+	c.Assert(lastKey, check.IsNil)
+	c.Assert(notes(items), check.DeepEquals, []string{"alice 9", "alice 8", "alice 7"})
+
+	_, err = s.table.Query([]dynamodb.AttributeComparison{
+		*dynamodb.NewStringAttributeComparison("User", dynamodb.COMPARISON_BEGINS_WITH, "a"),
+	})
+	c.Assert(err, check.ErrorMatches, "ValidationException: .*")
+}
+
+func (s *S) TestQueryPages(c *check.C) {
+	var pages [][]string
+	var lastKey dynamodb.StartKey
+	for {
+		q := dynamodb.NewQuery(s.table)
+		q.AddKeyConditions([]dynamodb.AttributeComparison{
+			*dynamodb.NewEqualStringAttributeComparison("User", "bob"),
+		})
+		q.AddLimit(4)
+		if lastKey != nil {
+			q.AddExclusiveStartKey(lastKey)
+		}
+		var items []map[string]*dynamodb.Attribute
+		var err error
+		items, lastKey, err = s.table.QueryTable(q)
+		c.Assert(err, check.IsNil)
+		pages = append(pages, notes(items))
+		if lastKey == nil {
+			break
+		}
+	}
+	c.Assert(pages, check.DeepEquals, [][]string{
+		{"bob 0", "bob 1", "bob 2", "bob 3"},
+		{"bob 4", "bob 5", "bob 6", "bob 7"},
+		{"bob 8", "bob 9"},
+	})
+}
+
+func (s *S) TestQueryOnIndex(c *check.C) {
+	// Only keys are projected into the local index.
+	items, err := s.table.QueryOnIndex([]dynamodb.AttributeComparison{
+		*dynamodb.NewEqualStringAttributeComparison("User", "alice"),
+		*dynamodb.NewEqualStringAttributeComparison("Kind", "view"),
+	}, "ByUserKind")
+	c.Assert(err, check.IsNil)
+	c.Assert(notes(items), check.DeepEquals, []string{"-", "-", "-", "-"})
+	c.Assert(items[0]["Time"].Value, check.Equals, "1")
+
+	items, err = s.table.QueryOnIndex([]dynamodb.AttributeComparison{
+		*dynamodb.NewEqualStringAttributeComparison("Kind", "click"),
+		{
+			AttributeName:      "Time",
+			ComparisonOperator: dynamodb.COMPARISON_BETWEEN,
+			AttributeValueList: []dynamodb.Attribute{
+				*dynamodb.NewNumericAttribute("Time", "2"),
+				*dynamodb.NewNumericAttribute("Time", "4"),
+			},
+		},
+	}, "ByKind")
+	c.Assert(err, check.IsNil)
+	c.Assert(notes(items), check.DeepEquals, []string{"alice 2", "bob 2", "alice 4", "bob 4"})
+}
+
+func (s *S) TestParallelScan(c *check.C) {
+	var all []string
+	for segment := 0; segment < 3; segment++ {
+		items, err := s.table.ParallelScan(nil, segment, 3)
+		c.Assert(err, check.IsNil)
+		all = append(all, notes(items)...)
+	}
+	sort.Strings(all)
+	c.Assert(all, check.HasLen, 20)
+	c.Assert(all[0], check.Equals, "alice 0")
+	c.Assert(all[19], check.Equals, "bob 9")
+
+	items, err := s.table.ParallelScan([]dynamodb.AttributeComparison{
+		*dynamodb.NewEqualStringAttributeComparison("Kind", "view"),
+	}, 0, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(items, check.HasLen, 8)
+
+	_, err = s.table.ParallelScan(nil, 3, 3)
+	c.Assert(err, check.ErrorMatches, "ValidationException: The Segment parameter is zero-based .*")
+}
+
+func (s *S) TestParallelScanAll(c *check.C) {
+	var all []string
+	err := s.table.ParallelScanAll(4, 2, 0, func(item map[string]*dynamodb.Attribute) error {
+		all = append(all, item["Note"].Value)
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	sort.Strings(all)
+	c.Assert(all, check.HasLen, 20)
+	c.Assert(all[0], check.Equals, "alice 0")
+	c.Assert(all[19], check.Equals, "bob 9")
+
+	// Eventually consistent reads of whole segments of small items consume
+	// half a unit each, so four pages read one after the other take at
+	// least 1.5 / 10 seconds.
+	start := time.Now()
+	err = s.table.ParallelScanAll(4, 1, 10, func(item map[string]*dynamodb.Attribute) error {
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(time.Since(start) >= 140*time.Millisecond, check.Equals, true)
+
+	// Pages of one small item consume half a unit each too, so twenty
+	// pages take at least 9.5 / 50 seconds.
+	all = nil
+	q := dynamodb.NewQuery(s.table)
+	q.AddLimit(1)
+	start = time.Now()
+	err = s.table.ParallelScanAllQuery(q, 3, 3, 50, func(item map[string]*dynamodb.Attribute) error {
+		all = append(all, item["Note"].Value)
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(all, check.HasLen, 20)
+	c.Assert(time.Since(start) >= 190*time.Millisecond, check.Equals, true)
+
+	// The scan stops at the first error.
+	n := 0
+	err = s.table.ParallelScanAllQuery(q, 3, 3, 0, func(item map[string]*dynamodb.Attribute) error {
+		n++
+		return fmt.Errorf("failed")
+	})
+	c.Assert(err, check.ErrorMatches, "failed")
+	c.Assert(n, check.Equals, 1)
+
+	missing := s.server.NewTable("Missing", s.table.Key)
+	err = missing.ParallelScanAll(2, 2, 0, func(item map[string]*dynamodb.Attribute) error {
+		return nil
+	})
+	c.Assert(err, check.ErrorMatches, "ResourceNotFoundException: .*")
+}
+
+func (s *S) TestBatchLimit(c *check.C) {
+	var keys []dynamodb.Key
+	for i := 0; i < 5; i++ {
+		keys = append(keys, dynamodb.Key{HashKey: "alice", RangeKey: fmt.Sprint(i)})
+	}
+	results, err := s.table.BatchGetItems(keys).Execute()
+	c.Assert(err, check.IsNil)
+	c.Assert(notes(results["Events"]), check.DeepEquals, []string{"alice 0", "alice 1", "alice 2"})
+
+	var puts [][]dynamodb.Attribute
+	for i := 0; i < 4; i++ {
+		puts = append(puts, []dynamodb.Attribute{
+			*dynamodb.NewStringAttribute("User", "carol"),
+			*dynamodb.NewNumericAttribute("Time", fmt.Sprint(i)),
+		})
+	}
+	unprocessed, err := s.table.BatchWriteItems(map[string][][]dynamodb.Attribute{"Put": puts}).Execute()
+	c.Assert(err, check.ErrorMatches, "One or more unprocessed items.")
+	c.Assert(unprocessed["Events"], check.HasLen, 1)
+	for i := 0; i < 3; i++ {
+		ok, err := s.table.DeleteItem(&dynamodb.Key{HashKey: "carol", RangeKey: fmt.Sprint(i)})
+		c.Assert(ok, check.Equals, true)
+		c.Assert(err, check.IsNil)
+	}
+}
+
+func (s *S) TestConditionExpression(c *check.C) {
+	key := &dynamodb.Key{HashKey: "dave", RangeKey: "1"}
+	ok, err := s.table.PutItem("dave", "1", []dynamodb.Attribute{*dynamodb.NewNumericAttribute("Count", "1")})
+	c.Assert(ok, check.Equals, true)
+	c.Assert(err, check.IsNil)
+	defer s.table.DeleteItem(key)
+
+	update := &dynamodb.Expression{
+		Text:            "SET #count = #count + :one, Tags = list_append(if_not_exists(Tags, :empty), :tags)",
+		AttributeNames:  map[string]string{"#count": "Count"},
+		AttributeValues: []dynamodb.Attribute{*dynamodb.NewNumericAttribute(":one", "1"), *dynamodb.NewListAttribute(":empty", nil), *dynamodb.NewListAttribute(":tags", []*dynamodb.Attribute{dynamodb.NewStringAttribute("", "new")})},
+	}
+	cond := &dynamodb.Expression{
+		Text:            "#count < :max AND attribute_not_exists(Tags)",
+		AttributeNames:  map[string]string{"#count": "Count"},
+		AttributeValues: []dynamodb.Attribute{*dynamodb.NewNumericAttribute(":max", "5")},
+	}
+	ok, err = s.table.UpdateExpressionUpdateAttributes(key, cond, update)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(err, check.IsNil)
+	item, err := s.table.GetItem(key)
+	c.Assert(err, check.IsNil)
+	c.Assert(item["Count"].Value, check.Equals, "2")
+	c.Assert(item["Tags"].ListValues, check.HasLen, 1)
+
+	// Tags exists now.
+	ok, err = s.table.UpdateExpressionUpdateAttributes(key, cond, update)
+	c.Assert(ok, check.Equals, false)
+	c.Assert(err, check.ErrorMatches, "ConditionalCheckFailedException: .*")
+
+	// Unused names are rejected.
+	cond.Text = "attribute_exists(Tags)"
+	_, err = s.table.UpdateExpressionUpdateAttributes(key, cond, update)
+	c.Assert(err, check.ErrorMatches, "ValidationException: Value provided in ExpressionAttributeValues unused in expressions: keys: {:max}")
+}
+
+type versionedNote struct {
+	Note    string
+	Version int64 `dynamodbav:",version"`
+}
+
+func (s *S) TestVersionedDocuments(c *check.C) {
+	key := &dynamodb.Key{HashKey: "erin", RangeKey: "1"}
+	defer s.table.DeleteItem(key)
+
+	doc := &versionedNote{Note: "first"}
+	c.Assert(s.table.PutDocument(key, doc), check.IsNil)
+	c.Assert(doc.Version, check.Equals, int64(1))
+
+	// A new document conflicts with the stored one.
+	c.Assert(s.table.PutDocument(key, &versionedNote{Note: "other"}), check.Equals, dynamodb.ErrVersionConflict)
+
+	stale := *doc
+	doc.Note = "second"
+	c.Assert(s.table.UpdateDocument(key, doc), check.IsNil)
+	c.Assert(doc.Version, check.Equals, int64(2))
+	var stored versionedNote
+	c.Assert(s.table.GetDocument(key, &stored), check.IsNil)
+	c.Assert(stored, check.Equals, versionedNote{Note: "second", Version: 2})
+
+	// The stale copy can be neither written nor deleted.
+	stale.Note = "stale"
+	c.Assert(s.table.PutDocument(key, &stale), check.Equals, dynamodb.ErrVersionConflict)
+	c.Assert(s.table.UpdateDocument(key, &stale), check.Equals, dynamodb.ErrVersionConflict)
+	c.Assert(s.table.DeleteVersionedDocument(key, &stale), check.Equals, dynamodb.ErrVersionConflict)
+	c.Assert(stale.Version, check.Equals, int64(1))
+
+	c.Assert(s.table.PutDocument(key, doc), check.IsNil)
+	c.Assert(doc.Version, check.Equals, int64(3))
+	c.Assert(s.table.DeleteVersionedDocument(key, doc), check.IsNil)
+	c.Assert(s.table.GetDocument(key, &stored), check.Equals, dynamodb.ErrNotFound)
+}
+
+// TestParameterMix tests that the legacy parameters cannot be used with
+// the expression parameters, whichever they are. The client always sends
+// expressions, so the requests are made by hand.
+func (s *S) TestParameterMix(c *check.C) {
+	key := `"Key": {"User": {"S": "dave"}, "Time": {"N": "2"}}`
+	for _, t := range []struct {
+		target, body, legacy, expressions string
+	}{
+		{"UpdateItem",
+			`{"TableName": "Events", ` + key + `, "AttributeUpdates": {"Note": {"Value": {"S": "x"}}}, "ConditionExpression": "attribute_not_exists(Note)"}`,
+			"AttributeUpdates", "ConditionExpression"},
+		{"UpdateItem",
+			`{"TableName": "Events", ` + key + `, "Expected": {"Note": {"Exists": false}}, "UpdateExpression": "SET Note = :n", "ExpressionAttributeValues": {":n": {"S": "x"}}}`,
+			"Expected", "UpdateExpression"},
+		{"PutItem",
+			`{"TableName": "Events", "Item": {"User": {"S": "dave"}, "Time": {"N": "2"}}, "ConditionalOperator": "AND", "ConditionExpression": "attribute_not_exists(Note)"}`,
+			"ConditionalOperator", "ConditionExpression"},
+		{"Scan",
+			`{"TableName": "Events", "ScanFilter": {"Note": {"ComparisonOperator": "NOT_NULL"}}, "ProjectionExpression": "Note"}`,
+			"ScanFilter", "ProjectionExpression"},
+		{"Query",
+			`{"TableName": "Events", "KeyConditionExpression": "#u = :u", "ExpressionAttributeNames": {"#u": "User"}, "ExpressionAttributeValues": {":u": {"S": "dave"}}, "AttributesToGet": ["Note"]}`,
+			"AttributesToGet", "KeyConditionExpression"},
+	} {
+		req, err := http.NewRequest("POST", s.srv.URL()+"/", strings.NewReader(t.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("X-Amz-Target", "DynamoDB_20120810."+t.target)
+		resp, err := http.DefaultClient.Do(req)
+		c.Assert(err, check.IsNil)
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		c.Assert(err, check.IsNil)
+		c.Check(resp.StatusCode, check.Equals, 400, check.Commentf("%s %s", t.target, data))
+		c.Check(string(data), check.Matches, fmt.Sprintf(`.*Non-expression parameters: \{%s\} Expression parameters: \{%s\}.*`, t.legacy, t.expressions))
+	}
+	_, err := s.table.GetItem(&dynamodb.Key{HashKey: "dave", RangeKey: "2"})
+	c.Assert(err, check.Equals, dynamodb.ErrNotFound)
+}
+
+func (s *S) TestTransactWriteItems(c *check.C) {
+	key := &dynamodb.Key{HashKey: "erin", RangeKey: "1"}
+	put := dynamodb.TransactWriteItem{Put: &dynamodb.TransactPut{
+		Table:      s.table,
+		Key:        key,
+		Attributes: []dynamodb.Attribute{*dynamodb.NewStringAttribute("Note", "erin 1")},
+	}}
+	badUpdate := dynamodb.TransactWriteItem{Update: &dynamodb.TransactUpdate{
+		Table:  s.table,
+		Key:    &dynamodb.Key{HashKey: "alice", RangeKey: "1"},
+		Update: dynamodb.NewUpdateExpression(dynamodb.NewUpdate().Add(dynamodb.Name("Note"), dynamodb.NewNumericAttribute("", "1"))),
+	}}
+	err := s.server.TransactWriteItems([]dynamodb.TransactWriteItem{put, badUpdate}, "")
+	c.Assert(err, check.ErrorMatches, `TransactionCanceledException: .* \[None, ValidationError\]`)
+	c.Assert(err.(*dynamodb.TransactionCanceledError).Reasons[1].Message, check.Matches, ".*An operand in the update expression has an incorrect data type")
+	_, err = s.table.GetItem(key)
+	c.Assert(err, check.Equals, dynamodb.ErrNotFound)
+
+	err = s.server.TransactWriteItems([]dynamodb.TransactWriteItem{put, put}, "")
+	c.Assert(err, check.ErrorMatches, "ValidationException: Transaction request cannot include multiple operations on one item")
+
+	err = s.server.TransactWriteItems([]dynamodb.TransactWriteItem{put}, "erin")
+	c.Assert(err, check.IsNil)
+	defer s.table.DeleteItem(key)
+	err = s.server.TransactWriteItems([]dynamodb.TransactWriteItem{put, badUpdate}, "erin")
+	c.Assert(err, check.ErrorMatches, "IdempotentParameterMismatchException: .*")
+}
+
+// createCounters creates a table billed per request, which the test
+// deletes.
+func (s *S) createCounters(c *check.C, name string) *dynamodb.Table {
+	desc := dynamodb.TableDescriptionT{
+		TableName:            name,
+		AttributeDefinitions: []dynamodb.AttributeDefinitionT{{Name: "Name", Type: "S"}},
+		KeySchema:            []dynamodb.KeySchemaT{{AttributeName: "Name", KeyType: "HASH"}},
+		BillingMode:          dynamodb.BILLING_MODE_PAY_PER_REQUEST,
+	}
+	_, err := s.server.CreateTable(desc)
+	c.Assert(err, check.IsNil)
+	pk, err := desc.BuildPrimaryKey()
+	c.Assert(err, check.IsNil)
+	return s.server.NewTable(name, pk)
+}
+
+func (s *S) TestUpdateTable(c *check.C) {
+	table := s.createCounters(c, "Counters")
+	defer s.server.DeleteTable(dynamodb.TableDescriptionT{TableName: "Counters"})
+	desc, err := table.DescribeTable()
+	c.Assert(err, check.IsNil)
+	c.Assert(desc.BillingModeSummary.BillingMode, check.Equals, dynamodb.BILLING_MODE_PAY_PER_REQUEST)
+	c.Assert(desc.BillingModeSummary.LastUpdateToPayPerRequestDateTime, check.Not(check.Equals), 0.0)
+	c.Assert(desc.ProvisionedThroughput.ReadCapacityUnits, check.Equals, int64(0))
+	c.Assert(desc.TableArn, check.Equals, "arn:aws:dynamodb:us-east-1:000000000000:table/Counters")
+
+	_, err = table.UpdateTable(dynamodb.TableUpdateT{
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughputT{ReadCapacityUnits: 5, WriteCapacityUnits: 5},
+	})
+	c.Assert(err, check.ErrorMatches, "ValidationException: .*PAY_PER_REQUEST")
+
+	desc, err = table.UpdateTable(dynamodb.TableUpdateT{
+		AttributeDefinitions: []dynamodb.AttributeDefinitionT{{Name: "Group", Type: "S"}},
+		GlobalSecondaryIndexUpdates: []dynamodb.GlobalSecondaryIndexUpdateT{{
+			Create: &dynamodb.GlobalSecondaryIndexT{
+				IndexName:  "ByGroup",
+				KeySchema:  []dynamodb.KeySchemaT{{AttributeName: "Group", KeyType: "HASH"}},
+				Projection: dynamodb.ProjectionT{ProjectionType: "KEYS_ONLY"},
+			},
+		}},
+		StreamSpecification: &dynamodb.StreamSpecificationT{StreamEnabled: true, StreamViewType: "KEYS_ONLY"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(desc.GlobalSecondaryIndexes, check.HasLen, 1)
+	c.Assert(desc.GlobalSecondaryIndexes[0].IndexStatus, check.Equals, "ACTIVE")
+	c.Assert(desc.AttributeDefinitions, check.HasLen, 2)
+	c.Assert(desc.LatestStreamArn, check.Not(check.Equals), "")
+
+	// Provisioned tables need the throughput of their indexes.
+	throughput := dynamodb.ProvisionedThroughputT{ReadCapacityUnits: 2, WriteCapacityUnits: 2}
+	update := dynamodb.TableUpdateT{
+		BillingMode:           dynamodb.BILLING_MODE_PROVISIONED,
+		ProvisionedThroughput: &throughput,
+	}
+	_, err = table.UpdateTable(update)
+	c.Assert(err, check.ErrorMatches, "ValidationException: .*ProvisionedThroughput must be specified for index: ByGroup")
+	update.GlobalSecondaryIndexUpdates = []dynamodb.GlobalSecondaryIndexUpdateT{{
+		Update: &dynamodb.GlobalSecondaryIndexThroughputT{IndexName: "ByGroup", ProvisionedThroughput: throughput},
+	}}
+	desc, err = table.UpdateTable(update)
+	c.Assert(err, check.IsNil)
+	c.Assert(desc.BillingModeSummary.BillingMode, check.Equals, dynamodb.BILLING_MODE_PROVISIONED)
+	c.Assert(desc.ProvisionedThroughput.ReadCapacityUnits, check.Equals, int64(2))
+	c.Assert(desc.GlobalSecondaryIndexes[0].ProvisionedThroughput.WriteCapacityUnits, check.Equals, int64(2))
+
+	desc, err = table.UpdateTable(dynamodb.TableUpdateT{
+		GlobalSecondaryIndexUpdates: []dynamodb.GlobalSecondaryIndexUpdateT{{
+			Delete: &dynamodb.GlobalSecondaryIndexNameT{IndexName: "ByGroup"},
+		}},
+		StreamSpecification: &dynamodb.StreamSpecificationT{StreamEnabled: false},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(desc.GlobalSecondaryIndexes, check.HasLen, 0)
+	c.Assert(desc.AttributeDefinitions, check.HasLen, 1)
+	c.Assert(desc.StreamSpecification, check.IsNil)
+
+	_, err = table.UpdateTable(dynamodb.TableUpdateT{})
+	c.Assert(err, check.ErrorMatches, "ValidationException: At least one of .*")
+	_, err = table.UpdateTable(dynamodb.TableUpdateT{
+		GlobalSecondaryIndexUpdates: []dynamodb.GlobalSecondaryIndexUpdateT{{}},
+	})
+	c.Assert(err, check.ErrorMatches, "A global secondary index update must have exactly one of .*")
+}
+
+func (s *S) TestTimeToLive(c *check.C) {
+	table := s.createCounters(c, "Sessions")
+	defer s.server.DeleteTable(dynamodb.TableDescriptionT{TableName: "Sessions"})
+	desc, err := s.server.DescribeTimeToLive("Sessions")
+	c.Assert(err, check.IsNil)
+	c.Assert(desc, check.DeepEquals, &dynamodb.TimeToLiveDescriptionT{TimeToLiveStatus: "DISABLED"})
+
+	now := time.Now()
+	for i, expires := range []time.Time{now.Add(-time.Hour), now.Add(time.Hour)} {
+		attrs := []dynamodb.Attribute{*dynamodb.NewNumericAttribute("Expires", fmt.Sprint(expires.Unix()))}
+		ok, err := table.PutItem(fmt.Sprint("session", i), "", attrs)
+		c.Assert(ok, check.Equals, true)
+		c.Assert(err, check.IsNil)
+	}
+	s.srv.ExpireItems(now)
+	_, err = table.GetItem(&dynamodb.Key{HashKey: "session0"})
+	c.Assert(err, check.IsNil)
+
+	c.Assert(s.server.UpdateTimeToLive("Sessions", "Expires", true), check.IsNil)
+	err = s.server.UpdateTimeToLive("Sessions", "Expires", true)
+	c.Assert(err, check.ErrorMatches, "ValidationException: TimeToLive is already enabled")
+	desc, err = s.server.DescribeTimeToLive("Sessions")
+	c.Assert(err, check.IsNil)
+	c.Assert(desc, check.DeepEquals, &dynamodb.TimeToLiveDescriptionT{AttributeName: "Expires", TimeToLiveStatus: "ENABLED"})
+
+	s.srv.ExpireItems(now)
+	_, err = table.GetItem(&dynamodb.Key{HashKey: "session0"})
+	c.Assert(err, check.Equals, dynamodb.ErrNotFound)
+	_, err = table.GetItem(&dynamodb.Key{HashKey: "session1"})
+	c.Assert(err, check.IsNil)
+
+	c.Assert(s.server.UpdateTimeToLive("Sessions", "Expires", false), check.IsNil)
+}
+
+func (s *S) TestTags(c *check.C) {
+	table := s.createCounters(c, "Tagged")
+	defer s.server.DeleteTable(dynamodb.TableDescriptionT{TableName: "Tagged"})
+	desc, err := table.DescribeTable()
+	c.Assert(err, check.IsNil)
+	arn := desc.TableArn
+
+	tags, err := s.server.ListTagsOfResource(arn)
+	c.Assert(err, check.IsNil)
+	c.Assert(tags, check.HasLen, 0)
+
+	err = s.server.TagResource(arn, []dynamodb.TagT{{Key: "team", Value: "ads"}, {Key: "env", Value: "test"}})
+	c.Assert(err, check.IsNil)
+	err = s.server.TagResource(arn, []dynamodb.TagT{{Key: "env", Value: "prod"}, {Key: "aws:owner", Value: "me"}})
+	c.Assert(err, check.ErrorMatches, "ValidationException: .*aws:owner")
+	err = s.server.UntagResource(arn, []string{"team"})
+	c.Assert(err, check.IsNil)
+	tags, err = s.server.ListTagsOfResource(arn)
+	c.Assert(err, check.IsNil)
+	c.Assert(tags, check.DeepEquals, []dynamodb.TagT{{Key: "env", Value: "test"}})
+
+	_, err = s.server.ListTagsOfResource(arn + "x")
+	c.Assert(err, check.ErrorMatches, "ResourceNotFoundException: .*")
+}
+
+// retryPolicy retries up to ten times, at once.
+type retryPolicy struct{}
+
+func (retryPolicy) ShouldRetry(target string, r *http.Response, err error, numRetries int) bool {
+	return numRetries < 10
+}
+
+func (retryPolicy) Delay(target string, r *http.Response, err error, numRetries int) time.Duration {
+	return 0
+}
+
+func (s *S) TestBatchWriter(c *check.C) {
+	s.createCounters(c, "Counters")
+	defer s.server.DeleteTable(dynamodb.TableDescriptionT{TableName: "Counters"})
+
+	// The server processes three items per request.
+	server := *s.server
+	server.RetryPolicy = retryPolicy{}
+	counters := server.NewTable("Counters", dynamodb.PrimaryKey{KeyAttribute: dynamodb.NewStringAttribute("Name", "")})
+	missing := server.NewTable("Missing", counters.Key)
+	w := server.NewBatchWriter(2)
+	for i := 0; i < 10; i++ {
+		err := w.Put(counters, &dynamodb.Key{HashKey: fmt.Sprint("c", i)}, []dynamodb.Attribute{*dynamodb.NewNumericAttribute("N", "1")})
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(w.Flush(), check.HasLen, 0)
+	items, err := counters.Scan(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(items, check.HasLen, 10)
+
+	// Requests that fail fail all their items.
+	c.Assert(w.Delete(counters, &dynamodb.Key{HashKey: "c0"}), check.IsNil)
+	c.Assert(w.Delete(missing, &dynamodb.Key{HashKey: "c0"}), check.IsNil)
+	failures := w.Close()
+	c.Assert(failures, check.HasLen, 2)
+	c.Assert(failures[0].Delete, check.Equals, true)
+	c.Assert(failures[0].Err, check.ErrorMatches, "ResourceNotFoundException: .*")
+
+	// Items still unprocessed once the retries are exhausted fail.
+	server.RetryPolicy = aws.NeverRetryPolicy{}
+	w = server.NewBatchWriter(1)
+	for i := 0; i < 10; i++ {
+		c.Assert(w.Delete(counters, &dynamodb.Key{HashKey: fmt.Sprint("c", i)}), check.IsNil)
+	}
+	failures = w.Close()
+	c.Assert(failures, check.HasLen, 7)
+	for _, f := range failures {
+		c.Assert(f.Table, check.Equals, counters)
+		c.Assert(f.Err, check.Equals, dynamodb.ErrNotProcessed)
+	}
+	items, err = counters.Scan(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(items, check.HasLen, 7)
+}
@@ -0,0 +1,441 @@
+package dynamodbtest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type streamSpecification struct {
+	StreamEnabled  bool
+	StreamViewType string `json:",omitempty"`
+}
+
+// stream is the stream of a table, which lives on, disabled, once the
+// table is deleted or its stream disabled.
+type stream struct {
+	arn       string
+	label     string
+	viewType  string
+	tableName string
+	hashKey   string
+	rangeKey  string
+	ctime     time.Time
+	enabled   bool
+	shardSize int // 0 for unlimited.
+	seq       int64
+	shards    []*shard
+}
+
+// shard is a shard of a stream. Only the last shard of a stream is
+// open.
+type shard struct {
+	id       string
+	parentID string // "" for the first shard.
+	startSeq int64
+	records  []*streamRecord
+	closed   bool
+}
+
+type streamRecord struct {
+	AwsRegion    string           `json:"awsRegion"`
+	Dynamodb     streamRecordData `json:"dynamodb"`
+	EventID      string           `json:"eventID"`
+	EventName    string           `json:"eventName"`
+	EventSource  string           `json:"eventSource"`
+	EventVersion string           `json:"eventVersion"`
+	UserIdentity *userIdentity    `json:"userIdentity,omitempty"`
+}
+
+// userIdentity is who made a change that DynamoDB made itself, such as
+// the deletion of an expired item.
+type userIdentity struct {
+	PrincipalId string `json:"principalId"`
+	Type        string `json:"type"`
+}
+
+type streamRecordData struct {
+	ApproximateCreationDateTime float64
+	Keys                        item
+	NewImage                    item `json:",omitempty"`
+	OldImage                    item `json:",omitempty"`
+	SequenceNumber              string
+	SizeBytes                   int64
+	StreamViewType              string
+}
+
+// streamLabelFormat is the format of stream labels, which are the times
+// streams were created at.
+const streamLabelFormat = "2006-01-02T15:04:05.000"
+
+func (srv *Server) newStream(t *table, viewType string) *stream {
+	switch viewType {
+	case "KEYS_ONLY", "NEW_IMAGE", "OLD_IMAGE", "NEW_AND_OLD_IMAGES":
+	default:
+		validationf("1 validation error detected: Value '%s' at 'streamSpecification.streamViewType' failed to satisfy constraint: Member must satisfy enum value set: [NEW_IMAGE, OLD_IMAGE, NEW_AND_OLD_IMAGES, KEYS_ONLY]", viewType)
+	}
+	s := &stream{
+		viewType:  viewType,
+		tableName: t.name,
+		hashKey:   t.hashKey,
+		rangeKey:  t.rangeKey,
+		ctime:     time.Now(),
+		enabled:   true,
+		shardSize: srv.config.StreamShardSize,
+	}
+	// Labels are unique, even for tables recreated right away.
+	for {
+		s.label = s.ctime.UTC().Format(streamLabelFormat)
+		s.arn = t.arn() + "/stream/" + s.label
+		if srv.streams[s.arn] == nil {
+			break
+		}
+		s.ctime = s.ctime.Add(time.Millisecond)
+	}
+	s.openShard("")
+	srv.streams[s.arn] = s
+	return s
+}
+
+func (s *stream) openShard(parentID string) {
+	s.shards = append(s.shards, &shard{
+		id:       fmt.Sprintf("shardId-%020d-%08d", s.ctime.UnixNano()/int64(time.Millisecond), len(s.shards)),
+		parentID: parentID,
+		startSeq: s.seq + 1,
+	})
+}
+
+func (s *stream) disable() {
+	s.enabled = false
+	s.shards[len(s.shards)-1].closed = true
+}
+
+// setItem replaces the item at key with it, or deletes it if it is nil,
+// and records the change in the stream of the table.
+func (t *table) setItem(key string, it item) {
+	old := t.items[key]
+	if it == nil {
+		delete(t.items, key)
+	} else {
+		t.items[key] = it
+	}
+	if t.stream != nil {
+		t.stream.record(old, it, nil)
+	}
+}
+
+// record appends the record of the change of an item from old to it to
+// the stream. Writes that change nothing are not recorded. identity is nil
+// unless DynamoDB made the change.
+func (s *stream) record(old, it item, identity *userIdentity) {
+	var name string
+	switch {
+	case old == nil && it == nil:
+		return
+	case old == nil:
+		name = "INSERT"
+	case it == nil:
+		name = "REMOVE"
+	default:
+		if itemsEqual(old, it) {
+			return
+		}
+		name = "MODIFY"
+	}
+
+	s.seq++
+	data := streamRecordData{
+		ApproximateCreationDateTime: float64(time.Now().Unix()),
+		Keys:                        make(item),
+		SequenceNumber:              formatSequenceNumber(s.seq),
+		StreamViewType:              s.viewType,
+	}
+	from := it
+	if from == nil {
+		from = old
+	}
+	for _, k := range []string{s.hashKey, s.rangeKey} {
+		if k != "" {
+			data.Keys[k] = from[k]
+		}
+	}
+	data.SizeBytes = itemSize(data.Keys)
+	if s.viewType == "NEW_IMAGE" || s.viewType == "NEW_AND_OLD_IMAGES" {
+		data.NewImage = it
+		data.SizeBytes += itemSize(it)
+	}
+	if s.viewType == "OLD_IMAGE" || s.viewType == "NEW_AND_OLD_IMAGES" {
+		data.OldImage = old
+		data.SizeBytes += itemSize(old)
+	}
+
+	sh := s.shards[len(s.shards)-1]
+	sh.records = append(sh.records, &streamRecord{
+		AwsRegion:    "us-east-1",
+		Dynamodb:     data,
+		EventID:      fmt.Sprintf("%032x", s.seq),
+		EventName:    name,
+		EventSource:  "aws:dynamodb",
+		EventVersion: "1.1",
+		UserIdentity: identity,
+	})
+	if s.shardSize > 0 && len(sh.records) == s.shardSize {
+		sh.closed = true
+		s.openShard(sh.id)
+	}
+}
+
+func itemsEqual(a, b item) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, v := range a {
+		if w := b[name]; w == nil || !equal(v, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatSequenceNumber formats sequence numbers so that their order is the
+// order of the strings.
+func formatSequenceNumber(seq int64) string {
+	return fmt.Sprintf("%021d", seq)
+}
+
+func (srv *Server) stream(arn string) *stream {
+	if arn == "" {
+		validationf("1 validation error detected: Value null at 'streamArn' failed to satisfy constraint: Member must not be null")
+	}
+	s := srv.streams[arn]
+	if s == nil {
+		fatalf(400, "ResourceNotFoundException", "Requested resource not found: Stream: %s not found", arn)
+	}
+	return s
+}
+
+func (s *stream) shard(id string) *shard {
+	for _, sh := range s.shards {
+		if sh.id == id {
+			return sh
+		}
+	}
+	fatalf(400, "ResourceNotFoundException", "Requested resource not found: Shard does not exist")
+	return nil
+}
+
+type listStreamsRequest struct {
+	TableName               string
+	Limit                   int
+	ExclusiveStartStreamArn string
+}
+
+type streamSummary struct {
+	StreamArn   string
+	StreamLabel string
+	TableName   string
+}
+
+func (srv *Server) listStreams(body []byte) interface{} {
+	var req listStreamsRequest
+	decode(body, &req)
+	if req.Limit < 0 || req.Limit > 100 {
+		validationf("1 validation error detected: Value '%d' at 'limit' failed to satisfy constraint: Member must have value less than or equal to 100", req.Limit)
+	}
+	if req.Limit == 0 {
+		req.Limit = 100
+	}
+	arns := []string{}
+	for arn, s := range srv.streams {
+		if arn > req.ExclusiveStartStreamArn && (req.TableName == "" || s.tableName == req.TableName) {
+			arns = append(arns, arn)
+		}
+	}
+	sort.Strings(arns)
+	resp := map[string]interface{}{}
+	if len(arns) > req.Limit {
+		arns = arns[:req.Limit]
+		resp["LastEvaluatedStreamArn"] = arns[len(arns)-1]
+	}
+	streams := []streamSummary{}
+	for _, arn := range arns {
+		s := srv.streams[arn]
+		streams = append(streams, streamSummary{s.arn, s.label, s.tableName})
+	}
+	resp["Streams"] = streams
+	return resp
+}
+
+type describeStreamRequest struct {
+	StreamArn             string
+	Limit                 int
+	ExclusiveStartShardId string
+}
+
+type sequenceNumberRange struct {
+	StartingSequenceNumber string
+	EndingSequenceNumber   string `json:",omitempty"`
+}
+
+type shardDescription struct {
+	ParentShardId       string `json:",omitempty"`
+	SequenceNumberRange sequenceNumberRange
+	ShardId             string
+}
+
+type streamDescription struct {
+	CreationRequestDateTime float64
+	KeySchema               []keySchemaElement
+	LastEvaluatedShardId    string `json:",omitempty"`
+	Shards                  []shardDescription
+	StreamArn               string
+	StreamLabel             string
+	StreamStatus            string
+	StreamViewType          string
+	TableName               string
+}
+
+func (srv *Server) describeStream(body []byte) interface{} {
+	var req describeStreamRequest
+	decode(body, &req)
+	s := srv.stream(req.StreamArn)
+	if req.Limit < 0 || req.Limit > 100 {
+		validationf("1 validation error detected: Value '%d' at 'limit' failed to satisfy constraint: Member must have value less than or equal to 100", req.Limit)
+	}
+	if req.Limit == 0 {
+		req.Limit = 100
+	}
+	d := &streamDescription{
+		CreationRequestDateTime: float64(s.ctime.UnixNano()) / float64(time.Second),
+		KeySchema:               keySchema(s.hashKey, s.rangeKey),
+		Shards:                  []shardDescription{},
+		StreamArn:               s.arn,
+		StreamLabel:             s.label,
+		StreamStatus:            "ENABLED",
+		StreamViewType:          s.viewType,
+		TableName:               s.tableName,
+	}
+	if !s.enabled {
+		d.StreamStatus = "DISABLED"
+	}
+	shards := s.shards
+	if req.ExclusiveStartShardId != "" {
+		for i, sh := range shards {
+			if sh.id == req.ExclusiveStartShardId {
+				shards = shards[i+1:]
+				break
+			}
+		}
+	}
+	if len(shards) > req.Limit {
+		shards = shards[:req.Limit]
+		d.LastEvaluatedShardId = shards[len(shards)-1].id
+	}
+	for _, sh := range shards {
+		sd := shardDescription{
+			ParentShardId: sh.parentID,
+			ShardId:       sh.id,
+		}
+		sd.SequenceNumberRange.StartingSequenceNumber = formatSequenceNumber(sh.startSeq)
+		if sh.closed {
+			sd.SequenceNumberRange.EndingSequenceNumber = formatSequenceNumber(sh.startSeq + int64(len(sh.records)) - 1)
+		}
+		d.Shards = append(d.Shards, sd)
+	}
+	return map[string]interface{}{"StreamDescription": d}
+}
+
+type getShardIteratorRequest struct {
+	StreamArn         string
+	ShardId           string
+	ShardIteratorType string
+	SequenceNumber    string
+}
+
+// shardIterator is a position in a shard, which clients get encoded.
+type shardIterator struct {
+	arn     string
+	shardID string
+	pos     int
+}
+
+func (it shardIterator) String() string {
+	s := it.arn + "|" + it.shardID + "|" + strconv.Itoa(it.pos)
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func (srv *Server) parseShardIterator(s string) (*stream, *shard, int) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	fields := strings.Split(string(data), "|")
+	if err != nil || len(fields) != 3 {
+		validationf("Invalid ShardIterator")
+	}
+	pos, err := strconv.Atoi(fields[2])
+	if err != nil {
+		validationf("Invalid ShardIterator")
+	}
+	st := srv.stream(fields[0])
+	return st, st.shard(fields[1]), pos
+}
+
+func (srv *Server) getShardIterator(body []byte) interface{} {
+	var req getShardIteratorRequest
+	decode(body, &req)
+	s := srv.stream(req.StreamArn)
+	sh := s.shard(req.ShardId)
+	var pos int
+	switch req.ShardIteratorType {
+	case "TRIM_HORIZON":
+		pos = 0
+	case "LATEST":
+		pos = len(sh.records)
+	case "AT_SEQUENCE_NUMBER", "AFTER_SEQUENCE_NUMBER":
+		seq, err := strconv.ParseInt(req.SequenceNumber, 10, 64)
+		if err != nil || seq < sh.startSeq || seq >= sh.startSeq+int64(len(sh.records)) {
+			validationf("Invalid SequenceNumber %s for shard %s", req.SequenceNumber, sh.id)
+		}
+		pos = int(seq - sh.startSeq)
+		if req.ShardIteratorType == "AFTER_SEQUENCE_NUMBER" {
+			pos++
+		}
+	default:
+		validationf("1 validation error detected: Value '%s' at 'shardIteratorType' failed to satisfy constraint: Member must satisfy enum value set: [AFTER_SEQUENCE_NUMBER, LATEST, AT_SEQUENCE_NUMBER, TRIM_HORIZON]", req.ShardIteratorType)
+	}
+	return map[string]interface{}{"ShardIterator": shardIterator{s.arn, sh.id, pos}.String()}
+}
+
+type getRecordsRequest struct {
+	ShardIterator string
+	Limit         int
+}
+
+func (srv *Server) getRecords(body []byte) interface{} {
+	var req getRecordsRequest
+	decode(body, &req)
+	s, sh, pos := srv.parseShardIterator(req.ShardIterator)
+	if req.Limit < 0 || req.Limit > 1000 {
+		validationf("1 validation error detected: Value '%d' at 'limit' failed to satisfy constraint: Member must have value less than or equal to 1000", req.Limit)
+	}
+	if req.Limit == 0 {
+		req.Limit = 1000
+	}
+	if pos < 0 || pos > len(sh.records) {
+		validationf("Invalid ShardIterator")
+	}
+	end := pos + req.Limit
+	if end > len(sh.records) {
+		end = len(sh.records)
+	}
+	records := sh.records[pos:end]
+	if records == nil {
+		records = []*streamRecord{}
+	}
+	resp := map[string]interface{}{"Records": records}
+	if !sh.closed || end < len(sh.records) {
+		resp["NextShardIterator"] = shardIterator{s.arn, sh.id, end}.String()
+	}
+	return resp
+}
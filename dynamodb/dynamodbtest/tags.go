@@ -0,0 +1,93 @@
+package dynamodbtest
+
+import (
+	"strings"
+)
+
+type tag struct {
+	Key   string
+	Value string
+}
+
+// tag adds a tag to the table, or replaces the value of the tag with the
+// same key.
+func (t *table) tag(tag tag) {
+	if tag.Key == "" || len(tag.Key) > 128 || len(tag.Value) > 256 {
+		validationf("One or more parameter values were invalid: Tag keys must be between 1 and 128 characters, and values at most 256 characters long")
+	}
+	if strings.HasPrefix(tag.Key, "aws:") {
+		validationf("One or more parameter values were invalid: The prefix aws: is reserved for AWS tags: %s", tag.Key)
+	}
+	t.tags[tag.Key] = tag.Value
+	if len(t.tags) > 50 {
+		validationf("One or more parameter values were invalid: Too many tags: a resource may have at most 50 tags")
+	}
+}
+
+// resource returns the table with the given ARN, or aborts the request.
+// Tables are the only resources that can be tagged.
+func (srv *Server) resource(arn string) *table {
+	if arn == "" {
+		validationf("1 validation error detected: Value null at 'resourceArn' failed to satisfy constraint: Member must not be null")
+	}
+	for _, t := range srv.tables {
+		if t.arn() == arn {
+			return t
+		}
+	}
+	fatalf(400, "ResourceNotFoundException", "Requested resource not found: ResourceArn: %s not found", arn)
+	return nil
+}
+
+type tagResourceRequest struct {
+	ResourceArn string
+	Tags        []tag
+}
+
+// tagResource adds all the tags, or none if one of them is invalid.
+func (srv *Server) tagResource(body []byte) interface{} {
+	var req tagResourceRequest
+	decode(body, &req)
+	t := srv.resource(req.ResourceArn)
+	tags := make(map[string]string)
+	for k, v := range t.tags {
+		tags[k] = v
+	}
+	u := &table{tags: tags}
+	for _, tag := range req.Tags {
+		u.tag(tag)
+	}
+	t.tags = tags
+	return map[string]interface{}{}
+}
+
+type untagResourceRequest struct {
+	ResourceArn string
+	TagKeys     []string
+}
+
+func (srv *Server) untagResource(body []byte) interface{} {
+	var req untagResourceRequest
+	decode(body, &req)
+	t := srv.resource(req.ResourceArn)
+	for _, key := range req.TagKeys {
+		delete(t.tags, key)
+	}
+	return map[string]interface{}{}
+}
+
+type listTagsOfResourceRequest struct {
+	ResourceArn string
+}
+
+// listTagsOfResource returns all the tags of a table at once, by key.
+func (srv *Server) listTagsOfResource(body []byte) interface{} {
+	var req listTagsOfResourceRequest
+	decode(body, &req)
+	t := srv.resource(req.ResourceArn)
+	tags := []tag{}
+	for _, key := range sortedKeys(t.tags) {
+		tags = append(tags, tag{key, t.tags[key]})
+	}
+	return map[string]interface{}{"Tags": tags}
+}
@@ -0,0 +1,242 @@
+package dynamodbtest
+
+import (
+	"strings"
+	"time"
+)
+
+// maxTransactItems is the number of actions a transaction has at most.
+const maxTransactItems = 100
+
+// clientTokenLifetime is how long a client request token makes a
+// TransactWriteItems request idempotent for.
+const clientTokenLifetime = 10 * time.Minute
+
+// clientToken is a client request token of a TransactWriteItems request
+// that succeeded.
+type clientToken struct {
+	body  string // the request made with the token.
+	ctime time.Time
+}
+
+// cancellationReason is the reason an action of a transaction was
+// canceled for, or "None" if it was not the cause of the cancellation.
+type cancellationReason struct {
+	Code    string
+	Message string `json:",omitempty"`
+	Item    item   `json:",omitempty"`
+}
+
+// transactParams are the parameters common to the actions of a
+// TransactWriteItems request.
+type transactParams struct {
+	ReturnValuesOnConditionCheckFailure string
+}
+
+type transactPut struct {
+	putItemRequest
+	transactParams
+}
+
+type transactUpdate struct {
+	updateItemRequest
+	transactParams
+}
+
+type transactDelete struct {
+	deleteItemRequest
+	transactParams
+}
+
+type transactConditionCheck struct {
+	TableName           string
+	Key                 item
+	ConditionExpression string
+	expressionParams
+	transactParams
+}
+
+func (req *transactConditionCheck) write(srv *Server) *write {
+	t := srv.table(req.TableName)
+	key := t.checkKey(req.Key)
+	if req.ConditionExpression == "" {
+		validationf("One or more parameter values were invalid: ConditionExpression must be provided for ConditionCheck")
+	}
+	ctx := req.context()
+	cond, err := ctx.parseCondition("ConditionExpression", req.ConditionExpression)
+	if err != nil {
+		validationf("%v", err)
+	}
+	checkUnused(ctx)
+	return &write{t: t, key: key, cond: cond}
+}
+
+type transactWriteItem struct {
+	ConditionCheck *transactConditionCheck
+	Put            *transactPut
+	Update         *transactUpdate
+	Delete         *transactDelete
+}
+
+// write returns the write of the action, and its parameters.
+func (a *transactWriteItem) write(srv *Server) (*write, *transactParams) {
+	var w *write
+	var p *transactParams
+	n := 0
+	if a.ConditionCheck != nil {
+		w, p = a.ConditionCheck.write(srv), &a.ConditionCheck.transactParams
+		n++
+	}
+	if a.Put != nil {
+		w, p = a.Put.write(srv), &a.Put.transactParams
+		n++
+	}
+	if a.Update != nil {
+		w, _ = a.Update.write(srv)
+		p = &a.Update.transactParams
+		n++
+	}
+	if a.Delete != nil {
+		w, p = a.Delete.write(srv), &a.Delete.transactParams
+		n++
+	}
+	if n != 1 {
+		validationf("TransactItems can only contain one of Check, Put, Update or Delete")
+	}
+	checkReturnValues(p.ReturnValuesOnConditionCheckFailure, "NONE", "ALL_OLD")
+	return w, p
+}
+
+type transactWriteItemsRequest struct {
+	TransactItems      []transactWriteItem
+	ClientRequestToken string
+}
+
+// transactWriteItems makes all the writes of the request, or none of
+// them. The conditions of all of them are checked, and all the new items
+// computed, before any is written.
+func (srv *Server) transactWriteItems(body []byte) interface{} {
+	var req transactWriteItemsRequest
+	decode(body, &req)
+	checkTransactItems(len(req.TransactItems))
+	if req.ClientRequestToken != "" {
+		if tok, ok := srv.tokens[req.ClientRequestToken]; ok && time.Since(tok.ctime) < clientTokenLifetime {
+			if tok.body != string(body) {
+				fatalf(400, "IdempotentParameterMismatchException", "The request uses the same client token as a previous, but non-identical request.")
+			}
+			return map[string]interface{}{}
+		}
+	}
+
+	writes := make([]*write, len(req.TransactItems))
+	params := make([]*transactParams, len(req.TransactItems))
+	seen := make(map[*table]map[string]bool)
+	for i := range req.TransactItems {
+		w, p := req.TransactItems[i].write(srv)
+		if seen[w.t] == nil {
+			seen[w.t] = make(map[string]bool)
+		}
+		if seen[w.t][w.key] {
+			validationf("Transaction request cannot include multiple operations on one item")
+		}
+		seen[w.t][w.key] = true
+		writes[i], params[i] = w, p
+	}
+
+	items := make([]item, len(writes))
+	reasons := make([]cancellationReason, len(writes))
+	canceled := false
+	for i, w := range writes {
+		reasons[i] = w.check(&items[i], params[i])
+		if reasons[i].Code != "None" {
+			canceled = true
+		}
+	}
+	if canceled {
+		codes := make([]string, len(reasons))
+		for i, r := range reasons {
+			codes[i] = r.Code
+		}
+		panic(&dynamoError{
+			statusCode:          400,
+			Type:                errorPrefix + "TransactionCanceledException",
+			Message:             "Transaction cancelled, please refer cancellation reasons for specific reasons [" + strings.Join(codes, ", ") + "]",
+			CancellationReasons: reasons,
+		})
+	}
+	for i, w := range writes {
+		if w.newItem != nil {
+			w.apply(items[i])
+		}
+	}
+	if req.ClientRequestToken != "" {
+		srv.tokens[req.ClientRequestToken] = clientToken{string(body), time.Now()}
+	}
+	return map[string]interface{}{}
+}
+
+// check checks the condition of the write of a transaction, and computes
+// the item it writes into *it. It returns the reason the transaction is
+// canceled for because of the write, whose code is "None" if there is
+// none.
+func (w *write) check(it *item, p *transactParams) (reason cancellationReason) {
+	old := w.old()
+	if w.cond != nil && !w.cond.eval(old) {
+		reason = cancellationReason{Code: "ConditionalCheckFailed", Message: "The conditional request failed"}
+		if p.ReturnValuesOnConditionCheckFailure == "ALL_OLD" {
+			reason.Item = old
+		}
+		return reason
+	}
+	if w.newItem == nil {
+		return cancellationReason{Code: "None"}
+	}
+	defer func() {
+		switch err := recover().(type) {
+		case *dynamoError:
+			if err.Type != validationException {
+				panic(err)
+			}
+			reason = cancellationReason{Code: "ValidationError", Message: err.Message}
+		case nil:
+		default:
+			panic(err)
+		}
+	}()
+	*it = w.newItem(old)
+	return cancellationReason{Code: "None"}
+}
+
+func checkTransactItems(n int) {
+	if n == 0 {
+		validationf("1 validation error detected: Value '[]' at 'transactItems' failed to satisfy constraint: Member must have length greater than or equal to 1")
+	}
+	if n > maxTransactItems {
+		validationf("1 validation error detected: Value at 'transactItems' failed to satisfy constraint: Member must have length less than or equal to %d", maxTransactItems)
+	}
+}
+
+type transactGetItem struct {
+	Get *getItemRequest
+}
+
+type transactGetItemsRequest struct {
+	TransactItems []transactGetItem
+}
+
+func (srv *Server) transactGetItems(body []byte) interface{} {
+	var req transactGetItemsRequest
+	decode(body, &req)
+	checkTransactItems(len(req.TransactItems))
+	responses := make([]map[string]interface{}, len(req.TransactItems))
+	for i, a := range req.TransactItems {
+		if a.Get == nil {
+			validationf("1 validation error detected: Value null at 'transactItems.%d.member.get' failed to satisfy constraint: Member must not be null", i+1)
+		}
+		responses[i] = map[string]interface{}{}
+		if it := a.Get.read(srv); it != nil {
+			responses[i]["Item"] = it
+		}
+	}
+	return map[string]interface{}{"Responses": responses}
+}
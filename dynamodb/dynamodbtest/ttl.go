@@ -0,0 +1,87 @@
+package dynamodbtest
+
+import (
+	"math/big"
+	"sort"
+	"time"
+)
+
+type timeToLiveSpecification struct {
+	AttributeName string
+	Enabled       bool
+}
+
+type updateTimeToLiveRequest struct {
+	TableName               string
+	TimeToLiveSpecification timeToLiveSpecification
+}
+
+// updateTimeToLive enables or disables the time to live of a table at
+// once, where DynamoDB takes up to an hour.
+func (srv *Server) updateTimeToLive(body []byte) interface{} {
+	var req updateTimeToLiveRequest
+	decode(body, &req)
+	t := srv.table(req.TableName)
+	spec := req.TimeToLiveSpecification
+	if spec.AttributeName == "" {
+		validationf("1 validation error detected: Value null at 'timeToLiveSpecification.attributeName' failed to satisfy constraint: Member must not be null")
+	}
+	switch {
+	case t.ttlAttribute != "" && t.ttlAttribute != spec.AttributeName:
+		validationf("TimeToLive is active on a different AttributeName: current AttributeName is %s", t.ttlAttribute)
+	case spec.Enabled && t.ttlAttribute != "":
+		validationf("TimeToLive is already enabled")
+	case !spec.Enabled && t.ttlAttribute == "":
+		validationf("TimeToLive is already disabled")
+	case spec.Enabled:
+		t.ttlAttribute = spec.AttributeName
+	default:
+		t.ttlAttribute = ""
+	}
+	return map[string]interface{}{"TimeToLiveSpecification": spec}
+}
+
+func (srv *Server) describeTimeToLive(body []byte) interface{} {
+	var req tableRequest
+	decode(body, &req)
+	t := srv.table(req.TableName)
+	desc := map[string]interface{}{"TimeToLiveStatus": "DISABLED"}
+	if t.ttlAttribute != "" {
+		desc["TimeToLiveStatus"] = "ENABLED"
+		desc["AttributeName"] = t.ttlAttribute
+	}
+	return map[string]interface{}{"TimeToLiveDescription": desc}
+}
+
+// ExpireItems deletes the items that expired before now, from the tables
+// whose time to live is enabled, as DynamoDB does some time after they
+// expire. Items expire at the time, in seconds since the Unix epoch, of
+// their time to live attribute, if it is a number. Their deletions are
+// recorded in the streams of the tables as made by DynamoDB.
+func (srv *Server) ExpireItems(now time.Time) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	deadline := new(big.Rat).SetFrac64(now.UnixNano(), int64(time.Second))
+	identity := &userIdentity{PrincipalId: "dynamodb.amazonaws.com", Type: "Service"}
+	for _, t := range srv.tables {
+		if t.ttlAttribute == "" {
+			continue
+		}
+		var expired []string
+		for key, it := range t.items {
+			v := it[t.ttlAttribute]
+			if v != nil && v.typ == "N" && parseNumber(v.s).Cmp(deadline) < 0 {
+				expired = append(expired, key)
+			}
+		}
+		sort.Strings(expired)
+		for _, key := range expired {
+			old := t.items[key]
+			delete(t.items, key)
+			if t.stream != nil {
+				t.stream.record(old, nil, identity)
+			}
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package dynamodbtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// value is a DynamoDB attribute value. Exactly one of its fields is set,
+// as given by typ.
+type value struct {
+	typ  string // "S", "N", "B", "BOOL", "NULL", "SS", "NS", "BS", "M" or "L".
+	s    string // S, N, and B, base64-encoded.
+	bool bool   // BOOL and NULL.
+	set  []string
+	m    map[string]*value
+	l    []*value
+}
+
+// item is a DynamoDB item: its attributes by name.
+type item map[string]*value
+
+func (v *value) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 1 {
+		return fmt.Errorf("Supplied AttributeValue has more than one datatypes set, must contain exactly one of the supported datatypes")
+	}
+	for typ, data := range raw {
+		v.typ = typ
+		var err error
+		switch typ {
+		case "S", "B":
+			err = json.Unmarshal(data, &v.s)
+		case "N":
+			err = json.Unmarshal(data, &v.s)
+			if err == nil {
+				v.s, err = normalizeNumber(v.s)
+			}
+		case "BOOL", "NULL":
+			// The dynamodb package sends booleans as strings.
+			var b flexBool
+			err = json.Unmarshal(data, &b)
+			v.bool = bool(b)
+		case "SS", "BS":
+			err = json.Unmarshal(data, &v.set)
+		case "NS":
+			err = json.Unmarshal(data, &v.set)
+			for i := 0; err == nil && i < len(v.set); i++ {
+				v.set[i], err = normalizeNumber(v.set[i])
+			}
+		case "M":
+			err = json.Unmarshal(data, &v.m)
+		case "L":
+			err = json.Unmarshal(data, &v.l)
+		default:
+			return fmt.Errorf("Supplied AttributeValue has an unknown datatype %q", typ)
+		}
+		if err != nil {
+			return fmt.Errorf("Invalid %s attribute value: %v", typ, err)
+		}
+	}
+	return v.validate()
+}
+
+func (v *value) MarshalJSON() ([]byte, error) {
+	var x interface{}
+	switch v.typ {
+	case "S", "N", "B":
+		x = v.s
+	case "BOOL", "NULL":
+		x = v.bool
+	case "SS", "NS", "BS":
+		x = v.set
+	case "M":
+		x = v.m
+	case "L":
+		x = v.l
+	}
+	return json.Marshal(map[string]interface{}{v.typ: x})
+}
+
+// validate checks the constraints DynamoDB puts on attribute values.
+func (v *value) validate() error {
+	switch v.typ {
+	case "B":
+		if _, err := base64.StdEncoding.DecodeString(v.s); err != nil {
+			return fmt.Errorf("Invalid binary attribute value: %v", err)
+		}
+	case "NULL":
+		if !v.bool {
+			return fmt.Errorf("Null attribute value types must have the value of true")
+		}
+	case "SS", "NS", "BS":
+		if len(v.set) == 0 {
+			return fmt.Errorf("One or more parameter values were invalid: An %s set may not be empty", setTypeNames[v.typ])
+		}
+		seen := make(map[string]bool)
+		for _, s := range v.set {
+			if seen[s] {
+				return fmt.Errorf("Input collection %v contains duplicates", v.set)
+			}
+			seen[s] = true
+		}
+	}
+	return nil
+}
+
+var setTypeNames = map[string]string{"SS": "string", "NS": "number", "BS": "binary"}
+
+// flexBool is a boolean that can also be given as a string.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", `"true"`:
+		*b = true
+	case "false", `"false"`, "null":
+		*b = false
+	default:
+		return fmt.Errorf("cannot unmarshal %s into a boolean", data)
+	}
+	return nil
+}
+
+// normalizeNumber returns the canonical form of the number s, as DynamoDB
+// returns it: without leading or trailing zeros, or exponent.
+func normalizeNumber(s string) (string, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "/") {
+		return "", fmt.Errorf("The parameter cannot be converted to a numeric value: %s", s)
+	}
+	return formatNumber(r), nil
+}
+
+func parseNumber(s string) *big.Rat {
+	r, _ := new(big.Rat).SetString(s)
+	return r
+}
+
+// formatNumber formats r, which has a finite decimal representation, in
+// decimal notation.
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	// The denominator of a decimal divides a power of ten.
+	ten := big.NewInt(10)
+	pow := big.NewInt(10)
+	digits := 1
+	for new(big.Int).Mod(pow, r.Denom()).Sign() != 0 {
+		pow.Mul(pow, ten)
+		digits++
+	}
+	return strings.TrimRight(r.FloatString(digits), "0")
+}
+
+func copyValue(v *value) *value {
+	c := *v
+	if v.set != nil {
+		c.set = append([]string(nil), v.set...)
+	}
+	if v.m != nil {
+		c.m = make(map[string]*value, len(v.m))
+		for k, e := range v.m {
+			c.m[k] = copyValue(e)
+		}
+	}
+	if v.l != nil {
+		c.l = make([]*value, len(v.l))
+		for i, e := range v.l {
+			c.l[i] = copyValue(e)
+		}
+	}
+	return &c
+}
+
+func copyItem(it item) item {
+	c := make(item, len(it))
+	for k, v := range it {
+		c[k] = copyValue(v)
+	}
+	return c
+}
+
+// equal reports whether a and b are the same value. Sets are compared
+// regardless of the order of their elements.
+func equal(a, b *value) bool {
+	if a == nil || b == nil || a.typ != b.typ {
+		return false
+	}
+	switch a.typ {
+	case "N":
+		return parseNumber(a.s).Cmp(parseNumber(b.s)) == 0
+	case "S", "B":
+		return a.s == b.s
+	case "BOOL", "NULL":
+		return a.bool == b.bool
+	case "SS", "NS", "BS":
+		if len(a.set) != len(b.set) {
+			return false
+		}
+		for _, s := range a.set {
+			if !setContains(b, s) {
+				return false
+			}
+		}
+		return true
+	case "M":
+		if len(a.m) != len(b.m) {
+			return false
+		}
+		for k, v := range a.m {
+			if !equal(v, b.m[k]) {
+				return false
+			}
+		}
+		return true
+	case "L":
+		if len(a.l) != len(b.l) {
+			return false
+		}
+		for i := range a.l {
+			if !equal(a.l[i], b.l[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compare orders two scalar values of the same type, the way DynamoDB
+// orders sort keys. ok is false if they cannot be compared.
+func compare(a, b *value) (cmp int, ok bool) {
+	if a == nil || b == nil || a.typ != b.typ {
+		return 0, false
+	}
+	switch a.typ {
+	case "N":
+		return parseNumber(a.s).Cmp(parseNumber(b.s)), true
+	case "S":
+		return strings.Compare(a.s, b.s), true
+	case "B":
+		ab, _ := base64.StdEncoding.DecodeString(a.s)
+		bb, _ := base64.StdEncoding.DecodeString(b.s)
+		return bytes.Compare(ab, bb), true
+	}
+	return 0, false
+}
+
+func setContains(set *value, s string) bool {
+	for _, e := range set.set {
+		if set.typ == "NS" && parseNumber(e).Cmp(parseNumber(s)) == 0 || e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// contains implements the contains function of condition expressions and
+// the CONTAINS comparison operator.
+func contains(a, b *value) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	switch a.typ {
+	case "S":
+		return b.typ == "S" && strings.Contains(a.s, b.s)
+	case "B":
+		if b.typ != "B" {
+			return false
+		}
+		ab, _ := base64.StdEncoding.DecodeString(a.s)
+		bb, _ := base64.StdEncoding.DecodeString(b.s)
+		return bytes.Contains(ab, bb)
+	case "SS", "NS", "BS":
+		return a.typ == b.typ+"S" && setContains(a, b.s)
+	case "L":
+		for _, e := range a.l {
+			if equal(e, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func beginsWith(a, b *value) bool {
+	if a == nil || b == nil || a.typ != b.typ {
+		return false
+	}
+	switch a.typ {
+	case "S":
+		return strings.HasPrefix(a.s, b.s)
+	case "B":
+		ab, _ := base64.StdEncoding.DecodeString(a.s)
+		bb, _ := base64.StdEncoding.DecodeString(b.s)
+		return bytes.HasPrefix(ab, bb)
+	}
+	return false
+}
+
+// size implements the size function of condition expressions.
+func size(v *value) (int, bool) {
+	switch v.typ {
+	case "S":
+		return len(v.s), true
+	case "B":
+		b, _ := base64.StdEncoding.DecodeString(v.s)
+		return len(b), true
+	case "SS", "NS", "BS":
+		return len(v.set), true
+	case "M":
+		return len(v.m), true
+	case "L":
+		return len(v.l), true
+	}
+	return 0, false
+}
+
+// add implements ADD update actions, and the + operator of SET actions
+// when both values are numbers.
+func add(a, b *value) (*value, error) {
+	switch {
+	case a == nil:
+		return copyValue(b), nil
+	case a.typ == "N" && b.typ == "N":
+		sum := new(big.Rat).Add(parseNumber(a.s), parseNumber(b.s))
+		return &value{typ: "N", s: formatNumber(sum)}, nil
+	case a.typ == b.typ && (a.typ == "SS" || a.typ == "NS" || a.typ == "BS"):
+		union := copyValue(a)
+		for _, s := range b.set {
+			if !setContains(a, s) {
+				union.set = append(union.set, s)
+			}
+		}
+		return union, nil
+	}
+	return nil, fmt.Errorf("An operand in the update expression has an incorrect data type")
+}
+
+func subtract(a, b *value) (*value, error) {
+	if a.typ != "N" || b.typ != "N" {
+		return nil, fmt.Errorf("An operand in the update expression has an incorrect data type")
+	}
+	diff := new(big.Rat).Sub(parseNumber(a.s), parseNumber(b.s))
+	return &value{typ: "N", s: formatNumber(diff)}, nil
+}
+
+// deleteFromSet implements DELETE update actions. It returns nil if no
+// element is left.
+func deleteFromSet(a, b *value) (*value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	if a.typ != b.typ || !(a.typ == "SS" || a.typ == "NS" || a.typ == "BS") {
+		return nil, fmt.Errorf("An operand in the update expression has an incorrect data type")
+	}
+	rest := &value{typ: a.typ}
+	for _, s := range a.set {
+		if !setContains(b, s) {
+			rest.set = append(rest.set, s)
+		}
+	}
+	if len(rest.set) == 0 {
+		return nil, nil
+	}
+	return rest, nil
+}
+
+// keyString returns a string identifying a key value, equal for equal
+// values.
+func keyString(v *value) string {
+	return v.typ + ":" + v.s
+}
+
+// sortedNames returns the keys of m, sorted.
+func sortedNames(m map[string]*value) []string {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
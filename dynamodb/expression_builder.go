@@ -0,0 +1,420 @@
+package dynamodb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The functions below build expressions out of conditions, updates and
+// document paths, generating the expression attribute names and values
+// they need:
+//
+//	cond := NewConditionExpression(And(
+//		AttributeExists(Name("Owner")),
+//		LessThan(Name("Count"), Value(NewNumericAttribute("", "10"))),
+//	))
+//	update := NewUpdateExpression(NewUpdate().
+//		Set(Name("Count"), Plus(Name("Count"), Value(NewNumericAttribute("", "1")))).
+//		Remove(Name("Tags").Index(0)))
+//	ok, err := table.UpdateExpressionUpdateAttributes(key, cond, update)
+//
+// Each kind of expression uses its own placeholders, so that the
+// expressions of a request never clash. The names of the values passed to
+// Value are ignored.
+//
+// See http://docs.aws.amazon.com/amazondynamodb/latest/developerguide/Expressions.html
+// for the meaning of each condition, function and action.
+
+// An Operand is an operand in a condition or update expression: a document
+// path, a value, or a function of them.
+type Operand interface {
+	operand(b *expressionBuilder) string
+}
+
+// A Condition is a condition in a condition, filter or key condition
+// expression.
+type Condition interface {
+	condition(b *expressionBuilder) string
+}
+
+// expressionBuilder accumulates the attribute names and values of an
+// expression.
+type expressionBuilder struct {
+	prefix       string
+	names        map[string]string // placeholders by attribute name.
+	expr         *Expression
+	valueCounter int
+}
+
+func newExpressionBuilder(prefix string) *expressionBuilder {
+	return &expressionBuilder{
+		prefix: prefix,
+		names:  make(map[string]string),
+		expr:   &Expression{AttributeNames: make(map[string]string)},
+	}
+}
+
+func (b *expressionBuilder) name(name string) string {
+	placeholder, ok := b.names[name]
+	if !ok {
+		placeholder = fmt.Sprintf("#%s%d", b.prefix, len(b.names))
+		b.names[name] = placeholder
+		b.expr.AttributeNames[placeholder] = name
+	}
+	return placeholder
+}
+
+func (b *expressionBuilder) value(a *Attribute) string {
+	placeholder := fmt.Sprintf(":%s%d", b.prefix, b.valueCounter)
+	b.valueCounter++
+	v := *a
+	v.Name = placeholder
+	b.expr.AttributeValues = append(b.expr.AttributeValues, v)
+	return placeholder
+}
+
+// A Path is a document path: an attribute, or an element nested within
+// one.
+type Path struct {
+	name     string
+	elements []pathElement
+}
+
+type pathElement struct {
+	field string
+	index int // used if field is "".
+}
+
+// Name returns the path of the top-level attribute with the given name.
+// The name is used as is, even if it contains dots or brackets.
+func Name(name string) Path {
+	return Path{name: name}
+}
+
+// Field returns the path of the attribute name of the map at p.
+func (p Path) Field(name string) Path {
+	return p.append(pathElement{field: name})
+}
+
+// Index returns the path of the element i of the list at p.
+func (p Path) Index(i int) Path {
+	return p.append(pathElement{index: i})
+}
+
+func (p Path) append(e pathElement) Path {
+	elements := make([]pathElement, len(p.elements), len(p.elements)+1)
+	copy(elements, p.elements)
+	return Path{p.name, append(elements, e)}
+}
+
+func (p Path) operand(b *expressionBuilder) string {
+	s := b.name(p.name)
+	for _, e := range p.elements {
+		if e.field != "" {
+			s += "." + b.name(e.field)
+		} else {
+			s += fmt.Sprintf("[%d]", e.index)
+		}
+	}
+	return s
+}
+
+type valueOperand struct {
+	a *Attribute
+}
+
+// Value returns an operand of value a.
+func Value(a *Attribute) Operand {
+	return valueOperand{a}
+}
+
+func (o valueOperand) operand(b *expressionBuilder) string {
+	return b.value(o.a)
+}
+
+type functionOperand struct {
+	name string
+	args []Operand
+}
+
+func (o functionOperand) operand(b *expressionBuilder) string {
+	args := make([]string, len(o.args))
+	for i, a := range o.args {
+		args[i] = a.operand(b)
+	}
+	return fmt.Sprintf("%s(%s)", o.name, strings.Join(args, ", "))
+}
+
+// Size returns an operand of the size of the attribute at p.
+func Size(p Path) Operand {
+	return functionOperand{"size", []Operand{p}}
+}
+
+// IfNotExists returns an operand of the attribute at p, or of v if there
+// is none. It can only be used in updates.
+func IfNotExists(p Path, v Operand) Operand {
+	return functionOperand{"if_not_exists", []Operand{p, v}}
+}
+
+// ListAppend returns an operand of the concatenation of two lists. It can
+// only be used in updates.
+func ListAppend(a, b Operand) Operand {
+	return functionOperand{"list_append", []Operand{a, b}}
+}
+
+type arithmeticOperand struct {
+	op   string
+	a, b Operand
+}
+
+func (o arithmeticOperand) operand(b *expressionBuilder) string {
+	return o.a.operand(b) + " " + o.op + " " + o.b.operand(b)
+}
+
+// Plus returns an operand of the sum of two numbers. It can only be used
+// in updates.
+func Plus(a, b Operand) Operand {
+	return arithmeticOperand{"+", a, b}
+}
+
+// Minus returns an operand of the difference of two numbers. It can only
+// be used in updates.
+func Minus(a, b Operand) Operand {
+	return arithmeticOperand{"-", a, b}
+}
+
+type comparisonCondition struct {
+	op   string
+	a, b Operand
+}
+
+func (c comparisonCondition) condition(b *expressionBuilder) string {
+	return c.a.operand(b) + " " + c.op + " " + c.b.operand(b)
+}
+
+func Equal(a, b Operand) Condition {
+	return comparisonCondition{"=", a, b}
+}
+
+func NotEqual(a, b Operand) Condition {
+	return comparisonCondition{"<>", a, b}
+}
+
+func LessThan(a, b Operand) Condition {
+	return comparisonCondition{"<", a, b}
+}
+
+func LessThanOrEqual(a, b Operand) Condition {
+	return comparisonCondition{"<=", a, b}
+}
+
+func GreaterThan(a, b Operand) Condition {
+	return comparisonCondition{">", a, b}
+}
+
+func GreaterThanOrEqual(a, b Operand) Condition {
+	return comparisonCondition{">=", a, b}
+}
+
+type betweenCondition struct {
+	a, lo, hi Operand
+}
+
+func (c betweenCondition) condition(b *expressionBuilder) string {
+	return c.a.operand(b) + " BETWEEN " + c.lo.operand(b) + " AND " + c.hi.operand(b)
+}
+
+// Between is true if a is greater than or equal to lo, and less than or
+// equal to hi.
+func Between(a, lo, hi Operand) Condition {
+	return betweenCondition{a, lo, hi}
+}
+
+type inCondition struct {
+	a      Operand
+	values []Operand
+}
+
+func (c inCondition) condition(b *expressionBuilder) string {
+	values := make([]string, len(c.values))
+	for i, v := range c.values {
+		values[i] = v.operand(b)
+	}
+	return c.a.operand(b) + " IN (" + strings.Join(values, ", ") + ")"
+}
+
+// In is true if a is equal to any of values.
+func In(a Operand, values ...Operand) Condition {
+	return inCondition{a, values}
+}
+
+type functionCondition functionOperand
+
+func (c functionCondition) condition(b *expressionBuilder) string {
+	return functionOperand(c).operand(b)
+}
+
+func AttributeExists(p Path) Condition {
+	return functionCondition{"attribute_exists", []Operand{p}}
+}
+
+func AttributeNotExists(p Path) Condition {
+	return functionCondition{"attribute_not_exists", []Operand{p}}
+}
+
+// AttributeType is true if the attribute at p is of type typ, one of the
+// TYPE_* constants.
+func AttributeType(p Path, typ string) Condition {
+	return functionCondition{"attribute_type", []Operand{p, Value(NewStringAttribute("", typ))}}
+}
+
+// BeginsWith is true if the string or binary attribute at p begins with
+// prefix.
+func BeginsWith(p Path, prefix Operand) Condition {
+	return functionCondition{"begins_with", []Operand{p, prefix}}
+}
+
+// Contains is true if the attribute at p is a string containing v, or a
+// set or a list with an element v.
+func Contains(p Path, v Operand) Condition {
+	return functionCondition{"contains", []Operand{p, v}}
+}
+
+type logicalCondition struct {
+	op         string
+	conditions []Condition
+}
+
+func (c logicalCondition) condition(b *expressionBuilder) string {
+	conditions := make([]string, len(c.conditions))
+	for i, cond := range c.conditions {
+		conditions[i] = cond.condition(b)
+	}
+	return "(" + strings.Join(conditions, " "+c.op+" ") + ")"
+}
+
+// And is true if all of conditions are.
+func And(conditions ...Condition) Condition {
+	return logicalCondition{"AND", conditions}
+}
+
+// Or is true if any of conditions is.
+func Or(conditions ...Condition) Condition {
+	return logicalCondition{"OR", conditions}
+}
+
+type notCondition struct {
+	c Condition
+}
+
+func (c notCondition) condition(b *expressionBuilder) string {
+	return "NOT (" + c.c.condition(b) + ")"
+}
+
+func Not(c Condition) Condition {
+	return notCondition{c}
+}
+
+// An Update is a list of actions on the attributes of an item.
+type Update struct {
+	set, remove, add, delete []func(b *expressionBuilder) string
+}
+
+func NewUpdate() *Update {
+	return &Update{}
+}
+
+// Set sets the attribute at p to v.
+func (u *Update) Set(p Path, v Operand) *Update {
+	u.set = append(u.set, func(b *expressionBuilder) string {
+		return p.operand(b) + " = " + v.operand(b)
+	})
+	return u
+}
+
+// Remove removes the attribute at p.
+func (u *Update) Remove(p Path) *Update {
+	u.remove = append(u.remove, p.operand)
+	return u
+}
+
+// Add adds the number v to the number at p, or the elements of the set v
+// to the set at p.
+func (u *Update) Add(p Path, v *Attribute) *Update {
+	u.add = append(u.add, func(b *expressionBuilder) string {
+		return p.operand(b) + " " + b.value(v)
+	})
+	return u
+}
+
+// Delete removes the elements of the set v from the set at p.
+func (u *Update) Delete(p Path, v *Attribute) *Update {
+	u.delete = append(u.delete, func(b *expressionBuilder) string {
+		return p.operand(b) + " " + b.value(v)
+	})
+	return u
+}
+
+func buildCondition(prefix string, c Condition) *Expression {
+	b := newExpressionBuilder(prefix)
+	b.expr.Text = c.condition(b)
+	return b.expr
+}
+
+// NewConditionExpression returns a condition expression, for
+// UntypedQuery.AddConditionExpression.
+func NewConditionExpression(c Condition) *Expression {
+	return buildCondition("c", c)
+}
+
+// NewFilterExpression returns a filter expression, for
+// UntypedQuery.AddFilterExpression.
+func NewFilterExpression(c Condition) *Expression {
+	return buildCondition("f", c)
+}
+
+// NewKeyConditionExpression returns a key condition expression, for
+// UntypedQuery.AddKeyConditionExpression. It must be an Equal condition
+// on the hash key, or an And of it and a condition on the range key.
+func NewKeyConditionExpression(c Condition) *Expression {
+	return buildCondition("k", c)
+}
+
+// NewUpdateExpression returns an update expression, for
+// UntypedQuery.AddUpdateExpression.
+func NewUpdateExpression(u *Update) *Expression {
+	b := newExpressionBuilder("u")
+	var sections []string
+	for _, s := range []struct {
+		name    string
+		actions []func(b *expressionBuilder) string
+	}{
+		{"SET", u.set},
+		{"REMOVE", u.remove},
+		{"ADD", u.add},
+		{"DELETE", u.delete},
+	} {
+		if len(s.actions) == 0 {
+			continue
+		}
+		actions := make([]string, len(s.actions))
+		for i, action := range s.actions {
+			actions[i] = action(b)
+		}
+		sections = append(sections, s.name+" "+strings.Join(actions, ", "))
+	}
+	b.expr.Text = strings.Join(sections, " ")
+	return b.expr
+}
+
+// NewProjectionExpression returns a projection expression, for
+// UntypedQuery.AddProjectionExpression.
+func NewProjectionExpression(paths ...Path) *Expression {
+	b := newExpressionBuilder("p")
+	projected := make([]string, len(paths))
+	for i, p := range paths {
+		projected[i] = p.operand(b)
+	}
+	b.expr.Text = strings.Join(projected, ", ")
+	return b.expr
+}
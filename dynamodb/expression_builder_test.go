@@ -0,0 +1,123 @@
+package dynamodb
+
+import (
+	"gopkg.in/check.v1"
+)
+
+type ExpressionBuilderSuite struct{}
+
+var _ = check.Suite(&ExpressionBuilderSuite{})
+
+func (s *ExpressionBuilderSuite) TestCondition(c *check.C) {
+	e := NewConditionExpression(And(
+		AttributeExists(Name("Owner")),
+		Or(
+			LessThan(Size(Name("Tags")), Value(NewNumericAttribute("ignored", "3"))),
+			Not(Contains(Name("Tags"), Value(NewStringAttribute("", "full")))),
+		),
+		Between(Name("Address").Field("Zip"), Value(NewStringAttribute("", "10000")), Value(NewStringAttribute("", "19999"))),
+		In(Name("Items").Index(2).Field("Owner"), Value(NewStringAttribute("", "a")), Value(NewStringAttribute("", "b"))),
+	))
+	c.Check(e.Text, check.Equals, "(attribute_exists(#c0) AND (size(#c1) < :c0 OR NOT (contains(#c1, :c1))) AND #c2.#c3 BETWEEN :c2 AND :c3 AND #c4[2].#c0 IN (:c4, :c5))")
+	c.Check(e.AttributeNames, check.DeepEquals, map[string]string{
+		"#c0": "Owner",
+		"#c1": "Tags",
+		"#c2": "Address",
+		"#c3": "Zip",
+		"#c4": "Items",
+	})
+	c.Check(e.AttributeValues, check.DeepEquals, []Attribute{
+		*NewNumericAttribute(":c0", "3"),
+		*NewStringAttribute(":c1", "full"),
+		*NewStringAttribute(":c2", "10000"),
+		*NewStringAttribute(":c3", "19999"),
+		*NewStringAttribute(":c4", "a"),
+		*NewStringAttribute(":c5", "b"),
+	})
+
+	e = NewFilterExpression(AttributeType(Name("a.b"), TYPE_NUMBER))
+	c.Check(e.Text, check.Equals, "attribute_type(#f0, :f0)")
+	c.Check(e.AttributeNames, check.DeepEquals, map[string]string{"#f0": "a.b"})
+	c.Check(e.AttributeValues, check.DeepEquals, []Attribute{*NewStringAttribute(":f0", "N")})
+}
+
+func (s *ExpressionBuilderSuite) TestUpdate(c *check.C) {
+	one := NewNumericAttribute("", "1")
+	u := NewUpdate().
+		Delete(Name("Colors"), NewStringSetAttribute("", []string{"red"})).
+		Set(Name("Count"), Plus(IfNotExists(Name("Count"), Value(one)), Value(one))).
+		Remove(Name("Tags").Index(0)).
+		Set(Name("Tags"), ListAppend(Name("Tags"), Value(NewListAttribute("", nil)))).
+		Add(Name("Visits"), one)
+	e := NewUpdateExpression(u)
+	c.Check(e.Text, check.Equals, "SET #u0 = if_not_exists(#u0, :u0) + :u1, #u1 = list_append(#u1, :u2) REMOVE #u1[0] ADD #u2 :u3 DELETE #u3 :u4")
+	c.Check(e.AttributeNames, check.DeepEquals, map[string]string{
+		"#u0": "Count",
+		"#u1": "Tags",
+		"#u2": "Visits",
+		"#u3": "Colors",
+	})
+	c.Check(e.AttributeValues, check.HasLen, 5)
+	c.Check(e.AttributeValues[4], check.DeepEquals, *NewStringSetAttribute(":u4", []string{"red"}))
+	c.Check(one.Name, check.Equals, "")
+}
+
+func (s *ExpressionBuilderSuite) TestProjection(c *check.C) {
+	e := NewProjectionExpression(Name("Name"), Name("Address").Field("City"), Name("Tags").Index(1))
+	c.Check(e.Text, check.Equals, "#p0, #p1.#p2, #p3[1]")
+	c.Check(e.AttributeNames, check.DeepEquals, map[string]string{
+		"#p0": "Name",
+		"#p1": "Address",
+		"#p2": "City",
+		"#p3": "Tags",
+	})
+	c.Check(e.AttributeValues, check.HasLen, 0)
+}
+
+func (s *ExpressionBuilderSuite) TestPathsAreImmutable(c *check.C) {
+	base := Name("a").Field("b")
+	x := base.Field("x")
+	y := base.Field("y")
+	e := NewProjectionExpression(x, y)
+	c.Check(e.Text, check.Equals, "#p0.#p1.#p2, #p0.#p1.#p3")
+}
+
+func (s *ItemSuite) TestExpressionBuilder(c *check.C) {
+	var rk string
+	if s.WithRange {
+		rk = "1"
+	}
+	pk := &Key{HashKey: "NewHashKeyVal", RangeKey: rk}
+	attrs := []Attribute{*NewNumericAttribute("Count", "1")}
+	if ok, err := s.table.PutItem(pk.HashKey, rk, attrs); !ok {
+		c.Fatal(err)
+	}
+
+	update := NewUpdateExpression(NewUpdate().
+		Set(Name("Count"), Plus(Name("Count"), Value(NewNumericAttribute("", "1")))).
+		Set(Name("Tags"), ListAppend(IfNotExists(Name("Tags"), Value(NewListAttribute("", nil))), Value(NewListAttribute("", []*Attribute{NewStringAttribute("", "new")})))).
+		Add(Name("Colors"), NewStringSetAttribute("", []string{"red", "blue"})))
+	cond := NewConditionExpression(And(
+		LessThan(Name("Count"), Value(NewNumericAttribute("", "2"))),
+		AttributeNotExists(Name("Tags")),
+	))
+	ok, err := s.table.UpdateExpressionUpdateAttributes(pk, cond, update)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+
+	// The condition no longer holds.
+	ok, err = s.table.UpdateExpressionUpdateAttributes(pk, cond, update)
+	c.Assert(ok, check.Equals, false)
+	c.Assert(err, check.ErrorMatches, "ConditionalCheckFailedException.*")
+
+	q := NewQuery(s.table)
+	q.AddKeyConditionExpression(NewKeyConditionExpression(Equal(Name("TestHashKey"), Value(NewStringAttribute("", pk.HashKey)))))
+	q.AddFilterExpression(NewFilterExpression(Contains(Name("Colors"), Value(NewStringAttribute("", "red")))))
+	q.AddProjectionExpression(NewProjectionExpression(Name("TestHashKey"), Name("Count"), Name("Tags").Index(0)))
+	out, _, err := s.table.QueryTable(q)
+	c.Assert(err, check.IsNil)
+	c.Assert(out, check.HasLen, 1)
+	c.Check(out[0]["Count"], check.DeepEquals, NewNumericAttribute("Count", "2"))
+	c.Check(out[0]["Tags"].ListValues, check.HasLen, 1)
+	c.Check(out[0]["Colors"], check.IsNil)
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"reflect"
+	"sort"
 	"strconv"
 
 	"github.com/AdRoll/goamz/dynamodb/dynamizer"
@@ -275,18 +277,33 @@ func (t *Table) putItem(hashKey, rangeKey string, attributes, expected []Attribu
 	return true, nil
 }
 
+// PutDocument writes the struct or map data as the item with the given key.
+//
+// If data has a version attribute (see dynamizer.Version), the item is
+// written only if the version of the stored item is the version of data,
+// or if there is no stored item and the version of data is 0, and
+// ErrVersionConflict is returned otherwise. The version is incremented in
+// the written item, and in data if it is a pointer.
 func (t *Table) PutDocument(key *Key, data interface{}) error {
 	item, err := dynamizer.ToDynamo(data)
 	if err != nil {
 		return err
 	}
+	versionName, version, err := dynamizer.Version(data)
+	if err != nil {
+		return err
+	}
 
 	q := NewDynamoQuery(t)
 	q.AddItem(key, item)
+	if versionName != "" {
+		item[versionName] = &dynamizer.DynamoAttribute{N: strconv.FormatInt(version+1, 10)}
+		q.AddConditionExpression(versionCondition(versionName, version))
+	}
 
 	jsonResponse, err := t.Server.queryServer(target("PutItem"), q)
 	if err != nil {
-		return err
+		return versionError(err, versionName)
 	}
 
 	// A successful PUT returns an empty JSON object. Simply checking for valid
@@ -297,7 +314,91 @@ func (t *Table) PutDocument(key *Key, data interface{}) error {
 		return err
 	}
 
-	return nil
+	return setVersion(data, versionName, version+1)
+}
+
+// UpdateDocument updates the item with the given key with the attributes
+// of the struct or map data, other than the key attributes, leaving the
+// other attributes of the stored item as they are. The item is created if
+// it does not exist. Versions are checked and incremented as by
+// PutDocument.
+func (t *Table) UpdateDocument(key *Key, data interface{}) error {
+	item, err := dynamizer.ToDynamo(data)
+	if err != nil {
+		return err
+	}
+	versionName, version, err := dynamizer.Version(data)
+	if err != nil {
+		return err
+	}
+	t.deleteKeyFromItem(item)
+	if versionName != "" {
+		item[versionName] = &dynamizer.DynamoAttribute{N: strconv.FormatInt(version+1, 10)}
+	}
+	if len(item) == 0 {
+		return errors.New("At least one attribute is required.")
+	}
+
+	names := make([]string, 0, len(item))
+	for name := range item {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	update := NewUpdate()
+	for _, name := range names {
+		if a := attributeFromDynamo(name, item[name]); a != nil {
+			update.Set(Name(name), Value(a))
+		}
+	}
+
+	q := NewQuery(t)
+	q.AddKey(key)
+	q.AddUpdateExpression(NewUpdateExpression(update))
+	if versionName != "" {
+		q.AddConditionExpression(versionCondition(versionName, version))
+	}
+
+	jsonResponse, err := t.Server.queryServer(target("UpdateItem"), q)
+	if err != nil {
+		return versionError(err, versionName)
+	}
+
+	_, err = simplejson.NewJson(jsonResponse)
+	if err != nil {
+		return err
+	}
+
+	return setVersion(data, versionName, version+1)
+}
+
+// versionCondition returns the condition that the stored item has the
+// given version, or does not exist if it is 0.
+func versionCondition(name string, version int64) *Expression {
+	if version == 0 {
+		return NewConditionExpression(AttributeNotExists(Name(name)))
+	}
+	return NewConditionExpression(Equal(Name(name), Value(NewNumericAttribute("", strconv.FormatInt(version, 10)))))
+}
+
+// versionError returns ErrVersionConflict for the failed conditions of
+// versioned writes, and err otherwise.
+func versionError(err error, versionName string) error {
+	if e, ok := err.(*Error); ok && versionName != "" && e.Code == "ConditionalCheckFailedException" {
+		return ErrVersionConflict
+	}
+	return err
+}
+
+// setVersion sets the version of data if it is a pointer to a versioned
+// struct.
+func setVersion(data interface{}, versionName string, version int64) error {
+	if versionName == "" {
+		return nil
+	}
+	if rv := reflect.ValueOf(data); rv.Kind() != reflect.Ptr {
+		return nil
+	}
+	return dynamizer.SetVersion(data, version)
 }
 
 func (t *Table) deleteItem(key *Key, expected []Attribute, condition *Expression) (bool, error) {
@@ -338,13 +439,34 @@ func (t *Table) ConditionExpressionDeleteItem(key *Key, condition *Expression) (
 	return t.deleteItem(key, nil, condition)
 }
 
+// DeleteDocument deletes the item with the given key, whatever its
+// version. Use DeleteVersionedDocument to delete versioned documents.
 func (t *Table) DeleteDocument(key *Key) error {
+	return t.deleteDocument(key, "", 0)
+}
+
+// DeleteVersionedDocument deletes the item with the given key if its
+// version is the version of the struct data (see dynamizer.Version), and
+// returns ErrVersionConflict otherwise. It is DeleteDocument if data has
+// no version attribute.
+func (t *Table) DeleteVersionedDocument(key *Key, data interface{}) error {
+	versionName, version, err := dynamizer.Version(data)
+	if err != nil {
+		return err
+	}
+	return t.deleteDocument(key, versionName, version)
+}
+
+func (t *Table) deleteDocument(key *Key, versionName string, version int64) error {
 	q := NewDynamoQuery(t)
 	q.AddKey(key)
+	if versionName != "" {
+		q.AddConditionExpression(versionCondition(versionName, version))
+	}
 
 	jsonResponse, err := t.Server.queryServer(target("DeleteItem"), q)
 	if err != nil {
-		return err
+		return versionError(err, versionName)
 	}
 
 	// A successful DELETE returns an empty JSON object. Simply checking for
@@ -362,6 +484,9 @@ func (t *Table) AddAttributes(key *Key, attributes []Attribute) (bool, error) {
 	return t.modifyAttributes(key, attributes, nil, nil, nil, "ADD")
 }
 
+// UpdateAttributes sets attributes of the item with the given key. It
+// neither checks nor increments the version of versioned documents: use
+// UpdateDocument for that.
 func (t *Table) UpdateAttributes(key *Key, attributes []Attribute) (bool, error) {
 	return t.modifyAttributes(key, attributes, nil, nil, nil, "PUT")
 }
@@ -0,0 +1,254 @@
+// Package lock implements distributed locks, or leases, on the items of a
+// DynamoDB table, for instance to elect the leader of a group of workers.
+//
+// The lock table must have a string hash key and no range key, which is
+// the name of the locks. The item of a lock that is held records its
+// owner, a token that identifies the lease, and the time at which the
+// lease expires, which the owner pushes back with heartbeats as long as it
+// holds the lock:
+//
+//	client := lock.New(table, "worker-1", 30*time.Second)
+//	l, err := client.Acquire("daily-report", time.Minute)
+//	if err != nil {
+//		return err
+//	}
+//	defer l.Release()
+//
+// A lock whose lease expired, because its owner stopped or could not reach
+// DynamoDB, may be acquired by another owner. The clocks of the owners are
+// compared to expire the leases, so they should be kept in sync, to well
+// under the lease duration. An owner that loses its lock is notified by
+// the Lost channel of the Lock.
+package lock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AdRoll/goamz/dynamodb"
+)
+
+// The attributes of the items of the locks.
+const (
+	OwnerAttribute   = "Owner"
+	TokenAttribute   = "Token"
+	ExpiresAttribute = "Expires" // in milliseconds since the Unix epoch.
+)
+
+var (
+	// ErrLockHeld is returned when a lock is held by another owner, whose
+	// lease did not expire.
+	ErrLockHeld = errors.New("lock: the lock is held by another owner")
+
+	// ErrLockLost is returned when a lock was acquired by another owner
+	// after its lease expired.
+	ErrLockLost = errors.New("lock: the lease of the lock was lost")
+)
+
+// A Client acquires locks on the items of a table, for an owner.
+type Client struct {
+	Table *dynamodb.Table
+
+	// Owner identifies the owner of the locks, for instance with the
+	// name of its host and its process ID.
+	Owner string
+
+	// LeaseDuration is the duration of the leases, of at least a
+	// millisecond. The locks of an owner that stops sending heartbeats
+	// expire after it.
+	LeaseDuration time.Duration
+
+	// HeartbeatPeriod is the time between the heartbeats that extend the
+	// leases of the locks held, which must be well under LeaseDuration. It
+	// defaults to a third of LeaseDuration.
+	HeartbeatPeriod time.Duration
+
+	// RetryPeriod is the time between the attempts of Acquire. It defaults
+	// to a tenth of LeaseDuration.
+	RetryPeriod time.Duration
+}
+
+// New returns a client for the locks of table, whose heartbeats are sent
+// three times per lease and whose attempts to acquire locks are retried
+// ten times per lease.
+func New(table *dynamodb.Table, owner string, leaseDuration time.Duration) *Client {
+	return &Client{
+		Table:           table,
+		Owner:           owner,
+		LeaseDuration:   leaseDuration,
+		HeartbeatPeriod: leaseDuration / 3,
+		RetryPeriod:     leaseDuration / 10,
+	}
+}
+
+// heartbeatPeriod returns HeartbeatPeriod, or its default if it is not
+// positive.
+func (c *Client) heartbeatPeriod() time.Duration {
+	if c.HeartbeatPeriod <= 0 {
+		return c.LeaseDuration / 3
+	}
+	return c.HeartbeatPeriod
+}
+
+// retryPeriod returns RetryPeriod, or its default if it is not positive.
+func (c *Client) retryPeriod() time.Duration {
+	if c.RetryPeriod <= 0 {
+		return c.LeaseDuration / 10
+	}
+	return c.RetryPeriod
+}
+
+// Acquire acquires the named lock, retrying every RetryPeriod while it is
+// held by another owner, until timeout. It returns ErrLockHeld if the
+// lock is still held then.
+func (c *Client) Acquire(name string, timeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		l, err := c.TryAcquire(name)
+		if err != ErrLockHeld || !time.Now().Add(c.retryPeriod()).Before(deadline) {
+			return l, err
+		}
+		time.Sleep(c.retryPeriod())
+	}
+}
+
+// TryAcquire acquires the named lock if it is not held, or if its lease
+// expired, and returns ErrLockHeld otherwise.
+func (c *Client) TryAcquire(name string) (*Lock, error) {
+	if c.LeaseDuration < time.Millisecond {
+		return nil, errors.New("lock: the lease duration must be at least a millisecond")
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	expires := now.Add(c.LeaseDuration)
+	attributes := []dynamodb.Attribute{
+		*dynamodb.NewStringAttribute(OwnerAttribute, c.Owner),
+		*dynamodb.NewStringAttribute(TokenAttribute, token),
+		*millisAttribute(ExpiresAttribute, expires),
+	}
+	keyName := c.Table.Key.KeyAttribute.Name
+	condition := dynamodb.NewConditionExpression(dynamodb.Or(
+		dynamodb.AttributeNotExists(dynamodb.Name(keyName)),
+		dynamodb.LessThan(dynamodb.Name(ExpiresAttribute), dynamodb.Value(millisAttribute("", now))),
+	))
+	if _, err := c.Table.ConditionExpressionPutItem(name, "", attributes, condition); err != nil {
+		if conditionFailed(err) {
+			return nil, ErrLockHeld
+		}
+		return nil, err
+	}
+
+	l := &Lock{
+		Name:    name,
+		client:  c,
+		token:   token,
+		expires: expires,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		lost:    make(chan struct{}),
+	}
+	go l.heartbeat()
+	return l, nil
+}
+
+// A Lock is a lock held by a Client, whose lease is extended until it is
+// released or lost.
+type Lock struct {
+	Name string
+
+	client   *Client
+	token    string
+	expires  time.Time // only accessed by heartbeat until it is done.
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{} // closed when heartbeat returns.
+	lost     chan struct{}
+}
+
+// Lost returns a channel that is closed when the lock is lost: when
+// another owner acquired it, or when its lease expired before a heartbeat
+// could extend it.
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Release stops the heartbeats and deletes the item of the lock, unless
+// another owner acquired it meanwhile, in which case it returns
+// ErrLockLost.
+func (l *Lock) Release() error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+
+	key := &dynamodb.Key{HashKey: l.Name}
+	if _, err := l.client.Table.ConditionExpressionDeleteItem(key, l.held()); err != nil {
+		if conditionFailed(err) {
+			return ErrLockLost
+		}
+		return err
+	}
+	return nil
+}
+
+// heartbeat extends the lease every HeartbeatPeriod until the lock is
+// released or lost. The failed heartbeats are retried with the next ones,
+// until the lease expires.
+func (l *Lock) heartbeat() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.client.heartbeatPeriod())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		expires := time.Now().Add(l.client.LeaseDuration)
+		key := &dynamodb.Key{HashKey: l.Name}
+		update := dynamodb.NewUpdateExpression(dynamodb.NewUpdate().Set(
+			dynamodb.Name(ExpiresAttribute),
+			dynamodb.Value(millisAttribute("", expires)),
+		))
+		_, err := l.client.Table.UpdateExpressionUpdateAttributes(key, l.held(), update)
+		switch {
+		case err == nil:
+			l.expires = expires
+		case conditionFailed(err) || time.Now().After(l.expires):
+			close(l.lost)
+			return
+		}
+	}
+}
+
+// held returns the condition that the lock is still held with this lease.
+func (l *Lock) held() *dynamodb.Expression {
+	return dynamodb.NewConditionExpression(dynamodb.Equal(
+		dynamodb.Name(TokenAttribute),
+		dynamodb.Value(dynamodb.NewStringAttribute("", l.token)),
+	))
+}
+
+func millisAttribute(name string, t time.Time) *dynamodb.Attribute {
+	return dynamodb.NewNumericAttribute(name, strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10))
+}
+
+func conditionFailed(err error) bool {
+	e, ok := err.(*dynamodb.Error)
+	return ok && e.Code == "ConditionalCheckFailedException"
+}
+
+// newToken returns a random token, which identifies a lease.
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,160 @@
+package lock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/dynamodb"
+	"github.com/AdRoll/goamz/dynamodb/dynamodbtest"
+	"github.com/AdRoll/goamz/dynamodb/lock"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type S struct {
+	srv   *dynamodbtest.Server
+	table *dynamodb.Table
+}
+
+var _ = check.Suite(&S{})
+
+var tableDescription = dynamodb.TableDescriptionT{
+	TableName:            "Locks",
+	AttributeDefinitions: []dynamodb.AttributeDefinitionT{{Name: "Name", Type: "S"}},
+	KeySchema:            []dynamodb.KeySchemaT{{AttributeName: "Name", KeyType: "HASH"}},
+	ProvisionedThroughput: dynamodb.ProvisionedThroughputT{
+		ReadCapacityUnits:  1,
+		WriteCapacityUnits: 1,
+	},
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	srv, err := dynamodbtest.NewServer(&dynamodbtest.Config{})
+	c.Assert(err, check.IsNil)
+	s.srv = srv
+	region := aws.Region{DynamoDBEndpoint: srv.URL()}
+	server := dynamodb.New(aws.Auth{AccessKey: "key", SecretKey: "secret"}, region)
+	_, err = server.CreateTable(tableDescription)
+	c.Assert(err, check.IsNil)
+	pk, err := tableDescription.BuildPrimaryKey()
+	c.Assert(err, check.IsNil)
+	s.table = server.NewTable(tableDescription.TableName, pk)
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	s.srv.Quit()
+}
+
+func (s *S) TestAcquireRelease(c *check.C) {
+	alice := lock.New(s.table, "alice", time.Second)
+	bob := lock.New(s.table, "bob", time.Second)
+
+	l, err := alice.TryAcquire("leader")
+	c.Assert(err, check.IsNil)
+	item, err := s.table.GetItem(&dynamodb.Key{HashKey: "leader"})
+	c.Assert(err, check.IsNil)
+	c.Assert(item[lock.OwnerAttribute].Value, check.Equals, "alice")
+
+	_, err = bob.TryAcquire("leader")
+	c.Assert(err, check.Equals, lock.ErrLockHeld)
+	start := time.Now()
+	_, err = bob.Acquire("leader", 300*time.Millisecond)
+	c.Assert(err, check.Equals, lock.ErrLockHeld)
+	c.Assert(time.Since(start) >= 200*time.Millisecond, check.Equals, true)
+
+	// Other locks are independent.
+	other, err := bob.TryAcquire("other")
+	c.Assert(err, check.IsNil)
+	c.Assert(other.Release(), check.IsNil)
+
+	// The lock is acquired as soon as it is released.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		l.Release()
+	}()
+	l, err = bob.Acquire("leader", time.Second)
+	c.Assert(err, check.IsNil)
+	c.Assert(l.Release(), check.IsNil)
+	_, err = s.table.GetItem(&dynamodb.Key{HashKey: "leader"})
+	c.Assert(err, check.Equals, dynamodb.ErrNotFound)
+}
+
+func (s *S) TestHeartbeat(c *check.C) {
+	alice := lock.New(s.table, "alice", 200*time.Millisecond)
+	bob := lock.New(s.table, "bob", 200*time.Millisecond)
+
+	l, err := alice.TryAcquire("leader")
+	c.Assert(err, check.IsNil)
+	defer l.Release()
+
+	// The heartbeats keep the lock held past its first lease.
+	_, err = bob.Acquire("leader", 500*time.Millisecond)
+	c.Assert(err, check.Equals, lock.ErrLockHeld)
+	select {
+	case <-l.Lost():
+		c.Fatal("lost the lock")
+	default:
+	}
+}
+
+func (s *S) TestSteal(c *check.C) {
+	// Alice stops sending heartbeats, as if she had crashed.
+	alice := lock.New(s.table, "alice", 100*time.Millisecond)
+	alice.HeartbeatPeriod = time.Hour
+	bob := lock.New(s.table, "bob", time.Second)
+
+	l, err := alice.TryAcquire("leader")
+	c.Assert(err, check.IsNil)
+	stolen, err := bob.Acquire("leader", time.Second)
+	c.Assert(err, check.IsNil)
+	item, err := s.table.GetItem(&dynamodb.Key{HashKey: "leader"})
+	c.Assert(err, check.IsNil)
+	c.Assert(item[lock.OwnerAttribute].Value, check.Equals, "bob")
+
+	c.Assert(l.Release(), check.Equals, lock.ErrLockLost)
+	c.Assert(stolen.Release(), check.IsNil)
+}
+
+func (s *S) TestLost(c *check.C) {
+	alice := lock.New(s.table, "alice", 100*time.Millisecond)
+	alice.HeartbeatPeriod = 20 * time.Millisecond
+
+	l, err := alice.TryAcquire("leader")
+	c.Assert(err, check.IsNil)
+
+	// The lock is taken over behind Alice's back.
+	_, err = s.table.UpdateAttributes(&dynamodb.Key{HashKey: "leader"}, []dynamodb.Attribute{*dynamodb.NewStringAttribute(lock.TokenAttribute, "other")})
+	c.Assert(err, check.IsNil)
+	select {
+	case <-l.Lost():
+	case <-time.After(time.Second):
+		c.Fatal("the lock was not lost")
+	}
+	c.Assert(l.Release(), check.Equals, lock.ErrLockLost)
+}
+
+func (s *S) TestZeroPeriods(c *check.C) {
+	_, err := (&lock.Client{Table: s.table, Owner: "alice"}).TryAcquire("leader")
+	c.Assert(err, check.ErrorMatches, "lock: the lease duration must be at least a millisecond")
+
+	// The zero periods default as with New.
+	alice := &lock.Client{Table: s.table, Owner: "alice", LeaseDuration: 200 * time.Millisecond}
+	bob := &lock.Client{Table: s.table, Owner: "bob", LeaseDuration: 200 * time.Millisecond}
+	l, err := alice.TryAcquire("leader")
+	c.Assert(err, check.IsNil)
+	defer l.Release()
+
+	start := time.Now()
+	_, err = bob.Acquire("leader", 500*time.Millisecond)
+	c.Assert(err, check.Equals, lock.ErrLockHeld)
+	c.Assert(time.Since(start) >= 400*time.Millisecond, check.Equals, true)
+	select {
+	case <-l.Lost():
+		c.Fatal("lost the lock")
+	default:
+	}
+}
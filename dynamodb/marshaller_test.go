@@ -1,8 +1,11 @@
 package dynamodb
 
 import (
-	"gopkg.in/check.v1"
+	"fmt"
 	"time"
+
+	"github.com/AdRoll/goamz/dynamodb/dynamizer"
+	"gopkg.in/check.v1"
 )
 
 type TestSubStruct struct {
@@ -114,6 +117,117 @@ func testObjectWithEmptySets() *TestStruct {
 }
 
 func testAttrs() []Attribute {
+	return []Attribute{
+		Attribute{Type: "BOOL", Name: "TestBool", Value: "true"},
+		Attribute{Type: "S", Name: "TestByteArray", Value: "Ynl0ZXM="},
+		Attribute{Type: "N", Name: "TestFloat32", Value: "9.9999"},
+		Attribute{Type: "N", Name: "TestFloat64", Value: "99.999999"},
+		Attribute{Type: "L", Name: "TestFloatArray", ListValues: []*Attribute{
+			&Attribute{Type: "N", Value: "0.1"},
+			&Attribute{Type: "N", Value: "1.1"},
+			&Attribute{Type: "N", Value: "1.2"},
+			&Attribute{Type: "N", Value: "1.23"},
+			&Attribute{Type: "N", Value: "1.234"},
+			&Attribute{Type: "N", Value: "1.2345"},
+		}},
+		Attribute{Type: "N", Name: "TestInt", Value: "-99"},
+		Attribute{Type: "N", Name: "TestInt32", Value: "999"},
+		Attribute{Type: "N", Name: "TestInt64", Value: "9999"},
+		Attribute{Type: "L", Name: "TestInt8Array", ListValues: []*Attribute{
+			&Attribute{Type: "N", Value: "0"},
+			&Attribute{Type: "N", Value: "1"},
+			&Attribute{Type: "N", Value: "12"},
+			&Attribute{Type: "N", Value: "123"},
+		}},
+		Attribute{Type: "L", Name: "TestIntArray", ListValues: []*Attribute{
+			&Attribute{Type: "N", Value: "0"},
+			&Attribute{Type: "N", Value: "1"},
+			&Attribute{Type: "N", Value: "12"},
+			&Attribute{Type: "N", Value: "123"},
+			&Attribute{Type: "N", Value: "1234"},
+			&Attribute{Type: "N", Value: "12345"},
+		}},
+		Attribute{Type: "S", Name: "TestString", Value: "test"},
+		Attribute{Type: "L", Name: "TestStringArray", ListValues: []*Attribute{
+			&Attribute{Type: "S", Value: "test1"},
+			&Attribute{Type: "S", Value: "test2"},
+			&Attribute{Type: "S", Value: "test3"},
+			&Attribute{Type: "S", Value: "test4"},
+		}},
+		Attribute{Type: "M", Name: "TestSub", MapValues: map[string]*Attribute{
+			"SubBool":   &Attribute{Type: "BOOL", Name: "SubBool", Value: "true"},
+			"SubInt":    &Attribute{Type: "N", Name: "SubInt", Value: "2"},
+			"SubString": &Attribute{Type: "S", Name: "SubString", Value: "subtest"},
+			"SubStringArray": &Attribute{Type: "L", Name: "SubStringArray", ListValues: []*Attribute{
+				&Attribute{Type: "S", Value: "sub1"},
+				&Attribute{Type: "S", Value: "sub2"},
+				&Attribute{Type: "S", Value: "sub3"},
+			}},
+		}},
+		Attribute{Type: "N", Name: "TestUint", Value: "99"},
+	}
+}
+
+func testAttrsTime() []Attribute {
+	return []Attribute{
+		Attribute{Type: "S", Name: "TestTime", Value: "2003-03-03T17:03:00Z"},
+	}
+}
+
+func testAttrsWithZeroValues() []Attribute {
+	return []Attribute{
+		Attribute{Type: "BOOL", Name: "TestBool", Value: "false"},
+		Attribute{Type: "NULL", Name: "TestByteArray", Value: "true"},
+		Attribute{Type: "N", Name: "TestFloat32", Value: "0"},
+		Attribute{Type: "N", Name: "TestFloat64", Value: "0"},
+		Attribute{Type: "NULL", Name: "TestFloatArray", Value: "true"},
+		Attribute{Type: "N", Name: "TestInt", Value: "0"},
+		Attribute{Type: "N", Name: "TestInt32", Value: "0"},
+		Attribute{Type: "N", Name: "TestInt64", Value: "0"},
+		Attribute{Type: "NULL", Name: "TestInt8Array", Value: "true"},
+		Attribute{Type: "NULL", Name: "TestIntArray", Value: "true"},
+		Attribute{Type: "S", Name: "TestString", Value: ""},
+		Attribute{Type: "NULL", Name: "TestStringArray", Value: "true"},
+		Attribute{Type: "M", Name: "TestSub", MapValues: map[string]*Attribute{
+			"SubBool":        &Attribute{Type: "BOOL", Name: "SubBool", Value: "false"},
+			"SubInt":         &Attribute{Type: "N", Name: "SubInt", Value: "0"},
+			"SubString":      &Attribute{Type: "S", Name: "SubString", Value: ""},
+			"SubStringArray": &Attribute{Type: "NULL", Name: "SubStringArray", Value: "true"},
+		}},
+		Attribute{Type: "N", Name: "TestUint", Value: "0"},
+	}
+}
+
+// testAttrsWithSets returns the attributes of testObjectWithNilSets or,
+// with an empty list, of testObjectWithEmptySets.
+func testAttrsWithSets(list []*Attribute) []Attribute {
+	attrs := []Attribute{
+		Attribute{Type: "BOOL", Name: "TestBool", Value: "true"},
+		Attribute{Type: "S", Name: "TestByteArray", Value: "Ynl0ZXM="},
+		Attribute{Type: "N", Name: "TestFloat32", Value: "9.9999"},
+		Attribute{Type: "N", Name: "TestFloat64", Value: "99.999999"},
+		Attribute{Type: "NULL", Name: "TestFloatArray", Value: "true"},
+		Attribute{Type: "N", Name: "TestInt", Value: "-99"},
+		Attribute{Type: "N", Name: "TestInt32", Value: "999"},
+		Attribute{Type: "N", Name: "TestInt64", Value: "9999"},
+		Attribute{Type: "NULL", Name: "TestInt8Array", Value: "true"},
+		Attribute{Type: "NULL", Name: "TestIntArray", Value: "true"},
+		Attribute{Type: "S", Name: "TestString", Value: "test"},
+		Attribute{Type: "NULL", Name: "TestStringArray", Value: "true"},
+		testAttrs()[12],
+		Attribute{Type: "N", Name: "TestUint", Value: "99"},
+	}
+	if list != nil {
+		for _, i := range []int{4, 9, 11} {
+			attrs[i] = *NewListAttribute(attrs[i].Name, list)
+		}
+	}
+	return attrs
+}
+
+// The attributes MarshalAttributes used to write, which are still read,
+// and written for the fields with the legacy option.
+func testLegacyAttrs() []Attribute {
 	return []Attribute{
 		Attribute{Type: "N", Name: "TestBool", Value: "1", SetValues: []string(nil)},
 		Attribute{Type: "N", Name: "TestInt", Value: "-99", SetValues: []string(nil)},
@@ -132,13 +246,13 @@ func testAttrs() []Attribute {
 	}
 }
 
-func testAttrsTime() []Attribute {
+func testLegacyAttrsTime() []Attribute {
 	return []Attribute{
 		Attribute{Type: "S", Name: "TestTime", Value: "\"2003-03-03T17:03:00Z\"", SetValues: []string(nil)},
 	}
 }
 
-func testAttrsWithZeroValues() []Attribute {
+func testLegacyAttrsWithZeroValues() []Attribute {
 	return []Attribute{
 		Attribute{Type: "N", Name: "TestBool", Value: "0", SetValues: []string(nil)},
 		Attribute{Type: "N", Name: "TestInt", Value: "0", SetValues: []string(nil)},
@@ -151,7 +265,7 @@ func testAttrsWithZeroValues() []Attribute {
 	}
 }
 
-func testAttrsWithNilSets() []Attribute {
+func testLegacyAttrsWithNilSets() []Attribute {
 	return []Attribute{
 		Attribute{Type: "N", Name: "TestBool", Value: "1", SetValues: []string(nil)},
 		Attribute{Type: "N", Name: "TestInt", Value: "-99", SetValues: []string(nil)},
@@ -166,6 +280,14 @@ func testAttrsWithNilSets() []Attribute {
 	}
 }
 
+func attrMap(attrs []Attribute) map[string]*Attribute {
+	m := map[string]*Attribute{}
+	for i, _ := range attrs {
+		m[attrs[i].Name] = &attrs[i]
+	}
+	return m
+}
+
 type MarshallerSuite struct {
 }
 
@@ -185,13 +307,21 @@ func (s *MarshallerSuite) TestMarshal(c *check.C) {
 func (s *MarshallerSuite) TestUnmarshal(c *check.C) {
 	testObj := &TestStruct{}
 
-	attrMap := map[string]*Attribute{}
-	attrs := testAttrs()
-	for i, _ := range attrs {
-		attrMap[attrs[i].Name] = &attrs[i]
+	attrs := attrMap(testAttrs())
+	err := UnmarshalAttributes(&attrs, testObj)
+	if err != nil {
+		c.Fatalf("Error from UnmarshalAttributes: %#v (Built: %#v)", err, testObj)
 	}
 
-	err := UnmarshalAttributes(&attrMap, testObj)
+	expected := testObject()
+	c.Check(testObj, check.DeepEquals, expected)
+}
+
+func (s *MarshallerSuite) TestUnmarshalLegacy(c *check.C) {
+	testObj := &TestStruct{}
+
+	attrs := attrMap(testLegacyAttrs())
+	err := UnmarshalAttributes(&attrs, testObj)
 	if err != nil {
 		c.Fatalf("Error from UnmarshalAttributes: %#v (Built: %#v)", err, testObj)
 	}
@@ -212,21 +342,18 @@ func (s *MarshallerSuite) TestMarshalTime(c *check.C) {
 }
 
 func (s *MarshallerSuite) TestUnmarshalTime(c *check.C) {
-	testObj := &TestStructTime{}
+	for _, attrs := range [][]Attribute{testAttrsTime(), testLegacyAttrsTime()} {
+		testObj := &TestStructTime{}
 
-	attrMap := map[string]*Attribute{}
-	attrs := testAttrsTime()
-	for i, _ := range attrs {
-		attrMap[attrs[i].Name] = &attrs[i]
-	}
+		m := attrMap(attrs)
+		err := UnmarshalAttributes(&m, testObj)
+		if err != nil {
+			c.Fatalf("Error from UnmarshalAttributes: %#v (Built: %#v)", err, testObj)
+		}
 
-	err := UnmarshalAttributes(&attrMap, testObj)
-	if err != nil {
-		c.Fatalf("Error from UnmarshalAttributes: %#v (Built: %#v)", err, testObj)
+		expected := testObjectTime()
+		c.Check(testObj, check.DeepEquals, expected)
 	}
-
-	expected := testObjectTime()
-	c.Check(testObj, check.DeepEquals, expected)
 }
 
 func (s *MarshallerSuite) TestMarshalNilSets(c *check.C) {
@@ -236,7 +363,7 @@ func (s *MarshallerSuite) TestMarshalNilSets(c *check.C) {
 		c.Errorf("Error from MarshalAttributes: %#v", err)
 	}
 
-	expected := testAttrsWithNilSets()
+	expected := testAttrsWithSets(nil)
 	c.Check(attrs, check.DeepEquals, expected)
 }
 
@@ -258,20 +385,15 @@ func (s *MarshallerSuite) TestMarshalEmptySets(c *check.C) {
 		c.Errorf("Error from MarshalAttributes: %#v", err)
 	}
 
-	expected := testAttrsWithNilSets()
+	expected := testAttrsWithSets([]*Attribute{})
 	c.Check(attrs, check.DeepEquals, expected)
 }
 
 func (s *MarshallerSuite) TestUnmarshalEmptySets(c *check.C) {
 	testObj := &TestStruct{}
 
-	attrMap := map[string]*Attribute{}
-	attrs := testAttrsWithNilSets()
-	for i, _ := range attrs {
-		attrMap[attrs[i].Name] = &attrs[i]
-	}
-
-	err := UnmarshalAttributes(&attrMap, testObj)
+	attrs := attrMap(testLegacyAttrsWithNilSets())
+	err := UnmarshalAttributes(&attrs, testObj)
 	if err != nil {
 		c.Fatalf("Error from UnmarshalAttributes: %#v (Built: %#v)", err, testObj)
 	}
@@ -279,3 +401,127 @@ func (s *MarshallerSuite) TestUnmarshalEmptySets(c *check.C) {
 	expected := testObjectWithNilSets()
 	c.Check(testObj, check.DeepEquals, expected)
 }
+
+type TestTaggedBase struct {
+	Id      string    `dynamodbav:"id"`
+	Expires time.Time `dynamodbav:"expires,unixtime"`
+}
+
+type TestTaggedStruct struct {
+	TestTaggedBase
+	Names   []string        `dynamodbav:"names,stringset"`
+	Scores  []float64       `dynamodbav:"scores,numberset,omitempty"`
+	Blobs   [][]byte        `dynamodbav:"blobs,binaryset"`
+	Note    string          `dynamodbav:"note,omitempty"`
+	Raw     []byte          `dynamodbav:"raw,binary"`
+	Color   TestColor       `dynamodbav:"color"`
+	Created time.Time       `json:"created"`
+	Skipped string          `dynamodbav:"-"`
+	Sub     *TestTaggedBase `dynamodbav:"sub,omitempty"`
+}
+
+// TestColor is stored as its name.
+type TestColor int
+
+func (t TestColor) MarshalDynamo() (*dynamizer.DynamoAttribute, error) {
+	name := []string{"red", "green"}[t]
+	return &dynamizer.DynamoAttribute{S: &name}, nil
+}
+
+func (t *TestColor) UnmarshalDynamo(a *dynamizer.DynamoAttribute) error {
+	if a.S == nil || *a.S != "red" && *a.S != "green" {
+		return fmt.Errorf("invalid color %#v", a)
+	}
+	*t = map[string]TestColor{"red": 0, "green": 1}[*a.S]
+	return nil
+}
+
+func (s *MarshallerSuite) TestMarshalTags(c *check.C) {
+	expires := time.Unix(1500000000, 0).UTC()
+	testObj := &TestTaggedStruct{
+		TestTaggedBase: TestTaggedBase{Id: "a", Expires: expires},
+		Names:          []string{"x", "y"},
+		Scores:         []float64{},
+		Blobs:          [][]byte{[]byte("b")},
+		Raw:            []byte("r"),
+		Color:          1,
+		Created:        expires,
+		Skipped:        "skipped",
+	}
+	attrs, err := MarshalAttributes(testObj)
+	c.Assert(err, check.IsNil)
+	c.Check(attrs, check.DeepEquals, []Attribute{
+		*NewBinarySetAttribute("blobs", []string{"Yg=="}),
+		*NewStringAttribute("color", "green"),
+		*NewStringAttribute("created", "2017-07-14T02:40:00Z"),
+		*NewNumericAttribute("expires", "1500000000"),
+		*NewStringAttribute("id", "a"),
+		*NewStringSetAttribute("names", []string{"x", "y"}),
+		*NewBinaryAttribute("raw", "cg=="),
+	})
+
+	m := attrMap(attrs)
+	actual := &TestTaggedStruct{}
+	c.Assert(UnmarshalAttributes(&m, actual), check.IsNil)
+	testObj.Scores = nil
+	testObj.Skipped = ""
+	c.Check(actual, check.DeepEquals, testObj)
+
+	// The Document APIs encode the same attributes.
+	item, err := dynamizer.ToDynamo(testObj)
+	c.Assert(err, check.IsNil)
+	c.Check(item, check.HasLen, len(attrs))
+	for _, a := range attrs {
+		d, err := dynamoFromAttribute(&a)
+		c.Assert(err, check.IsNil)
+		c.Check(item[a.Name], check.DeepEquals, d)
+	}
+
+	m["color"] = NewStringAttribute("color", "blue")
+	err = UnmarshalAttributes(&m, actual)
+	c.Check(err, check.ErrorMatches, "invalid color .*")
+}
+
+// TestLegacyStruct is TestStruct with the legacy option on every field.
+type TestLegacyStruct struct {
+	TestBool        bool          `dynamodbav:",legacy"`
+	TestInt         int           `dynamodbav:",legacy"`
+	TestInt32       int32         `dynamodbav:",legacy"`
+	TestInt64       int64         `dynamodbav:",legacy"`
+	TestUint        uint          `dynamodbav:",legacy"`
+	TestFloat32     float32       `dynamodbav:",legacy"`
+	TestFloat64     float64       `dynamodbav:",legacy"`
+	TestString      string        `dynamodbav:",legacy"`
+	TestByteArray   []byte        `dynamodbav:",legacy"`
+	TestStringArray []string      `dynamodbav:",legacy"`
+	TestIntArray    []int         `dynamodbav:",legacy"`
+	TestInt8Array   []int8        `dynamodbav:",legacy"`
+	TestFloatArray  []float64     `dynamodbav:",legacy"`
+	TestSub         TestSubStruct `dynamodbav:",legacy"`
+}
+
+func (s *MarshallerSuite) TestMarshalLegacy(c *check.C) {
+	tests := []struct {
+		obj      *TestStruct
+		expected []Attribute
+	}{
+		{testObject(), testLegacyAttrs()},
+		{testObjectWithZeroValues(), testLegacyAttrsWithZeroValues()},
+		{testObjectWithNilSets(), testLegacyAttrsWithNilSets()},
+		{testObjectWithEmptySets(), testLegacyAttrsWithNilSets()},
+	}
+	for _, t := range tests {
+		attrs, err := MarshalAttributes((*TestLegacyStruct)(t.obj))
+		c.Assert(err, check.IsNil)
+		c.Check(attrMap(attrs), check.DeepEquals, attrMap(t.expected))
+
+		m := attrMap(attrs)
+		actual := &TestLegacyStruct{}
+		c.Assert(UnmarshalAttributes(&m, actual), check.IsNil)
+	}
+
+	m := attrMap(testLegacyAttrs())
+	actual := &TestLegacyStruct{}
+	c.Assert(UnmarshalAttributes(&m, actual), check.IsNil)
+	c.Check((*TestStruct)(actual), check.DeepEquals, testObject())
+}
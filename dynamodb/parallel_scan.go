@@ -0,0 +1,185 @@
+package dynamodb
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ParallelScanAll scans the whole table in totalSegments segments, which
+// up to workers goroutines scan at once, and calls cb with each item.
+//
+// If readCapacity is positive, the scan is slowed down so that it consumes
+// no more than readCapacity read capacity units per second on average, as
+// DynamoDB reports them, in order to leave the rest of the throughput of
+// the table to the other readers. It is not limited otherwise.
+//
+// cb is called by one worker at a time. The scan stops at the first error,
+// of a request or of cb, which it returns once the workers are done.
+func (t *Table) ParallelScanAll(totalSegments, workers int, readCapacity float64, cb func(map[string]*Attribute) error) error {
+	return t.ParallelScanAllQuery(NewQuery(t), totalSegments, workers, readCapacity, cb)
+}
+
+// ParallelScanAllQuery is ParallelScanAll for a scan query, which may hold
+// a filter, a projection or a Limit on the items of each page.
+func (t *Table) ParallelScanAllQuery(query *UntypedQuery, totalSegments, workers int, readCapacity float64, cb func(map[string]*Attribute) error) error {
+	if totalSegments < 1 {
+		return errors.New("totalSegments must be at least 1")
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > totalSegments {
+		workers = totalSegments
+	}
+
+	s := &parallelScan{
+		table:    t,
+		query:    query,
+		total:    totalSegments,
+		cb:       cb,
+		segments: make(chan int, totalSegments),
+		limiter:  &capacityLimiter{rate: readCapacity},
+		stopped:  make(chan struct{}),
+	}
+	for i := 0; i < totalSegments; i++ {
+		s.segments <- i
+	}
+	close(s.segments)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for segment := range s.segments {
+				if err := s.scan(segment); err != nil {
+					s.stop(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return s.err
+}
+
+// parallelScan is the state of a ParallelScanAllQuery.
+type parallelScan struct {
+	table    *Table
+	query    *UntypedQuery
+	total    int
+	segments chan int
+	limiter  *capacityLimiter
+
+	mu      sync.Mutex // held while cb is called.
+	cb      func(map[string]*Attribute) error
+	err     error
+	stopped chan struct{} // closed on the first error.
+}
+
+// scan scans a segment, page after page.
+func (s *parallelScan) scan(segment int) error {
+	q := &UntypedQuery{msi{}, s.table}
+	for k, v := range s.query.buffer {
+		q.buffer[k] = v
+	}
+	q.AddParallelScanConfiguration(segment, s.total)
+	q.buffer["ReturnConsumedCapacity"] = "TOTAL"
+
+	for {
+		if !s.limiter.wait(s.stopped) {
+			return nil
+		}
+		results, lastEvaluatedKey, capacityUnits, err := s.table.fetchPartialResults(q)
+		if err != nil {
+			return err
+		}
+		s.limiter.consume(capacityUnits)
+
+		if err := s.handle(results); err != nil {
+			return err
+		}
+		if lastEvaluatedKey == nil {
+			return nil
+		}
+		q.AddExclusiveStartKey(lastEvaluatedKey)
+	}
+}
+
+// handle calls cb with the items of a page, unless the scan was stopped.
+func (s *parallelScan) handle(results []map[string]*Attribute) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range results {
+		if s.err != nil {
+			return nil
+		}
+		if err := s.cb(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stop stops the scan, keeping the first error.
+func (s *parallelScan) stop(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+		close(s.stopped)
+	}
+}
+
+// A capacityLimiter paces requests so that the capacity they consume,
+// known once they are done, is rate units per second on average: each
+// request delays the next ones by the time its capacity is worth.
+type capacityLimiter struct {
+	rate float64 // No limit if not positive.
+
+	mu   sync.Mutex
+	next time.Time // when the next request may be sent.
+}
+
+// wait waits until a request may be sent, and reports whether it may, or
+// if stopped was closed meanwhile.
+func (l *capacityLimiter) wait(stopped <-chan struct{}) bool {
+	select {
+	case <-stopped:
+		return false
+	default:
+	}
+	if l.rate <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	d := l.next.Sub(time.Now())
+	l.mu.Unlock()
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// consume records the capacity a request consumed.
+func (l *capacityLimiter) consume(units float64) {
+	if l.rate <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(units / l.rate * float64(time.Second)))
+}
@@ -141,19 +141,15 @@ func (q *UntypedQuery) AddWriteRequestItems(tableItems map[*Table]map[string][][
 func (q *UntypedQuery) AddCreateRequestTable(description TableDescriptionT) {
 	b := q.buffer
 
-	attDefs := []interface{}{}
-	for _, attr := range description.AttributeDefinitions {
-		attDefs = append(attDefs, msi{
-			"AttributeName": attr.Name,
-			"AttributeType": attr.Type,
-		})
-	}
-	b["AttributeDefinitions"] = attDefs
+	payPerRequest := description.BillingMode == BILLING_MODE_PAY_PER_REQUEST
+	b["AttributeDefinitions"] = attributeDefinitions(description.AttributeDefinitions)
 	b["KeySchema"] = description.KeySchema
 	b["TableName"] = description.TableName
-	b["ProvisionedThroughput"] = msi{
-		"ReadCapacityUnits":  int(description.ProvisionedThroughput.ReadCapacityUnits),
-		"WriteCapacityUnits": int(description.ProvisionedThroughput.WriteCapacityUnits),
+	if description.BillingMode != "" {
+		b["BillingMode"] = description.BillingMode
+	}
+	if !payPerRequest {
+		b["ProvisionedThroughput"] = provisionedThroughput(description.ProvisionedThroughput)
 	}
 
 	localSecondaryIndexes := []interface{}{}
@@ -173,20 +169,88 @@ func (q *UntypedQuery) AddCreateRequestTable(description TableDescriptionT) {
 	globalSecondaryIndexes := []interface{}{}
 
 	for _, ind := range description.GlobalSecondaryIndexes {
-		globalSecondaryIndexes = append(globalSecondaryIndexes, msi{
-			"IndexName":  ind.IndexName,
-			"KeySchema":  ind.KeySchema,
-			"Projection": ind.Projection,
-			"ProvisionedThroughput": msi{
-				"ReadCapacityUnits":  int(ind.ProvisionedThroughput.ReadCapacityUnits),
-				"WriteCapacityUnits": int(ind.ProvisionedThroughput.WriteCapacityUnits),
-			},
-		})
+		globalSecondaryIndexes = append(globalSecondaryIndexes, globalSecondaryIndex(ind, payPerRequest))
 	}
 
 	if len(globalSecondaryIndexes) > 0 {
 		b["GlobalSecondaryIndexes"] = globalSecondaryIndexes
 	}
+
+	if description.StreamSpecification != nil {
+		b["StreamSpecification"] = description.StreamSpecification
+	}
+}
+
+func attributeDefinitions(definitions []AttributeDefinitionT) []interface{} {
+	attDefs := []interface{}{}
+	for _, attr := range definitions {
+		attDefs = append(attDefs, msi{
+			"AttributeName": attr.Name,
+			"AttributeType": attr.Type,
+		})
+	}
+	return attDefs
+}
+
+func provisionedThroughput(t ProvisionedThroughputT) msi {
+	return msi{
+		"ReadCapacityUnits":  int(t.ReadCapacityUnits),
+		"WriteCapacityUnits": int(t.WriteCapacityUnits),
+	}
+}
+
+func globalSecondaryIndex(ind GlobalSecondaryIndexT, payPerRequest bool) msi {
+	gsi := msi{
+		"IndexName":  ind.IndexName,
+		"KeySchema":  ind.KeySchema,
+		"Projection": ind.Projection,
+	}
+	// Indexes created by UpdateTable on tables billed per request have no
+	// throughput, whether the billing mode is updated or not.
+	if !payPerRequest && ind.ProvisionedThroughput != (ProvisionedThroughputT{}) {
+		gsi["ProvisionedThroughput"] = provisionedThroughput(ind.ProvisionedThroughput)
+	}
+	return gsi
+}
+
+func (q *UntypedQuery) AddUpdateRequestTable(update TableUpdateT) error {
+	b := q.buffer
+
+	payPerRequest := update.BillingMode == BILLING_MODE_PAY_PER_REQUEST
+	if len(update.AttributeDefinitions) > 0 {
+		b["AttributeDefinitions"] = attributeDefinitions(update.AttributeDefinitions)
+	}
+	if update.BillingMode != "" {
+		b["BillingMode"] = update.BillingMode
+	}
+	if update.ProvisionedThroughput != nil {
+		b["ProvisionedThroughput"] = provisionedThroughput(*update.ProvisionedThroughput)
+	}
+
+	indexUpdates := []interface{}{}
+	for _, u := range update.GlobalSecondaryIndexUpdates {
+		switch {
+		case u.Create != nil && u.Update == nil && u.Delete == nil:
+			indexUpdates = append(indexUpdates, msi{"Create": globalSecondaryIndex(*u.Create, payPerRequest)})
+		case u.Update != nil && u.Create == nil && u.Delete == nil:
+			indexUpdates = append(indexUpdates, msi{"Update": msi{
+				"IndexName":             u.Update.IndexName,
+				"ProvisionedThroughput": provisionedThroughput(u.Update.ProvisionedThroughput),
+			}})
+		case u.Delete != nil && u.Create == nil && u.Update == nil:
+			indexUpdates = append(indexUpdates, msi{"Delete": msi{"IndexName": u.Delete.IndexName}})
+		default:
+			return errors.New("A global secondary index update must have exactly one of Create, Update and Delete.")
+		}
+	}
+	if len(indexUpdates) > 0 {
+		b["GlobalSecondaryIndexUpdates"] = indexUpdates
+	}
+
+	if update.StreamSpecification != nil {
+		b["StreamSpecification"] = update.StreamSpecification
+	}
+	return nil
 }
 
 func (q *UntypedQuery) AddDeleteRequestTable(description TableDescriptionT) {
@@ -316,6 +380,12 @@ func (q *UntypedQuery) AddFilterExpression(e *Expression) {
 	q.addExpressionAttributeValues(e)
 }
 
+func (q *UntypedQuery) AddKeyConditionExpression(e *Expression) {
+	q.buffer["KeyConditionExpression"] = e.Text
+	q.addExpressionAttributeNames(e)
+	q.addExpressionAttributeValues(e)
+}
+
 func (q *UntypedQuery) AddProjectionExpression(e *Expression) {
 	q.buffer["ProjectionExpression"] = e.Text
 	q.addExpressionAttributeNames(e)
@@ -9,20 +9,28 @@ import (
 )
 
 func (t *Table) FetchPartialResults(query ScanQuery) ([]map[string]*Attribute, StartKey, error) {
+	results, lastEvaluatedKey, _, err := t.fetchPartialResults(query)
+	return results, lastEvaluatedKey, err
+}
+
+// fetchPartialResults is FetchPartialResults, but also returns the read
+// capacity units the scan consumed, if the query asked for them with
+// ReturnConsumedCapacity.
+func (t *Table) fetchPartialResults(query ScanQuery) ([]map[string]*Attribute, StartKey, float64, error) {
 	jsonResponse, err := t.Server.queryServer(target("Scan"), query)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, 0, err
 	}
 
 	json, err := simplejson.NewJson(jsonResponse)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, 0, err
 	}
 
 	itemCount, err := json.Get("Count").Int()
 	if err != nil {
 		message := fmt.Sprintf("Unexpected response %s", jsonResponse)
-		return nil, nil, errors.New(message)
+		return nil, nil, 0, errors.New(message)
 	}
 
 	results := make([]map[string]*Attribute, itemCount)
@@ -30,7 +38,7 @@ func (t *Table) FetchPartialResults(query ScanQuery) ([]map[string]*Attribute, S
 		item, err := json.Get("Items").GetIndex(i).Map()
 		if err != nil {
 			message := fmt.Sprintf("Unexpected response %s", jsonResponse)
-			return nil, nil, errors.New(message)
+			return nil, nil, 0, errors.New(message)
 		}
 		results[i] = parseAttributes(item)
 	}
@@ -40,7 +48,8 @@ func (t *Table) FetchPartialResults(query ScanQuery) ([]map[string]*Attribute, S
 		lastEvaluatedKey = lastKeyMap
 	}
 
-	return results, lastEvaluatedKey, nil
+	capacityUnits := json.Get("ConsumedCapacity").Get("CapacityUnits").MustFloat64()
+	return results, lastEvaluatedKey, capacityUnits, nil
 }
 
 func (t *Table) FetchResultCallbackIterator(query ScanQuery, cb func(map[string]*Attribute) error) error {
@@ -32,6 +32,7 @@ type ProjectionT struct {
 type GlobalSecondaryIndexT struct {
 	IndexName             string
 	IndexSizeBytes        int64
+	IndexStatus           string // "CREATING", "UPDATING", "DELETING" or "ACTIVE".
 	ItemCount             int64
 	KeySchema             []KeySchemaT
 	Projection            ProjectionT
@@ -52,19 +53,90 @@ type ProvisionedThroughputT struct {
 	WriteCapacityUnits     int64
 }
 
+// StreamSpecificationT enables the stream of a table, whose records hold
+// the items written as the StreamViewType says: "KEYS_ONLY", "NEW_IMAGE",
+// "OLD_IMAGE" or "NEW_AND_OLD_IMAGES". The records are read with the
+// dynamodbstreams package.
+type StreamSpecificationT struct {
+	StreamEnabled  bool
+	StreamViewType string `json:",omitempty"`
+}
+
+// BillingModeSummaryT is how the reads and writes of a table are billed:
+// BillingMode is BILLING_MODE_PROVISIONED or BILLING_MODE_PAY_PER_REQUEST.
+type BillingModeSummaryT struct {
+	BillingMode                       string
+	LastUpdateToPayPerRequestDateTime float64
+}
+
+// SSEDescriptionT describes the encryption at rest of a table.
+type SSEDescriptionT struct {
+	Status          string // "ENABLING", "ENABLED", "DISABLING", "DISABLED", "UPDATING".
+	SSEType         string // "AES256" or "KMS".
+	KMSMasterKeyArn string
+}
+
+// Billing modes, for the BillingMode of TableDescriptionT and TableUpdateT.
+// The tables billed per request have no provisioned throughput, nor have
+// their global secondary indexes.
+const (
+	BILLING_MODE_PROVISIONED     = "PROVISIONED"
+	BILLING_MODE_PAY_PER_REQUEST = "PAY_PER_REQUEST"
+)
+
 type TableDescriptionT struct {
 	AttributeDefinitions   []AttributeDefinitionT
+	BillingMode            string // Only used by CreateTable; DescribeTable sets BillingModeSummary.
+	BillingModeSummary     *BillingModeSummaryT
 	CreationDateTime       float64
 	ItemCount              int64
 	KeySchema              []KeySchemaT
 	LocalSecondaryIndexes  []LocalSecondaryIndexT
 	GlobalSecondaryIndexes []GlobalSecondaryIndexT
 	ProvisionedThroughput  ProvisionedThroughputT
+	SSEDescription         *SSEDescriptionT
+	StreamSpecification    *StreamSpecificationT
+	LatestStreamArn        string
+	LatestStreamLabel      string
+	TableArn               string
 	TableName              string
 	TableSizeBytes         int64
 	TableStatus            string
 }
 
+// TableUpdateT holds the changes UpdateTable makes to a table. Its zero
+// fields leave the table unchanged.
+type TableUpdateT struct {
+	// AttributeDefinitions must define the key attributes of the
+	// global secondary indexes created.
+	AttributeDefinitions        []AttributeDefinitionT
+	BillingMode                 string
+	ProvisionedThroughput       *ProvisionedThroughputT
+	GlobalSecondaryIndexUpdates []GlobalSecondaryIndexUpdateT
+	StreamSpecification         *StreamSpecificationT
+}
+
+// GlobalSecondaryIndexUpdateT creates, updates the throughput of, or
+// deletes a global secondary index. Exactly one of its fields must be set.
+type GlobalSecondaryIndexUpdateT struct {
+	Create *GlobalSecondaryIndexT
+	Update *GlobalSecondaryIndexThroughputT
+	Delete *GlobalSecondaryIndexNameT
+}
+
+type GlobalSecondaryIndexThroughputT struct {
+	IndexName             string
+	ProvisionedThroughput ProvisionedThroughputT
+}
+
+type GlobalSecondaryIndexNameT struct {
+	IndexName string
+}
+
+type tableDescriptionResponse struct {
+	TableDescription TableDescriptionT
+}
+
 type describeTableResponse struct {
 	Table TableDescriptionT
 }
@@ -235,6 +307,84 @@ func (s *Server) DescribeTable(name string) (*TableDescriptionT, error) {
 	return &r.Table, nil
 }
 
+func (t *Table) UpdateTable(update TableUpdateT) (*TableDescriptionT, error) {
+	return t.Server.UpdateTable(t.Name, update)
+}
+
+// UpdateTable changes the billing mode, throughput, global secondary
+// indexes or stream of a table, and returns its description. The table
+// is UPDATING until the changes are made, and global secondary indexes
+// CREATING until they are filled.
+func (s *Server) UpdateTable(name string, update TableUpdateT) (*TableDescriptionT, error) {
+	q := NewEmptyQuery()
+	q.addTableByName(name)
+	if err := q.AddUpdateRequestTable(update); err != nil {
+		return nil, err
+	}
+
+	jsonResponse, err := s.queryServer(target("UpdateTable"), q)
+	if err != nil {
+		return nil, err
+	}
+
+	var r tableDescriptionResponse
+	err = json.Unmarshal(jsonResponse, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r.TableDescription, nil
+}
+
+// TimeToLiveDescriptionT describes the expiration of the items of a
+// table, which DynamoDB deletes some time after the time, in seconds
+// since the Unix epoch, of their AttributeName attribute.
+type TimeToLiveDescriptionT struct {
+	AttributeName    string
+	TimeToLiveStatus string // "ENABLING", "DISABLING", "ENABLED" or "DISABLED".
+}
+
+type describeTimeToLiveResponse struct {
+	TimeToLiveDescription TimeToLiveDescriptionT
+}
+
+// UpdateTimeToLive enables or disables the expiration of the items of a
+// table by their attributeName attribute.
+func (s *Server) UpdateTimeToLive(tableName, attributeName string, enabled bool) error {
+	q := NewEmptyQuery()
+	q.addTableByName(tableName)
+	q.buffer["TimeToLiveSpecification"] = msi{
+		"AttributeName": attributeName,
+		"Enabled":       enabled,
+	}
+
+	jsonResponse, err := s.queryServer(target("UpdateTimeToLive"), q)
+	if err != nil {
+		return err
+	}
+
+	_, err = simplejson.NewJson(jsonResponse)
+	return err
+}
+
+func (s *Server) DescribeTimeToLive(tableName string) (*TimeToLiveDescriptionT, error) {
+	q := NewEmptyQuery()
+	q.addTableByName(tableName)
+
+	jsonResponse, err := s.queryServer(target("DescribeTimeToLive"), q)
+	if err != nil {
+		return nil, err
+	}
+
+	var r describeTimeToLiveResponse
+	err = json.Unmarshal(jsonResponse, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r.TimeToLiveDescription, nil
+}
+
 func keyParam(k *PrimaryKey, hashKey string, rangeKey string) string {
 	value := fmt.Sprintf("{\"HashKeyElement\":{%s}", keyValue(k.KeyAttribute.Type, hashKey))
 
@@ -0,0 +1,80 @@
+package dynamodb
+
+import (
+	"encoding/json"
+
+	simplejson "github.com/bitly/go-simplejson"
+)
+
+// TagT is a tag of a table, such as its TableArn, for cost allocation.
+type TagT struct {
+	Key   string
+	Value string
+}
+
+type listTagsOfResourceResponse struct {
+	NextToken string
+	Tags      []TagT
+}
+
+// TagResource adds tags to a resource, replacing the values of the tags
+// it already has.
+func (s *Server) TagResource(resourceArn string, tags []TagT) error {
+	q := NewEmptyQuery()
+	q.buffer["ResourceArn"] = resourceArn
+	q.buffer["Tags"] = tags
+
+	jsonResponse, err := s.queryServer(target("TagResource"), q)
+	if err != nil {
+		return err
+	}
+
+	_, err = simplejson.NewJson(jsonResponse)
+	return err
+}
+
+// UntagResource removes the tags of a resource with the keys tagKeys.
+func (s *Server) UntagResource(resourceArn string, tagKeys []string) error {
+	q := NewEmptyQuery()
+	q.buffer["ResourceArn"] = resourceArn
+	q.buffer["TagKeys"] = tagKeys
+
+	jsonResponse, err := s.queryServer(target("UntagResource"), q)
+	if err != nil {
+		return err
+	}
+
+	_, err = simplejson.NewJson(jsonResponse)
+	return err
+}
+
+// ListTagsOfResource returns all the tags of a resource.
+func (s *Server) ListTagsOfResource(resourceArn string) ([]TagT, error) {
+	var tags []TagT
+	var nextToken string
+
+	for {
+		q := NewEmptyQuery()
+		q.buffer["ResourceArn"] = resourceArn
+		if nextToken != "" {
+			q.buffer["NextToken"] = nextToken
+		}
+
+		jsonResponse, err := s.queryServer(target("ListTagsOfResource"), q)
+		if err != nil {
+			return nil, err
+		}
+
+		var r listTagsOfResourceResponse
+		err = json.Unmarshal(jsonResponse, &r)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, r.Tags...)
+		if r.NextToken == "" {
+			return tags, nil
+		}
+		nextToken = r.NextToken
+	}
+}
@@ -0,0 +1,252 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/AdRoll/goamz/dynamodb/dynamizer"
+	simplejson "github.com/bitly/go-simplejson"
+)
+
+// MaxTransactItems limits the number of actions of a transaction.
+// cf: http://docs.aws.amazon.com/amazondynamodb/latest/developerguide/transaction-apis.html
+const MaxTransactItems = 100
+
+// A TransactWriteItem is an action of a TransactWriteItems transaction.
+// Exactly one of Put, Update, Delete and ConditionCheck must be set.
+type TransactWriteItem struct {
+	Put            *TransactPut
+	Update         *TransactUpdate
+	Delete         *TransactDelete
+	ConditionCheck *TransactConditionCheck
+
+	// ReturnOldItem asks for the item the action is about to be
+	// returned in its CancellationReason if its condition fails.
+	ReturnOldItem bool
+}
+
+// TransactPut writes an item, made of Key and either Attributes or
+// Item, such as one returned by dynamizer.ToDynamo.
+type TransactPut struct {
+	Table      *Table
+	Key        *Key
+	Attributes []Attribute
+	Item       dynamizer.DynamoItem
+	Condition  *Expression // optional.
+}
+
+// TransactUpdate updates the attributes of the item at Key.
+type TransactUpdate struct {
+	Table     *Table
+	Key       *Key
+	Update    *Expression
+	Condition *Expression // optional.
+}
+
+// TransactDelete deletes the item at Key.
+type TransactDelete struct {
+	Table     *Table
+	Key       *Key
+	Condition *Expression // optional.
+}
+
+// TransactConditionCheck checks a condition on the item at Key, which is
+// not changed.
+type TransactConditionCheck struct {
+	Table     *Table
+	Key       *Key
+	Condition *Expression
+}
+
+// A TransactGet reads the item at Key, projected with Projection unless
+// it is nil.
+type TransactGet struct {
+	Table      *Table
+	Key        *Key
+	Projection *Expression
+}
+
+// TransactionCanceledError is the error of a transaction that DynamoDB
+// canceled. Reasons holds the reason of each of its actions, in order.
+type TransactionCanceledError struct {
+	StatusCode int
+	Message    string
+	Reasons    []CancellationReason
+}
+
+// A CancellationReason is the reason an action of a transaction was
+// canceled for. Its Code is "None" if the action did not cause the
+// cancellation, or one of "ConditionalCheckFailed", "ValidationError",
+// "TransactionConflict", "ItemCollectionSizeLimitExceeded",
+// "ProvisionedThroughputExceeded" or "ThrottlingError".
+type CancellationReason struct {
+	Code    string
+	Message string
+	Item    map[string]*Attribute // if requested with ReturnOldItem.
+}
+
+func (e *TransactionCanceledError) Error() string {
+	return "TransactionCanceledException: " + e.Message
+}
+
+func (e *TransactionCanceledError) ErrorCode() string {
+	return "TransactionCanceledException"
+}
+
+func buildTransactionCanceledError(r *simplejson.Json, ddbError *Error) error {
+	tce := &TransactionCanceledError{
+		StatusCode: ddbError.StatusCode,
+		Message:    ddbError.Message,
+	}
+	reasons, _ := r.Get("CancellationReasons").Array()
+	for i := range reasons {
+		reason := r.Get("CancellationReasons").GetIndex(i)
+		cr := CancellationReason{
+			Code:    reason.Get("Code").MustString(),
+			Message: reason.Get("Message").MustString(),
+		}
+		if item, err := reason.Get("Item").Map(); err == nil {
+			cr.Item = parseAttributes(item)
+		}
+		tce.Reasons = append(tce.Reasons, cr)
+	}
+	return tce
+}
+
+// TransactWriteItems makes all the writes of items, across one or more
+// tables, or none of them. Requests with the same non-empty
+// clientRequestToken are idempotent for ten minutes, so that a request
+// can be retried without its writes being made twice.
+//
+// If a condition fails, the error is a *TransactionCanceledError.
+func (s *Server) TransactWriteItems(items []TransactWriteItem, clientRequestToken string) error {
+	actions := make([]msi, len(items))
+	for i, item := range items {
+		action, err := item.action()
+		if err != nil {
+			return err
+		}
+		actions[i] = action
+	}
+
+	q := NewEmptyQuery()
+	q.buffer["TransactItems"] = actions
+	if clientRequestToken != "" {
+		q.buffer["ClientRequestToken"] = clientRequestToken
+	}
+
+	jsonResponse, err := s.queryServer(target("TransactWriteItems"), q)
+	if err != nil {
+		return err
+	}
+
+	_, err = simplejson.NewJson(jsonResponse)
+	return err
+}
+
+func (item *TransactWriteItem) action() (msi, error) {
+	var name string
+	var q *UntypedQuery
+	n := 0
+	if p := item.Put; p != nil {
+		name, q = "Put", NewQuery(p.Table)
+		if p.Item != nil {
+			keys, err := buildKeyMap(p.Table, p.Key)
+			if err != nil {
+				return nil, err
+			}
+			it := dynamizer.DynamoItem{}
+			for k, v := range p.Item {
+				it[k] = v
+			}
+			for k, v := range keys {
+				it[k] = v
+			}
+			q.buffer["Item"] = it
+		} else {
+			attributes := append(p.Table.Key.Clone(p.Key.HashKey, p.Key.RangeKey), p.Attributes...)
+			q.AddItem(attributes)
+		}
+		if p.Condition != nil {
+			q.AddConditionExpression(p.Condition)
+		}
+		n++
+	}
+	if u := item.Update; u != nil {
+		if u.Update == nil {
+			return nil, errors.New("An update expression is required.")
+		}
+		name, q = "Update", NewQuery(u.Table)
+		q.AddKey(u.Key)
+		q.AddUpdateExpression(u.Update)
+		if u.Condition != nil {
+			q.AddConditionExpression(u.Condition)
+		}
+		n++
+	}
+	if d := item.Delete; d != nil {
+		name, q = "Delete", NewQuery(d.Table)
+		q.AddKey(d.Key)
+		if d.Condition != nil {
+			q.AddConditionExpression(d.Condition)
+		}
+		n++
+	}
+	if c := item.ConditionCheck; c != nil {
+		if c.Condition == nil {
+			return nil, errors.New("A condition expression is required.")
+		}
+		name, q = "ConditionCheck", NewQuery(c.Table)
+		q.AddKey(c.Key)
+		q.AddConditionExpression(c.Condition)
+		n++
+	}
+	if n != 1 {
+		return nil, fmt.Errorf("A transaction action must have exactly one of Put, Update, Delete and ConditionCheck, not %d.", n)
+	}
+	if item.ReturnOldItem {
+		q.buffer["ReturnValuesOnConditionCheckFailure"] = "ALL_OLD"
+	}
+	return msi{name: q.buffer}, nil
+}
+
+// TransactGetItems reads the items of gets, across one or more tables, as
+// of a single point in time. The item of a get is nil if there is none.
+func (s *Server) TransactGetItems(gets []TransactGet) ([]map[string]*Attribute, error) {
+	actions := make([]msi, len(gets))
+	for i, get := range gets {
+		q := NewQuery(get.Table)
+		q.AddKey(get.Key)
+		if get.Projection != nil {
+			q.AddProjectionExpression(get.Projection)
+		}
+		actions[i] = msi{"Get": q.buffer}
+	}
+
+	q := NewEmptyQuery()
+	q.buffer["TransactItems"] = actions
+
+	jsonResponse, err := s.queryServer(target("TransactGetItems"), q)
+	if err != nil {
+		return nil, err
+	}
+
+	json, err := simplejson.NewJson(jsonResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := json.Get("Responses").Array()
+	if err != nil || len(responses) != len(gets) {
+		message := fmt.Sprintf("Unexpected response %s", jsonResponse)
+		return nil, errors.New(message)
+	}
+
+	items := make([]map[string]*Attribute, len(gets))
+	for i := range responses {
+		if item, err := json.Get("Responses").GetIndex(i).Get("Item").Map(); err == nil {
+			items[i] = parseAttributes(item)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,114 @@
+package dynamodb
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ItemSuite) TestTransactWriteItems(c *check.C) {
+	var rk string
+	if s.WithRange {
+		rk = "1"
+	}
+	k1 := &Key{HashKey: "Key1", RangeKey: rk}
+	k2 := &Key{HashKey: "Key2", RangeKey: rk}
+	if ok, err := s.table.PutItem(k1.HashKey, rk, []Attribute{*NewNumericAttribute("Count", "1")}); !ok {
+		c.Fatal(err)
+	}
+
+	one := Value(NewNumericAttribute("", "1"))
+	items := []TransactWriteItem{
+		{
+			Update: &TransactUpdate{
+				Table:     s.table,
+				Key:       k1,
+				Update:    NewUpdateExpression(NewUpdate().Set(Name("Count"), Minus(Name("Count"), one))),
+				Condition: NewConditionExpression(GreaterThanOrEqual(Name("Count"), one)),
+			},
+			ReturnOldItem: true,
+		},
+		{
+			Put: &TransactPut{
+				Table:      s.table,
+				Key:        k2,
+				Attributes: []Attribute{*NewNumericAttribute("Count", "1")},
+				Condition:  NewConditionExpression(AttributeNotExists(Name("TestHashKey"))),
+			},
+		},
+	}
+	// Tokens are remembered across tests, and by real DynamoDB across runs.
+	token := fmt.Sprint("token-", time.Now().UnixNano())
+	err := s.server.TransactWriteItems(items, token)
+	c.Assert(err, check.IsNil)
+
+	item, err := s.table.GetItem(k1)
+	c.Assert(err, check.IsNil)
+	c.Check(item["Count"].Value, check.Equals, "0")
+	item, err = s.table.GetItem(k2)
+	c.Assert(err, check.IsNil)
+	c.Check(item["Count"].Value, check.Equals, "1")
+
+	// Retrying with the same token does not write again.
+	err = s.server.TransactWriteItems(items, token)
+	c.Assert(err, check.IsNil)
+	item, err = s.table.GetItem(k1)
+	c.Assert(err, check.IsNil)
+	c.Check(item["Count"].Value, check.Equals, "0")
+
+	// Both conditions fail now, and nothing is written.
+	err = s.server.TransactWriteItems(items, "")
+	c.Assert(err, check.FitsTypeOf, &TransactionCanceledError{})
+	reasons := err.(*TransactionCanceledError).Reasons
+	c.Assert(reasons, check.HasLen, 2)
+	c.Check(reasons[0].Code, check.Equals, "ConditionalCheckFailed")
+	c.Check(reasons[0].Item["Count"], check.DeepEquals, NewNumericAttribute("Count", "0"))
+	c.Check(reasons[1].Code, check.Equals, "ConditionalCheckFailed")
+	c.Check(reasons[1].Item, check.IsNil)
+
+	items = []TransactWriteItem{
+		{Delete: &TransactDelete{Table: s.table, Key: k2}},
+		{ConditionCheck: &TransactConditionCheck{
+			Table:     s.table,
+			Key:       k1,
+			Condition: NewConditionExpression(GreaterThan(Name("Count"), Value(NewNumericAttribute("", "0")))),
+		}},
+	}
+	err = s.server.TransactWriteItems(items, "")
+	c.Assert(err, check.ErrorMatches, "TransactionCanceledException: .*")
+	reasons = err.(*TransactionCanceledError).Reasons
+	c.Check(reasons[0].Code, check.Equals, "None")
+	c.Check(reasons[1].Code, check.Equals, "ConditionalCheckFailed")
+	_, err = s.table.GetItem(k2)
+	c.Assert(err, check.IsNil)
+
+	err = s.server.TransactWriteItems([]TransactWriteItem{{}}, "")
+	c.Assert(err, check.ErrorMatches, "A transaction action must have exactly one of .*")
+}
+
+func (s *ItemSuite) TestTransactGetItems(c *check.C) {
+	var rk string
+	if s.WithRange {
+		rk = "1"
+	}
+	k1 := &Key{HashKey: "Key1", RangeKey: rk}
+	attrs := []Attribute{
+		*NewStringAttribute("Name", "one"),
+		*NewNumericAttribute("Count", "1"),
+	}
+	if ok, err := s.table.PutItem(k1.HashKey, rk, attrs); !ok {
+		c.Fatal(err)
+	}
+
+	items, err := s.server.TransactGetItems([]TransactGet{
+		{Table: s.table, Key: k1, Projection: NewProjectionExpression(Name("Name"))},
+		{Table: s.table, Key: &Key{HashKey: "Missing", RangeKey: rk}},
+		{Table: s.table, Key: k1},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(items, check.HasLen, 3)
+	c.Check(items[0], check.DeepEquals, map[string]*Attribute{"Name": NewStringAttribute("Name", "one")})
+	c.Check(items[1], check.IsNil)
+	c.Check(items[2]["Count"], check.DeepEquals, NewNumericAttribute("Count", "1"))
+}
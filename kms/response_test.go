@@ -20,3 +20,11 @@ var ErrorExample = `
     "message": "This is a error test"
 }
 `
+
+var GenerateDataKeyExample = `
+{
+	"CiphertextBlob": "Y2lwaGVydGV4dA==",
+	"KeyId": "arn:aws:kms:us-east-1:123456789012:key/12345678-1234-1234-1234-123456789012",
+	"Plaintext": "cGxhaW50ZXh0"
+}
+`
@@ -0,0 +1,239 @@
+package s3
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLogRecord is a request logged by S3 server access logging. Fields
+// logged as "-" are left empty, or zero for numbers.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/userguide/LogFormat.html
+// for details.
+type AccessLogRecord struct {
+	BucketOwner string
+	Bucket      string
+	Time        time.Time
+	RemoteIP    string
+	Requester   string
+	RequestId   string
+	Operation   string // For example REST.GET.OBJECT.
+	Key         string // URL-encoded, as logged.
+	RequestURI  string // The Request-URI part of the HTTP request line.
+	HTTPStatus  int
+	ErrorCode   string
+	BytesSent   int64
+	ObjectSize  int64
+	// TotalTime is the time the request was in flight from the point of
+	// view of S3, and TurnAroundTime the time S3 spent processing it.
+	TotalTime      time.Duration
+	TurnAroundTime time.Duration
+	Referer        string
+	UserAgent      string
+	VersionId      string
+	HostId         string
+
+	// The fields below were added to the log format over time, and are
+	// empty in older records.
+	SignatureVersion   string
+	CipherSuite        string
+	AuthenticationType string
+	HostHeader         string
+	TLSVersion         string
+	AccessPointARN     string
+	ACLRequired        string
+}
+
+const accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLogReader reads the records of a server access log file.
+type AccessLogReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewAccessLogReader returns a reader of the access log records in r.
+func NewAccessLogReader(r io.Reader) *AccessLogReader {
+	s := bufio.NewScanner(r)
+	// Request URIs and user agents can make lines longer than the
+	// default limit of 64KB.
+	s.Buffer(nil, 1<<20)
+	return &AccessLogReader{s: s}
+}
+
+// Read returns the next record, or io.EOF when there are no more.
+func (r *AccessLogReader) Read() (*AccessLogRecord, error) {
+	for r.s.Scan() {
+		r.line++
+		line := r.s.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		rec, err := parseAccessLogLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("s3: access log line %d: %v", r.line, err)
+		}
+		return rec, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// ReadAccessLog reads the server access log file stored at path, calling
+// fn for each of its records in turn. If fn returns an error, reading
+// stops and that error is returned.
+func (b *Bucket) ReadAccessLog(path string, fn func(*AccessLogRecord) error) error {
+	rc, err := b.GetReader(path)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	r := NewAccessLogReader(rc)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// splitAccessLogLine splits line into its space-separated fields. Fields
+// may be enclosed in brackets or in double quotes, in which case they can
+// contain spaces, and are returned without them.
+func splitAccessLogLine(line string) ([]string, error) {
+	var fields []string
+	for {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			return fields, nil
+		}
+		var end string
+		switch line[0] {
+		case '[':
+			end = "]"
+		case '"':
+			// Quotes are not escaped within fields: a field ends at
+			// a quote followed by a space or the end of the line.
+			end = `"`
+		}
+		if end == "" {
+			i := strings.IndexByte(line, ' ')
+			if i < 0 {
+				i = len(line)
+			}
+			fields = append(fields, line[:i])
+			line = line[i:]
+			continue
+		}
+		i := 1
+		for {
+			j := strings.Index(line[i:], end)
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated field %q", line)
+			}
+			i += j
+			if i+1 == len(line) || line[i+1] == ' ' || end == "]" {
+				break
+			}
+			i++
+		}
+		fields = append(fields, line[1:i])
+		line = line[i+1:]
+	}
+}
+
+func parseAccessLogLine(line string) (*AccessLogRecord, error) {
+	fields, err := splitAccessLogLine(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) < 18 {
+		return nil, fmt.Errorf("found %d fields, want at least 18", len(fields))
+	}
+	// Fields that were not logged yet are "-" as well.
+	for len(fields) < 26 {
+		fields = append(fields, "-")
+	}
+	for i, f := range fields {
+		if f == "-" {
+			fields[i] = ""
+		}
+	}
+	rec := &AccessLogRecord{
+		BucketOwner:        fields[0],
+		Bucket:             fields[1],
+		RemoteIP:           fields[3],
+		Requester:          fields[4],
+		RequestId:          fields[5],
+		Operation:          fields[6],
+		Key:                fields[7],
+		RequestURI:         fields[8],
+		ErrorCode:          fields[10],
+		Referer:            fields[15],
+		UserAgent:          fields[16],
+		VersionId:          fields[17],
+		HostId:             fields[18],
+		SignatureVersion:   fields[19],
+		CipherSuite:        fields[20],
+		AuthenticationType: fields[21],
+		HostHeader:         fields[22],
+		TLSVersion:         fields[23],
+		AccessPointARN:     fields[24],
+		ACLRequired:        fields[25],
+	}
+	if rec.Time, err = time.Parse(accessLogTimeFormat, fields[2]); err != nil {
+		return nil, fmt.Errorf("bad time %q", fields[2])
+	}
+	if fields[9] != "" {
+		if rec.HTTPStatus, err = strconv.Atoi(fields[9]); err != nil {
+			return nil, fmt.Errorf("bad HTTP status %q", fields[9])
+		}
+	}
+	ints := []struct {
+		name string
+		s    string
+		v    *int64
+	}{
+		{"bytes sent", fields[11], &rec.BytesSent},
+		{"object size", fields[12], &rec.ObjectSize},
+	}
+	for _, n := range ints {
+		if n.s == "" {
+			continue
+		}
+		if *n.v, err = strconv.ParseInt(n.s, 10, 64); err != nil {
+			return nil, fmt.Errorf("bad %s %q", n.name, n.s)
+		}
+	}
+	durations := []struct {
+		name string
+		s    string
+		v    *time.Duration
+	}{
+		{"total time", fields[13], &rec.TotalTime},
+		{"turn-around time", fields[14], &rec.TurnAroundTime},
+	}
+	for _, d := range durations {
+		if d.s == "" {
+			continue
+		}
+		ms, err := strconv.ParseInt(d.s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad %s %q", d.name, d.s)
+		}
+		*d.v = time.Duration(ms) * time.Millisecond
+	}
+	return rec, nil
+}
@@ -0,0 +1,103 @@
+package s3_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+// Sample records from the S3 documentation, the second one in the older,
+// shorter format.
+const accessLog = `79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be awsexamplebucket1 [06/Feb/2019:00:00:38 +0000] 192.0.2.3 79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be 3E57427F3EXAMPLE REST.GET.VERSIONING - "GET /awsexamplebucket1?versioning HTTP/1.1" 200 - 113 - 7 - "-" "S3Console/0.4" - s9lzHYrFp76ZVxRcpX9+5cjAnEH2ROuNkd2BHfIa6UkFVdtjf5mKR3/eTPFvsiP/XV/VLi31234= SigV4 ECDHE-RSA-AES128-GCM-SHA256 AuthHeader awsexamplebucket1.s3.us-west-1.amazonaws.com TLSV1.2 arn:aws:s3:us-west-1:123456789012:accesspoint/example-AP Yes
+
+79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be awsexamplebucket1 [06/Feb/2019:00:00:39 +0100] 192.0.2.3 - 891CE47D2EXAMPLE REST.GET.OBJECT photos/my%20puppy.jpg "GET /awsexamplebucket1/photos/my%20puppy.jpg HTTP/1.1" 404 NoSuchKey 243 - 12 - "https://example.com/" "Mozilla/5.0 (X11; Linux x86_64)" -
+`
+
+func (s *S) TestAccessLogReader(c *check.C) {
+	r := s3.NewAccessLogReader(strings.NewReader(accessLog))
+	rec, err := r.Read()
+	c.Assert(err, check.IsNil)
+	c.Assert(rec, check.DeepEquals, &s3.AccessLogRecord{
+		BucketOwner:        "79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be",
+		Bucket:             "awsexamplebucket1",
+		Time:               rec.Time,
+		RemoteIP:           "192.0.2.3",
+		Requester:          "79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be",
+		RequestId:          "3E57427F3EXAMPLE",
+		Operation:          "REST.GET.VERSIONING",
+		RequestURI:         "GET /awsexamplebucket1?versioning HTTP/1.1",
+		HTTPStatus:         200,
+		BytesSent:          113,
+		TotalTime:          7 * time.Millisecond,
+		UserAgent:          "S3Console/0.4",
+		HostId:             "s9lzHYrFp76ZVxRcpX9+5cjAnEH2ROuNkd2BHfIa6UkFVdtjf5mKR3/eTPFvsiP/XV/VLi31234=",
+		SignatureVersion:   "SigV4",
+		CipherSuite:        "ECDHE-RSA-AES128-GCM-SHA256",
+		AuthenticationType: "AuthHeader",
+		HostHeader:         "awsexamplebucket1.s3.us-west-1.amazonaws.com",
+		TLSVersion:         "TLSV1.2",
+		AccessPointARN:     "arn:aws:s3:us-west-1:123456789012:accesspoint/example-AP",
+		ACLRequired:        "Yes",
+	})
+	c.Assert(rec.Time.Equal(time.Date(2019, 2, 6, 0, 0, 38, 0, time.UTC)), check.Equals, true)
+
+	rec, err = r.Read()
+	c.Assert(err, check.IsNil)
+	c.Assert(rec.Time.Equal(time.Date(2019, 2, 5, 23, 0, 39, 0, time.UTC)), check.Equals, true)
+	c.Assert(rec.Requester, check.Equals, "")
+	c.Assert(rec.Key, check.Equals, "photos/my%20puppy.jpg")
+	c.Assert(rec.HTTPStatus, check.Equals, 404)
+	c.Assert(rec.ErrorCode, check.Equals, "NoSuchKey")
+	c.Assert(rec.Referer, check.Equals, "https://example.com/")
+	c.Assert(rec.UserAgent, check.Equals, "Mozilla/5.0 (X11; Linux x86_64)")
+	c.Assert(rec.HostId, check.Equals, "")
+	c.Assert(rec.SignatureVersion, check.Equals, "")
+
+	_, err = r.Read()
+	c.Assert(err, check.Equals, io.EOF)
+}
+
+func (s *S) TestAccessLogReaderErrors(c *check.C) {
+	line := strings.SplitN(accessLog, "\n", 2)[0]
+	for _, test := range []struct {
+		line, err string
+	}{
+		{`owner bucket [06/Feb/2019:00:00:38 +0000] "unterminated`, `unterminated field "\\"unterminated"`},
+		{"owner bucket [06/Feb/2019:00:00:38 +0000] 192.0.2.3", "found 4 fields, want at least 18"},
+		{strings.Replace(line, "06/Feb/2019", "2019-02-06", 1), `bad time "2019-02-06:00:00:38 \+0000"`},
+		{strings.Replace(line, " 113 ", " many ", 1), `bad bytes sent "many"`},
+	} {
+		_, err := s3.NewAccessLogReader(strings.NewReader("\n" + test.line)).Read()
+		c.Check(err, check.ErrorMatches, "s3: access log line 2: "+test.err)
+	}
+}
+
+func (s *S) TestReadAccessLog(c *check.C) {
+	testServer.Response(200, nil, accessLog)
+
+	var ops []string
+	b := s.s3.Bucket("logs")
+	err := b.ReadAccessLog("2019-02-06-00-00-38-EXAMPLE", func(rec *s3.AccessLogRecord) error {
+		ops = append(ops, rec.Operation)
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(ops, check.DeepEquals, []string{"REST.GET.VERSIONING", "REST.GET.OBJECT"})
+	req := testServer.WaitRequest()
+	c.Assert(req.URL.Path, check.Equals, "/logs/2019-02-06-00-00-38-EXAMPLE")
+
+	testServer.Response(200, nil, accessLog)
+	stop := errors.New("stop")
+	n := 0
+	err = b.ReadAccessLog("2019-02-06-00-00-38-EXAMPLE", func(rec *s3.AccessLogRecord) error {
+		n++
+		return stop
+	})
+	c.Assert(err, check.Equals, stop)
+	c.Assert(n, check.Equals, 1)
+	testServer.WaitRequest()
+}
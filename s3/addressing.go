@@ -0,0 +1,119 @@
+package s3
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/AdRoll/goamz/aws"
+)
+
+// Addressing selects how requests name the bucket they operate on.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/dev/VirtualHosting.html
+// for details.
+type Addressing int
+
+const (
+	// AutoAddressing uses the S3BucketEndpoint of the region when it has
+	// one, and path-style addressing otherwise. Buckets whose names are not
+	// valid host names, or contain dots and would not match the wildcard
+	// certificate of an HTTPS endpoint, are addressed path-style.
+	AutoAddressing Addressing = iota
+
+	// VirtualHostedAddressing puts the bucket name in the host name, as in
+	// https://bucket.s3.amazonaws.com/key. The host is taken from the
+	// S3BucketEndpoint of the region or, if it is empty, is the host of
+	// S3Endpoint prefixed with the bucket name.
+	VirtualHostedAddressing
+
+	// PathAddressing puts the bucket name in the path, as in
+	// https://s3.amazonaws.com/bucket/key.
+	PathAddressing
+)
+
+// NewCompatible returns a client for an S3-compatible store, such as MinIO
+// or Ceph RGW, serving the S3 API at endpoint. region is the region name
+// the store expects in credentials; MinIO uses "us-east-1" unless
+// configured otherwise. The client signs requests with Signature Version 4,
+// addresses buckets path-style and never sends a LocationConstraint when
+// creating buckets; change Signature, Addressing or Region as needed.
+func NewCompatible(auth aws.Auth, endpoint, region string) *S3 {
+	s3 := New(auth, aws.Region{
+		Name:       region,
+		S3Endpoint: strings.TrimRight(endpoint, "/"),
+	})
+	s3.Signature = aws.V4Signature
+	s3.Addressing = PathAddressing
+	return s3
+}
+
+// addressing returns the addressing style of requests on bucket.
+func (s3 *S3) addressing(bucket string) Addressing {
+	if s3.Addressing != AutoAddressing {
+		return s3.Addressing
+	}
+	endpoint := s3.Region.S3BucketEndpoint
+	switch {
+	case endpoint == "":
+		return PathAddressing
+	case s3.Region.S3Endpoint == "":
+		// There is no other way.
+		return VirtualHostedAddressing
+	case !validHostLabels(bucket):
+		return PathAddressing
+	case strings.Contains(bucket, ".") && strings.HasPrefix(endpoint, "https:"):
+		return PathAddressing
+	}
+	return VirtualHostedAddressing
+}
+
+// Sets baseurl on req from bucket name and the region endpoint
+func (s3 *S3) setBaseURL(req *request) error {
+	if req.bucket == "" {
+		req.baseurl = s3.Region.S3Endpoint
+		return nil
+	}
+	if s3.addressing(req.bucket) == PathAddressing {
+		req.baseurl = s3.Region.S3Endpoint
+		req.path = "/" + req.bucket + req.path
+		return nil
+	}
+	// Just in case, prevent injection.
+	if strings.IndexAny(req.bucket, "/:@") >= 0 || !validHostLabels(req.bucket) {
+		return fmt.Errorf("bad S3 bucket: %q", req.bucket)
+	}
+	if s3.Region.S3BucketEndpoint != "" {
+		req.baseurl = strings.Replace(s3.Region.S3BucketEndpoint, "${bucket}", req.bucket, -1)
+	} else {
+		u, err := url.Parse(s3.Region.S3Endpoint)
+		if err != nil {
+			return fmt.Errorf("bad S3 endpoint URL %q: %v", s3.Region.S3Endpoint, err)
+		}
+		u.Host = req.bucket + "." + u.Host
+		req.baseurl = u.String()
+	}
+	req.virtualHosted = true
+	return nil
+}
+
+// validHostLabels reports whether the bucket name can be used as part of a
+// host name: dot separated labels of lower case letters, digits and
+// hyphens, not formatted as an IP address.
+func validHostLabels(bucket string) bool {
+	if len(bucket) < 3 || len(bucket) > 63 || net.ParseIP(bucket) != nil {
+		return false
+	}
+	for _, label := range strings.Split(bucket, ".") {
+		if label == "" || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package s3_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAutoAddressing(c *check.C) {
+	auth := aws.Auth{AccessKey: "abc", SecretKey: "123"}
+	region := aws.Region{
+		Name:             "faux-region-1",
+		S3Endpoint:       "https://s3.example.com",
+		S3BucketEndpoint: "https://${bucket}.s3.example.com",
+	}
+	s3c := s3.New(auth, region)
+	c.Assert(s3c.Bucket("bucket").URL("key"), check.Equals, "https://bucket.s3.example.com/key")
+	// Dotted names do not match the wildcard certificate.
+	c.Assert(s3c.Bucket("my.bucket").URL("key"), check.Equals, "https://s3.example.com/my.bucket/key")
+	// Nor can underscores appear in host names.
+	c.Assert(s3c.Bucket("my_bucket").URL("key"), check.Equals, "https://s3.example.com/my_bucket/key")
+
+	region.S3BucketEndpoint = "http://${bucket}.s3.example.com"
+	s3c = s3.New(auth, region)
+	c.Assert(s3c.Bucket("my.bucket").URL("key"), check.Equals, "http://my.bucket.s3.example.com/key")
+
+	region.S3BucketEndpoint = ""
+	s3c = s3.New(auth, region)
+	c.Assert(s3c.Bucket("bucket").URL("key"), check.Equals, "https://s3.example.com/bucket/key")
+}
+
+func (s *S) TestExplicitAddressing(c *check.C) {
+	auth := aws.Auth{AccessKey: "abc", SecretKey: "123"}
+	region := aws.Region{
+		Name:             "faux-region-1",
+		S3Endpoint:       "https://s3.example.com",
+		S3BucketEndpoint: "https://${bucket}.s3.example.com",
+	}
+	s3c := s3.New(auth, region)
+	s3c.Addressing = s3.PathAddressing
+	c.Assert(s3c.Bucket("bucket").URL("key"), check.Equals, "https://s3.example.com/bucket/key")
+
+	region.S3BucketEndpoint = ""
+	s3c = s3.New(auth, region)
+	s3c.Addressing = s3.VirtualHostedAddressing
+	c.Assert(s3c.Bucket("bucket").URL("key"), check.Equals, "https://bucket.s3.example.com/key")
+
+	s.DisableRetries()
+	_, err := s3c.Bucket("Bad_Bucket").Get("key")
+	c.Assert(err, check.ErrorMatches, `bad S3 bucket: "Bad_Bucket"`)
+}
+
+func (s *S) TestVirtualHostedSignatureV2(c *check.C) {
+	testServer.Response(200, nil, "content")
+
+	auth := aws.Auth{AccessKey: "abc", SecretKey: "123"}
+	s3c := s3.New(auth, aws.Region{
+		Name:       "faux-region-1",
+		S3Endpoint: testServer.URL,
+		// The test server answers for any bucket.
+		S3BucketEndpoint: testServer.URL,
+	})
+	b := s3c.Bucket("bucket")
+	data, err := b.Get("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "content")
+
+	req := testServer.WaitRequest()
+	c.Assert(req.URL.Path, check.Equals, "/name")
+	headers := map[string][]string{"Date": req.Header["Date"]}
+	s3.Sign(auth, "GET", "/bucket/name", nil, headers)
+	c.Assert(req.Header.Get("Authorization"), check.Equals, headers["Authorization"][0])
+}
+
+func (s *S) TestNewCompatible(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	s3c := s3.NewCompatible(aws.Auth{AccessKey: "abc", SecretKey: "123"}, testServer.URL+"/", "us-east-1")
+	c.Assert(s3c.Signature, check.Equals, aws.V4Signature)
+	c.Assert(s3c.Addressing, check.Equals, s3.PathAddressing)
+
+	b := s3c.Bucket("Bucket.Name")
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.Path, check.Equals, "/Bucket.Name/")
+	body, err := ioutil.ReadAll(req.Body)
+	c.Assert(err, check.IsNil)
+	c.Assert(body, check.HasLen, 0)
+	auth := req.Header.Get("Authorization")
+	c.Assert(strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=abc/"), check.Equals, true)
+	c.Assert(auth, check.Matches, ".*/us-east-1/s3/aws4_request,.*")
+}
+
+func (s *S) TestNoLocationConstraintInUSEast(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	s3c := s3.New(aws.Auth{AccessKey: "abc", SecretKey: "123"}, aws.Region{
+		Name:                 "us-east-1",
+		S3Endpoint:           testServer.URL,
+		S3LocationConstraint: true,
+	})
+	err := s3c.Bucket("bucket").PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	body, err := ioutil.ReadAll(req.Body)
+	c.Assert(err, check.IsNil)
+	c.Assert(body, check.HasLen, 0)
+}
+
+func (s *S) TestPostFormArgsVirtualHosted(c *check.C) {
+	s3c := s3.New(aws.Auth{AccessKey: "abc", SecretKey: "123"}, aws.Region{
+		Name:             "faux-region-1",
+		S3Endpoint:       "https://s3.example.com",
+		S3BucketEndpoint: "https://${bucket}.s3.example.com",
+	})
+	action, _ := s3c.Bucket("bucket").PostFormArgs("key", time.Now().Add(time.Hour), "")
+	c.Assert(action, check.Equals, "https://bucket.s3.example.com/")
+}
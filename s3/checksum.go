@@ -0,0 +1,184 @@
+package s3
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ChecksumAlgorithm selects an additional checksum S3 verifies and stores
+// with an object, on top of its MD5 sum.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/userguide/checking-object-integrity.html
+// for details.
+type ChecksumAlgorithm string
+
+const (
+	ChecksumCRC32C = ChecksumAlgorithm("CRC32C")
+	ChecksumSHA256 = ChecksumAlgorithm("SHA256")
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func (a ChecksumAlgorithm) new() (hash.Hash, error) {
+	switch a {
+	case ChecksumCRC32C:
+		return crc32.New(crc32cTable), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	}
+	return nil, fmt.Errorf("s3: unsupported checksum algorithm %q", string(a))
+}
+
+// header returns the name of the header holding the base64-encoded
+// checksum.
+func (a ChecksumAlgorithm) header() string {
+	return "x-amz-checksum-" + strings.ToLower(string(a))
+}
+
+// ChecksumError is returned when the data transferred does not match the
+// checksum S3 has for it.
+type ChecksumError struct {
+	Algorithm string // "MD5", "CRC32C", "SHA256", or "ETag" for multipart uploads.
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("s3: %s checksum mismatch: expected %s, got %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+// addUploadChecksums reads length bytes from r and adds to headers the
+// checksums options asks for, before seeking r back to where it was.
+func addUploadChecksums(headers map[string][]string, r io.Reader, length int64, options Options) error {
+	wantMD5 := options.VerifyChecksum && len(options.ContentMD5) == 0
+	if !wantMD5 && options.ChecksumAlgorithm == "" {
+		return nil
+	}
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		return errors.New("s3: computing the checksum of an upload needs an io.ReadSeeker")
+	}
+	var w []io.Writer
+	md5sum := md5.New()
+	if wantMD5 {
+		w = append(w, md5sum)
+	}
+	var sum hash.Hash
+	if options.ChecksumAlgorithm != "" {
+		var err error
+		if sum, err = options.ChecksumAlgorithm.new(); err != nil {
+			return err
+		}
+		w = append(w, sum)
+	}
+	pos, err := rs.Seek(0, 1)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(io.MultiWriter(w...), rs, length); err != nil {
+		return err
+	}
+	if _, err := rs.Seek(pos, 0); err != nil {
+		return err
+	}
+	if wantMD5 {
+		headers["Content-MD5"] = []string{base64.StdEncoding.EncodeToString(md5sum.Sum(nil))}
+	}
+	if sum != nil {
+		headers["x-amz-checksum-algorithm"] = []string{string(options.ChecksumAlgorithm)}
+		headers[options.ChecksumAlgorithm.header()] = []string{base64.StdEncoding.EncodeToString(sum.Sum(nil))}
+	}
+	return nil
+}
+
+// verifyChecksums returns the body of resp, checking that it matches the
+// checksums S3 returned with it once it has been read to the end. The MD5
+// sum of an object is its ETag unless it was uploaded in parts or
+// encrypted with KMS or a customer key. Partial and transparently
+// decompressed bodies are not checked.
+func verifyChecksums(resp *http.Response) io.ReadCloser {
+	if resp.StatusCode != http.StatusOK || resp.Uncompressed {
+		return resp.Body
+	}
+	vr := &verifyingReader{ReadCloser: resp.Body}
+	for _, a := range []ChecksumAlgorithm{ChecksumCRC32C, ChecksumSHA256} {
+		expected := resp.Header.Get(a.header())
+		// Checksums of multipart uploads are checksums of checksums.
+		if expected == "" || strings.Contains(expected, "-") {
+			continue
+		}
+		h, _ := a.new()
+		vr.add(string(a), h, expected, base64.StdEncoding.EncodeToString)
+	}
+	etag := strings.Trim(resp.Header.Get("ETag"), `"`)
+	_, err := hex.DecodeString(etag)
+	if len(etag) == 2*md5.Size && err == nil &&
+		resp.Header.Get("x-amz-server-side-encryption") != string(KMSManaged) &&
+		resp.Header.Get("x-amz-server-side-encryption-customer-algorithm") == "" {
+		vr.add("MD5", md5.New(), etag, hex.EncodeToString)
+	}
+	if len(vr.checks) == 0 {
+		return resp.Body
+	}
+	return vr
+}
+
+type checksum struct {
+	algorithm string
+	hash      hash.Hash
+	expected  string
+	encode    func([]byte) string
+}
+
+type verifyingReader struct {
+	io.ReadCloser
+	checks []checksum
+}
+
+func (vr *verifyingReader) add(algorithm string, h hash.Hash, expected string, encode func([]byte) string) {
+	vr.checks = append(vr.checks, checksum{algorithm, h, expected, encode})
+}
+
+func (vr *verifyingReader) Read(p []byte) (int, error) {
+	n, err := vr.ReadCloser.Read(p)
+	for _, c := range vr.checks {
+		c.hash.Write(p[:n])
+	}
+	if err == io.EOF {
+		for _, c := range vr.checks {
+			if actual := c.encode(c.hash.Sum(nil)); actual != c.expected {
+				return n, &ChecksumError{c.algorithm, c.expected, actual}
+			}
+		}
+	}
+	return n, err
+}
+
+// multipartETag returns the ETag of the object assembled from parts: the
+// MD5 sum of the MD5 sums of the parts, followed by the number of parts.
+// The sums are taken from md5s, in hex by part number, or else from the
+// ETags of the parts.
+func multipartETag(parts completeParts, md5s map[int]string) (string, error) {
+	sum := md5.New()
+	for _, p := range parts {
+		partMD5, ok := md5s[p.PartNumber]
+		if !ok {
+			partMD5 = strings.Trim(p.ETag, `"`)
+		}
+		partSum, err := hex.DecodeString(partMD5)
+		if err != nil || len(partSum) != md5.Size {
+			return "", fmt.Errorf("s3: part %d has no MD5 ETag: %q", p.PartNumber, p.ETag)
+		}
+		sum.Write(partSum)
+	}
+	return fmt.Sprintf("%x-%d", sum.Sum(nil), len(parts)), nil
+}
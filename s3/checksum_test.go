@@ -0,0 +1,153 @@
+package s3_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"strings"
+
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+const contentMD5 = "9a0364b9e99bb480dd25e1f0284c8555" // MD5 sum of "content".
+
+func (s *S) getVerified(c *check.C, headers map[string]string, body string) ([]byte, error) {
+	testServer.Response(200, headers, body)
+
+	b := s.s3.Bucket("bucket")
+	rc, err := b.GetReaderWithOptions("name", s3.Options{VerifyChecksum: true})
+	c.Assert(err, check.IsNil)
+	defer rc.Close()
+	req := testServer.WaitRequest()
+	c.Assert(req.Header.Get("X-Amz-Checksum-Mode"), check.Equals, "ENABLED")
+	return ioutil.ReadAll(rc)
+}
+
+func (s *S) TestGetVerifyMD5(c *check.C) {
+	data, err := s.getVerified(c, map[string]string{"ETag": `"` + contentMD5 + `"`}, "content")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "content")
+
+	_, err = s.getVerified(c, map[string]string{"ETag": `"` + contentMD5 + `"`}, "corrupt")
+	c.Assert(err, check.FitsTypeOf, &s3.ChecksumError{})
+	c.Assert(err.(*s3.ChecksumError).Algorithm, check.Equals, "MD5")
+	c.Assert(err.(*s3.ChecksumError).Expected, check.Equals, contentMD5)
+	c.Assert(err, check.ErrorMatches, "s3: MD5 checksum mismatch: expected "+contentMD5+", got .*")
+}
+
+func (s *S) TestGetVerifySkipsOpaqueETags(c *check.C) {
+	// Neither the ETag of a multipart object nor that of an object
+	// encrypted with KMS is an MD5 sum.
+	_, err := s.getVerified(c, map[string]string{"ETag": `"3858f62230ac3c915f300c664312c11f-9"`}, "content")
+	c.Assert(err, check.IsNil)
+	_, err = s.getVerified(c, map[string]string{
+		"ETag":                         `"3858f62230ac3c915f300c664312c11f"`,
+		"X-Amz-Server-Side-Encryption": "aws:kms",
+	}, "content")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestGetVerifyAdditionalChecksums(c *check.C) {
+	crc := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	crc.Write([]byte("content"))
+	crc32c := base64.StdEncoding.EncodeToString(crc.Sum(nil))
+	sha := sha256.Sum256([]byte("content"))
+	sha256sum := base64.StdEncoding.EncodeToString(sha[:])
+
+	headers := map[string]string{
+		"X-Amz-Checksum-Crc32c": crc32c,
+		"X-Amz-Checksum-Sha256": sha256sum,
+	}
+	data, err := s.getVerified(c, headers, "content")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "content")
+
+	_, err = s.getVerified(c, map[string]string{"X-Amz-Checksum-Crc32c": crc32c}, "corrupt")
+	c.Assert(err, check.FitsTypeOf, &s3.ChecksumError{})
+	c.Assert(err.(*s3.ChecksumError).Algorithm, check.Equals, "CRC32C")
+
+	_, err = s.getVerified(c, map[string]string{"X-Amz-Checksum-Sha256": sha256sum}, "corrupt")
+	c.Assert(err, check.FitsTypeOf, &s3.ChecksumError{})
+	c.Assert(err.(*s3.ChecksumError).Algorithm, check.Equals, "SHA256")
+}
+
+func (s *S) TestPutChecksums(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.Put("name", []byte("content"), "text/plain", s3.Private, s3.Options{
+		VerifyChecksum:    true,
+		ChecksumAlgorithm: s3.ChecksumSHA256,
+	})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	sum := md5.Sum([]byte("content"))
+	sha := sha256.Sum256([]byte("content"))
+	c.Assert(req.Header.Get("Content-MD5"), check.Equals, base64.StdEncoding.EncodeToString(sum[:]))
+	c.Assert(req.Header.Get("X-Amz-Checksum-Algorithm"), check.Equals, "SHA256")
+	c.Assert(req.Header.Get("X-Amz-Checksum-Sha256"), check.Equals, base64.StdEncoding.EncodeToString(sha[:]))
+	c.Assert(readAll(req.Body), check.Equals, "content")
+
+	err = b.PutReader("name", ioutil.NopCloser(strings.NewReader("content")), 7, "text/plain", s3.Private, s3.Options{
+		ChecksumAlgorithm: s3.ChecksumCRC32C,
+	})
+	c.Assert(err, check.ErrorMatches, "s3: computing the checksum of an upload needs an io.ReadSeeker")
+}
+
+func (s *S) TestMultiCompleteVerifyETag(c *check.C) {
+	part1, part2 := md5.Sum([]byte("part1")), md5.Sum([]byte("part2"))
+	etag := md5.Sum(append(part1[:], part2[:]...))
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(200, nil, fmt.Sprintf(`<CompleteMultipartUploadResult><ETag>"%x-2"</ETag></CompleteMultipartUploadResult>`, etag))
+	testServer.Response(200, nil, MultiCompleteDump)
+
+	b := s.s3.Bucket("sample")
+	multi, err := b.InitMulti("multi", "text/plain", s3.Private, s3.Options{VerifyChecksum: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(multi.VerifyChecksum, check.Equals, true)
+
+	parts := []s3.Part{
+		{N: 2, ETag: fmt.Sprintf(`"%x"`, part2)},
+		{N: 1, ETag: fmt.Sprintf(`"%x"`, part1)},
+	}
+	err = multi.Complete(parts)
+	c.Assert(err, check.IsNil)
+
+	err = multi.Complete(parts)
+	c.Assert(err, check.FitsTypeOf, &s3.ChecksumError{})
+	c.Assert(err.(*s3.ChecksumError).Algorithm, check.Equals, "ETag")
+	c.Assert(err.(*s3.ChecksumError).Actual, check.Equals, "3858f62230ac3c915f300c664312c11f-9")
+}
+
+func (s *S) TestMultiPutPartVerifyETag(c *check.C) {
+	part1, part2 := md5.Sum([]byte("part1")), md5.Sum([]byte("part2"))
+	etag := md5.Sum(append(part1[:], part2[:]...))
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(200, map[string]string{"ETag": fmt.Sprintf(`"%x"`, part1)}, "")
+	testServer.Response(200, map[string]string{"ETag": fmt.Sprintf(`"%x"`, part1)}, "")
+	testServer.Response(200, nil, fmt.Sprintf(`<CompleteMultipartUploadResult><ETag>"%x-2"</ETag></CompleteMultipartUploadResult>`, etag))
+
+	b := s.s3.Bucket("sample")
+	multi, err := b.InitMulti("multi", "text/plain", s3.Private, s3.Options{VerifyChecksum: true})
+	c.Assert(err, check.IsNil)
+
+	p1, err := multi.PutPart(1, strings.NewReader("part1"))
+	c.Assert(err, check.IsNil)
+
+	// S3 returns the ETag of another part.
+	_, err = multi.PutPart(2, strings.NewReader("part2"))
+	c.Assert(err, check.FitsTypeOf, &s3.ChecksumError{})
+	c.Assert(err.(*s3.ChecksumError).Algorithm, check.Equals, "MD5")
+	c.Assert(err.(*s3.ChecksumError).Expected, check.Equals, fmt.Sprintf("%x", part2))
+
+	// The ETag of the object is computed from the MD5 sum of part 1 sent
+	// above, whatever the ETag of its Part says.
+	p1.ETag = fmt.Sprintf(`"%x"`, etag)
+	err = multi.Complete([]s3.Part{p1, {N: 2, ETag: fmt.Sprintf(`"%x"`, part2)}})
+	c.Assert(err, check.IsNil)
+}
@@ -25,3 +25,15 @@ func SetListPartsMax(n int) {
 func SetListMultiMax(n int) {
 	listMultiMax = n
 }
+
+func SetWalkMax(n int) {
+	walkMax = n
+}
+
+func SetDelMultiMax(n int) {
+	delMultiMax = n
+}
+
+func SetCopyPartSize(n int64) {
+	copyPartSize = n
+}
@@ -0,0 +1,257 @@
+package s3
+
+import (
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InventoryManifest describes an S3 Inventory report: the manifest.json
+// file written next to its data files.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/userguide/storage-inventory-location.html
+// for details.
+type InventoryManifest struct {
+	SourceBucket      string          `json:"sourceBucket"`
+	DestinationBucket string          `json:"destinationBucket"` // An ARN.
+	Version           string          `json:"version"`
+	CreationTimestamp string          `json:"creationTimestamp"` // Milliseconds since the epoch.
+	FileFormat        string          `json:"fileFormat"`        // Only "CSV" can be read.
+	FileSchema        string          `json:"fileSchema"`        // Comma-separated field names.
+	Files             []InventoryFile `json:"files"`
+}
+
+// InventoryFile is a data file of an inventory report.
+type InventoryFile struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	MD5Checksum string `json:"MD5checksum"` // Of the gzip-compressed file.
+}
+
+// Schema returns the names of the fields of the records of the report.
+func (m *InventoryManifest) Schema() []string {
+	fields := strings.Split(m.FileSchema, ",")
+	for i, f := range fields {
+		fields[i] = strings.TrimSpace(f)
+	}
+	return fields
+}
+
+// CreationTime returns the time the report was created at.
+func (m *InventoryManifest) CreationTime() (time.Time, error) {
+	ms, err := strconv.ParseInt(m.CreationTimestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("s3: bad inventory creation timestamp %q", m.CreationTimestamp)
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC(), nil
+}
+
+// InventoryRecord is an object, or a version of an object, listed in an
+// inventory report. Only the fields of the report schema are set.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/userguide/storage-inventory.html
+// for the meaning of each field.
+type InventoryRecord struct {
+	Bucket                       string
+	Key                          string // Decoded.
+	VersionId                    string
+	IsLatest                     bool
+	IsDeleteMarker               bool
+	Size                         int64
+	LastModified                 time.Time
+	ETag                         string // Without quotes.
+	StorageClass                 string
+	IsMultipartUploaded          bool
+	ReplicationStatus            string
+	EncryptionStatus             string
+	ObjectLockRetainUntilDate    time.Time
+	ObjectLockMode               string
+	ObjectLockLegalHoldStatus    string
+	IntelligentTieringAccessTier string
+	BucketKeyStatus              string
+	ChecksumAlgorithm            string
+
+	// Fields holds the raw value of every field of the record, including
+	// the ones not listed above, by their name in the schema.
+	Fields map[string]string
+}
+
+// InventoryReader reads the records of an inventory data file.
+type InventoryReader struct {
+	r      *csv.Reader
+	schema []string
+}
+
+// NewInventoryReader returns a reader of the records in r, an
+// uncompressed CSV data file of a report with the given schema.
+func NewInventoryReader(r io.Reader, schema []string) *InventoryReader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(schema)
+	cr.ReuseRecord = true
+	return &InventoryReader{cr, schema}
+}
+
+// Read returns the next record, or io.EOF when there are no more.
+func (r *InventoryReader) Read() (*InventoryRecord, error) {
+	fields, err := r.r.Read()
+	if err != nil {
+		if err != io.EOF {
+			err = fmt.Errorf("s3: inventory: %v", err)
+		}
+		return nil, err
+	}
+	rec := &InventoryRecord{Fields: make(map[string]string, len(fields))}
+	for i, v := range fields {
+		name := r.schema[i]
+		rec.Fields[name] = v
+		if err := rec.set(name, v); err != nil {
+			line, _ := r.r.FieldPos(i)
+			return nil, fmt.Errorf("s3: inventory line %d: bad %s %q", line, name, v)
+		}
+	}
+	return rec, nil
+}
+
+func (rec *InventoryRecord) set(name, v string) (err error) {
+	switch name {
+	case "Bucket":
+		rec.Bucket = v
+	case "Key":
+		rec.Key, err = url.QueryUnescape(v)
+	case "VersionId":
+		rec.VersionId = v
+	case "IsLatest":
+		rec.IsLatest, err = parseInventoryBool(v)
+	case "IsDeleteMarker":
+		rec.IsDeleteMarker, err = parseInventoryBool(v)
+	case "Size":
+		if v != "" {
+			rec.Size, err = strconv.ParseInt(v, 10, 64)
+		}
+	case "LastModifiedDate":
+		rec.LastModified, err = parseInventoryTime(v)
+	case "ETag":
+		rec.ETag = v
+	case "StorageClass":
+		rec.StorageClass = v
+	case "IsMultipartUploaded":
+		rec.IsMultipartUploaded, err = parseInventoryBool(v)
+	case "ReplicationStatus":
+		rec.ReplicationStatus = v
+	case "EncryptionStatus":
+		rec.EncryptionStatus = v
+	case "ObjectLockRetainUntilDate":
+		rec.ObjectLockRetainUntilDate, err = parseInventoryTime(v)
+	case "ObjectLockMode":
+		rec.ObjectLockMode = v
+	case "ObjectLockLegalHoldStatus":
+		rec.ObjectLockLegalHoldStatus = v
+	case "IntelligentTieringAccessTier":
+		rec.IntelligentTieringAccessTier = v
+	case "BucketKeyStatus":
+		rec.BucketKeyStatus = v
+	case "ChecksumAlgorithm":
+		rec.ChecksumAlgorithm = v
+	}
+	return err
+}
+
+func parseInventoryBool(v string) (bool, error) {
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+func parseInventoryTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// GetInventoryManifest retrieves the manifest.json file of an inventory
+// report stored at path.
+func (b *Bucket) GetInventoryManifest(path string) (*InventoryManifest, error) {
+	rc, err := b.GetReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	m := &InventoryManifest{}
+	if err := json.NewDecoder(rc).Decode(m); err != nil {
+		return nil, fmt.Errorf("s3: bad inventory manifest: %v", err)
+	}
+	return m, nil
+}
+
+// ReadInventory reads the data files of the inventory report described by
+// m, which must be stored in b, calling fn for each of their records in
+// turn. If fn returns an error, reading stops and that error is returned.
+//
+// Data files are streamed, and checked against the MD5 sums of the
+// manifest once read to the end: records read from a corrupt file before
+// a *ChecksumError is returned cannot be told apart from good ones.
+func (b *Bucket) ReadInventory(m *InventoryManifest, fn func(*InventoryRecord) error) error {
+	if m.FileFormat != "CSV" {
+		return fmt.Errorf("s3: unsupported inventory file format %q", m.FileFormat)
+	}
+	schema := m.Schema()
+	for _, f := range m.Files {
+		if err := b.readInventoryFile(f, schema, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bucket) readInventoryFile(f InventoryFile, schema []string, fn func(*InventoryRecord) error) error {
+	rc, err := b.GetReader(f.Key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	var sum hash.Hash
+	var r io.Reader = rc
+	if f.MD5Checksum != "" {
+		sum = md5.New()
+		r = io.TeeReader(rc, sum)
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("s3: inventory file %s: %v", f.Key, err)
+	}
+	ir := NewInventoryReader(gz, schema)
+	for {
+		rec, err := ir.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%v in %s", err, f.Key)
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	if sum == nil {
+		return nil
+	}
+	// Read anything left after the end of the gzip stream.
+	if _, err := io.Copy(sum, rc); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(sum.Sum(nil)); actual != f.MD5Checksum {
+		return &ChecksumError{"MD5", f.MD5Checksum, actual}
+	}
+	return nil
+}
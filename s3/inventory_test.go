@@ -0,0 +1,111 @@
+package s3_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+const inventoryManifest = `{
+    "sourceBucket": "example-source-bucket",
+    "destinationBucket": "arn:aws:s3:::example-inventory-destination-bucket",
+    "version": "2016-11-30",
+    "creationTimestamp": "1514944800000",
+    "fileFormat": "CSV",
+    "fileSchema": "Bucket, Key, VersionId, IsLatest, IsDeleteMarker, Size, LastModifiedDate, ETag, StorageClass, IsMultipartUploaded, ReplicationStatus, EncryptionStatus, ObjectLockRetainUntilDate, ObjectLockMode, ObjectLockLegalHoldStatus, FutureField",
+    "files": [
+        {
+            "key": "inventory/data/1.csv.gz",
+            "size": 0,
+            "MD5checksum": "%x"
+        }
+    ]
+}`
+
+const inventoryData = `"example-source-bucket","photos/my+puppy%2B1.jpg","v1","true","false","1024","2016-11-06T21:32:00.000Z","d41d8cd98f00b204e9800998ecf8427e","STANDARD","false","","SSE-S3","","","","future"
+"example-source-bucket","deleted","v2","false","true","","2016-11-06T21:33:00.000Z","","","","","","2030-01-01T00:00:00.000Z","COMPLIANCE","OFF",""
+`
+
+func gzipped(data string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(data))
+	w.Close()
+	return buf.Bytes()
+}
+
+func (s *S) TestReadInventory(c *check.C) {
+	gz := gzipped(inventoryData)
+	testServer.Response(200, nil, fmt.Sprintf(inventoryManifest, md5.Sum(gz)))
+	testServer.Response(200, nil, string(gz))
+
+	b := s.s3.Bucket("example-inventory-destination-bucket")
+	m, err := b.GetInventoryManifest("inventory/2016-11-06T21-32Z/manifest.json")
+	c.Assert(err, check.IsNil)
+	c.Assert(m.SourceBucket, check.Equals, "example-source-bucket")
+	c.Assert(m.Files, check.HasLen, 1)
+	c.Assert(m.Schema()[:3], check.DeepEquals, []string{"Bucket", "Key", "VersionId"})
+	created, err := m.CreationTime()
+	c.Assert(err, check.IsNil)
+	c.Assert(created, check.Equals, time.Date(2018, 1, 3, 2, 0, 0, 0, time.UTC))
+	testServer.WaitRequest()
+
+	var recs []*s3.InventoryRecord
+	err = b.ReadInventory(m, func(rec *s3.InventoryRecord) error {
+		recs = append(recs, rec)
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	req := testServer.WaitRequest()
+	c.Assert(req.URL.Path, check.Equals, "/example-inventory-destination-bucket/inventory/data/1.csv.gz")
+
+	c.Assert(recs, check.HasLen, 2)
+	c.Assert(recs[0].Key, check.Equals, "photos/my puppy+1.jpg")
+	c.Assert(recs[0].IsLatest, check.Equals, true)
+	c.Assert(recs[0].Size, check.Equals, int64(1024))
+	c.Assert(recs[0].LastModified, check.Equals, time.Date(2016, 11, 6, 21, 32, 0, 0, time.UTC))
+	c.Assert(recs[0].ETag, check.Equals, "d41d8cd98f00b204e9800998ecf8427e")
+	c.Assert(recs[0].EncryptionStatus, check.Equals, "SSE-S3")
+	c.Assert(recs[0].Fields["FutureField"], check.Equals, "future")
+	c.Assert(recs[1].IsDeleteMarker, check.Equals, true)
+	c.Assert(recs[1].Size, check.Equals, int64(0))
+	c.Assert(recs[1].ObjectLockRetainUntilDate, check.Equals, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.Assert(recs[1].ObjectLockMode, check.Equals, "COMPLIANCE")
+}
+
+func (s *S) TestReadInventoryErrors(c *check.C) {
+	b := s.s3.Bucket("example-inventory-destination-bucket")
+	m := &s3.InventoryManifest{FileFormat: "ORC"}
+	err := b.ReadInventory(m, nil)
+	c.Assert(err, check.ErrorMatches, `s3: unsupported inventory file format "ORC"`)
+
+	gz := gzipped(inventoryData)
+	m = &s3.InventoryManifest{
+		FileFormat: "CSV",
+		FileSchema: "Bucket, Key, VersionId, IsLatest, IsDeleteMarker, Size, LastModifiedDate, ETag, StorageClass, IsMultipartUploaded, ReplicationStatus, EncryptionStatus, ObjectLockRetainUntilDate, ObjectLockMode, ObjectLockLegalHoldStatus, FutureField",
+		Files:      []s3.InventoryFile{{Key: "1.csv.gz", MD5Checksum: "d41d8cd98f00b204e9800998ecf8427e"}},
+	}
+	testServer.Response(200, nil, string(gz))
+	n := 0
+	err = b.ReadInventory(m, func(rec *s3.InventoryRecord) error {
+		n++
+		return nil
+	})
+	c.Assert(err, check.FitsTypeOf, &s3.ChecksumError{})
+	c.Assert(n, check.Equals, 2)
+	testServer.WaitRequest()
+
+	m.Files[0].MD5Checksum = ""
+	testServer.Response(200, nil, string(gzipped(strings.Replace(inventoryData, `"1024"`, `"big"`, 1))))
+	err = b.ReadInventory(m, func(rec *s3.InventoryRecord) error {
+		return nil
+	})
+	c.Assert(err, check.ErrorMatches, `s3: inventory line 1: bad Size "big" in 1.csv.gz`)
+	testServer.WaitRequest()
+}
@@ -7,12 +7,14 @@ import (
 	"encoding/hex"
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // Multi represents an unfinished multipart upload.
@@ -26,6 +28,20 @@ type Multi struct {
 	Bucket   *Bucket
 	Key      string
 	UploadId string
+	// Progress, if not nil, is called as parts are sent by PutPart and
+	// PutAll.
+	Progress ProgressFunc `xml:"-"`
+	// VerifyChecksum, if set, makes PutPart and PutAll check the ETag of
+	// each part against its MD5 sum, and Complete check that the ETag of
+	// the object is the one expected of the MD5 sums of its parts. It does not hold for
+	// objects encrypted with KMS or a customer key.
+	VerifyChecksum bool `xml:"-"`
+
+	progressOnce sync.Once
+	progress     *transfer
+
+	mu   sync.Mutex
+	md5s map[int]string // the MD5 sums of the parts sent, in hex.
 }
 
 // That's the default. Here just for testing.
@@ -138,12 +154,29 @@ func (b *Bucket) InitMulti(key string, contType string, perm ACL, options Option
 	if err != nil {
 		return nil, err
 	}
-	return &Multi{Bucket: b, Key: key, UploadId: resp.UploadId}, nil
+	return &Multi{
+		Bucket:         b,
+		Key:            key,
+		UploadId:       resp.UploadId,
+		Progress:       options.Progress,
+		VerifyChecksum: options.VerifyChecksum && !options.SSEKMS && len(options.SSECustomerKey) == 0,
+	}, nil
 }
 
 func (m *Multi) PutPartCopy(n int, options CopyOptions, source string) (*CopyObjectResult, Part, error) {
+	sourceBucket := m.Bucket.S3.Bucket(strings.TrimRight(strings.SplitAfterN(source, "/", 2)[0], "/"))
+	sourceMeta, err := sourceBucket.HeadVersion(strings.SplitAfterN(source, "/", 2)[1], options.CopySourceVersionId, nil)
+	if err != nil {
+		return nil, Part{}, err
+	}
+	return m.putPartCopy(n, options, source, sourceMeta.ContentLength)
+}
+
+// putPartCopy copies part n of the multipart upload, of partSize bytes,
+// from source.
+func (m *Multi) putPartCopy(n int, options CopyOptions, source string, partSize int64) (*CopyObjectResult, Part, error) {
 	headers := map[string][]string{
-		"x-amz-copy-source": {url.QueryEscape(source)},
+		"x-amz-copy-source": {url.QueryEscape(source) + options.copySourceVersion()},
 	}
 	options.addHeaders(headers)
 	params := map[string][]string{
@@ -151,12 +184,7 @@ func (m *Multi) PutPartCopy(n int, options CopyOptions, source string) (*CopyObj
 		"partNumber": {strconv.FormatInt(int64(n), 10)},
 	}
 
-	sourceBucket := m.Bucket.S3.Bucket(strings.TrimRight(strings.SplitAfterN(source, "/", 2)[0], "/"))
-	sourceMeta, err := sourceBucket.Head(strings.SplitAfterN(source, "/", 2)[1], nil)
-	if err != nil {
-		return nil, Part{}, err
-	}
-
+	var err error
 	for attempt := attempts.Start(); attempt.Next(); {
 		req := &request{
 			method:  "PUT",
@@ -176,24 +204,146 @@ func (m *Multi) PutPartCopy(n int, options CopyOptions, source string) (*CopyObj
 		if resp.ETag == "" {
 			return nil, Part{}, errors.New("part upload succeeded with no ETag")
 		}
-		return resp, Part{n, resp.ETag, sourceMeta.ContentLength}, nil
+		return resp, Part{n, resp.ETag, partSize}, nil
 	}
 	panic("unreachable")
 }
 
+// The size of the parts copied by CopyLarge, and how many are copied at
+// once. Variables just for testing.
+var (
+	copyPartSize    int64 = 512 << 20
+	copyConcurrency       = 8
+)
+
+// S3 accepts at most 10000 parts in a multipart upload.
+const maxParts = 10000
+
+// CopyLarge copies the object source, given as "bucket/key", to path in b.
+// Unlike PutCopy, which S3 limits to objects of 5 GB, it copies large
+// objects with a multipart upload whose parts S3 copies concurrently.
+// Unless options.MetadataDirective is "REPLACE", the content type and
+// metadata of the source are preserved. The copy fails if the source
+// changes while it is in progress. Objects small enough to fit in a single
+// part are copied with PutCopy.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/dev/CopyingObjctsMPUapi.html
+// for details.
+func (b *Bucket) CopyLarge(path string, perm ACL, options CopyOptions, source string) error {
+	i := strings.Index(source, "/")
+	if i <= 0 || i == len(source)-1 {
+		return fmt.Errorf("s3: bad copy source %q", source)
+	}
+	head, err := b.S3.Bucket(source[:i]).HeadVersion(source[i+1:], options.CopySourceVersionId, nil)
+	if err != nil {
+		return err
+	}
+	if head.ContentLength <= copyPartSize {
+		_, err := b.PutCopy(path, perm, options, source)
+		return err
+	}
+
+	contType := options.ContentType
+	initOptions := options.Options
+	initOptions.ContentMD5 = ""
+	if options.MetadataDirective != "REPLACE" {
+		contType = head.Header.Get("Content-Type")
+		initOptions.ContentEncoding = head.Header.Get("Content-Encoding")
+		initOptions.CacheControl = head.Header.Get("Cache-Control")
+		initOptions.ContentDisposition = head.Header.Get("Content-Disposition")
+		initOptions.Meta = make(map[string][]string)
+		for k, v := range head.Header {
+			if strings.HasPrefix(k, "X-Amz-Meta-") {
+				initOptions.Meta[strings.ToLower(k[len("X-Amz-Meta-"):])] = v
+			}
+		}
+	}
+	// Parts are copied with the preconditions on the source only.
+	partOptions := CopyOptions{
+		CopySourceVersionId:         options.CopySourceVersionId,
+		CopySourceIfMatch:           options.CopySourceIfMatch,
+		CopySourceIfNoneMatch:       options.CopySourceIfNoneMatch,
+		CopySourceIfModifiedSince:   options.CopySourceIfModifiedSince,
+		CopySourceIfUnmodifiedSince: options.CopySourceIfUnmodifiedSince,
+	}
+	if partOptions.CopySourceIfMatch == "" {
+		partOptions.CopySourceIfMatch = head.Header.Get("ETag")
+	}
+
+	multi, err := b.InitMulti(path, contType, perm, initOptions)
+	if err != nil {
+		return err
+	}
+	parts, err := multi.copyParts(partOptions, source, head.ContentLength)
+	if err == nil {
+		err = multi.Complete(parts)
+	}
+	if err != nil {
+		multi.Abort()
+		return err
+	}
+	return nil
+}
+
+// copyParts copies the size bytes of source into the parts of m,
+// copyConcurrency at a time.
+func (m *Multi) copyParts(options CopyOptions, source string, size int64) ([]Part, error) {
+	partSize := copyPartSize
+	if min := (size + maxParts - 1) / maxParts; partSize < min {
+		partSize = min
+	}
+	n := int((size + partSize - 1) / partSize)
+	parts := make([]Part, n)
+	errs := make([]error, n)
+	sem := make(chan bool, copyConcurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		start := int64(i) * partSize
+		end := start + partSize
+		if end > size {
+			end = size
+		}
+		o := options
+		o.CopySourceOptions = fmt.Sprintf("bytes=%d-%d", start, end-1)
+		sem <- true
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, parts[i], errs[i] = m.putPartCopy(i+1, o, source, end-start)
+			<-sem
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return parts, nil
+}
+
 // PutPart sends part n of the multipart upload, reading all the content from r.
 // Each part, except for the last one, must be at least 5MB in size.
 //
 // See http://goo.gl/pqZer for details.
 func (m *Multi) PutPart(n int, r io.ReadSeeker) (Part, error) {
-	partSize, _, md5b64, err := seekerInfo(r)
+	partSize, md5hex, md5b64, err := seekerInfo(r)
 	if err != nil {
 		return Part{}, err
 	}
-	return m.putPart(n, r, partSize, md5b64)
+	return m.putPart(n, r, partSize, md5hex, md5b64)
+}
+
+// transfer returns the progress of the parts sent, or nil if m.Progress is
+// nil.
+func (m *Multi) transfer() *transfer {
+	m.progressOnce.Do(func() {
+		m.progress = newTransfer(m.Progress, -1)
+	})
+	return m.progress
 }
 
-func (m *Multi) putPart(n int, r io.ReadSeeker, partSize int64, md5b64 string) (Part, error) {
+func (m *Multi) putPart(n int, r io.ReadSeeker, partSize int64, md5hex, md5b64 string) (Part, error) {
 	headers := map[string][]string{
 		"Content-Length": {strconv.FormatInt(partSize, 10)},
 		"Content-MD5":    {md5b64},
@@ -219,8 +369,10 @@ func (m *Multi) putPart(n int, r io.ReadSeeker, partSize int64, md5b64 string) (
 		if err != nil {
 			return Part{}, err
 		}
+		req.progress = m.transfer()
 		resp, err := m.Bucket.S3.run(req, nil)
 		if shouldRetry(err) && attempt.HasNext() {
+			req.progress.retry(req.sent)
 			continue
 		}
 		if err != nil {
@@ -230,11 +382,33 @@ func (m *Multi) putPart(n int, r io.ReadSeeker, partSize int64, md5b64 string) (
 		if etag == "" {
 			return Part{}, errors.New("part upload succeeded with no ETag")
 		}
+		if err := m.checkPart(n, etag, md5hex); err != nil {
+			return Part{}, err
+		}
+		req.progress.partDone(0)
 		return Part{n, etag, partSize}, nil
 	}
 	panic("unreachable")
 }
 
+// checkPart checks, if m.VerifyChecksum is set, that the ETag S3 returned
+// for part n is its MD5 sum, and records the sum for Complete.
+func (m *Multi) checkPart(n int, etag, md5hex string) error {
+	if !m.VerifyChecksum {
+		return nil
+	}
+	if actual := strings.Trim(etag, `"`); actual != md5hex {
+		return &ChecksumError{"MD5", md5hex, actual}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.md5s == nil {
+		m.md5s = make(map[int]string)
+	}
+	m.md5s[n] = md5hex
+	return nil
+}
+
 func seekerInfo(r io.ReadSeeker) (size int64, md5hex string, md5b64 string, err error) {
 	_, err = r.Seek(0, 0)
 	if err != nil {
@@ -343,6 +517,11 @@ func (m *Multi) PutAll(r ReaderAtSeeker, partSize int64) ([]Part, error) {
 		return nil, err
 	}
 	first := true // Must send at least one empty part if the file is empty.
+	parts := 1
+	if totalSize > partSize {
+		parts = int((totalSize + partSize - 1) / partSize)
+	}
+	m.transfer().expect(totalSize, parts)
 	var result []Part
 NextSection:
 	for offset := int64(0); offset < totalSize || first; offset += partSize {
@@ -361,6 +540,10 @@ NextSection:
 			etag := `"` + md5hex + `"`
 			if part.N == current && part.Size == partSize && part.ETag == etag {
 				// Checksum matches. Reuse the old part.
+				if err := m.checkPart(part.N, part.ETag, md5hex); err != nil {
+					return nil, err
+				}
+				m.transfer().partDone(partSize)
 				result = append(result, *part)
 				current++
 				continue NextSection
@@ -369,7 +552,7 @@ NextSection:
 		}
 
 		// Part wasn't found or doesn't match. Send it.
-		part, err := m.putPart(current, section, partSize, md5b64)
+		part, err := m.putPart(current, section, partSize, md5hex, md5b64)
 		if err != nil {
 			return nil, err
 		}
@@ -395,6 +578,29 @@ func (p completeParts) Len() int           { return len(p) }
 func (p completeParts) Less(i, j int) bool { return p[i].PartNumber < p[j].PartNumber }
 func (p completeParts) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
+// verifyETag checks that the ETag in the CompleteMultipartUploadResult
+// with the given inner XML is the one of the object assembled from parts,
+// computed from the MD5 sums of the parts sent by m, and from the ETags
+// of the other parts.
+func (m *Multi) verifyETag(parts completeParts, innerXML string) error {
+	var result struct {
+		ETag string
+	}
+	if err := xml.Unmarshal([]byte("<CompleteMultipartUploadResult>"+innerXML+"</CompleteMultipartUploadResult>"), &result); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	expected, err := multipartETag(parts, m.md5s)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if actual := strings.Trim(result.ETag, `"`); actual != expected {
+		return &ChecksumError{"ETag", expected, actual}
+	}
+	return nil
+}
+
 // We can't know in advance whether we'll have an Error or a
 // CompleteMultipartUploadResult, so this structure is just a placeholder to
 // know the name of the XML object.
@@ -464,6 +670,9 @@ func (m *Multi) Complete(parts []Part) error {
 		if resp.XMLName.Local == "CompleteMultipartUploadResult" {
 			// FIXME: One could probably add a CompleteFull method returning the
 			// actual contents of the CompleteMultipartUploadResult object.
+			if m.VerifyChecksum {
+				return m.verifyETag(c.Parts, resp.InnerXML)
+			}
 			return nil
 		}
 
@@ -0,0 +1,264 @@
+package s3
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Implements an interface for s3 bucket event notification configuration
+// and for the event messages S3 delivers to the configured targets.
+// See http://docs.aws.amazon.com/AmazonS3/latest/dev/NotificationHowTo.html
+// for details.
+
+type EventType string
+
+const (
+	ObjectCreatedAll                     = EventType("s3:ObjectCreated:*")
+	ObjectCreatedPut                     = EventType("s3:ObjectCreated:Put")
+	ObjectCreatedPost                    = EventType("s3:ObjectCreated:Post")
+	ObjectCreatedCopy                    = EventType("s3:ObjectCreated:Copy")
+	ObjectCreatedCompleteMultipartUpload = EventType("s3:ObjectCreated:CompleteMultipartUpload")
+	ObjectRemovedAll                     = EventType("s3:ObjectRemoved:*")
+	ObjectRemovedDelete                  = EventType("s3:ObjectRemoved:Delete")
+	ObjectRemovedDeleteMarkerCreated     = EventType("s3:ObjectRemoved:DeleteMarkerCreated")
+	ObjectRestoreAll                     = EventType("s3:ObjectRestore:*")
+	ObjectRestorePost                    = EventType("s3:ObjectRestore:Post")
+	ObjectRestoreCompleted               = EventType("s3:ObjectRestore:Completed")
+	ReducedRedundancyLostObject          = EventType("s3:ReducedRedundancyLostObject")
+)
+
+const (
+	FilterRulePrefix = "prefix"
+	FilterRuleSuffix = "suffix"
+)
+
+type FilterRule struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+type NotificationFilter struct {
+	FilterRules []FilterRule `xml:"S3Key>FilterRule"`
+}
+
+type QueueConfiguration struct {
+	Id     string              `xml:"Id,omitempty"`
+	Filter *NotificationFilter `xml:"Filter,omitempty"`
+	Queue  string              `xml:"Queue"`
+	Events []EventType         `xml:"Event"`
+}
+
+type TopicConfiguration struct {
+	Id     string              `xml:"Id,omitempty"`
+	Filter *NotificationFilter `xml:"Filter,omitempty"`
+	Topic  string              `xml:"Topic"`
+	Events []EventType         `xml:"Event"`
+}
+
+type LambdaFunctionConfiguration struct {
+	Id             string              `xml:"Id,omitempty"`
+	Filter         *NotificationFilter `xml:"Filter,omitempty"`
+	LambdaFunction string              `xml:"CloudFunction"`
+	Events         []EventType         `xml:"Event"`
+}
+
+type NotificationConfiguration struct {
+	XMLName                      xml.Name                      `xml:"NotificationConfiguration"`
+	QueueConfigurations          []QueueConfiguration          `xml:"QueueConfiguration,omitempty"`
+	TopicConfigurations          []TopicConfiguration          `xml:"TopicConfiguration,omitempty"`
+	LambdaFunctionConfigurations []LambdaFunctionConfiguration `xml:"CloudFunctionConfiguration,omitempty"`
+}
+
+// NewNotificationFilter returns a filter matching keys that begin with
+// prefix and end with suffix. Empty values are left out of the filter.
+func NewNotificationFilter(prefix, suffix string) *NotificationFilter {
+	f := &NotificationFilter{}
+	if prefix != "" {
+		f.FilterRules = append(f.FilterRules, FilterRule{Name: FilterRulePrefix, Value: prefix})
+	}
+	if suffix != "" {
+		f.FilterRules = append(f.FilterRules, FilterRule{Name: FilterRuleSuffix, Value: suffix})
+	}
+	return f
+}
+
+// Adds a notification sent to the SQS queue with the given ARN.
+func (c *NotificationConfiguration) AddQueue(id, queueArn string, filter *NotificationFilter, events ...EventType) {
+	c.QueueConfigurations = append(c.QueueConfigurations, QueueConfiguration{
+		Id:     id,
+		Filter: filter,
+		Queue:  queueArn,
+		Events: events,
+	})
+}
+
+// Adds a notification published to the SNS topic with the given ARN.
+func (c *NotificationConfiguration) AddTopic(id, topicArn string, filter *NotificationFilter, events ...EventType) {
+	c.TopicConfigurations = append(c.TopicConfigurations, TopicConfiguration{
+		Id:     id,
+		Filter: filter,
+		Topic:  topicArn,
+		Events: events,
+	})
+}
+
+// Adds a notification invoking the Lambda function with the given ARN.
+func (c *NotificationConfiguration) AddLambdaFunction(id, functionArn string, filter *NotificationFilter, events ...EventType) {
+	c.LambdaFunctionConfigurations = append(c.LambdaFunctionConfigurations, LambdaFunctionConfiguration{
+		Id:             id,
+		Filter:         filter,
+		LambdaFunction: functionArn,
+		Events:         events,
+	})
+}
+
+// Sets the bucket's notification configuration. An empty configuration
+// disables all notifications on the bucket.
+func (b *Bucket) PutNotificationConfiguration(c *NotificationConfiguration) error {
+	doc, err := xml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	buf := makeXmlBuffer(doc)
+
+	headers := map[string][]string{
+		"Content-Length": {strconv.FormatInt(int64(buf.Len()), 10)},
+	}
+
+	req := &request{
+		path:    "/",
+		method:  "PUT",
+		bucket:  b.Name,
+		headers: headers,
+		payload: buf,
+		params:  url.Values{"notification": {""}},
+	}
+
+	return b.S3.query(req, nil)
+}
+
+// Retrieves the notification configuration for the bucket. A bucket
+// without notifications returns an empty configuration.
+func (b *Bucket) GetNotificationConfiguration() (*NotificationConfiguration, error) {
+	req := &request{
+		method: "GET",
+		bucket: b.Name,
+		path:   "/",
+		params: url.Values{"notification": {""}},
+	}
+
+	conf := &NotificationConfiguration{}
+	var err error
+	for attempt := attempts.Start(); attempt.Next(); {
+		err = b.S3.query(req, conf)
+		if !shouldRetry(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// The EventIdentity type holds the principal that caused an event or
+// that owns the bucket.
+type EventIdentity struct {
+	PrincipalId string `json:"principalId"`
+}
+
+type EventBucket struct {
+	Name          string        `json:"name"`
+	OwnerIdentity EventIdentity `json:"ownerIdentity"`
+	Arn           string        `json:"arn"`
+}
+
+type EventObject struct {
+	// Key is URL-decoded by ParseEventRecords.
+	Key       string `json:"key"`
+	Size      int64  `json:"size"`
+	ETag      string `json:"eTag"`
+	VersionId string `json:"versionId"`
+	Sequencer string `json:"sequencer"`
+}
+
+type EventEntity struct {
+	SchemaVersion   string      `json:"s3SchemaVersion"`
+	ConfigurationId string      `json:"configurationId"`
+	Bucket          EventBucket `json:"bucket"`
+	Object          EventObject `json:"object"`
+}
+
+type EventGlacierRestore struct {
+	LifecycleRestorationExpiryTime time.Time `json:"lifecycleRestorationExpiryTime"`
+	LifecycleRestoreStorageClass   string    `json:"lifecycleRestoreStorageClass"`
+}
+
+type EventGlacier struct {
+	RestoreEventData EventGlacierRestore `json:"restoreEventData"`
+}
+
+// The EventRecord type represents a single record of an S3 event
+// notification message.
+type EventRecord struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AwsRegion         string            `json:"awsRegion"`
+	EventTime         time.Time         `json:"eventTime"`
+	EventName         string            `json:"eventName"`
+	UserIdentity      EventIdentity     `json:"userIdentity"`
+	RequestParameters map[string]string `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                EventEntity       `json:"s3"`
+	GlacierEventData  *EventGlacier     `json:"glacierEventData,omitempty"`
+}
+
+// EventType returns the record's event name in the "s3:" prefixed form
+// used by the notification configuration.
+func (r *EventRecord) EventType() EventType {
+	return EventType("s3:" + r.EventName)
+}
+
+type eventMessage struct {
+	Records []EventRecord
+	// Set on the test message S3 sends when a configuration is saved.
+	Event string
+	// Set when the message was delivered through an SNS topic.
+	Type    string
+	Message string
+}
+
+// ErrTestEvent is returned by ParseEventRecords for the s3:TestEvent
+// message S3 sends to a target when a notification configuration is saved.
+var ErrTestEvent = errors.New("s3: test event")
+
+// ParseEventRecords decodes an S3 event notification message as delivered
+// to an SQS queue, an SNS subscriber or a Lambda function. Messages that
+// went through an SNS topic before reaching a queue are unwrapped. Object
+// keys are URL-decoded.
+func ParseEventRecords(data []byte) ([]EventRecord, error) {
+	var msg eventMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Type == "Notification" && msg.Records == nil {
+		return ParseEventRecords([]byte(msg.Message))
+	}
+	if msg.Event == "s3:TestEvent" {
+		return nil, ErrTestEvent
+	}
+	for i := range msg.Records {
+		obj := &msg.Records[i].S3.Object
+		key, err := url.QueryUnescape(obj.Key)
+		if err != nil {
+			return nil, err
+		}
+		obj.Key = key
+	}
+	return msg.Records, nil
+}
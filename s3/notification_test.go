@@ -0,0 +1,93 @@
+package s3_test
+
+import (
+	"io/ioutil"
+
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestPutNotificationConfiguration(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	conf := &s3.NotificationConfiguration{}
+	conf.AddQueue("images", "arn:aws:sqs:us-east-1:123456789012:queue",
+		s3.NewNotificationFilter("images/", ".jpg"), s3.ObjectCreatedAll)
+	conf.AddTopic("", "arn:aws:sns:us-east-1:123456789012:topic", nil,
+		s3.ObjectRemovedDelete, s3.ObjectRemovedDeleteMarkerCreated)
+	conf.AddLambdaFunction("fn", "arn:aws:lambda:us-east-1:123456789012:function:fn",
+		s3.NewNotificationFilter("", ".txt"), s3.ObjectCreatedPut)
+
+	b := s.s3.Bucket("bucket")
+	err := b.PutNotificationConfiguration(conf)
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/")
+	c.Assert(req.URL.RawQuery, check.Equals, "notification=")
+
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, NotificationConfigurationDump)
+}
+
+func (s *S) TestGetNotificationConfiguration(c *check.C) {
+	testServer.Response(200, nil, NotificationConfigurationDump)
+
+	b := s.s3.Bucket("bucket")
+	conf, err := b.GetNotificationConfiguration()
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/")
+	c.Assert(req.URL.RawQuery, check.Equals, "notification=")
+
+	c.Assert(conf.QueueConfigurations, check.HasLen, 1)
+	c.Assert(conf.QueueConfigurations[0], check.DeepEquals, s3.QueueConfiguration{
+		Id:     "images",
+		Filter: s3.NewNotificationFilter("images/", ".jpg"),
+		Queue:  "arn:aws:sqs:us-east-1:123456789012:queue",
+		Events: []s3.EventType{s3.ObjectCreatedAll},
+	})
+	c.Assert(conf.TopicConfigurations, check.HasLen, 1)
+	c.Assert(conf.TopicConfigurations[0].Filter, check.IsNil)
+	c.Assert(conf.TopicConfigurations[0].Events, check.DeepEquals,
+		[]s3.EventType{s3.ObjectRemovedDelete, s3.ObjectRemovedDeleteMarkerCreated})
+	c.Assert(conf.LambdaFunctionConfigurations, check.HasLen, 1)
+	c.Assert(conf.LambdaFunctionConfigurations[0].LambdaFunction, check.Equals,
+		"arn:aws:lambda:us-east-1:123456789012:function:fn")
+}
+
+func (s *S) TestParseEventRecords(c *check.C) {
+	records, err := s3.ParseEventRecords([]byte(ObjectCreatedEventDump))
+	c.Assert(err, check.IsNil)
+	c.Assert(records, check.HasLen, 1)
+
+	r := records[0]
+	c.Assert(r.EventSource, check.Equals, "aws:s3")
+	c.Assert(r.AwsRegion, check.Equals, "us-west-2")
+	c.Assert(r.EventType(), check.Equals, s3.ObjectCreatedPut)
+	c.Assert(r.EventTime.Year(), check.Equals, 2015)
+	c.Assert(r.RequestParameters["sourceIPAddress"], check.Equals, "127.0.0.1")
+	c.Assert(r.S3.ConfigurationId, check.Equals, "images")
+	c.Assert(r.S3.Bucket.Name, check.Equals, "sourcebucket")
+	c.Assert(r.S3.Object.Key, check.Equals, "images/happy face+1.jpg")
+	c.Assert(r.S3.Object.Size, check.Equals, int64(1024))
+	c.Assert(r.S3.Object.VersionId, check.Equals, "v1")
+}
+
+func (s *S) TestParseEventRecordsFromSNS(c *check.C) {
+	records, err := s3.ParseEventRecords([]byte(SNSWrappedEventDump))
+	c.Assert(err, check.IsNil)
+	c.Assert(records, check.HasLen, 1)
+	c.Assert(records[0].S3.Object.Key, check.Equals, "a b")
+}
+
+func (s *S) TestParseEventRecordsTestEvent(c *check.C) {
+	records, err := s3.ParseEventRecords([]byte(TestEventDump))
+	c.Assert(err, check.Equals, s3.ErrTestEvent)
+	c.Assert(records, check.IsNil)
+}
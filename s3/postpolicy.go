@@ -0,0 +1,194 @@
+package s3
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+)
+
+// PostPolicy describes the conditions a browser-based POST upload must
+// meet, and the form fields that satisfy them.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html
+// for details.
+type PostPolicy struct {
+	Expiration time.Time
+	conditions []interface{}
+	raw        []string // conditions added unchecked by PostFormArgsEx.
+	fields     map[string]string
+}
+
+// NewPostPolicy returns an empty policy valid until expiration.
+func NewPostPolicy(expiration time.Time) *PostPolicy {
+	return &PostPolicy{Expiration: expiration, fields: make(map[string]string)}
+}
+
+// AddCondition adds a condition on the form field named field. match is
+// either "eq", requiring the field to be value, or "starts-with", requiring
+// it to begin with value. Eq conditions also set the form field.
+func (p *PostPolicy) AddCondition(match, field, value string) error {
+	switch match {
+	case "eq":
+		p.fields[field] = value
+	case "starts-with":
+	default:
+		return fmt.Errorf("s3: bad POST policy match type %q", match)
+	}
+	p.conditions = append(p.conditions, []string{match, "$" + field, value})
+	return nil
+}
+
+// SetKey requires the object to be uploaded at key.
+func (p *PostPolicy) SetKey(key string) {
+	p.AddCondition("eq", "key", key)
+}
+
+// SetKeyStartsWith requires the object key to begin with prefix. The key
+// form field defaults to prefix followed by the name of the uploaded file.
+func (p *PostPolicy) SetKeyStartsWith(prefix string) {
+	p.AddCondition("starts-with", "key", prefix)
+	p.fields["key"] = prefix + "${filename}"
+}
+
+// SetContentType requires the Content-Type of the object to be contType.
+func (p *PostPolicy) SetContentType(contType string) {
+	p.AddCondition("eq", "Content-Type", contType)
+}
+
+// SetContentTypeStartsWith requires the Content-Type of the object to begin
+// with prefix, such as "image/".
+func (p *PostPolicy) SetContentTypeStartsWith(prefix string) {
+	p.AddCondition("starts-with", "Content-Type", prefix)
+}
+
+// SetContentLengthRange requires the size of the uploaded object to be
+// between min and max bytes inclusive.
+func (p *PostPolicy) SetContentLengthRange(min, max int64) error {
+	if min < 0 || min > max {
+		return fmt.Errorf("s3: bad content length range [%d, %d]", min, max)
+	}
+	p.conditions = append(p.conditions, []interface{}{"content-length-range", min, max})
+	return nil
+}
+
+// SetMetadata requires the user metadata entry name to be value.
+func (p *PostPolicy) SetMetadata(name, value string) {
+	p.AddCondition("eq", "x-amz-meta-"+name, value)
+}
+
+// SetACL requires the object to be uploaded with the canned ACL perm.
+func (p *PostPolicy) SetACL(perm ACL) {
+	p.AddCondition("eq", "acl", string(perm))
+}
+
+// SetSuccessActionStatus sets the status code S3 returns after a
+// successful upload: 200, 201 or 204.
+func (p *PostPolicy) SetSuccessActionStatus(status int) error {
+	switch status {
+	case 200, 201, 204:
+	default:
+		return fmt.Errorf("s3: bad success action status %d", status)
+	}
+	return p.AddCondition("eq", "success_action_status", strconv.Itoa(status))
+}
+
+// SetSuccessActionRedirect sets the URL the browser is redirected to after
+// a successful upload.
+func (p *PostPolicy) SetSuccessActionRedirect(redirect string) {
+	p.AddCondition("eq", "success_action_redirect", redirect)
+}
+
+// AddRawCondition adds a condition already encoded as JSON, such as
+// `["starts-with", "$x-amz-meta-tag", ""]`. It returns an error if cond is
+// not valid JSON.
+func (p *PostPolicy) AddRawCondition(cond string) error {
+	var v interface{}
+	if err := json.Unmarshal([]byte(cond), &v); err != nil {
+		return fmt.Errorf("s3: bad POST policy condition %q: %v", cond, err)
+	}
+	p.conditions = append(p.conditions, json.RawMessage(cond))
+	return nil
+}
+
+// encode returns the base64-encoded policy document, with extra
+// conditions appended to those of p.
+func (p *PostPolicy) encode(extra ...interface{}) (string, error) {
+	doc := struct {
+		Expiration string        `json:"expiration"`
+		Conditions []interface{} `json:"conditions"`
+	}{
+		p.Expiration.UTC().Format("2006-01-02T15:04:05.000Z"),
+		append(append([]interface{}{}, p.conditions...), extra...),
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	if len(p.raw) > 0 {
+		// The conditions list is never empty, since it holds the bucket.
+		i := strings.Index(string(data), `"conditions":[`) + len(`"conditions":[`)
+		data = []byte(string(data[:i]) + strings.Join(p.raw, ",") + "," + string(data[i:]))
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// PostForm returns the action and input fields of an HTML form allowing
+// anonymous uploads to the bucket under policy p, signed with AWS
+// Signature Version 4. The file itself must be the last field of the form.
+func (b *Bucket) PostForm(p *PostPolicy) (action string, fields map[string]string, err error) {
+	req := &request{bucket: b.Name, path: "/"}
+	if err := b.S3.setBaseURL(req); err != nil {
+		return "", nil, err
+	}
+	u, err := req.url()
+	if err != nil {
+		return "", nil, err
+	}
+
+	t := time.Now().UTC()
+	signer := aws.NewV4Signer(b.S3.Auth, "s3", b.S3.Region)
+	fields = map[string]string{
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": signer.Credential(t),
+		"x-amz-date":       t.Format(aws.ISO8601BasicFormat),
+	}
+	extra := []interface{}{map[string]string{"bucket": b.Name}}
+	if token := b.S3.Auth.Token(); token != "" {
+		fields["x-amz-security-token"] = token
+	}
+	for _, name := range []string{"x-amz-algorithm", "x-amz-credential", "x-amz-date", "x-amz-security-token"} {
+		if v, ok := fields[name]; ok {
+			extra = append(extra, map[string]string{name: v})
+		}
+	}
+	policy, err := p.encode(extra...)
+	if err != nil {
+		return "", nil, err
+	}
+	for k, v := range p.fields {
+		fields[k] = v
+	}
+	fields["policy"] = policy
+	fields["x-amz-signature"] = signer.SignPolicy(t, policy)
+	return u.String(), fields, nil
+}
+
+// postFormArgsV4 implements PostFormArgsEx for buckets signing with
+// Signature Version 4. conds are JSON-encoded conditions, which are added
+// to the policy unchecked, as with Signature Version 2.
+func (b *Bucket) postFormArgsV4(path string, expires time.Time, redirect string, conds []string) (action string, fields map[string]string, err error) {
+	p := NewPostPolicy(expires)
+	for _, cond := range conds {
+		p.raw = append(p.raw, strings.TrimSpace(cond))
+	}
+	p.SetKey(path)
+	if redirect != "" {
+		p.SetSuccessActionRedirect(redirect)
+	}
+	return b.PostForm(p)
+}
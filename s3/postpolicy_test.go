@@ -0,0 +1,130 @@
+package s3_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) v4Bucket() *s3.Bucket {
+	auth := aws.Auth{AccessKey: "abc", SecretKey: "123"}
+	region := aws.Region{Name: "faux-region-1", S3Endpoint: testServer.URL}
+	v4 := s3.New(auth, region)
+	v4.Signature = aws.V4Signature
+	return v4.Bucket("bucket")
+}
+
+func decodePolicy(c *check.C, policy string) (expiration string, conditions []interface{}) {
+	data, err := base64.StdEncoding.DecodeString(policy)
+	c.Assert(err, check.IsNil)
+	var doc struct {
+		Expiration string
+		Conditions []interface{}
+	}
+	c.Assert(json.Unmarshal(data, &doc), check.IsNil)
+	return doc.Expiration, doc.Conditions
+}
+
+func (s *S) TestPostForm(c *check.C) {
+	b := s.v4Bucket()
+	p := s3.NewPostPolicy(time.Date(2015, 12, 30, 12, 0, 0, 0, time.UTC))
+	p.SetKeyStartsWith("user/alice/")
+	p.SetContentTypeStartsWith("image/")
+	p.SetACL(s3.PublicRead)
+	p.SetMetadata("uuid", "14365123651274")
+	c.Assert(p.SetContentLengthRange(1, 1048576), check.IsNil)
+	c.Assert(p.SetSuccessActionStatus(201), check.IsNil)
+
+	action, fields, err := b.PostForm(p)
+	c.Assert(err, check.IsNil)
+	c.Assert(action, check.Equals, testServer.URL+"/bucket/")
+
+	date := fields["x-amz-date"]
+	t, err := time.Parse(aws.ISO8601BasicFormat, date)
+	c.Assert(err, check.IsNil)
+	signer := aws.NewV4Signer(b.S3.Auth, "s3", b.S3.Region)
+	credential := "abc/" + t.Format("20060102") + "/faux-region-1/s3/aws4_request"
+
+	c.Assert(fields, check.DeepEquals, map[string]string{
+		"key":                   "user/alice/${filename}",
+		"acl":                   "public-read",
+		"x-amz-meta-uuid":       "14365123651274",
+		"success_action_status": "201",
+		"x-amz-algorithm":       "AWS4-HMAC-SHA256",
+		"x-amz-credential":      credential,
+		"x-amz-date":            date,
+		"policy":                fields["policy"],
+		"x-amz-signature":       signer.SignPolicy(t, fields["policy"]),
+	})
+
+	expiration, conditions := decodePolicy(c, fields["policy"])
+	c.Assert(expiration, check.Equals, "2015-12-30T12:00:00.000Z")
+	c.Assert(conditions, check.DeepEquals, []interface{}{
+		[]interface{}{"starts-with", "$key", "user/alice/"},
+		[]interface{}{"starts-with", "$Content-Type", "image/"},
+		[]interface{}{"eq", "$acl", "public-read"},
+		[]interface{}{"eq", "$x-amz-meta-uuid", "14365123651274"},
+		[]interface{}{"content-length-range", 1.0, 1048576.0},
+		[]interface{}{"eq", "$success_action_status", "201"},
+		map[string]interface{}{"bucket": "bucket"},
+		map[string]interface{}{"x-amz-algorithm": "AWS4-HMAC-SHA256"},
+		map[string]interface{}{"x-amz-credential": credential},
+		map[string]interface{}{"x-amz-date": date},
+	})
+}
+
+func (s *S) TestPostPolicyErrors(c *check.C) {
+	p := s3.NewPostPolicy(time.Now())
+	c.Assert(p.SetContentLengthRange(10, 1), check.ErrorMatches, `s3: bad content length range \[10, 1\]`)
+	c.Assert(p.SetSuccessActionStatus(302), check.ErrorMatches, "s3: bad success action status 302")
+	c.Assert(p.AddCondition("ends-with", "key", "x"), check.ErrorMatches, `s3: bad POST policy match type "ends-with"`)
+	c.Assert(p.AddRawCondition(`["eq", "$key"`), check.ErrorMatches, `s3: bad POST policy condition .*`)
+}
+
+func (s *S) TestPostFormArgsExV4(c *check.C) {
+	b := s.v4Bucket()
+	expires := time.Date(2015, 12, 30, 12, 0, 0, 0, time.UTC)
+	conds := []string{`["starts-with", "$Content-Type", "text/"]`}
+	action, fields := b.PostFormArgsEx("file.txt", expires, "http://example.com/done", conds)
+	c.Assert(action, check.Equals, testServer.URL+"/bucket/")
+	c.Assert(fields["key"], check.Equals, "file.txt")
+	c.Assert(fields["success_action_redirect"], check.Equals, "http://example.com/done")
+	c.Assert(fields["x-amz-algorithm"], check.Equals, "AWS4-HMAC-SHA256")
+	c.Assert(fields["x-amz-signature"], check.HasLen, 64)
+	c.Assert(fields["signature"], check.Equals, "")
+	c.Assert(fields["AWSAccessKeyId"], check.Equals, "")
+
+	_, conditions := decodePolicy(c, fields["policy"])
+	c.Assert(conditions[:3], check.DeepEquals, []interface{}{
+		[]interface{}{"starts-with", "$Content-Type", "text/"},
+		[]interface{}{"eq", "$key", "file.txt"},
+		[]interface{}{"eq", "$success_action_redirect", "http://example.com/done"},
+	})
+
+	// Invalid conditions are signed as they are, as with Signature Version 2.
+	action, fields = b.PostFormArgsEx("file.txt", expires, "", []string{"{"})
+	c.Assert(action, check.Equals, testServer.URL+"/bucket/")
+	c.Assert(fields["x-amz-signature"], check.HasLen, 64)
+	policy, err := base64.StdEncoding.DecodeString(fields["policy"])
+	c.Assert(err, check.IsNil)
+	c.Assert(string(policy), check.Matches, `\{"expiration":"2015-12-30T12:00:00.000Z","conditions":\[\{,\["eq","\$key","file.txt"\],.*`)
+}
+
+func (s *S) TestUploadSignedURLV4(c *check.C) {
+	b := s.v4Bucket()
+	signed := b.UploadSignedURL("images/ali.png", "PUT", "image/png", time.Now().Add(time.Hour))
+	u, err := url.Parse(signed)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Path, check.Equals, "/bucket/images/ali.png")
+	q := u.Query()
+	c.Assert(q.Get("X-Amz-Algorithm"), check.Equals, "AWS4-HMAC-SHA256")
+	c.Assert(q.Get("X-Amz-SignedHeaders"), check.Equals, "content-type;host")
+	c.Assert(q.Get("X-Amz-Credential"), check.Matches, "abc/[0-9]{8}/faux-region-1/s3/aws4_request")
+	c.Assert(q.Get("X-Amz-Signature"), check.HasLen, 64)
+	c.Assert(q.Get("Signature"), check.Equals, "")
+}
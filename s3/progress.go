@@ -0,0 +1,142 @@
+package s3
+
+import (
+	"io"
+	"sync"
+)
+
+// Progress describes how far a transfer has gone.
+type Progress struct {
+	// Transferred is the number of bytes sent or received so far. When
+	// a request is retried, the bytes it sent are not counted again.
+	Transferred int64
+	// Total is the number of bytes to transfer, or -1 if it is unknown.
+	Total int64
+	// Parts is the number of parts of a multipart upload sent so far,
+	// and TotalParts the number of parts it has, or 0 if it is unknown.
+	Parts      int
+	TotalParts int
+	// Retries is the number of requests retried so far.
+	Retries int
+}
+
+// ProgressFunc is called as a transfer makes progress, from the goroutine
+// doing the transfer. The calls for the parts of a multipart upload sent
+// from several goroutines are made one at a time. It should return
+// quickly.
+type ProgressFunc func(Progress)
+
+// transfer tracks the progress of a transfer on behalf of a ProgressFunc.
+// A nil *transfer reports nothing. It may be shared by the requests of the
+// parts of a multipart upload, sent concurrently.
+type transfer struct {
+	fn ProgressFunc
+
+	mu sync.Mutex // held while fn is called.
+	p  Progress
+}
+
+// newTransfer returns a transfer of total bytes reporting to fn, or nil if
+// fn is nil.
+func newTransfer(fn ProgressFunc, total int64) *transfer {
+	if fn == nil {
+		return nil
+	}
+	return &transfer{fn: fn, p: Progress{Total: total}}
+}
+
+// report calls fn, with t.mu held.
+func (t *transfer) report() {
+	t.fn(t.p)
+}
+
+// setTotal records the number of bytes to transfer, once it is known.
+func (t *transfer) setTotal(total int64) {
+	if t != nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.p.Total = total
+		t.report()
+	}
+}
+
+// expect records that size more bytes, in parts more parts, are to be
+// transferred.
+func (t *transfer) expect(size int64, parts int) {
+	if t != nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.p.Total = t.p.Transferred + size
+		t.p.TotalParts = t.p.Parts + parts
+		t.report()
+	}
+}
+
+// retry records that a request is retried, and discounts the bytes it
+// transferred, which sent counted if it is not nil.
+func (t *transfer) retry(sent *progressReader) {
+	if t != nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if sent != nil {
+			t.p.Transferred -= sent.n
+			sent.n = 0
+			sent.discarded = true
+		}
+		t.p.Retries++
+		t.report()
+	}
+}
+
+// partDone records that a part of a multipart upload was sent. reused is
+// the size of the part if it was sent by a previous upload, and so was
+// not counted yet.
+func (t *transfer) partDone(reused int64) {
+	if t != nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.p.Transferred += reused
+		t.p.Parts++
+		t.report()
+	}
+}
+
+// reader returns rc, counting the bytes read from it as transferred, or
+// nil if t or rc is nil.
+func (t *transfer) reader(rc io.ReadCloser) *progressReader {
+	if t == nil || rc == nil {
+		return nil
+	}
+	return &progressReader{ReadCloser: rc, t: t}
+}
+
+// readCloser is reader, returning rc itself if it counts nothing.
+func (t *transfer) readCloser(rc io.ReadCloser) io.ReadCloser {
+	if pr := t.reader(rc); pr != nil {
+		return pr
+	}
+	return rc
+}
+
+// A progressReader counts the bytes of a request, so that they can be
+// discounted if it is retried.
+type progressReader struct {
+	io.ReadCloser
+	t         *transfer
+	n         int64 // bytes counted, guarded by t.mu.
+	discarded bool  // set by retry, guarded by t.mu.
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.ReadCloser.Read(p)
+	if n > 0 {
+		pr.t.mu.Lock()
+		if !pr.discarded {
+			pr.n += int64(n)
+			pr.t.p.Transferred += int64(n)
+			pr.t.report()
+		}
+		pr.t.mu.Unlock()
+	}
+	return n, err
+}
@@ -0,0 +1,108 @@
+package s3_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+// progressLog records the progress reported by a transfer.
+type progressLog []s3.Progress
+
+func (l *progressLog) add(p s3.Progress) {
+	*l = append(*l, p)
+}
+
+func (l progressLog) last() s3.Progress {
+	return l[len(l)-1]
+}
+
+func (s *S) TestPutReaderProgress(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	var log progressLog
+	b := s.s3.Bucket("bucket")
+	err := b.PutReader("name", strings.NewReader("content"), 7, "text/plain", s3.Private, s3.Options{Progress: log.add})
+	c.Assert(err, check.IsNil)
+	c.Assert(readAll(testServer.WaitRequest().Body), check.Equals, "content")
+
+	c.Assert(log, check.Not(check.HasLen), 0)
+	c.Assert(log.last(), check.Equals, s3.Progress{Transferred: 7, Total: 7})
+}
+
+func (s *S) TestGetReaderWithOptionsProgress(c *check.C) {
+	testServer.Response(200, nil, "content")
+
+	var log progressLog
+	b := s.s3.Bucket("bucket")
+	rc, err := b.GetReaderWithOptions("name", s3.Options{Progress: log.add})
+	c.Assert(err, check.IsNil)
+	c.Assert(log, check.DeepEquals, progressLog{{Transferred: 0, Total: 7}})
+	data, err := ioutil.ReadAll(rc)
+	c.Assert(err, check.IsNil)
+	c.Assert(rc.Close(), check.IsNil)
+	c.Assert(string(data), check.Equals, "content")
+	c.Assert(log.last(), check.Equals, s3.Progress{Transferred: 7, Total: 7})
+}
+
+func (s *S) TestPutAllProgress(c *check.C) {
+	etag2 := map[string]string{"ETag": `"etag2"`}
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(200, nil, ListPartsResultDump1)
+	testServer.Response(200, nil, ListPartsResultDump2)
+	testServer.Response(500, nil, InternalErrorDump)
+	testServer.Response(200, etag2, "")
+
+	var log progressLog
+	b := s.s3.Bucket("sample")
+	multi, err := b.InitMulti("multi", "text/plain", s3.Private, s3.Options{Progress: log.add})
+	c.Assert(err, check.IsNil)
+
+	// "part1" and "part3" are reused, "partX" is sent twice.
+	parts, err := multi.PutAll(strings.NewReader("part1partXpart3"), 5)
+	c.Assert(err, check.IsNil)
+	c.Assert(parts, check.HasLen, 3)
+
+	c.Assert(log[0], check.Equals, s3.Progress{Total: 15, TotalParts: 3})
+	c.Assert(log[1], check.Equals, s3.Progress{Transferred: 5, Total: 15, Parts: 1, TotalParts: 3})
+	var retried bool
+	for _, p := range log {
+		c.Assert(p.Transferred <= 15, check.Equals, true)
+		if p.Retries == 1 && !retried {
+			retried = true
+			c.Assert(p.Transferred, check.Equals, int64(5))
+		}
+	}
+	c.Assert(retried, check.Equals, true)
+	c.Assert(log.last(), check.Equals, s3.Progress{Transferred: 15, Total: 15, Parts: 3, TotalParts: 3, Retries: 1})
+}
+
+func (s *S) TestPutPartProgressConcurrent(c *check.C) {
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(500, nil, InternalErrorDump)
+	testServer.Responses(4, 200, map[string]string{"ETag": `"etag"`}, "")
+
+	var log progressLog
+	b := s.s3.Bucket("sample")
+	multi, err := b.InitMulti("multi", "text/plain", s3.Private, s3.Options{Progress: log.add})
+	c.Assert(err, check.IsNil)
+	testServer.WaitRequest()
+
+	// One of the parts is retried, while the others are in flight.
+	var wg sync.WaitGroup
+	for n := 1; n <= 4; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			_, err := multi.PutPart(n, strings.NewReader("part"+strings.Repeat("x", n)))
+			c.Check(err, check.IsNil)
+		}(n)
+	}
+	testServer.WaitRequests(5)
+	wg.Wait()
+
+	c.Assert(log.last(), check.Equals, s3.Progress{Transferred: 26, Total: -1, Parts: 4, Retries: 1})
+}
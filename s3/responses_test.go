@@ -246,3 +246,206 @@ var GetLocationUsWest1 = `
 
 var BucketWebsiteConfigurationDump = `<?xml version="1.0" encoding="UTF-8"?>
 <WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><RedirectAllRequestsTo><HostName>example.com</HostName></RedirectAllRequestsTo></WebsiteConfiguration>`
+
+var NotificationConfigurationDump = `<?xml version="1.0" encoding="UTF-8"?>
+<NotificationConfiguration><QueueConfiguration><Id>images</Id><Filter><S3Key><FilterRule><Name>prefix</Name><Value>images/</Value></FilterRule><FilterRule><Name>suffix</Name><Value>.jpg</Value></FilterRule></S3Key></Filter><Queue>arn:aws:sqs:us-east-1:123456789012:queue</Queue><Event>s3:ObjectCreated:*</Event></QueueConfiguration><TopicConfiguration><Topic>arn:aws:sns:us-east-1:123456789012:topic</Topic><Event>s3:ObjectRemoved:Delete</Event><Event>s3:ObjectRemoved:DeleteMarkerCreated</Event></TopicConfiguration><CloudFunctionConfiguration><Id>fn</Id><Filter><S3Key><FilterRule><Name>suffix</Name><Value>.txt</Value></FilterRule></S3Key></Filter><CloudFunction>arn:aws:lambda:us-east-1:123456789012:function:fn</CloudFunction><Event>s3:ObjectCreated:Put</Event></CloudFunctionConfiguration></NotificationConfiguration>`
+
+var ObjectCreatedEventDump = `
+{
+  "Records": [
+    {
+      "eventVersion": "2.0",
+      "eventSource": "aws:s3",
+      "awsRegion": "us-west-2",
+      "eventTime": "2015-10-01T23:28:54.280Z",
+      "eventName": "ObjectCreated:Put",
+      "userIdentity": {
+        "principalId": "AWS:AIDAJDPLRKLG7UEXAMPLE"
+      },
+      "requestParameters": {
+        "sourceIPAddress": "127.0.0.1"
+      },
+      "responseElements": {
+        "x-amz-request-id": "C3D13FE58DE4C810",
+        "x-amz-id-2": "FMyUVURIY8/IgAtTv8xRjskZQpcIZ9KG4V5Wp6S7S/JRWeUWerMUE5JgHvANOjpD"
+      },
+      "s3": {
+        "s3SchemaVersion": "1.0",
+        "configurationId": "images",
+        "bucket": {
+          "name": "sourcebucket",
+          "ownerIdentity": {
+            "principalId": "A3NL1KOZZKExample"
+          },
+          "arn": "arn:aws:s3:::sourcebucket"
+        },
+        "object": {
+          "key": "images/happy+face%2B1.jpg",
+          "size": 1024,
+          "eTag": "d41d8cd98f00b204e9800998ecf8427e",
+          "versionId": "v1",
+          "sequencer": "0055AED6DCD90281E5"
+        }
+      }
+    }
+  ]
+}
+`
+
+var SNSWrappedEventDump = `
+{
+  "Type": "Notification",
+  "MessageId": "f0b1a3c4-0000-0000-0000-000000000000",
+  "TopicArn": "arn:aws:sns:us-east-1:123456789012:topic",
+  "Subject": "Amazon S3 Notification",
+  "Message": "{\"Records\":[{\"eventSource\":\"aws:s3\",\"eventName\":\"ObjectRemoved:Delete\",\"eventTime\":\"2015-10-01T23:28:54.280Z\",\"s3\":{\"bucket\":{\"name\":\"sourcebucket\"},\"object\":{\"key\":\"a+b\"}}}]}"
+}
+`
+
+var TestEventDump = `
+{
+  "Service": "Amazon S3",
+  "Event": "s3:TestEvent",
+  "Time": "2015-10-01T23:28:54.280Z",
+  "Bucket": "sourcebucket",
+  "RequestId": "C3D13FE58DE4C810",
+  "HostId": "FMyUVURIY8/IgAtTv8xRjskZQpcIZ9KG4V5Wp6S7S/JRWeUWerMUE5JgHvANOjpD"
+}
+`
+
+var GetVersionsResultDump = `
+<?xml version="1.0" encoding="UTF-8"?>
+<ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01">
+  <Name>bucket</Name>
+  <Prefix>my</Prefix>
+  <KeyMarker/>
+  <VersionIdMarker/>
+  <NextKeyMarker>my-second-image.jpg</NextKeyMarker>
+  <NextVersionIdMarker>03jpff543dhffds434rfdsFDN943fdsFkdmqnh892</NextVersionIdMarker>
+  <MaxKeys>3</MaxKeys>
+  <IsTruncated>true</IsTruncated>
+  <Version>
+    <Key>my-image.jpg</Key>
+    <VersionId>3/L4kqtJl40Nr8X8gdRQBpUMLUo</VersionId>
+    <IsLatest>true</IsLatest>
+    <LastModified>2009-10-12T17:50:30.000Z</LastModified>
+    <ETag>"fba9dede5f27731c9771645a39863328"</ETag>
+    <Size>434234</Size>
+    <StorageClass>STANDARD</StorageClass>
+    <Owner>
+      <ID>75aa57f09aa0c8caeab4f8c24e99d10f8e7faeebf76c078efc7c6caea54ba06a</ID>
+      <DisplayName>mtd@amazon.com</DisplayName>
+    </Owner>
+  </Version>
+  <DeleteMarker>
+    <Key>my-second-image.jpg</Key>
+    <VersionId>03jpff543dhffds434rfdsFDN943fdsFkdmqnh892</VersionId>
+    <IsLatest>true</IsLatest>
+    <LastModified>2009-11-12T17:50:30.000Z</LastModified>
+    <Owner>
+      <ID>75aa57f09aa0c8caeab4f8c24e99d10f8e7faeebf76c078efc7c6caea54ba06a</ID>
+      <DisplayName>mtd@amazon.com</DisplayName>
+    </Owner>
+  </DeleteMarker>
+  <Version>
+    <Key>my-second-image.jpg</Key>
+    <VersionId>QUpfdndhfd8438MNFDN93jdnJFkdmqnh893</VersionId>
+    <IsLatest>false</IsLatest>
+    <LastModified>2009-10-10T17:50:30.000Z</LastModified>
+    <ETag>"9b2cf535f27731c974343645a3985328"</ETag>
+    <Size>166434</Size>
+    <StorageClass>STANDARD</StorageClass>
+    <Owner>
+      <ID>75aa57f09aa0c8caeab4f8c24e99d10f8e7faeebf76c078efc7c6caea54ba06a</ID>
+      <DisplayName>mtd@amazon.com</DisplayName>
+    </Owner>
+  </Version>
+</ListVersionsResult>
+`
+
+var GetTaggingDump = `
+<?xml version="1.0" encoding="UTF-8"?>
+<Tagging xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <TagSet>
+    <Tag>
+      <Key>project</Key>
+      <Value>goamz</Value>
+    </Tag>
+    <Tag>
+      <Key>owner</Key>
+      <Value>ops</Value>
+    </Tag>
+  </TagSet>
+</Tagging>
+`
+
+var GetListV2ResultDump = `
+<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>quotes</Name>
+  <Prefix>N</Prefix>
+  <KeyCount>1</KeyCount>
+  <MaxKeys>1</MaxKeys>
+  <IsTruncated>true</IsTruncated>
+  <ContinuationToken>1ueGcxLPRx1Tr/XYExHnhbYLgveDs2J/wm36Hy4vbOwM=</ContinuationToken>
+  <NextContinuationToken>1w41l63U0xa8q7smH50vCxyTQqdxo69O3EmK28Bi5PcROI4wI/EyIJg==</NextContinuationToken>
+  <StartAfter>M</StartAfter>
+  <Contents>
+    <Key>Nelson</Key>
+    <LastModified>2006-01-01T12:00:00.000Z</LastModified>
+    <ETag>&quot;828ef3fdfa96f00ad9f27c383fc9ac7f&quot;</ETag>
+    <Size>5</Size>
+    <StorageClass>STANDARD</StorageClass>
+    <Owner>
+      <ID>bcaf161ca5fb16fd081034f</ID>
+      <DisplayName>webfile</DisplayName>
+    </Owner>
+  </Contents>
+</ListBucketResult>
+`
+
+var GetVersionsResultDump2 = `
+<?xml version="1.0" encoding="UTF-8"?>
+<ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01">
+  <Name>bucket</Name>
+  <Prefix>my</Prefix>
+  <KeyMarker>my-second-image.jpg</KeyMarker>
+  <VersionIdMarker>03jpff543dhffds434rfdsFDN943fdsFkdmqnh892</VersionIdMarker>
+  <MaxKeys>3</MaxKeys>
+  <IsTruncated>false</IsTruncated>
+  <Version>
+    <Key>my-third-image.jpg</Key>
+    <VersionId>null</VersionId>
+    <IsLatest>true</IsLatest>
+    <LastModified>2009-10-12T17:50:30.000Z</LastModified>
+    <ETag>"fba9dede5f27731c9771645a39863328"</ETag>
+    <Size>434234</Size>
+    <StorageClass>STANDARD</StorageClass>
+  </Version>
+</ListVersionsResult>
+`
+
+var DelMultiResultDump = `
+<?xml version="1.0" encoding="UTF-8"?>
+<DeleteResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Deleted>
+    <Key>sample1.txt</Key>
+  </Deleted>
+  <Error>
+    <Key>sample2.txt</Key>
+    <Code>AccessDenied</Code>
+    <Message>Access Denied</Message>
+  </Error>
+</DeleteResult>
+`
+
+var PreconditionFailedDump = `
+<?xml version="1.0" encoding="UTF-8"?>
+<Error>
+  <Code>PreconditionFailed</Code>
+  <Message>At least one of the pre-conditions you specified did not hold</Message>
+  <Condition>x-amz-copy-source-If-Match</Condition>
+  <RequestId>3A4E3F9C7B2D1A6E</RequestId>
+  <HostId>fWL8mTKZr4+4VhmdPvVyLSYjU0bWmcGxXHmPHGKbS0BS0j5oFRRTrVCbYEaVYpYr</HostId>
+</Error>
+`
@@ -0,0 +1,128 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Implements an interface for restoring archived s3 objects.
+// See http://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectPOSTrestore.html
+// for details.
+
+type RestoreTier string
+
+const (
+	RestoreTierExpedited = RestoreTier("Expedited")
+	RestoreTierStandard  = RestoreTier("Standard")
+	RestoreTierBulk      = RestoreTier("Bulk")
+)
+
+type restoreRequest struct {
+	XMLName xml.Name    `xml:"RestoreRequest"`
+	Days    int         `xml:"Days"`
+	Tier    RestoreTier `xml:"GlacierJobParameters>Tier,omitempty"`
+}
+
+// RestoreObject initiates the restore of an archived object for the given
+// number of days. An empty tier uses the S3 default (Standard). An empty
+// versionId addresses the latest version of the object.
+//
+// Use RestoreStatus to follow the progress of the restore.
+func (b *Bucket) RestoreObject(path, versionId string, days int, tier RestoreTier) error {
+	doc, err := xml.Marshal(restoreRequest{Days: days, Tier: tier})
+	if err != nil {
+		return err
+	}
+
+	buf := makeXmlBuffer(doc)
+	digest := md5.New()
+	size, err := digest.Write(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	headers := map[string][]string{
+		"Content-Length": {strconv.FormatInt(int64(size), 10)},
+		"Content-MD5":    {base64.StdEncoding.EncodeToString(digest.Sum(nil))},
+	}
+	params := url.Values{"restore": {""}}
+	if versionId != "" {
+		params.Set("versionId", versionId)
+	}
+	req := &request{
+		method:  "POST",
+		bucket:  b.Name,
+		path:    path,
+		headers: headers,
+		params:  params,
+		payload: buf,
+	}
+	return b.S3.query(req, nil)
+}
+
+// The RestoreStatus type holds the restore state reported in the
+// x-amz-restore header of an archived object.
+type RestoreStatus struct {
+	// Ongoing is true while the restore is still in progress.
+	Ongoing bool
+	// ExpiryDate is when the restored copy will be removed. It is zero
+	// while the restore is ongoing.
+	ExpiryDate time.Time
+}
+
+// ParseRestoreStatus parses the x-amz-restore header in h. It returns nil
+// if the header is absent, i.e. no restore has been requested for the
+// object or the restored copy has expired.
+func ParseRestoreStatus(h http.Header) (*RestoreStatus, error) {
+	v := h.Get("x-amz-restore")
+	if v == "" {
+		return nil, nil
+	}
+	status := &RestoreStatus{}
+	for v != "" {
+		i := strings.Index(v, `="`)
+		if i < 0 {
+			return nil, fmt.Errorf("bad x-amz-restore header %q", h.Get("x-amz-restore"))
+		}
+		name := strings.TrimSpace(strings.TrimLeft(v[:i], ", "))
+		v = v[i+2:]
+		j := strings.IndexByte(v, '"')
+		if j < 0 {
+			return nil, fmt.Errorf("bad x-amz-restore header %q", h.Get("x-amz-restore"))
+		}
+		value := v[:j]
+		v = v[j+1:]
+		switch name {
+		case "ongoing-request":
+			status.Ongoing = value == "true"
+		case "expiry-date":
+			t, err := time.Parse(time.RFC1123, value)
+			if err != nil {
+				return nil, err
+			}
+			status.ExpiryDate = t
+		}
+	}
+	return status, nil
+}
+
+// RestoreStatus HEADs the object at path and returns its restore status,
+// or nil if no restore is in progress or available. An empty versionId
+// addresses the latest version of the object.
+func (b *Bucket) RestoreStatus(path, versionId string) (*RestoreStatus, error) {
+	resp, err := b.HeadVersion(path, versionId, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Body != nil {
+		resp.Body.Close()
+	}
+	return ParseRestoreStatus(resp.Header)
+}
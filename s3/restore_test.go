@@ -0,0 +1,63 @@
+package s3_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestRestoreObject(c *check.C) {
+	testServer.Response(202, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.RestoreObject("name", "", 2, s3.RestoreTierBulk)
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "POST")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.URL.RawQuery, check.Equals, "restore=")
+
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<RestoreRequest><Days>2</Days><GlacierJobParameters><Tier>Bulk</Tier></GlacierJobParameters></RestoreRequest>`)
+}
+
+func (s *S) TestRestoreStatus(c *check.C) {
+	testServer.Response(200, map[string]string{
+		"x-amz-restore": `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`,
+	}, "")
+
+	b := s.s3.Bucket("bucket")
+	status, err := b.RestoreStatus("name", "v1")
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "HEAD")
+	c.Assert(req.URL.RawQuery, check.Equals, "versionId=v1")
+
+	c.Assert(status.Ongoing, check.Equals, false)
+	c.Assert(status.ExpiryDate.Equal(time.Date(2012, 12, 21, 0, 0, 0, 0, time.UTC)), check.Equals, true)
+}
+
+func (s *S) TestParseRestoreStatus(c *check.C) {
+	status, err := s3.ParseRestoreStatus(http.Header{})
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.IsNil)
+
+	h := http.Header{}
+	h.Set("x-amz-restore", `ongoing-request="true"`)
+	status, err = s3.ParseRestoreStatus(h)
+	c.Assert(err, check.IsNil)
+	c.Assert(status.Ongoing, check.Equals, true)
+	c.Assert(status.ExpiryDate.IsZero(), check.Equals, true)
+
+	h.Set("x-amz-restore", `ongoing-request="true`)
+	_, err = s3.ParseRestoreStatus(h)
+	c.Assert(err, check.NotNil)
+}
@@ -28,6 +28,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/AdRoll/goamz/aws"
@@ -42,6 +43,7 @@ type S3 struct {
 	ConnectTimeout time.Duration
 	ReadTimeout    time.Duration
 	Signature      int
+	Addressing     Addressing
 	private        byte // Reserve the right of using private data.
 }
 
@@ -74,6 +76,29 @@ type Options struct {
 	ContentDisposition   string
 	Range                string
 	StorageClass         StorageClass
+	Tags                 []Tag
+	// Preconditions on the current state of the object. Requests fail
+	// with a NotModified or PreconditionFailed error (see IsNotModified
+	// and IsPreconditionFailed) when they do not hold.
+	IfMatch           string
+	IfNoneMatch       string
+	IfModifiedSince   time.Time
+	IfUnmodifiedSince time.Time
+	// Progress, if not nil, is called as the object is uploaded by
+	// PutReader or Put, or downloaded by GetReaderWithOptions or
+	// GetResponseWithOptions. Multipart uploads started by InitMulti
+	// report the progress of their parts to it too.
+	Progress ProgressFunc
+	// VerifyChecksum makes PutReader and Put send the MD5 sum of the
+	// object for S3 to check, and GetReaderWithOptions and
+	// GetResponseWithOptions check the object against the checksums S3
+	// returns with it, failing the final Read with a *ChecksumError.
+	// Multipart uploads started by InitMulti check their ETag on
+	// completion.
+	VerifyChecksum bool
+	// ChecksumAlgorithm makes PutReader and Put send an additional
+	// checksum of the object, which S3 checks and stores with it.
+	ChecksumAlgorithm ChecksumAlgorithm
 	// What else?
 }
 
@@ -82,6 +107,15 @@ type CopyOptions struct {
 	CopySourceOptions string
 	MetadataDirective string
 	ContentType       string
+	// CopySourceVersionId selects a version of the source object other
+	// than the latest one.
+	CopySourceVersionId string
+	// Preconditions on the source object. PutCopy fails with a
+	// PreconditionFailed error when they do not hold.
+	CopySourceIfMatch           string
+	CopySourceIfNoneMatch       string
+	CopySourceIfModifiedSince   time.Time
+	CopySourceIfUnmodifiedSince time.Time
 }
 
 // CopyObjectResult is the output from a Copy request
@@ -98,7 +132,7 @@ var attempts = aws.AttemptStrategy{
 
 // New creates a new S3.
 func New(auth aws.Auth, region aws.Region) *S3 {
-	return &S3{auth, region, 0, 0, aws.V2Signature, 0}
+	return &S3{auth, region, 0, 0, aws.V2Signature, AutoAddressing, 0}
 }
 
 // Bucket returns a Bucket with the given name.
@@ -149,7 +183,8 @@ var createBucketConfiguration = `<CreateBucketConfiguration xmlns="http://s3.ama
 // See http://goo.gl/bh9Kq for details.
 func (s3 *S3) locationConstraint() io.Reader {
 	constraint := ""
-	if s3.Region.S3LocationConstraint {
+	// us-east-1 is the default location, and naming it is an error.
+	if s3.Region.S3LocationConstraint && s3.Region.Name != "us-east-1" {
 		constraint = fmt.Sprintf(createBucketConfiguration, s3.Region.Name)
 	}
 	return strings.NewReader(constraint)
@@ -281,7 +316,8 @@ func (b *Bucket) GetReaderWithHeaders(path string) (rc io.ReadCloser, header htt
 // GetReader retrieves an object from an S3 bucket,
 // returning the body of the HTTP response.
 // It is the caller's responsibility to call Close on rc when
-// finished reading.
+// finished reading. It reports no progress: use GetReaderWithOptions
+// with Options.Progress for that.
 func (b *Bucket) GetReader(path string) (rc io.ReadCloser, err error) {
 	resp, err := b.GetResponse(path)
 	if resp != nil {
@@ -304,9 +340,78 @@ func (b *Bucket) GetResponse(path string) (resp *http.Response, err error) {
 // It is the caller's responsibility to call Close on rc when
 // finished reading
 func (b *Bucket) GetResponseWithHeaders(path string, headers map[string][]string) (resp *http.Response, err error) {
+	return b.getResponse(path, nil, headers, nil)
+}
+
+// GetResponseWithOptions retrieves an object from an S3 bucket, sending the
+// headers for the Range, server-side encryption and precondition fields
+// of options, and returning the HTTP response.
+// It is the caller's responsibility to call Close on rc when
+// finished reading
+func (b *Bucket) GetResponseWithOptions(path string, options Options) (resp *http.Response, err error) {
+	headers := make(http.Header)
+	options.addHeaders(headers)
+	if options.VerifyChecksum {
+		headers["x-amz-checksum-mode"] = []string{"ENABLED"}
+	}
+	resp, err = b.getResponse(path, nil, headers, newTransfer(options.Progress, -1))
+	if err == nil && options.VerifyChecksum {
+		resp.Body = verifyChecksums(resp)
+	}
+	return resp, err
+}
+
+// GetReaderWithOptions retrieves an object from an S3 bucket like
+// GetResponseWithOptions, returning the body of the HTTP response.
+// It is the caller's responsibility to call Close on rc when
+// finished reading.
+func (b *Bucket) GetReaderWithOptions(path string, options Options) (rc io.ReadCloser, err error) {
+	resp, err := b.GetResponseWithOptions(path, options)
+	if resp != nil {
+		return resp.Body, err
+	}
+	return nil, err
+}
+
+// GetVersion retrieves a specific version of an object from an S3 bucket.
+//
+// See http://goo.gl/isCO7 for details.
+func (b *Bucket) GetVersion(path, versionId string) (data []byte, err error) {
+	body, err := b.GetVersionReader(path, versionId)
+	if err != nil {
+		return nil, err
+	}
+	data, err = ioutil.ReadAll(body)
+	body.Close()
+	return data, err
+}
+
+// GetVersionReader retrieves a specific version of an object from an S3
+// bucket, returning the body of the HTTP response.
+// It is the caller's responsibility to call Close on rc when
+// finished reading.
+func (b *Bucket) GetVersionReader(path, versionId string) (rc io.ReadCloser, err error) {
+	resp, err := b.GetVersionResponseWithHeaders(path, versionId, make(http.Header))
+	if resp != nil {
+		return resp.Body, err
+	}
+	return nil, err
+}
+
+// GetVersionResponseWithHeaders retrieves a specific version of an object
+// from an S3 bucket, sending the custom headers given as the third parameter
+// and returning the HTTP response.
+// It is the caller's responsibility to call Close on rc when
+// finished reading
+func (b *Bucket) GetVersionResponseWithHeaders(path, versionId string, headers map[string][]string) (resp *http.Response, err error) {
+	return b.getResponse(path, versionParams(versionId), headers, nil)
+}
+
+func (b *Bucket) getResponse(path string, params url.Values, headers map[string][]string, t *transfer) (resp *http.Response, err error) {
 	req := &request{
 		bucket:  b.Name,
 		path:    path,
+		params:  params,
 		headers: headers,
 	}
 	err = b.S3.prepare(req)
@@ -316,11 +421,14 @@ func (b *Bucket) GetResponseWithHeaders(path string, headers map[string][]string
 	for attempt := attempts.Start(); attempt.Next(); {
 		resp, err := b.S3.run(req, nil)
 		if shouldRetry(err) && attempt.HasNext() {
+			t.retry(nil)
 			continue
 		}
 		if err != nil {
 			return nil, err
 		}
+		t.setTotal(resp.ContentLength)
+		resp.Body = t.readCloser(resp.Body)
 		return resp, nil
 	}
 	panic("unreachable")
@@ -366,10 +474,29 @@ func (b *Bucket) Exists(path string) (exists bool, err error) {
 // Head HEADs an object in the S3 bucket, returns the response with
 // no body see http://bit.ly/17K1ylI
 func (b *Bucket) Head(path string, headers map[string][]string) (*http.Response, error) {
+	return b.head(path, nil, headers)
+}
+
+// HeadWithOptions HEADs an object in the S3 bucket like Head, sending the
+// headers for the fields of options.
+func (b *Bucket) HeadWithOptions(path string, options Options) (*http.Response, error) {
+	headers := make(http.Header)
+	options.addHeaders(headers)
+	return b.head(path, nil, headers)
+}
+
+// HeadVersion HEADs a specific version of an object in the S3 bucket,
+// returns the response with no body.
+func (b *Bucket) HeadVersion(path, versionId string, headers map[string][]string) (*http.Response, error) {
+	return b.head(path, versionParams(versionId), headers)
+}
+
+func (b *Bucket) head(path string, params url.Values, headers map[string][]string) (*http.Response, error) {
 	req := &request{
 		method:  "HEAD",
 		bucket:  b.Name,
 		path:    path,
+		params:  params,
 		headers: headers,
 	}
 	err := b.S3.prepare(req)
@@ -394,7 +521,7 @@ func (b *Bucket) Head(path string, headers map[string][]string) (*http.Response,
 //
 // See http://goo.gl/FEBPD for details.
 func (b *Bucket) Put(path string, data []byte, contType string, perm ACL, options Options) error {
-	body := bytes.NewBuffer(data)
+	body := bytes.NewReader(data)
 	return b.PutReader(path, body, int64(len(data)), contType, perm, options)
 }
 
@@ -402,7 +529,7 @@ func (b *Bucket) Put(path string, data []byte, contType string, perm ACL, option
 func (b *Bucket) PutCopy(path string, perm ACL, options CopyOptions, source string) (*CopyObjectResult, error) {
 	headers := map[string][]string{
 		"x-amz-acl":         {string(perm)},
-		"x-amz-copy-source": {escapePath(source)},
+		"x-amz-copy-source": {escapePath(source) + options.copySourceVersion()},
 	}
 	options.addHeaders(headers)
 	req := &request{
@@ -420,7 +547,8 @@ func (b *Bucket) PutCopy(path string, perm ACL, options CopyOptions, source stri
 }
 
 // PutReader inserts an object into the S3 bucket by consuming data
-// from r until EOF.
+// from r until EOF. r must be an io.ReadSeeker if options.VerifyChecksum
+// or options.ChecksumAlgorithm is set.
 func (b *Bucket) PutReader(path string, r io.Reader, length int64, contType string, perm ACL, options Options) error {
 	headers := map[string][]string{
 		"Content-Length": {strconv.FormatInt(length, 10)},
@@ -428,12 +556,16 @@ func (b *Bucket) PutReader(path string, r io.Reader, length int64, contType stri
 		"x-amz-acl":      {string(perm)},
 	}
 	options.addHeaders(headers)
+	if err := addUploadChecksums(headers, r, length, options); err != nil {
+		return err
+	}
 	req := &request{
-		method:  "PUT",
-		bucket:  b.Name,
-		path:    path,
-		headers: headers,
-		payload: r,
+		method:   "PUT",
+		bucket:   b.Name,
+		path:     path,
+		headers:  headers,
+		payload:  r,
+		progress: newTransfer(options.Progress, length),
 	}
 	return b.S3.query(req, nil)
 }
@@ -475,11 +607,32 @@ func (o Options) addHeaders(headers map[string][]string) {
 		headers["x-amz-storage-class"] = []string{string(o.StorageClass)}
 
 	}
+	if len(o.Tags) != 0 {
+		headers["x-amz-tagging"] = []string{encodeTags(o.Tags)}
+	}
+	addPreconditions(headers, "", o.IfMatch, o.IfNoneMatch, o.IfModifiedSince, o.IfUnmodifiedSince)
 	for k, v := range o.Meta {
 		headers["x-amz-meta-"+k] = v
 	}
 }
 
+// addPreconditions adds the conditional request headers, named with prefix,
+// for the non-zero arguments to headers.
+func addPreconditions(headers map[string][]string, prefix, ifMatch, ifNoneMatch string, ifModifiedSince, ifUnmodifiedSince time.Time) {
+	if len(ifMatch) != 0 {
+		headers[prefix+"If-Match"] = []string{ifMatch}
+	}
+	if len(ifNoneMatch) != 0 {
+		headers[prefix+"If-None-Match"] = []string{ifNoneMatch}
+	}
+	if !ifModifiedSince.IsZero() {
+		headers[prefix+"If-Modified-Since"] = []string{ifModifiedSince.UTC().Format(http.TimeFormat)}
+	}
+	if !ifUnmodifiedSince.IsZero() {
+		headers[prefix+"If-Unmodified-Since"] = []string{ifUnmodifiedSince.UTC().Format(http.TimeFormat)}
+	}
+}
+
 // addHeaders adds o's specified fields to headers
 func (o CopyOptions) addHeaders(headers map[string][]string) {
 	o.Options.addHeaders(headers)
@@ -492,6 +645,25 @@ func (o CopyOptions) addHeaders(headers map[string][]string) {
 	if len(o.ContentType) != 0 {
 		headers["Content-Type"] = []string{o.ContentType}
 	}
+	addPreconditions(headers, "x-amz-copy-source-", o.CopySourceIfMatch, o.CopySourceIfNoneMatch, o.CopySourceIfModifiedSince, o.CopySourceIfUnmodifiedSince)
+}
+
+// copySourceVersion returns the suffix to append to x-amz-copy-source to
+// address o.CopySourceVersionId, if set.
+func (o CopyOptions) copySourceVersion() string {
+	if len(o.CopySourceVersionId) == 0 {
+		return ""
+	}
+	return "?versionId=" + url.QueryEscape(o.CopySourceVersionId)
+}
+
+// versionParams returns the query parameters addressing versionId of an
+// object. An empty versionId addresses the latest version.
+func versionParams(versionId string) url.Values {
+	if versionId == "" {
+		return nil
+	}
+	return url.Values{"versionId": {versionId}}
 }
 
 func makeXmlBuffer(doc []byte) *bytes.Buffer {
@@ -542,6 +714,42 @@ func (b *Bucket) PutBucketWebsite(configuration WebsiteConfiguration) error {
 	return b.PutBucketSubresource("website", buf, int64(buf.Len()))
 }
 
+// GetBucketWebsite returns the website configuration of the bucket.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/API/RESTBucketGETwebsite.html for details.
+func (b *Bucket) GetBucketWebsite() (*WebsiteConfiguration, error) {
+	req := &request{
+		bucket: b.Name,
+		path:   "/",
+		params: url.Values{"website": {""}},
+	}
+	conf := &WebsiteConfiguration{}
+	var err error
+	for attempt := attempts.Start(); attempt.Next(); {
+		err = b.S3.query(req, conf)
+		if !shouldRetry(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// DelBucketWebsite removes the website configuration of the bucket.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/API/RESTBucketDELETEwebsite.html for details.
+func (b *Bucket) DelBucketWebsite() error {
+	req := &request{
+		method: "DELETE",
+		bucket: b.Name,
+		path:   "/",
+		params: url.Values{"website": {""}},
+	}
+	return b.S3.query(req, nil)
+}
+
 func (b *Bucket) PutBucketSubresource(subresource string, r io.Reader, length int64) error {
 	headers := map[string][]string{
 		"Content-Length": {strconv.FormatInt(length, 10)},
@@ -562,10 +770,19 @@ func (b *Bucket) PutBucketSubresource(subresource string, r io.Reader, length in
 //
 // See http://goo.gl/APeTt for details.
 func (b *Bucket) Del(path string) error {
+	return b.DelVersion(path, "")
+}
+
+// DelVersion permanently removes a specific version of an object from the
+// S3 bucket. An empty versionId behaves like Del.
+//
+// See http://goo.gl/APeTt for details.
+func (b *Bucket) DelVersion(path, versionId string) error {
 	req := &request{
 		method: "DELETE",
 		bucket: b.Name,
 		path:   path,
+		params: versionParams(versionId),
 	}
 	return b.S3.query(req, nil)
 }
@@ -580,20 +797,130 @@ type Object struct {
 	VersionId string `xml:"VersionId,omitempty"`
 }
 
-// DelMulti removes up to 1000 objects from the S3 bucket.
+// The DeleteResult type holds the results of a DelMultiFull operation.
+type DeleteResult struct {
+	// Deleted lists the objects that were deleted. It is empty in Quiet
+	// mode.
+	Deleted []DeletedObject `xml:"Deleted"`
+	Errors  []DeleteError   `xml:"Error"`
+}
+
+// The DeletedObject type represents an object removed by a multi-object
+// delete.
+type DeletedObject struct {
+	Key                   string
+	VersionId             string
+	DeleteMarker          bool
+	DeleteMarkerVersionId string
+}
+
+// The DeleteError type represents an object that a multi-object delete
+// failed to remove.
+type DeleteError struct {
+	Key       string
+	VersionId string
+	Code      string
+	Message   string
+}
+
+func (e *DeleteError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.Message)
+}
+
+// MultiDeleteError is returned by DelMulti and DeletePrefix when some of
+// the objects could not be removed.
+type MultiDeleteError struct {
+	Errors []DeleteError
+}
+
+func (e *MultiDeleteError) Error() string {
+	if len(e.Errors) == 1 {
+		return "failed to delete " + e.Errors[0].Error()
+	}
+	return fmt.Sprintf("failed to delete %d objects; first error: %s", len(e.Errors), e.Errors[0].Error())
+}
+
+// The S3 maximum number of keys per multi-object delete request. Here
+// as a var just for testing.
+var delMultiMax = 1000
+
+// Number of multi-object delete requests run at the same time.
+var delMultiWorkers = 4
+
+// DelMulti removes objects from the S3 bucket, sending them in batches of
+// up to 1000 objects. If any of the objects could not be removed, the
+// returned error is a *MultiDeleteError listing them.
 //
 // See http://goo.gl/jx6cWK for details.
 func (b *Bucket) DelMulti(objects Delete) error {
-	doc, err := xml.Marshal(objects)
+	result, err := b.DelMultiFull(objects)
 	if err != nil {
 		return err
 	}
+	if len(result.Errors) > 0 {
+		return &MultiDeleteError{result.Errors}
+	}
+	return nil
+}
+
+// DelMultiFull removes objects from the S3 bucket and returns the result
+// for every object. Objects are split into batches of up to 1000 keys,
+// which are sent concurrently. Per-object failures are reported in the
+// result, not as an error; the error is only set when a whole request
+// failed, in which case the result holds what the other batches did.
+func (b *Bucket) DelMultiFull(objects Delete) (*DeleteResult, error) {
+	var batches []Delete
+	for all := objects.Objects; len(all) > 0; {
+		n := len(all)
+		if n > delMultiMax {
+			n = delMultiMax
+		}
+		batches = append(batches, Delete{Quiet: objects.Quiet, Objects: all[:n]})
+		all = all[n:]
+	}
+
+	results := make([]*DeleteResult, len(batches))
+	errs := make([]error, len(batches))
+	sem := make(chan bool, delMultiWorkers)
+	var wg sync.WaitGroup
+	for i := range batches {
+		wg.Add(1)
+		sem <- true
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = b.delMulti(batches[i])
+			<-sem
+		}(i)
+	}
+	wg.Wait()
+
+	result := &DeleteResult{}
+	var err error
+	for i, r := range results {
+		if errs[i] != nil {
+			if err == nil {
+				err = errs[i]
+			}
+			continue
+		}
+		result.Deleted = append(result.Deleted, r.Deleted...)
+		result.Errors = append(result.Errors, r.Errors...)
+	}
+	return result, err
+}
+
+// delMulti sends a single multi-object delete request.
+func (b *Bucket) delMulti(objects Delete) (*DeleteResult, error) {
+	doc, err := xml.Marshal(objects)
+	if err != nil {
+		return nil, err
+	}
 
 	buf := makeXmlBuffer(doc)
 	digest := md5.New()
 	size, err := digest.Write(buf.Bytes())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	headers := map[string][]string{
@@ -601,16 +928,62 @@ func (b *Bucket) DelMulti(objects Delete) error {
 		"Content-MD5":    {base64.StdEncoding.EncodeToString(digest.Sum(nil))},
 		"Content-Type":   {"text/xml"},
 	}
-	req := &request{
-		path:    "/",
-		method:  "POST",
-		params:  url.Values{"delete": {""}},
-		bucket:  b.Name,
-		headers: headers,
-		payload: buf,
+	for attempt := attempts.Start(); attempt.Next(); {
+		req := &request{
+			path:    "/",
+			method:  "POST",
+			params:  url.Values{"delete": {""}},
+			bucket:  b.Name,
+			headers: headers,
+			payload: bytes.NewReader(buf.Bytes()),
+		}
+		resp := &DeleteResult{}
+		err = b.S3.query(req, resp)
+		if err == io.EOF {
+			// Some S3-compatible servers reply with an empty body.
+			return resp, nil
+		}
+		if shouldRetry(err) && attempt.HasNext() {
+			continue
+		}
+		return resp, err
 	}
+	panic("unreachable")
+}
 
-	return b.S3.query(req, nil)
+// DeletePrefix removes every object under prefix from the S3 bucket,
+// including all object versions and delete markers if the bucket is
+// versioned. If any of the objects could not be removed, the returned
+// error is a *MultiDeleteError listing them.
+func (b *Bucket) DeletePrefix(prefix string) error {
+	var failed []DeleteError
+	keyMarker, versionIdMarker := "", ""
+	for {
+		resp, err := b.Versions(prefix, "", keyMarker, versionIdMarker, delMultiMax)
+		if err != nil {
+			return err
+		}
+		objects := Delete{Quiet: true}
+		for _, v := range resp.Versions {
+			objects.Objects = append(objects.Objects, Object{Key: v.Key, VersionId: v.VersionId})
+		}
+		for _, m := range resp.DeleteMarkers {
+			objects.Objects = append(objects.Objects, Object{Key: m.Key, VersionId: m.VersionId})
+		}
+		result, err := b.DelMultiFull(objects)
+		if err != nil {
+			return err
+		}
+		failed = append(failed, result.Errors...)
+		if !resp.IsTruncated {
+			break
+		}
+		keyMarker, versionIdMarker = resp.NextKeyMarker, resp.NextVersionIdMarker
+	}
+	if len(failed) > 0 {
+		return &MultiDeleteError{failed}
+	}
+	return nil
 }
 
 // The ListResp type holds the results of a List bucket operation.
@@ -734,6 +1107,73 @@ func (b *Bucket) List(prefix, delim, marker string, max int) (result *ListResp,
 	return result, nil
 }
 
+// The ListV2Resp type holds the results of a ListV2 bucket operation.
+type ListV2Resp struct {
+	Name              string
+	Prefix            string
+	Delimiter         string
+	StartAfter        string
+	ContinuationToken string
+	MaxKeys           int
+	// KeyCount is the number of keys and common prefixes returned.
+	KeyCount       int
+	IsTruncated    bool
+	Contents       []Key
+	CommonPrefixes []string `xml:">Prefix"`
+	// if IsTruncated is true, pass NextContinuationToken as
+	// continuationToken argument to ListV2() to get the next set of keys
+	NextContinuationToken string
+}
+
+// ListV2 returns information about objects in an S3 bucket using version 2
+// of the ListObjects API.
+//
+// The prefix, delim and max parameters behave as in List.
+//
+// The continuationToken parameter resumes a truncated listing; pass the
+// NextContinuationToken of the previous response. The startAfter parameter
+// makes the listing start after the given key and is ignored by S3 when a
+// continuationToken is given.
+//
+// Key owners are only returned when fetchOwner is true.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/API/v2-RESTBucketGET.html
+// for details.
+func (b *Bucket) ListV2(prefix, delim, continuationToken, startAfter string, max int, fetchOwner bool) (result *ListV2Resp, err error) {
+	params := map[string][]string{
+		"list-type": {"2"},
+		"prefix":    {prefix},
+		"delimiter": {delim},
+	}
+	if continuationToken != "" {
+		params["continuation-token"] = []string{continuationToken}
+	}
+	if startAfter != "" {
+		params["start-after"] = []string{startAfter}
+	}
+	if max != 0 {
+		params["max-keys"] = []string{strconv.FormatInt(int64(max), 10)}
+	}
+	if fetchOwner {
+		params["fetch-owner"] = []string{"true"}
+	}
+	req := &request{
+		bucket: b.Name,
+		params: params,
+	}
+	result = &ListV2Resp{}
+	for attempt := attempts.Start(); attempt.Next(); {
+		err = b.S3.query(req, result)
+		if !shouldRetry(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // The VersionsResp type holds the results of a list bucket Versions operation.
 type VersionsResp struct {
 	Name            string
@@ -743,8 +1183,14 @@ type VersionsResp struct {
 	MaxKeys         int
 	Delimiter       string
 	IsTruncated     bool
-	Versions        []Version `xml:"Version"`
-	CommonPrefixes  []string  `xml:">Prefix"`
+	Versions        []Version      `xml:"Version"`
+	DeleteMarkers   []DeleteMarker `xml:"DeleteMarker"`
+	CommonPrefixes  []string       `xml:">Prefix"`
+	// if IsTruncated is true, pass NextKeyMarker and NextVersionIdMarker
+	// as keyMarker and versionIdMarker arguments to Versions() to get the
+	// next set of versions
+	NextKeyMarker       string
+	NextVersionIdMarker string
 }
 
 // The Version type represents an object version stored in an S3 bucket.
@@ -761,6 +1207,16 @@ type Version struct {
 	StorageClass string
 }
 
+// The DeleteMarker type represents a delete marker placed on a key of a
+// versioned S3 bucket.
+type DeleteMarker struct {
+	Key          string
+	VersionId    string
+	IsLatest     bool
+	LastModified string
+	Owner        Owner
+}
+
 func (b *Bucket) Versions(prefix, delim, keyMarker string, versionIdMarker string, max int) (result *VersionsResp, err error) {
 	params := map[string][]string{
 		"versions":  {""},
@@ -891,12 +1347,22 @@ func (b *Bucket) SignedURLWithMethod(method, path string, expires time.Time, par
 // to upload the object at path. The signature is valid until expires.
 // contenttype is a string like image/png
 // name is the resource name in s3 terminology like images/ali.png [obviously excluding the bucket name itself]
+// When the S3 Signature is aws.V4Signature the URL is presigned with
+// Signature Version 4, and the upload must send the same Content-Type.
 func (b *Bucket) UploadSignedURL(name, method, content_type string, expires time.Time) string {
 	expire_date := expires.Unix()
 	if method != "POST" {
 		method = "PUT"
 	}
 
+	if b.S3.Signature == aws.V4Signature {
+		var headers http.Header
+		if content_type != "" {
+			headers = http.Header{"Content-Type": {content_type}}
+		}
+		return b.SignedURLWithMethod(method, name, expires, nil, headers)
+	}
+
 	a := b.S3.Auth
 	tokenData := ""
 
@@ -916,17 +1382,21 @@ func (b *Bucket) UploadSignedURL(name, method, content_type string, expires time
 	var signedurl *url.URL
 	var err error
 	if b.Region.S3Endpoint != "" {
-		signedurl, err = url.Parse(b.Region.S3Endpoint)
-		name = b.Name + "/" + name
+		req := &request{bucket: b.Name, path: "/" + name}
+		if err = b.S3.setBaseURL(req); err == nil {
+			signedurl, err = req.url()
+		}
 	} else {
 		signedurl, err = url.Parse("https://" + b.Name + ".s3.amazonaws.com/")
+		if err == nil {
+			signedurl.Path = "/" + name
+		}
 	}
 
 	if err != nil {
 		log.Println("ERROR sining url for S3 upload", err)
 		return ""
 	}
-	signedurl.Path = name
 	params := url.Values{}
 	params.Add("AWSAccessKeyId", accessId)
 	params.Add("Expires", strconv.FormatInt(expire_date, 10))
@@ -942,7 +1412,19 @@ func (b *Bucket) UploadSignedURL(name, method, content_type string, expires time
 // PostFormArgs returns the action and input fields needed to allow anonymous
 // uploads to a bucket within the expiration limit
 // Additional conditions can be specified with conds
+// When the S3 Signature is aws.V4Signature the fields are signed with
+// Signature Version 4 (see PostForm and PostPolicy).
+// conds are copied into the policy unchecked, whatever the signature: S3
+// rejects the uploads if one is not valid JSON. Use PostForm with
+// PostPolicy.AddRawCondition to get an error for them instead.
 func (b *Bucket) PostFormArgsEx(path string, expires time.Time, redirect string, conds []string) (action string, fields map[string]string) {
+	if b.S3.Signature == aws.V4Signature {
+		action, fields, err := b.postFormArgsV4(path, expires, redirect, conds)
+		if err != nil {
+			panic(err)
+		}
+		return action, fields
+	}
 	conditions := make([]string, 0)
 	fields = map[string]string{
 		"AWSAccessKeyId": b.Auth.AccessKey,
@@ -976,7 +1458,15 @@ func (b *Bucket) PostFormArgsEx(path string, expires time.Time, redirect string,
 	signer.Write([]byte(policy64))
 	fields["signature"] = base64.StdEncoding.EncodeToString(signer.Sum(nil))
 
-	action = fmt.Sprintf("%s/%s/", b.S3.Region.S3Endpoint, b.Name)
+	req := &request{bucket: b.Name, path: "/"}
+	if err := b.S3.setBaseURL(req); err != nil {
+		panic(err)
+	}
+	u, err := req.url()
+	if err != nil {
+		panic(err)
+	}
+	action = u.String()
 	return
 }
 
@@ -995,6 +1485,13 @@ type request struct {
 	baseurl  string
 	payload  io.Reader
 	prepared bool
+	// virtualHosted is set when the bucket is named by the host
+	// rather than the path.
+	virtualHosted bool
+	// progress, if not nil, counts the payload bytes sent, which sent
+	// counts for the last attempt.
+	progress *transfer
+	sent     *progressReader
 }
 
 func (req *request) url() (*url.URL, error) {
@@ -1051,28 +1548,6 @@ func (s3 *S3) queryV4Sign(req *request, resp interface{}) error {
 	return err
 }
 
-// Sets baseurl on req from bucket name and the region endpoint
-func (s3 *S3) setBaseURL(req *request) error {
-	if req.bucket == "" {
-		req.baseurl = s3.Region.S3Endpoint
-	} else {
-		req.baseurl = s3.Region.S3BucketEndpoint
-		if req.baseurl == "" {
-			// Use the path method to address the bucket.
-			req.baseurl = s3.Region.S3Endpoint
-			req.path = "/" + req.bucket + req.path
-		} else {
-			// Just in case, prevent injection.
-			if strings.IndexAny(req.bucket, "/:@") >= 0 {
-				return fmt.Errorf("bad S3 bucket: %q", req.bucket)
-			}
-			req.baseurl = strings.Replace(req.baseurl, "${bucket}", req.bucket, -1)
-		}
-	}
-
-	return nil
-}
-
 // partiallyEscapedPath partially escapes the S3 path allowing for all S3 REST API calls.
 //
 // Some commands including:
@@ -1144,7 +1619,7 @@ func (s3 *S3) prepare(req *request) error {
 		}
 
 		signpathPartiallyEscaped := partiallyEscapedPath(req.path)
-		if strings.IndexAny(s3.Region.S3BucketEndpoint, "${bucket}") >= 0 {
+		if req.virtualHosted {
 			signpathPartiallyEscaped = "/" + req.bucket + signpathPartiallyEscaped
 		}
 		req.headers["Host"] = []string{u.Host}
@@ -1241,7 +1716,7 @@ func (s3 *S3) doHttpRequest(hreq *http.Request, resp interface{}) (*http.Respons
 		dump, _ := httputil.DumpResponse(hresp, true)
 		log.Printf("} -> %s\n", dump)
 	}
-	if hresp.StatusCode != 200 && hresp.StatusCode != 204 && hresp.StatusCode != 206 {
+	if hresp.StatusCode != 200 && hresp.StatusCode != 202 && hresp.StatusCode != 204 && hresp.StatusCode != 206 {
 		return nil, buildError(hresp)
 	}
 	if resp != nil {
@@ -1268,6 +1743,10 @@ func (s3 *S3) run(req *request, resp interface{}) (*http.Response, error) {
 	if err != nil {
 		return nil, err
 	}
+	req.sent = req.progress.reader(hreq.Body)
+	if req.sent != nil {
+		hreq.Body = req.sent
+	}
 
 	return s3.doHttpRequest(hreq, resp)
 }
@@ -1303,6 +1782,15 @@ func buildError(r *http.Response) error {
 	xml.NewDecoder(r.Body).Decode(&err)
 	r.Body.Close()
 	err.StatusCode = r.StatusCode
+	if err.Code == "" {
+		// Responses to HEAD requests and 304s have no body.
+		switch r.StatusCode {
+		case 304:
+			err.Code = "NotModified"
+		case 412:
+			err.Code = "PreconditionFailed"
+		}
+	}
 	if err.Message == "" {
 		err.Message = r.Status
 	}
@@ -1312,6 +1800,22 @@ func buildError(r *http.Response) error {
 	return &err
 }
 
+// IsNotModified reports whether err is the error returned when an
+// If-None-Match or If-Modified-Since precondition does not hold, meaning the
+// copy of the object held by the caller is current.
+func IsNotModified(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == 304
+}
+
+// IsPreconditionFailed reports whether err is the error returned when an
+// If-Match or If-Unmodified-Since precondition, or any precondition on the
+// source of a copy, does not hold.
+func IsPreconditionFailed(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == 412
+}
+
 func shouldRetry(err error) bool {
 	if err == nil {
 		return false
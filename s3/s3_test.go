@@ -2,8 +2,11 @@ package s3_test
 
 import (
 	"bytes"
+	"encoding/xml"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"testing"
 	"time"
 
@@ -107,6 +110,44 @@ func (s *S) TestHead(c *check.C) {
 	c.Assert(resp, check.FitsTypeOf, &http.Response{})
 }
 
+func (s *S) TestGetResponseWithOptions(c *check.C) {
+	testServer.Response(304, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	since := time.Date(2015, 12, 30, 12, 0, 0, 0, time.FixedZone("X", 3600))
+	_, err := b.GetResponseWithOptions("name", s3.Options{
+		IfNoneMatch:     `"etag"`,
+		IfModifiedSince: since,
+		Range:           "bytes=0-9",
+	})
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.Header["If-None-Match"], check.DeepEquals, []string{`"etag"`})
+	c.Assert(req.Header["If-Modified-Since"], check.DeepEquals, []string{"Wed, 30 Dec 2015 11:00:00 GMT"})
+	c.Assert(req.Header["Range"], check.DeepEquals, []string{"bytes=0-9"})
+
+	c.Assert(s3.IsNotModified(err), check.Equals, true)
+	c.Assert(s3.IsPreconditionFailed(err), check.Equals, false)
+	c.Assert(err.(*s3.Error).Code, check.Equals, "NotModified")
+}
+
+func (s *S) TestHeadWithOptionsPreconditionFailed(c *check.C) {
+	testServer.Response(412, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	_, err := b.HeadWithOptions("name", s3.Options{IfMatch: `"etag"`})
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "HEAD")
+	c.Assert(req.Header["If-Match"], check.DeepEquals, []string{`"etag"`})
+
+	c.Assert(s3.IsPreconditionFailed(err), check.Equals, true)
+	c.Assert(s3.IsNotModified(err), check.Equals, false)
+	c.Assert(err.(*s3.Error).Code, check.Equals, "PreconditionFailed")
+}
+
 // DeleteBucket docs: http://goo.gl/GoBrY
 
 func (s *S) TestDelBucket(c *check.C) {
@@ -369,6 +410,93 @@ func (s *S) TestDelMultiObjects(c *check.C) {
 	c.Assert(req.ContentLength, check.Not(check.Equals), "")
 }
 
+func (s *S) TestDelMultiObjectsErrors(c *check.C) {
+	testServer.Response(200, nil, DelMultiResultDump)
+
+	b := s.s3.Bucket("bucket")
+	objects := []s3.Object{{Key: "sample1.txt"}, {Key: "sample2.txt"}}
+	result, err := b.DelMultiFull(s3.Delete{Objects: objects})
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Deleted, check.DeepEquals, []s3.DeletedObject{{Key: "sample1.txt"}})
+	c.Assert(result.Errors, check.DeepEquals, []s3.DeleteError{{
+		Key:     "sample2.txt",
+		Code:    "AccessDenied",
+		Message: "Access Denied",
+	}})
+	testServer.WaitRequest()
+
+	testServer.Response(200, nil, DelMultiResultDump)
+	err = b.DelMulti(s3.Delete{Objects: objects})
+	c.Assert(err, check.FitsTypeOf, &s3.MultiDeleteError{})
+	c.Assert(err.(*s3.MultiDeleteError).Errors, check.HasLen, 1)
+	c.Assert(err.Error(), check.Equals, "failed to delete sample2.txt: Access Denied")
+}
+
+func (s *S) TestDelMultiObjectsBatches(c *check.C) {
+	s3.SetDelMultiMax(2)
+	defer s3.SetDelMultiMax(1000)
+	for i := 0; i < 3; i++ {
+		testServer.Response(200, nil, "")
+	}
+
+	b := s.s3.Bucket("bucket")
+	var objects []s3.Object
+	for i := 0; i < 5; i++ {
+		objects = append(objects, s3.Object{Key: fmt.Sprintf("key%d", i)})
+	}
+	err := b.DelMulti(s3.Delete{Quiet: true, Objects: objects})
+	c.Assert(err, check.IsNil)
+
+	var keys []string
+	for _, req := range testServer.WaitRequests(3) {
+		c.Assert(req.Method, check.Equals, "POST")
+		c.Assert(req.URL.RawQuery, check.Equals, "delete=")
+		var body s3.Delete
+		err := xml.NewDecoder(req.Body).Decode(&body)
+		c.Assert(err, check.IsNil)
+		c.Assert(body.Quiet, check.Equals, true)
+		c.Assert(len(body.Objects) <= 2, check.Equals, true)
+		for _, o := range body.Objects {
+			keys = append(keys, o.Key)
+		}
+	}
+	sort.Strings(keys)
+	c.Assert(keys, check.DeepEquals, []string{"key0", "key1", "key2", "key3", "key4"})
+}
+
+func (s *S) TestDeletePrefix(c *check.C) {
+	testServer.Response(200, nil, GetVersionsResultDump)
+	testServer.Response(200, nil, "")
+	testServer.Response(200, nil, GetVersionsResultDump2)
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.DeletePrefix("my")
+	c.Assert(err, check.IsNil)
+
+	reqs := testServer.WaitRequests(4)
+	c.Assert(reqs[0].Form["versions"], check.DeepEquals, []string{""})
+	c.Assert(reqs[0].Form["prefix"], check.DeepEquals, []string{"my"})
+	c.Assert(reqs[2].Form["key-marker"], check.DeepEquals, []string{"my-second-image.jpg"})
+	c.Assert(reqs[2].Form["version-id-marker"], check.DeepEquals, []string{"03jpff543dhffds434rfdsFDN943fdsFkdmqnh892"})
+
+	var body s3.Delete
+	err = xml.NewDecoder(reqs[1].Body).Decode(&body)
+	c.Assert(err, check.IsNil)
+	c.Assert(body.Objects, check.DeepEquals, []s3.Object{
+		{Key: "my-image.jpg", VersionId: "3/L4kqtJl40Nr8X8gdRQBpUMLUo"},
+		{Key: "my-second-image.jpg", VersionId: "QUpfdndhfd8438MNFDN93jdnJFkdmqnh893"},
+		{Key: "my-second-image.jpg", VersionId: "03jpff543dhffds434rfdsFDN943fdsFkdmqnh892"},
+	})
+
+	body = s3.Delete{}
+	err = xml.NewDecoder(reqs[3].Body).Decode(&body)
+	c.Assert(err, check.IsNil)
+	c.Assert(body.Objects, check.DeepEquals, []s3.Object{
+		{Key: "my-third-image.jpg", VersionId: "null"},
+	})
+}
+
 // Bucket List Objects docs: http://goo.gl/YjQTc
 
 func (s *S) TestList(c *check.C) {
@@ -436,6 +564,34 @@ func (s *S) TestListWithDelimiter(c *check.C) {
 	c.Assert(data.CommonPrefixes, check.DeepEquals, []string{"photos/2006/feb/", "photos/2006/jan/"})
 }
 
+func (s *S) TestListV2(c *check.C) {
+	testServer.Response(200, nil, GetListV2ResultDump)
+
+	b := s.s3.Bucket("quotes")
+
+	data, err := b.ListV2("N", "", "1ueGcxLPRx1Tr/XYExHnhbYLgveDs2J/wm36Hy4vbOwM=", "M", 1, true)
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/quotes/")
+	c.Assert(req.Form["list-type"], check.DeepEquals, []string{"2"})
+	c.Assert(req.Form["prefix"], check.DeepEquals, []string{"N"})
+	c.Assert(req.Form["continuation-token"], check.DeepEquals, []string{"1ueGcxLPRx1Tr/XYExHnhbYLgveDs2J/wm36Hy4vbOwM="})
+	c.Assert(req.Form["start-after"], check.DeepEquals, []string{"M"})
+	c.Assert(req.Form["max-keys"], check.DeepEquals, []string{"1"})
+	c.Assert(req.Form["fetch-owner"], check.DeepEquals, []string{"true"})
+
+	c.Assert(data.Name, check.Equals, "quotes")
+	c.Assert(data.KeyCount, check.Equals, 1)
+	c.Assert(data.IsTruncated, check.Equals, true)
+	c.Assert(data.StartAfter, check.Equals, "M")
+	c.Assert(data.NextContinuationToken, check.Equals, "1w41l63U0xa8q7smH50vCxyTQqdxo69O3EmK28Bi5PcROI4wI/EyIJg==")
+	c.Assert(data.Contents, check.HasLen, 1)
+	c.Assert(data.Contents[0].Key, check.Equals, "Nelson")
+	c.Assert(data.Contents[0].Owner.DisplayName, check.Equals, "webfile")
+}
+
 func (s *S) TestExists(c *check.C) {
 	testServer.Response(200, nil, "")
 
@@ -534,3 +690,109 @@ func (s *S) TestSupportRadosGW(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(req.RequestURI, check.Equals, "/bucket/rgw")
 }
+
+func (s *S) TestGetVersion(c *check.C) {
+	testServer.Response(200, nil, "content")
+
+	b := s.s3.Bucket("bucket")
+	data, err := b.GetVersion("name", "3/L4kqtJl40Nr8X8gdRQBpUMLUo")
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.Form["versionId"], check.DeepEquals, []string{"3/L4kqtJl40Nr8X8gdRQBpUMLUo"})
+
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "content")
+}
+
+func (s *S) TestHeadVersion(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	_, err := b.HeadVersion("name", "v1", nil)
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "HEAD")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.URL.RawQuery, check.Equals, "versionId=v1")
+}
+
+func (s *S) TestDelVersion(c *check.C) {
+	testServer.Response(204, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.DelVersion("name", "v1")
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "DELETE")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.URL.RawQuery, check.Equals, "versionId=v1")
+}
+
+func (s *S) TestPutCopyVersion(c *check.C) {
+	testServer.Response(200, nil, PutCopyResultDump)
+
+	b := s.s3.Bucket("bucket")
+	_, err := b.PutCopy("name", s3.Private, s3.CopyOptions{CopySourceVersionId: "v1"}, "source-bucket/source")
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Header["X-Amz-Copy-Source"], check.DeepEquals, []string{"source-bucket/source?versionId=v1"})
+}
+
+func (s *S) TestPutCopyPreconditions(c *check.C) {
+	testServer.Response(412, nil, PreconditionFailedDump)
+
+	b := s.s3.Bucket("bucket")
+	_, err := b.PutCopy("name", s3.Private, s3.CopyOptions{
+		CopySourceIfMatch:           `"etag"`,
+		CopySourceIfUnmodifiedSince: time.Date(2015, 12, 30, 12, 0, 0, 0, time.UTC),
+	}, "source-bucket/source")
+	c.Assert(s3.IsPreconditionFailed(err), check.Equals, true)
+	c.Assert(err, check.ErrorMatches, "At least one of the pre-conditions you specified did not hold")
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Header["X-Amz-Copy-Source-If-Match"], check.DeepEquals, []string{`"etag"`})
+	c.Assert(req.Header["X-Amz-Copy-Source-If-Unmodified-Since"], check.DeepEquals, []string{"Wed, 30 Dec 2015 12:00:00 GMT"})
+	c.Assert(req.Header["If-Match"], check.IsNil)
+}
+
+func (s *S) TestPutObjectTags(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	tags := []s3.Tag{{Key: "project", Value: "goamz"}, {Key: "owner", Value: "a&b c"}}
+	err := b.Put("name", []byte("content"), "content-type", s3.Private, s3.Options{Tags: tags})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Header["X-Amz-Tagging"], check.DeepEquals, []string{"project=goamz&owner=a%26b+c"})
+}
+
+func (s *S) TestVersions(c *check.C) {
+	testServer.Response(200, nil, GetVersionsResultDump)
+
+	b := s.s3.Bucket("bucket")
+	data, err := b.Versions("my", "", "", "", 0)
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/")
+	c.Assert(req.Form["prefix"], check.DeepEquals, []string{"my"})
+
+	c.Assert(data.IsTruncated, check.Equals, true)
+	c.Assert(data.NextKeyMarker, check.Equals, "my-second-image.jpg")
+	c.Assert(data.NextVersionIdMarker, check.Equals, "03jpff543dhffds434rfdsFDN943fdsFkdmqnh892")
+	c.Assert(data.Versions, check.HasLen, 2)
+	c.Assert(data.Versions[0].Key, check.Equals, "my-image.jpg")
+	c.Assert(data.Versions[0].VersionId, check.Equals, "3/L4kqtJl40Nr8X8gdRQBpUMLUo")
+	c.Assert(data.Versions[0].IsLatest, check.Equals, true)
+	c.Assert(data.DeleteMarkers, check.HasLen, 1)
+	c.Assert(data.DeleteMarkers[0].Key, check.Equals, "my-second-image.jpg")
+	c.Assert(data.DeleteMarkers[0].VersionId, check.Equals, "03jpff543dhffds434rfdsFDN943fdsFkdmqnh892")
+	c.Assert(data.DeleteMarkers[0].IsLatest, check.Equals, true)
+}
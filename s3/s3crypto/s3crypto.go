@@ -0,0 +1,256 @@
+// Package s3crypto implements client-side envelope encryption of S3
+// objects with data keys generated by AWS KMS.
+//
+// Object bodies are encrypted locally with AES-256-GCM before they are
+// uploaded, so they never reach S3 in plaintext. The data key, wrapped by
+// KMS, and the IV are stored in the object metadata using the layout of the
+// AWS S3 Encryption Client v2 ("kms+context" key wrapping), so objects
+// written here can be read by the AWS SDKs and the other way around.
+//
+// AES-GCM authenticates the whole body at once: objects are buffered in
+// memory while they are encrypted or decrypted, and no plaintext is
+// returned before the body has been verified.
+package s3crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/AdRoll/goamz/kms"
+	"github.com/AdRoll/goamz/s3"
+)
+
+// Metadata keys used by the AWS S3 Encryption Client v2. They are stored
+// as x-amz-meta-* headers on the object.
+const (
+	MetaKey                   = "x-amz-key-v2"
+	MetaIV                    = "x-amz-iv"
+	MetaMatDesc               = "x-amz-matdesc"
+	MetaWrapAlg               = "x-amz-wrap-alg"
+	MetaCEKAlg                = "x-amz-cek-alg"
+	MetaTagLen                = "x-amz-tag-len"
+	MetaUnencryptedContentLen = "x-amz-unencrypted-content-length"
+)
+
+const (
+	// AESGCMNoPadding is the content encryption algorithm used for
+	// object bodies.
+	AESGCMNoPadding = "AES/GCM/NoPadding"
+	// KMSContextWrap is the key wrapping algorithm of the v2 format: the
+	// encryption context sent to KMS includes the content algorithm.
+	KMSContextWrap = "kms+context"
+	// KMSWrap is the key wrapping algorithm written by older clients. It
+	// is accepted on read only.
+	KMSWrap = "kms"
+
+	cekAlgContextKey = "aws:x-amz-cek-alg"
+	gcmTagSize       = 16
+)
+
+// KMS is the subset of the kms.KMS client used to wrap and unwrap data
+// keys. *kms.KMS implements it.
+type KMS interface {
+	GenerateDataKey(info kms.GenerateDataKeyInfo) (kms.GenerateDataKeyResp, error)
+	Decrypt(info kms.DecryptInfo) (kms.DecryptResp, error)
+}
+
+// Client reads and writes encrypted objects in an S3 bucket.
+type Client struct {
+	Bucket *s3.Bucket
+	KMS    KMS
+	// KeyId identifies the KMS customer master key that wraps the data
+	// keys of new objects. It is not needed to read objects, since KMS
+	// finds the key from the wrapped data key.
+	KeyId string
+	// Context is added to the KMS encryption context of new objects and
+	// stored in their material description. It must not contain the
+	// reserved key "aws:x-amz-cek-alg".
+	Context map[string]string
+}
+
+// New returns a Client encrypting the objects of bucket with data keys
+// wrapped by the KMS key keyId.
+func New(bucket *s3.Bucket, k KMS, keyId string) *Client {
+	return &Client{Bucket: bucket, KMS: k, KeyId: keyId}
+}
+
+// Put encrypts data and inserts it into the bucket at path.
+func (c *Client) Put(path string, data []byte, contType string, perm s3.ACL, options s3.Options) error {
+	return c.PutReader(path, bytes.NewReader(data), int64(len(data)), contType, perm, options)
+}
+
+// PutReader encrypts length bytes read from r and inserts them into the
+// bucket at path. options.Meta must not use the metadata keys reserved by
+// this package. If options.ContentMD5 is set, it is recomputed over the
+// encrypted body.
+func (c *Client) PutReader(path string, r io.Reader, length int64, contType string, perm s3.ACL, options s3.Options) error {
+	if _, ok := c.Context[cekAlgContextKey]; ok {
+		return fmt.Errorf("s3crypto: encryption context uses the reserved key %q", cekAlgContextKey)
+	}
+	plaintext := make([]byte, length)
+	if _, err := io.ReadFull(r, plaintext); err != nil {
+		return err
+	}
+
+	context := map[string]string{cekAlgContextKey: AESGCMNoPadding}
+	for k, v := range c.Context {
+		context[k] = v
+	}
+	dataKey, err := c.KMS.GenerateDataKey(kms.GenerateDataKeyInfo{
+		KeyId:         c.KeyId,
+		ProduceKeyOpt: kms.ProduceKeyOpt{EncryptionContext: context},
+		KeySpec:       "AES_256",
+	})
+	if err != nil {
+		return err
+	}
+	defer zero(dataKey.Plaintext)
+
+	aead, err := newGCM(dataKey.Plaintext)
+	if err != nil {
+		return err
+	}
+	iv := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return err
+	}
+	ciphertext := aead.Seal(nil, iv, plaintext, nil)
+
+	matdesc, err := json.Marshal(context)
+	if err != nil {
+		return err
+	}
+	meta := make(map[string][]string, len(options.Meta)+7)
+	for k, v := range options.Meta {
+		meta[k] = v
+	}
+	meta[MetaKey] = []string{base64.StdEncoding.EncodeToString(dataKey.CiphertextBlob)}
+	meta[MetaIV] = []string{base64.StdEncoding.EncodeToString(iv)}
+	meta[MetaMatDesc] = []string{string(matdesc)}
+	meta[MetaWrapAlg] = []string{KMSContextWrap}
+	meta[MetaCEKAlg] = []string{AESGCMNoPadding}
+	meta[MetaTagLen] = []string{strconv.Itoa(gcmTagSize * 8)}
+	meta[MetaUnencryptedContentLen] = []string{strconv.FormatInt(length, 10)}
+	options.Meta = meta
+	if options.ContentMD5 != "" {
+		sum := md5.Sum(ciphertext)
+		options.ContentMD5 = base64.StdEncoding.EncodeToString(sum[:])
+	}
+
+	return c.Bucket.PutReader(path, bytes.NewReader(ciphertext), int64(len(ciphertext)), contType, perm, options)
+}
+
+// Get retrieves and decrypts the object at path.
+func (c *Client) Get(path string) ([]byte, error) {
+	resp, err := c.Bucket.GetResponse(path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return c.decrypt(resp.Header, resp.Body)
+}
+
+// GetReader retrieves the object at path and returns its decrypted body.
+// It is the caller's responsibility to call Close on rc when finished
+// reading.
+func (c *Client) GetReader(path string) (rc io.ReadCloser, err error) {
+	data, err := c.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// decrypt unwraps the data key described by the metadata in h and uses it
+// to decrypt body.
+func (c *Client) decrypt(h http.Header, body io.Reader) ([]byte, error) {
+	meta := func(name string) string {
+		return h.Get("x-amz-meta-" + name)
+	}
+	if meta(MetaKey) == "" {
+		if meta("x-amz-key") != "" {
+			return nil, fmt.Errorf("s3crypto: v1 encrypted objects are not supported")
+		}
+		return nil, fmt.Errorf("s3crypto: object is not encrypted")
+	}
+	if alg := meta(MetaCEKAlg); alg != AESGCMNoPadding {
+		return nil, fmt.Errorf("s3crypto: unsupported content encryption algorithm %q", alg)
+	}
+	if tagLen := meta(MetaTagLen); tagLen != "" && tagLen != strconv.Itoa(gcmTagSize*8) {
+		return nil, fmt.Errorf("s3crypto: unsupported tag length %q", tagLen)
+	}
+
+	var context map[string]string
+	if err := json.Unmarshal([]byte(meta(MetaMatDesc)), &context); err != nil {
+		return nil, fmt.Errorf("s3crypto: bad material description: %v", err)
+	}
+	switch wrap := meta(MetaWrapAlg); wrap {
+	case KMSContextWrap:
+		if context[cekAlgContextKey] != AESGCMNoPadding {
+			return nil, fmt.Errorf("s3crypto: material description does not match content encryption algorithm")
+		}
+	case KMSWrap:
+	default:
+		return nil, fmt.Errorf("s3crypto: unsupported key wrapping algorithm %q", wrap)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(meta(MetaKey))
+	if err != nil {
+		return nil, fmt.Errorf("s3crypto: bad wrapped key: %v", err)
+	}
+	iv, err := base64.StdEncoding.DecodeString(meta(MetaIV))
+	if err != nil {
+		return nil, fmt.Errorf("s3crypto: bad IV: %v", err)
+	}
+
+	dataKey, err := c.KMS.Decrypt(kms.DecryptInfo{
+		CiphertextBlob: wrapped,
+		ProduceKeyOpt:  kms.ProduceKeyOpt{EncryptionContext: context},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer zero(dataKey.Plaintext)
+
+	aead, err := newGCM(dataKey.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aead.NonceSize() {
+		return nil, fmt.Errorf("s3crypto: bad IV length %d", len(iv))
+	}
+	ciphertext, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(ciphertext[:0], iv, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("s3crypto: %v", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// zero clears key material once it is no longer needed.
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
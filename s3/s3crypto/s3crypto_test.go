@@ -0,0 +1,192 @@
+package s3crypto_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/kms"
+	"github.com/AdRoll/goamz/s3"
+	"github.com/AdRoll/goamz/s3/s3crypto"
+	"github.com/AdRoll/goamz/s3/s3test"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type S struct {
+	srv    *s3test.Server
+	bucket *s3.Bucket
+	kms    *fakeKMS
+	client *s3crypto.Client
+}
+
+var _ = check.Suite(&S{})
+
+func (s *S) SetUpSuite(c *check.C) {
+	srv, err := s3test.NewServer(nil)
+	c.Assert(err, check.IsNil)
+	s.srv = srv
+	region := aws.Region{
+		Name:                 "faux-region-1",
+		S3Endpoint:           srv.URL(),
+		S3LocationConstraint: true,
+	}
+	s.bucket = s3.New(aws.Auth{}, region).Bucket("bucket")
+	c.Assert(s.bucket.PutBucket(s3.Private), check.IsNil)
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	s.srv.Quit()
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	s.kms = &fakeKMS{keys: make(map[string]fakeKey)}
+	s.client = s3crypto.New(s.bucket, s.kms, "alias/test")
+}
+
+// fakeKMS hands out random data keys and remembers them by their
+// "wrapped" form, checking the encryption context on Decrypt like KMS.
+type fakeKMS struct {
+	keys map[string]fakeKey
+	n    int
+}
+
+type fakeKey struct {
+	plaintext []byte
+	context   map[string]string
+}
+
+func (k *fakeKMS) GenerateDataKey(info kms.GenerateDataKeyInfo) (kms.GenerateDataKeyResp, error) {
+	if info.KeySpec != "AES_256" {
+		return kms.GenerateDataKeyResp{}, fmt.Errorf("unexpected key spec %q", info.KeySpec)
+	}
+	plaintext := make([]byte, 32)
+	rand.Read(plaintext)
+	k.n++
+	blob := fmt.Sprintf("%s/%d", info.KeyId, k.n)
+	k.keys[blob] = fakeKey{append([]byte(nil), plaintext...), info.EncryptionContext}
+	return kms.GenerateDataKeyResp{CiphertextBlob: []byte(blob), KeyId: info.KeyId, Plaintext: plaintext}, nil
+}
+
+func (k *fakeKMS) Decrypt(info kms.DecryptInfo) (kms.DecryptResp, error) {
+	key, ok := k.keys[string(info.CiphertextBlob)]
+	if !ok || !reflect.DeepEqual(key.context, info.EncryptionContext) {
+		return kms.DecryptResp{}, errors.New("InvalidCiphertextException")
+	}
+	return kms.DecryptResp{Plaintext: append([]byte(nil), key.plaintext...)}, nil
+}
+
+func (s *S) TestRoundTrip(c *check.C) {
+	s.client.Context = map[string]string{"purpose": "test"}
+	data := []byte("some secret content")
+	err := s.client.Put("secret", data, "text/plain", s3.Private, s3.Options{
+		Meta: map[string][]string{"owner": {"ops"}},
+	})
+	c.Assert(err, check.IsNil)
+
+	stored, header, err := s.bucket.GetWithHeaders("secret")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored, check.HasLen, len(data)+16)
+	c.Assert(bytes.Contains(stored, data), check.Equals, false)
+	c.Assert(header.Get("X-Amz-Meta-Owner"), check.Equals, "ops")
+	c.Assert(header.Get("X-Amz-Meta-X-Amz-Wrap-Alg"), check.Equals, "kms+context")
+	c.Assert(header.Get("X-Amz-Meta-X-Amz-Cek-Alg"), check.Equals, "AES/GCM/NoPadding")
+	c.Assert(header.Get("X-Amz-Meta-X-Amz-Tag-Len"), check.Equals, "128")
+	c.Assert(header.Get("X-Amz-Meta-X-Amz-Unencrypted-Content-Length"), check.Equals, "19")
+	c.Assert(header.Get("X-Amz-Meta-X-Amz-Key-V2"), check.Not(check.Equals), "")
+	c.Assert(header.Get("X-Amz-Meta-X-Amz-Iv"), check.Not(check.Equals), "")
+	var matdesc map[string]string
+	err = json.Unmarshal([]byte(header.Get("X-Amz-Meta-X-Amz-Matdesc")), &matdesc)
+	c.Assert(err, check.IsNil)
+	c.Assert(matdesc, check.DeepEquals, map[string]string{
+		"aws:x-amz-cek-alg": "AES/GCM/NoPadding",
+		"purpose":           "test",
+	})
+
+	got, err := s.client.Get("secret")
+	c.Assert(err, check.IsNil)
+	c.Assert(got, check.DeepEquals, data)
+
+	rc, err := s.client.GetReader("secret")
+	c.Assert(err, check.IsNil)
+	got, err = ioutil.ReadAll(rc)
+	rc.Close()
+	c.Assert(err, check.IsNil)
+	c.Assert(got, check.DeepEquals, data)
+}
+
+func (s *S) TestEmptyObject(c *check.C) {
+	err := s.client.Put("empty", nil, "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	got, err := s.client.Get("empty")
+	c.Assert(err, check.IsNil)
+	c.Assert(got, check.HasLen, 0)
+}
+
+func (s *S) TestContentMD5OfCiphertext(c *check.C) {
+	// The fake server rejects bodies that do not match Content-MD5.
+	err := s.client.Put("md5", []byte("content"), "text/plain", s3.Private, s3.Options{ContentMD5: "bogus"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestTamperedObject(c *check.C) {
+	err := s.client.Put("tampered", []byte("content"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	resp, err := s.bucket.GetResponse("tampered")
+	c.Assert(err, check.IsNil)
+	stored, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.Assert(err, check.IsNil)
+	stored[0] ^= 1
+	meta := make(map[string][]string)
+	for k, v := range resp.Header {
+		if len(k) > len("X-Amz-Meta-") && k[:len("X-Amz-Meta-")] == "X-Amz-Meta-" {
+			meta[k[len("X-Amz-Meta-"):]] = v
+		}
+	}
+	err = s.bucket.Put("tampered", stored, "text/plain", s3.Private, s3.Options{Meta: meta})
+	c.Assert(err, check.IsNil)
+
+	_, err = s.client.Get("tampered")
+	c.Assert(err, check.ErrorMatches, "s3crypto: .*authentication failed")
+}
+
+func (s *S) TestWrongContext(c *check.C) {
+	s.client.Context = map[string]string{"purpose": "test"}
+	err := s.client.Put("context", []byte("content"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	for _, key := range s.kms.keys {
+		key.context["purpose"] = "other"
+	}
+	_, err = s.client.Get("context")
+	c.Assert(err, check.ErrorMatches, "InvalidCiphertextException")
+}
+
+func (s *S) TestNotEncrypted(c *check.C) {
+	err := s.bucket.Put("plain", []byte("content"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	_, err = s.client.Get("plain")
+	c.Assert(err, check.ErrorMatches, "s3crypto: object is not encrypted")
+}
+
+func (s *S) TestReservedContextKey(c *check.C) {
+	s.client.Context = map[string]string{"aws:x-amz-cek-alg": "AES/CBC/PKCS5Padding"}
+	err := s.client.Put("reserved", []byte("content"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.ErrorMatches, `s3crypto: encryption context uses the reserved key "aws:x-amz-cek-alg"`)
+
+	_, err = s.bucket.Get("reserved")
+	c.Assert(err, check.NotNil)
+}
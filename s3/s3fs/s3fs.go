@@ -0,0 +1,427 @@
+// Package s3fs presents the objects of an S3 bucket as a read-only file
+// system implementing io/fs.FS, fs.ReadDirFS and fs.StatFS.
+//
+// Object keys under a prefix are mapped to slash-separated paths, and
+// directories are the common prefixes found when listing with a "/"
+// delimiter: S3 has no directories of its own, so a directory exists as
+// long as some key is stored under it. Keys that do not form valid paths,
+// such as those containing empty elements, are not visible.
+//
+// Files implement io.ReaderAt and io.Seeker with ranged GET requests, so
+// an FS can be served over HTTP, with support for range requests, with:
+//
+//	http.Handle("/", http.FileServer(http.FS(s3fs.New(bucket, "static/"))))
+package s3fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AdRoll/goamz/s3"
+)
+
+// FS is a read-only file system over the keys of an S3 bucket that begin
+// with a prefix.
+type FS struct {
+	bucket *s3.Bucket
+	prefix string
+}
+
+// New returns a file system over the keys of bucket under prefix. The
+// prefix usually ends in "/"; the file named "a/b" in the returned file
+// system is the object with key prefix+"a/b".
+func New(bucket *s3.Bucket, prefix string) *FS {
+	return &FS{bucket, prefix}
+}
+
+var (
+	_ fs.ReadDirFS = (*FS)(nil)
+	_ fs.StatFS    = (*FS)(nil)
+)
+
+// key returns the key of the object with the given name.
+func (fsys *FS) key(name string) string {
+	if name == "." {
+		return fsys.prefix
+	}
+	return fsys.prefix + name
+}
+
+// dirPrefix returns the prefix of the keys in the directory with the
+// given name.
+func (fsys *FS) dirPrefix(name string) string {
+	if name == "." {
+		return fsys.prefix
+	}
+	return fsys.prefix + name + "/"
+}
+
+// Open opens the named file or directory.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	info, err := fsys.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &dir{fsys: fsys, name: name, info: info}, nil
+	}
+	return &file{fsys: fsys, name: name, info: info}, nil
+}
+
+// Stat returns a FileInfo describing the named file or directory. Files
+// are stat'ed with a HEAD request, and directories by listing at most one
+// key under them.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	return fsys.stat("stat", name)
+}
+
+func (fsys *FS) stat(op, name string) (*fileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &fileInfo{name: ".", dir: true}, nil
+	}
+	resp, err := fsys.bucket.Head(fsys.key(name), nil)
+	if err == nil {
+		resp.Body.Close()
+		info := &fileInfo{
+			name: path.Base(name),
+			key: s3.Key{
+				Key:          fsys.key(name),
+				Size:         resp.ContentLength,
+				ETag:         resp.Header.Get("ETag"),
+				LastModified: resp.Header.Get("Last-Modified"),
+			},
+		}
+		info.modTime, _ = http.ParseTime(info.key.LastModified)
+		return info, nil
+	}
+	if !isNotExist(err) {
+		return nil, pathError(op, name, err)
+	}
+	list, err := fsys.bucket.List(fsys.dirPrefix(name), "/", "", 1)
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+	if len(list.Contents) == 0 && len(list.CommonPrefixes) == 0 {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return &fileInfo{name: path.Base(name), dir: true}, nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by
+// name.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	info, err := fsys.stat("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	entries, err := fsys.readDir(name)
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+	return entries, nil
+}
+
+// readDir lists the directory with the given name, paging through the
+// results of List.
+func (fsys *FS) readDir(name string) ([]fs.DirEntry, error) {
+	prefix := fsys.dirPrefix(name)
+	var entries []fs.DirEntry
+	marker := ""
+	for {
+		list, err := fsys.bucket.List(prefix, "/", marker, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range list.Contents {
+			if base := key.Key[len(prefix):]; validName(base) {
+				entries = append(entries, fs.FileInfoToDirEntry(newFileInfo(base, key)))
+			}
+		}
+		for _, p := range list.CommonPrefixes {
+			if base := strings.TrimSuffix(p[len(prefix):], "/"); validName(base) {
+				entries = append(entries, fs.FileInfoToDirEntry(&fileInfo{name: base, dir: true}))
+			}
+		}
+		if !list.IsTruncated || list.NextMarker == "" {
+			break
+		}
+		marker = list.NextMarker
+	}
+	// Keys are listed in byte order, where "a/" sorts after "a.txt".
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// validName reports whether name can be the name of a directory entry.
+// Directory markers, the empty objects some tools create with keys ending
+// in "/", have an empty name and are not listed.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.Contains(name, "/")
+}
+
+func isNotExist(err error) bool {
+	e, ok := err.(*s3.Error)
+	return ok && e.StatusCode == 404
+}
+
+// pathError wraps err, an error returned by S3, in a *fs.PathError,
+// translating the status codes that have an equivalent in io/fs.
+func pathError(op, name string, err error) error {
+	if e, ok := err.(*s3.Error); ok {
+		switch e.StatusCode {
+		case 404:
+			err = fs.ErrNotExist
+		case 403:
+			err = fs.ErrPermission
+		}
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// fileInfo describes an object or a directory.
+type fileInfo struct {
+	name    string
+	dir     bool
+	key     s3.Key
+	modTime time.Time
+}
+
+func newFileInfo(name string, key s3.Key) *fileInfo {
+	modTime, _ := time.Parse(time.RFC3339, key.LastModified)
+	// Listings have a resolution of a millisecond, but Last-Modified
+	// headers only of a second. Keep them consistent.
+	modTime = modTime.Truncate(time.Second)
+	return &fileInfo{name: name, key: key, modTime: modTime}
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.key.Size }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.dir }
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// Sys returns the s3.Key of an object, and nil for directories.
+func (fi *fileInfo) Sys() interface{} {
+	if fi.dir {
+		return nil
+	}
+	return fi.key
+}
+
+// file is an open object. Its body is fetched lazily, from the current
+// offset, on the first Read after opening or seeking. All requests are
+// made conditional on the ETag the object had when it was opened, so
+// reading an object that is being overwritten fails rather than mixing
+// both versions.
+type file struct {
+	fsys   *FS
+	name   string
+	info   *fileInfo
+	offset int64
+	body   io.ReadCloser
+	closed bool
+}
+
+var (
+	_ io.ReaderAt = (*file)(nil)
+	_ io.Seeker   = (*file)(nil)
+)
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// get returns the body of the object from off to off+n-1, or to its end
+// if n is negative.
+func (f *file) get(off, n int64) (io.ReadCloser, error) {
+	r := fmt.Sprintf("bytes=%d-", off)
+	if n >= 0 {
+		r += fmt.Sprint(off + n - 1)
+	}
+	headers := map[string][]string{"Range": {r}}
+	if etag := f.info.key.ETag; etag != "" {
+		headers["If-Match"] = []string{etag}
+	}
+	resp, err := f.fsys.bucket.GetResponseWithHeaders(f.info.key.Key, headers)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.offset >= f.info.Size() {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if f.body == nil {
+		body, err := f.get(f.offset, -1)
+		if err != nil {
+			return 0, pathError("read", f.name, err)
+		}
+		f.body = body
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	if err == io.EOF && f.offset < f.info.Size() {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil && err != io.EOF {
+		err = pathError("read", f.name, err)
+	}
+	return n, err
+}
+
+// ReadAt reads len(p) bytes at offset off with a ranged GET request. It
+// does not change the offset of the file.
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
+	}
+	size := f.info.Size()
+	if off >= size {
+		return 0, io.EOF
+	}
+	want := int64(len(p))
+	if off+want > size {
+		want = size - off
+	}
+	if want == 0 {
+		return 0, nil
+	}
+	body, err := f.get(off, want)
+	if err != nil {
+		return 0, pathError("read", f.name, err)
+	}
+	defer body.Close()
+	n, err := io.ReadFull(body, p[:want])
+	if err != nil {
+		return n, pathError("read", f.name, err)
+	}
+	if want < int64(len(p)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Seek sets the offset of the next Read. The body being read, if any, is
+// dropped, and fetched again from the new offset.
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *file) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	if f.body != nil {
+		return f.body.Close()
+	}
+	return nil
+}
+
+// dir is an open directory. Its entries are listed on the first call to
+// ReadDir.
+type dir struct {
+	fsys    *FS
+	name    string
+	info    *fileInfo
+	entries []fs.DirEntry
+	listed  bool
+	closed  bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+// ReadDir returns the next n entries of the directory, sorted by name, or
+// all the remaining ones if n <= 0.
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fs.ErrClosed}
+	}
+	if !d.listed {
+		entries, err := d.fsys.readDir(d.name)
+		if err != nil {
+			return nil, pathError("readdir", d.name, err)
+		}
+		d.entries, d.listed = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func (d *dir) Close() error {
+	if d.closed {
+		return &fs.PathError{Op: "close", Path: d.name, Err: fs.ErrClosed}
+	}
+	d.closed = true
+	return nil
+}
@@ -0,0 +1,189 @@
+package s3fs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/s3"
+	"github.com/AdRoll/goamz/s3/s3fs"
+	"github.com/AdRoll/goamz/s3/s3test"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type S struct {
+	srv    *s3test.Server
+	bucket *s3.Bucket
+	fsys   *s3fs.FS
+}
+
+var _ = check.Suite(&S{})
+
+var objects = map[string]string{
+	"outside.txt":                "not under the prefix",
+	"site/index.html":            "<h1>Hello</h1>",
+	"site/a.txt":                 "a",
+	"site/a/b.txt":               "b",
+	"site/a/c/d.txt":             "d",
+	"site/empty":                 "",
+	"site/assets/":               "", // A directory marker.
+	"site/assets/style.css":      "body { color: black; }",
+	"site/assets/large.bin":      "0123456789abcdefghijklmnopqrstuvwxyz",
+	"site/bad//empty-element":    "not visible",
+	"site/assets/../dot-dot.txt": "not visible",
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	srv, err := s3test.NewServer(nil)
+	c.Assert(err, check.IsNil)
+	s.srv = srv
+	region := aws.Region{
+		Name:                 "faux-region-1",
+		S3Endpoint:           srv.URL(),
+		S3LocationConstraint: true,
+	}
+	s.bucket = s3.New(aws.Auth{}, region).Bucket("bucket")
+	c.Assert(s.bucket.PutBucket(s3.Private), check.IsNil)
+	for key, data := range objects {
+		err := s.bucket.Put(key, []byte(data), "text/plain", s3.Private, s3.Options{})
+		c.Assert(err, check.IsNil)
+	}
+	s.fsys = s3fs.New(s.bucket, "site/")
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	s.srv.Quit()
+}
+
+func (s *S) TestFS(c *check.C) {
+	err := fstest.TestFS(s.fsys, "index.html", "a.txt", "a/b.txt", "a/c/d.txt", "empty", "assets/style.css", "assets/large.bin")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestReadDir(c *check.C) {
+	entries, err := fs.ReadDir(s.fsys, ".")
+	c.Assert(err, check.IsNil)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	// "bad" only holds a key with an empty path element.
+	c.Assert(names, check.DeepEquals, []string{"a", "a.txt", "assets", "bad", "empty", "index.html"})
+	c.Assert(entries[0].IsDir(), check.Equals, true)
+	c.Assert(entries[1].IsDir(), check.Equals, false)
+
+	entries, err = fs.ReadDir(s.fsys, "bad")
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 0)
+
+	_, err = fs.ReadDir(s.fsys, "a.txt")
+	c.Assert(err, check.ErrorMatches, "readdir a.txt: not a directory")
+}
+
+func (s *S) TestStat(c *check.C) {
+	info, err := fs.Stat(s.fsys, "assets/style.css")
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Name(), check.Equals, "style.css")
+	c.Assert(info.Size(), check.Equals, int64(22))
+	c.Assert(info.Mode(), check.Equals, fs.FileMode(0444))
+	c.Assert(info.ModTime().IsZero(), check.Equals, false)
+	c.Assert(info.Sys().(s3.Key).Key, check.Equals, "site/assets/style.css")
+
+	info, err = fs.Stat(s.fsys, "a/c")
+	c.Assert(err, check.IsNil)
+	c.Assert(info.IsDir(), check.Equals, true)
+	c.Assert(info.Name(), check.Equals, "c")
+
+	_, err = fs.Stat(s.fsys, "missing")
+	c.Assert(errors.Is(err, fs.ErrNotExist), check.Equals, true)
+	c.Assert(err, check.ErrorMatches, "stat missing: file does not exist")
+
+	_, err = fs.Stat(s.fsys, "../outside.txt")
+	c.Assert(errors.Is(err, fs.ErrInvalid), check.Equals, true)
+}
+
+func (s *S) TestReadAtAndSeek(c *check.C) {
+	f, err := s.fsys.Open("assets/large.bin")
+	c.Assert(err, check.IsNil)
+	defer f.Close()
+
+	buf := make([]byte, 4)
+	n, err := f.(io.ReaderAt).ReadAt(buf, 10)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(buf[:n]), check.Equals, "abcd")
+	n, err = f.(io.ReaderAt).ReadAt(buf, 34)
+	c.Assert(err, check.Equals, io.EOF)
+	c.Assert(string(buf[:n]), check.Equals, "yz")
+
+	n, err = f.Read(buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(buf[:n]), check.Equals, "0123")
+	pos, err := f.(io.Seeker).Seek(-3, io.SeekEnd)
+	c.Assert(err, check.IsNil)
+	c.Assert(pos, check.Equals, int64(33))
+	rest, err := ioutil.ReadAll(f)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(rest), check.Equals, "xyz")
+}
+
+func (s *S) TestReadChangedObject(c *check.C) {
+	err := s.bucket.Put("tmp/changing", []byte("before"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+	f, err := s3fs.New(s.bucket, "tmp/").Open("changing")
+	c.Assert(err, check.IsNil)
+	defer f.Close()
+
+	err = s.bucket.Put("tmp/changing", []byte("after!"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+	_, err = ioutil.ReadAll(f)
+	c.Assert(err, check.ErrorMatches, "read changing: .*")
+	c.Assert(errors.As(err, new(*s3.Error)), check.Equals, true)
+}
+
+func (s *S) TestHTTPFileServer(c *check.C) {
+	srv := httptest.NewServer(http.FileServer(http.FS(s.fsys)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/assets/style.css")
+	c.Assert(err, check.IsNil)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.StatusCode, check.Equals, 200)
+	c.Assert(resp.Header.Get("Content-Type"), check.Equals, "text/css; charset=utf-8")
+	c.Assert(string(body), check.Equals, objects["site/assets/style.css"])
+
+	req, err := http.NewRequest("GET", srv.URL+"/assets/large.bin", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Range", "bytes=10-12")
+	resp, err = http.DefaultClient.Do(req)
+	c.Assert(err, check.IsNil)
+	body, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.StatusCode, check.Equals, http.StatusPartialContent)
+	c.Assert(string(body), check.Equals, "abc")
+
+	// Directories are served through their index.html.
+	resp, err = http.Get(srv.URL + "/")
+	c.Assert(err, check.IsNil)
+	body, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.Assert(err, check.IsNil)
+	c.Assert(string(body), check.Equals, "<h1>Hello</h1>")
+
+	resp, err = http.Get(srv.URL + "/missing")
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, 404)
+}
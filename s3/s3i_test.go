@@ -122,12 +122,7 @@ func killBucket(b *s3.Bucket) {
 		}
 		if ok && e.Code == "BucketNotEmpty" {
 			// Errors are ignored here. Just retry.
-			resp, err := b.List("", "", "", 1000)
-			if err == nil {
-				for _, key := range resp.Contents {
-					_ = b.Del(key.Key)
-				}
-			}
+			_ = b.DeletePrefix("")
 			multis, _, _ := b.ListMulti("", "")
 			for _, m := range multis {
 				_ = m.Abort()
@@ -406,6 +401,325 @@ func (s *ClientTests) TestBucketList(c *check.C) {
 	}
 }
 
+func (s *ClientTests) TestBucketListV2(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	objData := make(map[string][]byte)
+	for i, path := range objectNames {
+		data := []byte(strings.Repeat("a", i))
+		err := b.Put(path, data, "text/plain", s3.Private, s3.Options{})
+		c.Assert(err, check.IsNil)
+		defer b.Del(path)
+		objData[path] = data
+	}
+
+	for i, t := range listTests {
+		c.Logf("test %d", i)
+		resp, err := b.ListV2(t.Prefix, t.Delimiter, "", t.Marker, t.MaxKeys, false)
+		c.Assert(err, check.IsNil)
+		c.Check(resp.Name, check.Equals, b.Name)
+		c.Check(resp.Delimiter, check.Equals, t.Delimiter)
+		c.Check(resp.IsTruncated, check.Equals, t.IsTruncated)
+		c.Check(resp.KeyCount, check.Equals, len(t.Contents)+len(t.CommonPrefixes))
+		c.Check(resp.CommonPrefixes, check.DeepEquals, t.CommonPrefixes)
+		checkContents(c, resp.Contents, objData, t.Contents)
+	}
+
+	// Follow continuation tokens through the whole bucket.
+	var names []string
+	token := ""
+	for {
+		resp, err := b.ListV2("", "", token, "", 3, false)
+		c.Assert(err, check.IsNil)
+		for _, k := range resp.Contents {
+			names = append(names, k.Key)
+		}
+		if !resp.IsTruncated {
+			break
+		}
+		token = resp.NextContinuationToken
+	}
+	c.Check(names, check.DeepEquals, objectNames)
+}
+
+func (s *ClientTests) TestBucketWalk(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	for _, path := range objectNames {
+		err := b.Put(path, nil, "text/plain", s3.Private, s3.Options{})
+		c.Assert(err, check.IsNil)
+		defer b.Del(path)
+	}
+
+	s3.SetWalkMax(2)
+	defer s3.SetWalkMax(1000)
+
+	var names []string
+	err = b.Walk("", func(k s3.Key) error {
+		names = append(names, k.Key)
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, objectNames)
+
+	names = nil
+	err = b.WalkDelim("", "/", func(k s3.Key) error {
+		names = append(names, k.Key)
+		if k.Key == "photos/2006/January/" {
+			return s3.SkipPrefix
+		}
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{
+		"index.html",
+		"index2.html",
+		"photos/",
+		"photos/2006/",
+		"photos/2006/February/",
+		"photos/2006/February/sample2.jpg",
+		"photos/2006/February/sample3.jpg",
+		"photos/2006/February/sample4.jpg",
+		"photos/2006/January/",
+		"test/",
+		"test/bar",
+		"test/foo",
+	})
+
+	// Returned for a key, SkipPrefix skips the rest of its level.
+	names = nil
+	err = b.WalkDelim("photos/", "/", func(k s3.Key) error {
+		names = append(names, k.Key)
+		if k.Key == "photos/2006/February/sample2.jpg" {
+			return s3.SkipPrefix
+		}
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{
+		"photos/2006/",
+		"photos/2006/February/",
+		"photos/2006/February/sample2.jpg",
+		"photos/2006/January/",
+		"photos/2006/January/sample.jpg",
+	})
+
+	names = nil
+	err = b.Walk("", func(k s3.Key) error {
+		names = append(names, k.Key)
+		return s3.SkipPrefix
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"index.html"})
+
+	stop := fmt.Errorf("stop")
+	names = nil
+	err = b.Walk("photos/", func(k s3.Key) error {
+		names = append(names, k.Key)
+		return stop
+	})
+	c.Assert(err, check.Equals, stop)
+	c.Check(names, check.DeepEquals, []string{"photos/2006/February/sample2.jpg"})
+}
+
+func (s *ClientTests) TestVersioning(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	status, err := b.GetVersioning()
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, s3.VersioningStatus(""))
+
+	err = b.Put("name", []byte("v0"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+	err = b.PutVersioning(s3.VersioningEnabled)
+	c.Assert(err, check.IsNil)
+	defer b.DeletePrefix("")
+	status, err = b.GetVersioning()
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, s3.VersioningEnabled)
+
+	for _, data := range []string{"v1", "v2"} {
+		err = b.Put("name", []byte(data), "text/plain", s3.Private, s3.Options{})
+		c.Assert(err, check.IsNil)
+	}
+	resp, err := b.Versions("", "", "", "", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Versions, check.HasLen, 3)
+	c.Assert(resp.Versions[0].IsLatest, check.Equals, true)
+	c.Assert(resp.Versions[1].IsLatest, check.Equals, false)
+	c.Assert(resp.Versions[2].VersionId, check.Equals, "null")
+	v1 := resp.Versions[1].VersionId
+
+	head, err := b.Head("name", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(head.Header.Get("x-amz-version-id"), check.Equals, resp.Versions[0].VersionId)
+	data, err := b.GetVersion("name", v1)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "v1")
+	data, err = b.GetVersion("name", "null")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "v0")
+
+	_, err = b.PutCopy("copy", s3.Private, s3.CopyOptions{CopySourceVersionId: v1}, b.Name+"/name")
+	c.Assert(err, check.IsNil)
+	data, err = b.Get("copy")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "v1")
+
+	// Paging.
+	page, err := b.Versions("name", "", "", "", 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(page.IsTruncated, check.Equals, true)
+	c.Assert(page.Versions, check.HasLen, 2)
+	c.Assert(page.NextKeyMarker, check.Equals, "name")
+	c.Assert(page.NextVersionIdMarker, check.Equals, v1)
+	page, err = b.Versions("name", "", page.NextKeyMarker, page.NextVersionIdMarker, 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(page.IsTruncated, check.Equals, false)
+	c.Assert(page.Versions, check.HasLen, 1)
+	c.Assert(page.Versions[0].VersionId, check.Equals, "null")
+
+	// Deleting puts a delete marker in place of the object.
+	err = b.Del("name")
+	c.Assert(err, check.IsNil)
+	_, err = b.Get("name")
+	c.Assert(err, check.ErrorMatches, "The specified key does not exist.")
+	resp, err = b.Versions("name", "", "", "", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Versions, check.HasLen, 3)
+	c.Assert(resp.Versions[0].IsLatest, check.Equals, false)
+	c.Assert(resp.DeleteMarkers, check.HasLen, 1)
+	c.Assert(resp.DeleteMarkers[0].IsLatest, check.Equals, true)
+
+	// Deleting the delete marker restores the object.
+	err = b.DelVersion("name", resp.DeleteMarkers[0].VersionId)
+	c.Assert(err, check.IsNil)
+	data, err = b.Get("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "v2")
+
+	err = b.DeletePrefix("")
+	c.Assert(err, check.IsNil)
+	resp, err = b.Versions("", "", "", "", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Versions, check.HasLen, 0)
+	c.Assert(resp.DeleteMarkers, check.HasLen, 0)
+}
+
+func (s *ClientTests) TestBucketSubresources(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	_, err = b.GetLifecycleConfiguration()
+	c.Assert(err, check.NotNil)
+	c.Assert(err.(*s3.Error).Code, check.Equals, "NoSuchLifecycleConfiguration")
+	lifecycle := &s3.LifecycleConfiguration{}
+	rule := s3.NewLifecycleRule("expire", "logs/")
+	rule.SetExpirationDays(30)
+	lifecycle.AddRule(rule)
+	err = b.PutLifecycleConfiguration(lifecycle)
+	c.Assert(err, check.IsNil)
+	got, err := b.GetLifecycleConfiguration()
+	c.Assert(err, check.IsNil)
+	c.Assert(*got.Rules, check.HasLen, 1)
+	c.Assert((*got.Rules)[0].ID, check.Equals, "expire")
+	c.Assert((*got.Rules)[0].Expiration.Days, check.DeepEquals, rule.Expiration.Days)
+	err = b.DeleteLifecycleConfiguration()
+	c.Assert(err, check.IsNil)
+	_, err = b.GetLifecycleConfiguration()
+	c.Assert(err, check.NotNil)
+
+	_, err = b.GetBucketWebsite()
+	c.Assert(err, check.NotNil)
+	c.Assert(err.(*s3.Error).Code, check.Equals, "NoSuchWebsiteConfiguration")
+	err = b.PutBucketWebsite(s3.WebsiteConfiguration{
+		IndexDocument: &s3.IndexDocument{Suffix: "index.html"},
+	})
+	c.Assert(err, check.IsNil)
+	website, err := b.GetBucketWebsite()
+	c.Assert(err, check.IsNil)
+	c.Assert(website.IndexDocument, check.DeepEquals, &s3.IndexDocument{Suffix: "index.html"})
+	err = b.DelBucketWebsite()
+	c.Assert(err, check.IsNil)
+
+	notification, err := b.GetNotificationConfiguration()
+	c.Assert(err, check.IsNil)
+	c.Assert(notification.QueueConfigurations, check.HasLen, 0)
+
+	acl, err := b.GetACL("")
+	c.Assert(err, check.IsNil)
+	c.Assert(acl.Owner.ID, check.Not(check.Equals), "")
+	c.Assert(s3.GetCannedPolicyByAcl(*acl), check.Equals, s3.Private)
+
+	err = b.Put("name", []byte("content"), "text/plain", s3.PublicRead, s3.Options{})
+	c.Assert(err, check.IsNil)
+	defer b.Del("name")
+	acl, err = b.GetACL("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(acl.Grants.Grant, check.HasLen, 2)
+	c.Assert(acl.Grants.Grant[0].Permission, check.Equals, "FULL_CONTROL")
+	c.Assert(acl.Grants.Grant[1].Grantee[0].URI, check.Equals, s3.AllUsersUri)
+	c.Assert(s3.GetCannedPolicyByAcl(*acl), check.Equals, s3.PublicRead)
+}
+
+func (s *ClientTests) TestConditionalRequests(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	err = b.Put("name", []byte("content"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+	defer b.Del("name")
+
+	resp, err := b.Head("name", nil)
+	c.Assert(err, check.IsNil)
+	etag := resp.Header.Get("ETag")
+	lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	c.Assert(err, check.IsNil)
+
+	// If-None-Match takes precedence over If-Modified-Since.
+	_, err = b.GetResponseWithOptions("name", s3.Options{IfNoneMatch: etag})
+	c.Assert(s3.IsNotModified(err), check.Equals, true)
+	resp, err = b.GetResponseWithOptions("name", s3.Options{IfNoneMatch: `"other"`, IfModifiedSince: lastModified})
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+	_, err = b.HeadWithOptions("name", s3.Options{IfModifiedSince: lastModified})
+	c.Assert(s3.IsNotModified(err), check.Equals, true)
+
+	// If-Match takes precedence over If-Unmodified-Since.
+	_, err = b.GetResponseWithOptions("name", s3.Options{IfMatch: `"other"`})
+	c.Assert(s3.IsPreconditionFailed(err), check.Equals, true)
+	resp, err = b.GetResponseWithOptions("name", s3.Options{IfMatch: etag, IfUnmodifiedSince: lastModified.Add(-time.Hour)})
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+	_, err = b.HeadWithOptions("name", s3.Options{IfUnmodifiedSince: lastModified.Add(-time.Hour)})
+	c.Assert(s3.IsPreconditionFailed(err), check.Equals, true)
+
+	_, err = b.PutCopy("copy", s3.Private, s3.CopyOptions{CopySourceIfMatch: `"other"`}, b.Name+"/name")
+	c.Assert(s3.IsPreconditionFailed(err), check.Equals, true)
+	_, err = b.PutCopy("copy", s3.Private, s3.CopyOptions{CopySourceIfNoneMatch: `"other"`}, b.Name+"/name")
+	c.Assert(err, check.IsNil)
+	defer b.Del("copy")
+
+	err = b.Put("name", []byte("new"), "text/plain", s3.Private, s3.Options{IfNoneMatch: "*"})
+	c.Assert(s3.IsPreconditionFailed(err), check.Equals, true)
+	err = b.Put("name", []byte("new"), "text/plain", s3.Private, s3.Options{IfMatch: etag})
+	c.Assert(err, check.IsNil)
+	err = b.Put("name", []byte("newer"), "text/plain", s3.Private, s3.Options{IfMatch: etag})
+	c.Assert(s3.IsPreconditionFailed(err), check.Equals, true)
+
+	data, err := b.Get("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "new")
+}
+
 func etag(data []byte) string {
 	sum := md5.New()
 	sum.Write(data)
@@ -1,12 +1,15 @@
 package s3_test
 
 import (
+	"fmt"
 	"github.com/AdRoll/goamz/aws"
 	"github.com/AdRoll/goamz/s3"
 	"github.com/AdRoll/goamz/s3/s3test"
 	"github.com/AdRoll/goamz/testutil"
 	"gopkg.in/check.v1"
 	"io/ioutil"
+	"net/http"
+	"strings"
 	"time"
 )
 
@@ -16,6 +19,9 @@ type LocalServer struct {
 	srv    *s3test.Server
 	config *s3test.Config
 	clock  fakeClock
+	// persistent makes the server keep its data in a temporary
+	// directory rather than in memory.
+	persistent bool
 }
 
 func (s *LocalServer) SetUp(c *check.C) {
@@ -25,6 +31,9 @@ func (s *LocalServer) SetUp(c *check.C) {
 	if s.config.Clock == nil {
 		s.config.Clock = &s.clock
 	}
+	if s.persistent && s.config.Root == "" {
+		s.config.Root = c.MkDir()
+	}
 	srv, err := s3test.NewServer(s.config)
 	c.Assert(err, check.IsNil)
 	c.Assert(srv, check.NotNil)
@@ -57,6 +66,11 @@ var (
 			},
 		},
 	})
+	_ = check.Suite(&LocalServerSuite{
+		srv: LocalServer{
+			persistent: true,
+		},
+	})
 )
 
 func (s *LocalServerSuite) SetUpSuite(c *check.C) {
@@ -84,6 +98,26 @@ func (s *LocalServerSuite) TestBucketList(c *check.C) {
 	s.clientTests.TestBucketList(c)
 }
 
+func (s *LocalServerSuite) TestBucketListV2(c *check.C) {
+	s.clientTests.TestBucketListV2(c)
+}
+
+func (s *LocalServerSuite) TestBucketWalk(c *check.C) {
+	s.clientTests.TestBucketWalk(c)
+}
+
+func (s *LocalServerSuite) TestVersioning(c *check.C) {
+	s.clientTests.TestVersioning(c)
+}
+
+func (s *LocalServerSuite) TestBucketSubresources(c *check.C) {
+	s.clientTests.TestBucketSubresources(c)
+}
+
+func (s *LocalServerSuite) TestConditionalRequests(c *check.C) {
+	s.clientTests.TestConditionalRequests(c)
+}
+
 func (s *LocalServerSuite) TestDoublePutBucket(c *check.C) {
 	s.clientTests.TestDoublePutBucket(c)
 }
@@ -122,6 +156,203 @@ func (s *LocalServerSuite) TestGetHeaders(c *check.C) {
 	c.Check(resp.Header.Get("Last-Modified"), check.Equals, "Mon, 2 Jan 2006 15:04:05 GMT")
 }
 
+func (s *LocalServerSuite) TestDelMultiBatches(c *check.C) {
+	b := testBucket(s.clientTests.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	var objects []s3.Object
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("key%d", i)
+		err := b.Put(key, []byte("content"), "text/plain", s3.Private, s3.Options{})
+		c.Assert(err, check.IsNil)
+		objects = append(objects, s3.Object{Key: key})
+	}
+	objects = append(objects, s3.Object{Key: "missing"})
+
+	s3.SetDelMultiMax(2)
+	defer s3.SetDelMultiMax(1000)
+
+	result, err := b.DelMultiFull(s3.Delete{Objects: objects})
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Deleted, check.HasLen, 5)
+	c.Assert(result.Errors, check.HasLen, 1)
+	c.Assert(result.Errors[0].Key, check.Equals, "missing")
+
+	resp, err := b.List("", "", "", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Contents, check.HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestRestart(c *check.C) {
+	if !s.srv.persistent {
+		c.Skip("server keeps its data in memory")
+	}
+	b := testBucket(s.clientTests.s3)
+	err := b.PutBucket(s3.PublicRead)
+	c.Assert(err, check.IsNil)
+	err = b.Put("plain", []byte("plain content"), "text/plain", s3.Private, s3.Options{
+		Meta: map[string][]string{"color": {"blue"}},
+	})
+	c.Assert(err, check.IsNil)
+	err = b.PutVersioning(s3.VersioningEnabled)
+	c.Assert(err, check.IsNil)
+	err = b.Put("versioned", []byte("v1"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+	err = b.Put("versioned", []byte("v2"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+	err = b.Put("deleted", []byte("gone"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+	err = b.Del("deleted")
+	c.Assert(err, check.IsNil)
+	err = b.PutBucketWebsite(s3.WebsiteConfiguration{
+		IndexDocument: &s3.IndexDocument{Suffix: "index.html"},
+	})
+	c.Assert(err, check.IsNil)
+	multi, err := b.InitMulti("multi", "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+	_, err = multi.PutPart(1, strings.NewReader("part"))
+	c.Assert(err, check.IsNil)
+
+	before, err := b.Versions("", "", "", "", 0)
+	c.Assert(err, check.IsNil)
+
+	// Start a new server on the same directory.
+	s.srv.srv.Quit()
+	s.srv.SetUp(c)
+	s.clientTests.s3 = s3.New(s.srv.auth, s.srv.region)
+	b = testBucket(s.clientTests.s3)
+
+	data, header, err := b.GetWithHeaders("plain")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "plain content")
+	c.Assert(header.Get("Content-Type"), check.Equals, "text/plain")
+	c.Assert(header.Get("X-Amz-Meta-Color"), check.Equals, "blue")
+
+	data, err = b.Get("versioned")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "v2")
+	_, err = b.Get("deleted")
+	c.Assert(err, check.NotNil)
+	c.Assert(err.(*s3.Error).Code, check.Equals, "NoSuchKey")
+
+	after, err := b.Versions("", "", "", "", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(after.Versions, check.DeepEquals, before.Versions)
+	c.Assert(after.DeleteMarkers, check.DeepEquals, before.DeleteMarkers)
+
+	status, err := b.GetVersioning()
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, s3.VersioningEnabled)
+	website, err := b.GetBucketWebsite()
+	c.Assert(err, check.IsNil)
+	c.Assert(website.IndexDocument, check.DeepEquals, &s3.IndexDocument{Suffix: "index.html"})
+	acl, err := b.GetACL("")
+	c.Assert(err, check.IsNil)
+	c.Assert(s3.GetCannedPolicyByAcl(*acl), check.Equals, s3.PublicRead)
+
+	// Multipart uploads in progress do not survive a restart.
+	multi.Bucket = b
+	err = multi.Abort()
+	c.Assert(err, check.NotNil)
+	c.Assert(err.(*s3.Error).Code, check.Equals, "NoSuchUpload")
+}
+
+func (s *LocalServerSuite) TestCopyLarge(c *check.C) {
+	src := testBucket(s.clientTests.s3)
+	err := src.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+	dst := s.clientTests.s3.Bucket(src.Name + "-archive")
+	err = dst.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+	defer killBucket(dst)
+
+	content := "0123456789abcdefghijklmnopqrstuvwxyz"
+	err = src.Put("large", []byte(content), "text/plain", s3.Private, s3.Options{
+		Meta:         map[string][]string{"origin": {"test"}},
+		CacheControl: "no-cache",
+	})
+	c.Assert(err, check.IsNil)
+
+	s3.SetCopyPartSize(10)
+	defer s3.SetCopyPartSize(512 << 20)
+
+	err = dst.CopyLarge("copy", s3.Private, s3.CopyOptions{}, src.Name+"/large")
+	c.Assert(err, check.IsNil)
+	data, header, err := dst.GetWithHeaders("copy")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, content)
+	c.Assert(header.Get("Content-Type"), check.Equals, "text/plain")
+	c.Assert(header.Get("Cache-Control"), check.Equals, "no-cache")
+	c.Assert(header.Get("X-Amz-Meta-Origin"), check.Equals, "test")
+
+	err = dst.CopyLarge("replaced", s3.Private, s3.CopyOptions{
+		MetadataDirective: "REPLACE",
+		ContentType:       "application/octet-stream",
+	}, src.Name+"/large")
+	c.Assert(err, check.IsNil)
+	data, header, err = dst.GetWithHeaders("replaced")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, content)
+	c.Assert(header.Get("Content-Type"), check.Equals, "application/octet-stream")
+	c.Assert(header.Get("X-Amz-Meta-Origin"), check.Equals, "")
+
+	err = dst.CopyLarge("failed", s3.Private, s3.CopyOptions{CopySourceIfMatch: `"0"`}, src.Name+"/large")
+	c.Assert(s3.IsPreconditionFailed(err), check.Equals, true)
+	exists, err := dst.Exists("failed")
+	c.Assert(err, check.IsNil)
+	c.Assert(exists, check.Equals, false)
+
+	err = dst.CopyLarge("missing", s3.Private, s3.CopyOptions{}, src.Name+"/missing")
+	c.Assert(err, check.NotNil)
+}
+
+func (s *LocalServerSuite) TestChecksums(c *check.C) {
+	b := testBucket(s.clientTests.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	verify := s3.Options{VerifyChecksum: true, ChecksumAlgorithm: s3.ChecksumCRC32C}
+	err = b.Put("single", []byte("content"), "text/plain", s3.Private, verify)
+	c.Assert(err, check.IsNil)
+	rc, err := b.GetReaderWithOptions("single", verify)
+	c.Assert(err, check.IsNil)
+	data, err := ioutil.ReadAll(rc)
+	rc.Close()
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "content")
+
+	multi, err := b.InitMulti("multi", "text/plain", s3.Private, verify)
+	c.Assert(err, check.IsNil)
+	part1, err := multi.PutPart(1, strings.NewReader("part1"))
+	c.Assert(err, check.IsNil)
+	part2, err := multi.PutPart(2, strings.NewReader("part2"))
+	c.Assert(err, check.IsNil)
+	err = multi.Complete([]s3.Part{part1, part2})
+	c.Assert(err, check.IsNil)
+	resp, err := b.Head("multi", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Header.Get("ETag"), check.Matches, `"[0-9a-f]{32}-2"`)
+	rc, err = b.GetReaderWithOptions("multi", verify)
+	c.Assert(err, check.IsNil)
+	data, err = ioutil.ReadAll(rc)
+	rc.Close()
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "part1part2")
+
+	// The server checks the additional checksums it is sent.
+	req, err := http.NewRequest("PUT", b.URL("corrupt"), strings.NewReader("content"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("X-Amz-Checksum-Sha256", "bm90IGEgY2hlY2tzdW0=")
+	hresp, err := http.DefaultClient.Do(req)
+	c.Assert(err, check.IsNil)
+	hresp.Body.Close()
+	c.Assert(hresp.StatusCode, check.Equals, http.StatusBadRequest)
+	exists, err := b.Exists("corrupt")
+	c.Assert(err, check.IsNil)
+	c.Assert(exists, check.Equals, false)
+}
+
 type fakeClock struct {
 	// Time to return for Now(). If nil, return current time.
 	now *time.Time
@@ -3,13 +3,15 @@ package s3test
 import (
 	"bytes"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/xml"
 	"fmt"
 	"github.com/AdRoll/goamz/s3"
+	"hash"
+	"hash/crc32"
 	"io"
-	"io/ioutil"
 	"log"
 	"math/rand"
 	"net"
@@ -73,6 +75,12 @@ type Config struct {
 	// Clock used to set mtime when updating an object. If nil,
 	// use the real clock.
 	Clock Clock
+
+	// Root is the directory in which to keep buckets and objects. By
+	// default, they are kept in memory and lost when the server quits.
+	// A server started with the Root of a previous one serves the same
+	// buckets and objects; multipart uploads in progress are lost.
+	Root string
 }
 
 func (c *Config) send409Conflict() bool {
@@ -83,7 +91,8 @@ func (c *Config) send409Conflict() bool {
 }
 
 // Server is a fake S3 server for testing purposes.
-// All of the data for the server is kept in memory.
+// Unless Config.Root is set, all of the data for the server is kept in
+// memory.
 type Server struct {
 	url      string
 	reqId    int
@@ -91,29 +100,205 @@ type Server struct {
 	mu       sync.Mutex
 	buckets  map[string]*bucket
 	config   *Config
+	store    *store
 	closed   bool
 }
 
 type bucket struct {
 	name             string
 	acl              s3.ACL
+	aclDoc           []byte // set when the ACL is not a canned one.
 	ctime            time.Time
-	objects          map[string]*object
+	objects          map[string]*object // current version of each key.
 	multipartUploads map[string][]*multipartUploadPart
 	multipartMeta    map[string]http.Header
+	// versioning is "", "Enabled" or "Suspended". Once it has been
+	// configured, versions holds all the versions of each key, oldest
+	// first, including delete markers.
+	versioning   string
+	versions     map[string][]*object
+	subresources map[string][]byte // lifecycle, website, policy...
+	store        *store
+	seq          int64 // number of the last object written.
+}
+
+func newBucket(name string, st *store) *bucket {
+	return &bucket{
+		name:             name,
+		objects:          make(map[string]*object),
+		multipartUploads: make(map[string][]*multipartUploadPart),
+		multipartMeta:    make(map[string]http.Header),
+		versions:         make(map[string][]*object),
+		subresources:     make(map[string][]byte),
+		store:            st,
+	}
 }
 
 type object struct {
-	name     string
-	mtime    time.Time
-	meta     http.Header // metadata to return with requests.
-	checksum []byte      // also held as Content-MD5 in meta.
-	data     []byte
+	id           string // name of the files of obj in a disk store.
+	seq          int64  // order in which objects were written.
+	name         string
+	versionId    string // "" if versioning was never configured.
+	deleteMarker bool
+	mtime        time.Time
+	meta         http.Header // metadata to return with requests.
+	checksum     []byte      // also held as Content-MD5 in meta.
+	parts        int         // number of parts, if uploaded in parts.
+	body         objectBody
+	acl          s3.ACL
+	aclDoc       []byte // set when the ACL is not a canned one.
+}
+
+// etag returns the quoted entity tag of obj.
+func (obj *object) etag() string {
+	if obj.parts > 0 {
+		// The checksum of a multipart object is the MD5 sum of the MD5
+		// sums of its parts.
+		return fmt.Sprintf(`"%x-%d"`, obj.checksum, obj.parts)
+	}
+	return fmt.Sprintf(`"%x"`, obj.checksum)
+}
+
+// save writes the configuration of b to its store.
+func (b *bucket) save() {
+	if err := b.store.saveBucket(b); err != nil {
+		fatalf(500, "InternalError", "cannot save bucket: %v", err)
+	}
+}
+
+// saveObject writes the metadata of obj to the store of b.
+func (b *bucket) saveObject(obj *object) {
+	if err := b.store.saveObject(b, obj); err != nil {
+		fatalf(500, "InternalError", "cannot save object: %v", err)
+	}
+}
+
+// removeObject discards obj, which is no longer in b, from its store.
+func (b *bucket) removeObject(obj *object) {
+	if err := b.store.removeObject(b, obj); err != nil {
+		fatalf(500, "InternalError", "cannot remove object: %v", err)
+	}
+}
+
+// setVersioning changes the versioning state of b. Keys written before
+// versioning was configured become their "null" version.
+func (b *bucket) setVersioning(status string) {
+	if b.versioning == "" {
+		for name, obj := range b.objects {
+			obj.versionId = "null"
+			b.versions[name] = []*object{obj}
+			b.saveObject(obj)
+		}
+	}
+	b.versioning = status
+}
+
+// putObject makes obj, which may be a delete marker, the current version
+// of its key. Unless versioning is enabled, it replaces the "null" version.
+func (b *bucket) putObject(obj *object) {
+	var replaced *object
+	switch b.versioning {
+	case "":
+		obj.versionId = ""
+		replaced = b.objects[obj.name]
+	case "Enabled":
+		obj.versionId = newId()
+		b.versions[obj.name] = append(b.versions[obj.name], obj)
+	default:
+		replaced = b.findVersion(obj.name, "null")
+		obj.versionId = "null"
+		b.versions[obj.name] = append(removeVersion(b.versions[obj.name], "null"), obj)
+	}
+	if obj.deleteMarker {
+		delete(b.objects, obj.name)
+	} else {
+		b.objects[obj.name] = obj
+	}
+	b.seq++
+	obj.seq = b.seq
+	b.saveObject(obj)
+	if replaced != nil && replaced != obj {
+		b.removeObject(replaced)
+	}
+}
+
+// deleteObject deletes the current version of the key name. In a versioned
+// bucket, it returns the delete marker put in its place.
+func (b *bucket) deleteObject(name string, now time.Time) *object {
+	if b.versioning == "" {
+		if obj := b.objects[name]; obj != nil {
+			delete(b.objects, name)
+			b.removeObject(obj)
+		}
+		return nil
+	}
+	marker := &object{
+		name:         name,
+		deleteMarker: true,
+		mtime:        now,
+		meta:         make(http.Header),
+	}
+	b.putObject(marker)
+	return marker
+}
+
+// findVersion returns the version of the key name with the given id, or
+// nil.
+func (b *bucket) findVersion(name, versionId string) *object {
+	if b.versioning == "" {
+		if obj := b.objects[name]; obj != nil && versionId == "null" {
+			return obj
+		}
+		return nil
+	}
+	for _, obj := range b.versions[name] {
+		if obj.versionId == versionId {
+			return obj
+		}
+	}
+	return nil
+}
+
+// deleteVersion permanently deletes the version of the key name with the
+// given id, and returns it. The previous version, if any, becomes the
+// current one.
+func (b *bucket) deleteVersion(name, versionId string) *object {
+	obj := b.findVersion(name, versionId)
+	if obj == nil {
+		return nil
+	}
+	b.removeObject(obj)
+	if b.versioning == "" {
+		delete(b.objects, name)
+		return obj
+	}
+	versions := removeVersion(b.versions[name], versionId)
+	if len(versions) == 0 {
+		delete(b.versions, name)
+		delete(b.objects, name)
+		return obj
+	}
+	b.versions[name] = versions
+	if latest := versions[len(versions)-1]; latest.deleteMarker {
+		delete(b.objects, name)
+	} else {
+		b.objects[name] = latest
+	}
+	return obj
+}
+
+func removeVersion(versions []*object, versionId string) []*object {
+	for i, obj := range versions {
+		if obj.versionId == versionId {
+			return append(versions[:i:i], versions[i+1:]...)
+		}
+	}
+	return versions
 }
 
 type multipartUploadPart struct {
 	index        uint
-	data         []byte
+	body         objectBody
 	etag         string
 	lastModified time.Time
 }
@@ -167,6 +352,13 @@ func NewServer(config *Config) (*Server, error) {
 		buckets:  make(map[string]*bucket),
 		config:   config,
 	}
+	if config.Root != "" {
+		srv.store = &store{root: config.Root}
+		if srv.buckets, err = srv.store.load(); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("cannot load buckets: %v", err)
+		}
+	}
 	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		srv.serveHTTP(w, req)
 	}))
@@ -230,6 +422,8 @@ func (srv *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
 				err.BucketName = r.bucket.name
 			case bucketResource:
 				err.BucketName = r.name
+			case bucketSubresource:
+				err.BucketName = r.bucket.name
 			}
 			err.RequestId = a.reqId
 			// TODO HostId
@@ -274,21 +468,11 @@ func xmlMarshal(w io.Writer, x interface{}) {
 // In a fully implemented test server, each of these would have
 // its own resource type.
 var unimplementedBucketResourceNames = map[string]bool{
-	"acl":            true,
-	"lifecycle":      true,
-	"policy":         true,
-	"location":       true,
-	"logging":        true,
-	"notification":   true,
-	"versions":       true,
-	"requestPayment": true,
-	"versioning":     true,
-	"website":        true,
-	"uploads":        true,
+	"location": true,
+	"uploads":  true,
 }
 
 var unimplementedObjectResourceNames = map[string]bool{
-	"acl":     true,
 	"torrent": true,
 }
 
@@ -315,6 +499,12 @@ func (srv *Server) resourceForURL(u *url.URL) (r resource) {
 			if unimplementedBucketResourceNames[name] {
 				return nullResource{}
 			}
+			if bucketSubresources[name] != nil {
+				if b.bucket == nil {
+					fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
+				}
+				return bucketSubresource{name: name, bucket: b.bucket}
+			}
 		}
 		return b
 
@@ -332,7 +522,9 @@ func (srv *Server) resourceForURL(u *url.URL) (r resource) {
 			return nullResource{}
 		}
 	}
-	if obj := objr.bucket.objects[objr.name]; obj != nil {
+	if objr.version != "" {
+		objr.object = objr.bucket.findVersion(objr.name, objr.version)
+	} else if obj := objr.bucket.objects[objr.name]; obj != nil {
 		objr.object = obj
 	}
 	return objr
@@ -365,8 +557,20 @@ func (r bucketResource) get(a *action) interface{} {
 	if r.bucket == nil {
 		fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
 	}
+	if _, ok := a.req.Form["versions"]; ok {
+		return r.listVersions(a)
+	}
 	delimiter := a.req.Form.Get("delimiter")
 	marker := a.req.Form.Get("marker")
+	listV2 := a.req.Form.Get("list-type") == "2"
+	if listV2 {
+		// The continuation token is the name of the last key or common
+		// prefix of the previous page, so it works just like a marker.
+		marker = a.req.Form.Get("start-after")
+		if token := a.req.Form.Get("continuation-token"); token != "" {
+			marker = token
+		}
+	}
 	maxKeys := -1
 	if s := a.req.Form.Get("max-keys"); s != "" {
 		i, err := strconv.Atoi(s)
@@ -400,23 +604,10 @@ func (r bucketResource) get(a *action) interface{} {
 		Prefix string
 	}
 
-	type serverListResponse struct {
-		s3.ListResp
-		CommonPrefixes []commonPrefix
-	}
-
-	resp := &serverListResponse{
-		ListResp: s3.ListResp{
-			Name:      r.bucket.name,
-			Prefix:    prefix,
-			Delimiter: delimiter,
-			Marker:    marker,
-			MaxKeys:   maxKeys,
-		},
-	}
-
+	var contents []s3.Key
 	var prefixes []commonPrefix
-	var lastName string
+	var isTruncated bool
+	var lastName, nextMarker string
 	for _, obj := range objs {
 		if !strings.HasPrefix(obj.name, prefix) {
 			continue
@@ -435,21 +626,62 @@ func (r bucketResource) get(a *action) interface{} {
 		if name <= marker {
 			continue
 		}
-		if len(resp.Contents)+len(prefixes) >= maxKeys {
-			resp.IsTruncated = true
-			resp.NextMarker = lastName
+		if len(contents)+len(prefixes) >= maxKeys {
+			isTruncated = true
+			nextMarker = lastName
 			break
 		}
 		if isPrefix {
 			prefixes = append(prefixes, commonPrefix{Prefix: name})
 		} else {
 			// Contents contains only keys not found in CommonPrefixes
-			resp.Contents = append(resp.Contents, obj.s3Key())
+			contents = append(contents, obj.s3Key())
 		}
 		lastName = name
 	}
-	resp.CommonPrefixes = prefixes
-	return resp
+
+	if listV2 {
+		type serverListV2Response struct {
+			XMLName xml.Name `xml:"ListBucketResult"`
+			s3.ListV2Resp
+			CommonPrefixes []commonPrefix
+		}
+
+		return &serverListV2Response{
+			ListV2Resp: s3.ListV2Resp{
+				Name:                  r.bucket.name,
+				Prefix:                prefix,
+				Delimiter:             delimiter,
+				StartAfter:            a.req.Form.Get("start-after"),
+				ContinuationToken:     a.req.Form.Get("continuation-token"),
+				MaxKeys:               maxKeys,
+				KeyCount:              len(contents) + len(prefixes),
+				IsTruncated:           isTruncated,
+				Contents:              contents,
+				NextContinuationToken: nextMarker,
+			},
+			CommonPrefixes: prefixes,
+		}
+	}
+
+	type serverListResponse struct {
+		s3.ListResp
+		CommonPrefixes []commonPrefix
+	}
+
+	return &serverListResponse{
+		ListResp: s3.ListResp{
+			Name:        r.bucket.name,
+			Prefix:      prefix,
+			Delimiter:   delimiter,
+			Marker:      marker,
+			MaxKeys:     maxKeys,
+			IsTruncated: isTruncated,
+			Contents:    contents,
+			NextMarker:  nextMarker,
+		},
+		CommonPrefixes: prefixes,
+	}
 }
 
 // orderedObjects holds a slice of objects that can be sorted
@@ -470,8 +702,8 @@ func (obj *object) s3Key() s3.Key {
 	return s3.Key{
 		Key:          obj.name,
 		LastModified: obj.mtime.UTC().Format(timeFormat),
-		Size:         int64(len(obj.data)),
-		ETag:         fmt.Sprintf(`"%x"`, obj.checksum),
+		Size:         obj.body.size,
+		ETag:         obj.etag(),
 		// TODO StorageClass
 		// TODO Owner
 	}
@@ -483,9 +715,12 @@ func (r bucketResource) delete(a *action) interface{} {
 	if b == nil {
 		fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
 	}
-	if len(b.objects) > 0 {
+	if len(b.objects) > 0 || len(b.versions) > 0 {
 		fatalf(400, "BucketNotEmpty", "The bucket you tried to delete is not empty")
 	}
+	if err := a.srv.store.removeBucket(b); err != nil {
+		fatalf(500, "InternalError", "cannot remove bucket: %v", err)
+	}
 	delete(a.srv.buckets, b.name)
 	return nil
 }
@@ -502,13 +737,8 @@ func (r bucketResource) put(a *action) interface{} {
 			fatalf(400, "InvalidRequets", "The unspecified location constraint is incompatible for the region specific endpoint this request was sent to.")
 		}
 		// TODO validate acl
-		r.bucket = &bucket{
-			name: r.name,
-			// TODO default acl
-			objects:          make(map[string]*object),
-			multipartUploads: make(map[string][]*multipartUploadPart),
-			multipartMeta:    make(map[string]http.Header),
-		}
+		r.bucket = newBucket(r.name, a.srv.store)
+		r.bucket.ctime = a.srv.config.Clock.Now()
 		a.srv.buckets[r.name] = r.bucket
 		created = true
 	}
@@ -516,6 +746,7 @@ func (r bucketResource) put(a *action) interface{} {
 		fatalf(409, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.")
 	}
 	r.bucket.acl = s3.ACL(a.req.Header.Get("x-amz-acl"))
+	r.bucket.save()
 	return nil
 }
 
@@ -540,15 +771,19 @@ func (b bucketResource) multiDel(a *action) interface{} {
 	}
 
 	type multiDelDelete struct {
-		XMLName struct{} `xml:"Deleted"`
-		Key     string
+		XMLName               struct{} `xml:"Deleted"`
+		Key                   string
+		VersionId             string `xml:",omitempty"`
+		DeleteMarker          bool   `xml:",omitempty"`
+		DeleteMarkerVersionId string `xml:",omitempty"`
 	}
 
 	type multiDelError struct {
-		XMLName struct{} `xml:"Error"`
-		Key     string
-		Code    string
-		Message string
+		XMLName   struct{} `xml:"Error"`
+		Key       string
+		VersionId string `xml:",omitempty"`
+		Code      string
+		Message   string
 	}
 
 	type multiDelResult struct {
@@ -569,12 +804,28 @@ func (b bucketResource) multiDel(a *action) interface{} {
 	}
 
 	for _, o := range req.Object {
-		if _, exists := b.bucket.objects[o.Key]; exists {
-			delete(b.bucket.objects, o.Key)
-
-			res.Deleted = append(res.Deleted, &multiDelDelete{
-				Key: o.Key,
-			})
+		if o.VersionId != "" {
+			if obj := b.bucket.deleteVersion(o.Key, o.VersionId); obj != nil {
+				res.Deleted = append(res.Deleted, &multiDelDelete{
+					Key:          o.Key,
+					VersionId:    o.VersionId,
+					DeleteMarker: obj.deleteMarker,
+				})
+			} else {
+				res.Error = append(res.Error, &multiDelError{
+					Key:       o.Key,
+					VersionId: o.VersionId,
+					Code:      "NoSuchVersion",
+					Message:   "The specified version does not exist.",
+				})
+			}
+		} else if _, exists := b.bucket.objects[o.Key]; exists {
+			deleted := &multiDelDelete{Key: o.Key}
+			if marker := b.bucket.deleteObject(o.Key, a.srv.config.Clock.Now()); marker != nil {
+				deleted.DeleteMarker = true
+				deleted.DeleteMarkerVersionId = marker.versionId
+			}
+			res.Deleted = append(res.Deleted, deleted)
 		} else {
 			res.Error = append(res.Error, &multiDelError{
 				Key:     o.Key,
@@ -583,6 +834,9 @@ func (b bucketResource) multiDel(a *action) interface{} {
 			})
 		}
 	}
+	if req.Quiet {
+		res.Deleted = nil
+	}
 
 	return res
 }
@@ -644,10 +898,35 @@ type objectResource struct {
 // http://docs.amazonwebservices.com/AmazonS3/latest/API/RESTObjectGET.html
 func (objr objectResource) get(a *action) interface{} {
 	obj := objr.object
+	h := a.w.Header()
 	if obj == nil {
+		if objr.version != "" {
+			fatalf(404, "NoSuchVersion", "The specified version does not exist.")
+		}
+		if versions := objr.bucket.versions[objr.name]; len(versions) > 0 {
+			h.Set("x-amz-delete-marker", "true")
+		}
 		fatalf(404, "NoSuchKey", "The specified key does not exist.")
 	}
-	h := a.w.Header()
+	if obj.deleteMarker {
+		h.Set("x-amz-delete-marker", "true")
+		fatalf(405, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	}
+	if _, ok := a.req.URL.Query()["acl"]; ok {
+		return objectACL(a, obj)
+	}
+	if obj.versionId != "" {
+		h.Set("x-amz-version-id", obj.versionId)
+	}
+	switch checkPreconditions(a.req.Header, "", obj) {
+	case http.StatusNotModified:
+		h.Set("ETag", obj.etag())
+		h.Set("Last-Modified", obj.mtime.UTC().Format(lastModifiedTimeFormat))
+		a.w.WriteHeader(http.StatusNotModified)
+		return nil
+	case http.StatusPreconditionFailed:
+		fatalf(412, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+	}
 	// add metadata
 	for name, d := range obj.meta {
 		h[name] = d
@@ -663,42 +942,37 @@ func (objr objectResource) get(a *action) interface{} {
 		}
 	}
 
-	data := obj.data
+	size := obj.body.size
+	start, end := int64(0), size-1
 	status := http.StatusOK
 	if r := a.req.Header.Get("Range"); r != "" {
 		// s3 ignores invalid ranges
 		if matches := rangePattern.FindStringSubmatch(r); len(matches) == 3 {
 			var err error
-			start := 0
-			end := len(obj.data) - 1
+			rstart, rend := int64(0), size-1
 			if matches[1] != "" {
-				start, err = strconv.Atoi(matches[1])
+				rstart, err = strconv.ParseInt(matches[1], 10, 64)
 			}
 			if err == nil && matches[2] != "" {
-				end, err = strconv.Atoi(matches[2])
+				rend, err = strconv.ParseInt(matches[2], 10, 64)
 			}
-			if err == nil && start >= 0 && end >= start {
-				if start >= len(obj.data) {
+			if err == nil && rstart >= 0 && rend >= rstart {
+				if rstart >= size {
 					fatalf(416, "InvalidRequest", "The requested range is not satisfiable")
 				}
-				if end > len(obj.data)-1 {
-					end = len(obj.data) - 1
+				if rend > size-1 {
+					rend = size - 1
 				}
-				data = obj.data[start : end+1]
+				start, end = rstart, rend
 				status = http.StatusPartialContent
-				h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(obj.data)))
+				h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
 			}
 		}
 	}
-	// TODO Last-Modified-Since
-	// TODO If-Modified-Since
-	// TODO If-Unmodified-Since
-	// TODO If-Match
-	// TODO If-None-Match
 	// TODO Connection: close ??
 	// TODO x-amz-request-id
-	h.Set("Content-Length", fmt.Sprint(len(data)))
-	h.Set("ETag", "\""+hex.EncodeToString(obj.checksum)+"\"")
+	h.Set("Content-Length", fmt.Sprint(end-start+1))
+	h.Set("ETag", obj.etag())
 	h.Set("Last-Modified", obj.mtime.UTC().Format(lastModifiedTimeFormat))
 
 	if status != http.StatusOK {
@@ -708,8 +982,13 @@ func (objr objectResource) get(a *action) interface{} {
 	if a.req.Method == "HEAD" {
 		return nil
 	}
+	body, err := obj.body.open()
+	if err != nil {
+		fatalf(500, "InternalError", "cannot read object: %v", err)
+	}
+	defer body.Close()
 	// TODO avoid holding the lock when writing data.
-	_, err := a.w.Write(data)
+	_, err = io.Copy(a.w, io.NewSectionReader(body, start, end-start+1))
 	if err != nil {
 		// we can't do much except just log the fact.
 		log.Printf("error writing data: %v", err)
@@ -717,21 +996,98 @@ func (objr objectResource) get(a *action) interface{} {
 	return nil
 }
 
+// checkPreconditions evaluates the conditional headers of a request
+// against obj, in the order S3 does. prefix is "" for the headers of GET,
+// HEAD and PUT requests and "X-Amz-Copy-Source-" for the source of a copy.
+// It returns http.StatusNotModified or http.StatusPreconditionFailed if a
+// condition does not hold, or 0.
+func checkPreconditions(h http.Header, prefix string, obj *object) int {
+	etag := obj.etag()
+	// Last-Modified has a resolution of one second.
+	mtime := obj.mtime.Truncate(time.Second)
+	if m := h.Get(prefix + "If-Match"); m != "" {
+		if !etagMatches(m, etag) {
+			return http.StatusPreconditionFailed
+		}
+	} else if t, err := http.ParseTime(h.Get(prefix + "If-Unmodified-Since")); err == nil && mtime.After(t) {
+		return http.StatusPreconditionFailed
+	}
+	if m := h.Get(prefix + "If-None-Match"); m != "" {
+		if etagMatches(m, etag) {
+			return http.StatusNotModified
+		}
+	} else if t, err := http.ParseTime(h.Get(prefix + "If-Modified-Since")); err == nil && !mtime.After(t) {
+		return http.StatusNotModified
+	}
+	return 0
+}
+
+// verifyChecksums checks body against the additional checksums sent with
+// the request that uploaded it, and discards it if they do not match.
+func verifyChecksums(a *action, body objectBody) {
+	for name, newHash := range map[string]func() hash.Hash{
+		"X-Amz-Checksum-Crc32c": func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+		"X-Amz-Checksum-Sha256": sha256.New,
+	} {
+		expected := a.req.Header.Get(name)
+		if expected == "" {
+			continue
+		}
+		r, err := body.open()
+		if err != nil {
+			fatalf(500, "InternalError", "cannot read object: %v", err)
+		}
+		h := newHash()
+		_, err = io.Copy(h, io.NewSectionReader(r, 0, body.size))
+		r.Close()
+		if err != nil {
+			fatalf(500, "InternalError", "cannot read object: %v", err)
+		}
+		if base64.StdEncoding.EncodeToString(h.Sum(nil)) != expected {
+			a.srv.store.removeBody(body)
+			fatalf(400, "BadDigest", "The %s you specified did not match the calculated checksum.", name)
+		}
+	}
+}
+
+// etagMatches reports whether etag is in the comma separated list of
+// entity tags list, or list is "*".
+func etagMatches(list, etag string) bool {
+	for _, m := range strings.Split(list, ",") {
+		m = strings.TrimPrefix(strings.TrimSpace(m), "W/")
+		if m == "*" || strings.Trim(m, `"`) == strings.Trim(etag, `"`) {
+			return true
+		}
+	}
+	return false
+}
+
 var metaHeaders = map[string]bool{
-	"Content-MD5":         true,
-	"x-amz-acl":           true,
-	"Content-Type":        true,
-	"Content-Encoding":    true,
-	"Content-Disposition": true,
+	"Content-MD5":           true,
+	"x-amz-acl":             true,
+	"Content-Type":          true,
+	"Content-Encoding":      true,
+	"Content-Disposition":   true,
+	"Cache-Control":         true,
+	"X-Amz-Checksum-Crc32c": true,
+	"X-Amz-Checksum-Sha256": true,
 }
 
 // PUT on an object creates the object.
 func (objr objectResource) put(a *action) interface{} {
-	// TODO Cache-Control header
 	// TODO Expires header
 	// TODO x-amz-server-side-encryption
 	// TODO x-amz-storage-class
 
+	if _, ok := a.req.URL.Query()["acl"]; ok {
+		if objr.object == nil || objr.object.deleteMarker {
+			fatalf(404, "NoSuchKey", "The specified key does not exist.")
+		}
+		objr.object.acl, objr.object.aclDoc = putACL(a)
+		objr.bucket.saveObject(objr.object)
+		return nil
+	}
+
 	var res interface{}
 
 	uploadId := a.req.URL.Query().Get("uploadId")
@@ -766,19 +1122,25 @@ func (objr objectResource) put(a *action) interface{} {
 			fatalf(400, "InvalidDigest", "The Content-MD5 you specified was invalid")
 		}
 	}
-	sum := md5.New()
+	st := objr.bucket.store
+	dir := st.objectsDir(objr.bucket.name)
+	if uploadId != "" {
+		dir = st.uploadsDir()
+	}
 	// TODO avoid holding lock while reading data.
-	data, err := ioutil.ReadAll(io.TeeReader(a.req.Body, sum))
+	body, gotHash, err := st.newBody(dir, a.req.Body)
 	if err != nil {
 		fatalf(400, "TODO", "read error")
 	}
-	gotHash := sum.Sum(nil)
 	if expectHash != nil && bytes.Compare(gotHash, expectHash) != 0 {
+		st.removeBody(body)
 		fatalf(400, "BadDigest", "The Content-MD5 you specified did not match what we received")
 	}
-	if a.req.ContentLength >= 0 && int64(len(data)) != a.req.ContentLength {
+	if a.req.ContentLength >= 0 && body.size != a.req.ContentLength {
+		st.removeBody(body)
 		fatalf(400, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header")
 	}
+	verifyChecksums(a, body)
 
 	etag := fmt.Sprintf("\"%x\"", gotHash)
 
@@ -787,14 +1149,29 @@ func (objr objectResource) put(a *action) interface{} {
 	if uploadId == "" {
 		// For traditional uploads
 
+		// Conditional writes: If-None-Match: * fails if the object
+		// exists, If-Match if it does not have the given ETag.
+		if objr.object != nil {
+			if checkPreconditions(a.req.Header, "", objr.object) != 0 {
+				st.removeBody(body)
+				fatalf(412, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+			}
+		} else if a.req.Header.Get("If-Match") != "" {
+			st.removeBody(body)
+			fatalf(404, "NoSuchKey", "The specified key does not exist.")
+		}
+
 		// TODO is this correct, or should we erase all previous metadata?
 		obj := objr.object
-		if obj == nil {
+		if obj == nil || objr.bucket.versioning != "" {
+			// Versions are immutable.
 			obj = &object{
 				name: objr.name,
 				meta: make(http.Header),
 			}
 		}
+		obj.acl = s3.ACL(a.req.Header.Get("x-amz-acl"))
+		obj.aclDoc = nil
 
 		// PUT request has been successful - save data and metadata
 		for key, values := range a.req.Header {
@@ -805,34 +1182,18 @@ func (objr objectResource) put(a *action) interface{} {
 		}
 		obj.mtime = a.srv.config.Clock.Now()
 
+		oldBody := obj.body
 		if copySource := a.req.Header.Get("X-Amz-Copy-Source"); copySource != "" {
-			idx := strings.IndexByte(copySource, '/')
-
-			if idx == -1 {
-				fatalf(400, "InvalidRequest", "Wrongly formatted X-Amz-Copy-Source")
-			}
-
-			sourceBucketName := copySource[0:idx]
-			sourceKey := copySource[1+idx:]
-
-			sourceBucket := a.srv.buckets[sourceBucketName]
-
-			if sourceBucket == nil {
-				fatalf(404, "NoSuchBucket", "The specified source bucket does not exist")
-			}
-
-			sourceObject := sourceBucket.objects[sourceKey]
-
-			if sourceObject == nil {
-				fatalf(404, "NoSuchKey", "The specified source key does not exist")
-			}
+			// The body of a copy request is empty.
+			st.removeBody(body)
+			sourceObject := copySourceObject(a, copySource)
 
 			if obj != sourceObject {
-				obj.data = make([]byte, len(sourceObject.data))
-				copy(obj.data, sourceObject.data)
-
-				obj.checksum = make([]byte, len(sourceObject.checksum))
-				copy(obj.checksum, sourceObject.checksum)
+				obj.body, obj.checksum, err = st.copyBody(dir, sourceObject.body)
+				if err != nil {
+					fatalf(500, "InternalError", "cannot copy object: %v", err)
+				}
+				obj.parts = 0
 
 				obj.meta = make(http.Header, len(sourceObject.meta))
 
@@ -843,21 +1204,39 @@ func (objr objectResource) put(a *action) interface{} {
 			}
 
 			res = &s3.CopyObjectResult{
-				ETag:         etag,
+				ETag:         obj.etag(),
 				LastModified: obj.mtime.UTC().Format(time.RFC3339),
 			}
 		} else {
-			obj.data = data
+			obj.body = body
 			obj.checksum = gotHash
+			obj.parts = 0
+		}
+		objr.bucket.putObject(obj)
+		if obj.body.file != oldBody.file {
+			st.removeBody(oldBody)
+		}
+		if obj.versionId != "" {
+			a.w.Header().Set("x-amz-version-id", obj.versionId)
 		}
-		objr.bucket.objects[objr.name] = obj
 	} else {
 		// For multipart commit
 
+		if copySource := a.req.Header.Get("X-Amz-Copy-Source"); copySource != "" {
+			st.removeBody(body)
+			body, gotHash = copyPart(a, dir, copySourceObject(a, copySource))
+			etag = fmt.Sprintf("\"%x\"", gotHash)
+			a.w.Header().Set("ETag", etag)
+			res = &copyPartResult{
+				ETag:         etag,
+				LastModified: a.srv.config.Clock.Now().UTC().Format(time.RFC3339),
+			}
+		}
+
 		parts := objr.bucket.multipartUploads[uploadId]
 		part := &multipartUploadPart{
 			index:        partNumber,
-			data:         data,
+			body:         body,
 			etag:         etag,
 			lastModified: a.srv.config.Clock.Now(),
 		}
@@ -868,20 +1247,120 @@ func (objr objectResource) put(a *action) interface{} {
 	return res
 }
 
+// copySourceObject returns the object named by the X-Amz-Copy-Source
+// header of a copy request, after checking the preconditions on it.
+func copySourceObject(a *action, copySource string) *object {
+	sourceVersion := ""
+	if i := strings.Index(copySource, "?versionId="); i >= 0 {
+		sourceVersion, _ = url.QueryUnescape(copySource[i+len("?versionId="):])
+		copySource = copySource[:i]
+	}
+	if unescaped, err := url.PathUnescape(copySource); err == nil {
+		copySource = unescaped
+	}
+	copySource = strings.TrimPrefix(copySource, "/")
+
+	idx := strings.IndexByte(copySource, '/')
+
+	if idx == -1 {
+		fatalf(400, "InvalidRequest", "Wrongly formatted X-Amz-Copy-Source")
+	}
+
+	sourceBucketName := copySource[0:idx]
+	sourceKey := copySource[1+idx:]
+
+	sourceBucket := a.srv.buckets[sourceBucketName]
+
+	if sourceBucket == nil {
+		fatalf(404, "NoSuchBucket", "The specified source bucket does not exist")
+	}
+
+	sourceObject := sourceBucket.objects[sourceKey]
+	if sourceVersion != "" {
+		sourceObject = sourceBucket.findVersion(sourceKey, sourceVersion)
+		if sourceObject == nil {
+			fatalf(404, "NoSuchVersion", "The specified source version does not exist")
+		}
+		if sourceObject.deleteMarker {
+			fatalf(400, "InvalidRequest", "The source of a copy request may not specifically refer to a delete marker by version id.")
+		}
+	}
+
+	if sourceObject == nil {
+		fatalf(404, "NoSuchKey", "The specified source key does not exist")
+	}
+
+	if checkPreconditions(a.req.Header, "X-Amz-Copy-Source-", sourceObject) != 0 {
+		fatalf(412, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+	}
+	return sourceObject
+}
+
+type copyPartResult struct {
+	XMLName      struct{} `xml:"CopyPartResult"`
+	ETag         string
+	LastModified string
+}
+
+var copySourceRangePattern = regexp.MustCompile(`^bytes=(\d+)-(\d+)$`)
+
+// copyPart returns a copy of the part of source given by the
+// X-Amz-Copy-Source-Range header of an upload part copy request, stored in
+// dir, with its MD5 sum.
+func copyPart(a *action, dir string, source *object) (objectBody, []byte) {
+	start, end := int64(0), source.body.size-1
+	if r := a.req.Header.Get("X-Amz-Copy-Source-Range"); r != "" {
+		m := copySourceRangePattern.FindStringSubmatch(r)
+		if m == nil {
+			fatalf(400, "InvalidArgument", "The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy")
+		}
+		start, _ = strconv.ParseInt(m[1], 10, 64)
+		end, _ = strconv.ParseInt(m[2], 10, 64)
+		if end < start || end >= source.body.size {
+			fatalf(400, "InvalidArgument", "Range specified is not valid for source object of size: %d", source.body.size)
+		}
+	}
+	r, err := source.body.open()
+	if err != nil {
+		fatalf(500, "InternalError", "cannot read object: %v", err)
+	}
+	defer r.Close()
+	st := a.srv.store
+	body, sum, err := st.newBody(dir, io.NewSectionReader(r, start, end-start+1))
+	if err != nil {
+		fatalf(500, "InternalError", "cannot copy part: %v", err)
+	}
+	return body, sum
+}
+
 func (objr objectResource) delete(a *action) interface{} {
 	uploadId := a.req.URL.Query().Get("uploadId")
 
 	if uploadId == "" {
 		// Traditional object delete
-		delete(objr.bucket.objects, objr.name)
+		var obj *object
+		if objr.version != "" {
+			obj = objr.bucket.deleteVersion(objr.name, objr.version)
+		} else {
+			obj = objr.bucket.deleteObject(objr.name, a.srv.config.Clock.Now())
+		}
+		if obj != nil && obj.versionId != "" {
+			a.w.Header().Set("x-amz-version-id", obj.versionId)
+			if obj.deleteMarker {
+				a.w.Header().Set("x-amz-delete-marker", "true")
+			}
+		}
 	} else {
 		// Multipart commit abort
-		_, ok := objr.bucket.multipartUploads[uploadId]
+		parts, ok := objr.bucket.multipartUploads[uploadId]
 
 		if !ok {
 			fatalf(404, "NoSuchUpload", "The specified multipart upload does not exist. The upload ID might be invalid, or the multipart upload might have been aborted or completed.")
 		}
 
+		for _, p := range parts {
+			objr.bucket.store.removeBody(p.body)
+		}
 		delete(objr.bucket.multipartUploads, uploadId)
 	}
 	return nil
@@ -952,12 +1431,11 @@ func (objr objectResource) post(a *action) interface{} {
 			fatalf(400, "InvalidRequest", fmt.Sprintf("Number of parts does not match: expected %d, received %d", len(parts), len(req.Part)))
 		}
 
-		sum := md5.New()
-		data := &bytes.Buffer{}
-		w := io.MultiWriter(sum, data)
-
 		sort.Sort(multipartUploadPartByIndex(parts))
 
+		var readers []io.Reader
+		partSums := md5.New()
+
 		for i, p := range parts {
 			reqPart := req.Part[i]
 
@@ -968,26 +1446,49 @@ func (objr objectResource) post(a *action) interface{} {
 			if reqPart.ETag != p.etag {
 				fatalf(400, "InvalidRequest", fmt.Sprintf("Invalid etag for part %d", reqPart.PartNumber))
 			}
+			partSum, _ := hex.DecodeString(strings.Trim(p.etag, `"`))
+			partSums.Write(partSum)
 
-			w.Write(p.data)
+			r, err := p.body.open()
+			if err != nil {
+				fatalf(500, "InternalError", "cannot read part %d: %v", p.index, err)
+			}
+			defer r.Close()
+			readers = append(readers, io.NewSectionReader(r, 0, p.body.size))
 		}
 
+		st := objr.bucket.store
+		body, _, err := st.newBody(st.objectsDir(objr.bucket.name), io.MultiReader(readers...))
+		if err != nil {
+			fatalf(500, "InternalError", "cannot write object: %v", err)
+		}
+		for _, p := range parts {
+			st.removeBody(p.body)
+		}
 		delete(objr.bucket.multipartUploads, uploadId)
 
 		obj := objr.object
 
-		if obj == nil {
+		if obj == nil || objr.bucket.versioning != "" {
 			obj = &object{
 				name: objr.name,
 				meta: make(http.Header),
 			}
 		}
 
-		obj.data = data.Bytes()
-		obj.checksum = sum.Sum(nil)
+		oldBody := obj.body
+		obj.body = body
+		obj.checksum = partSums.Sum(nil)
+		obj.parts = len(parts)
 		obj.mtime = time.Now()
-		objr.bucket.objects[objr.name] = obj
 		obj.meta = objr.bucket.multipartMeta[uploadId]
+		objr.bucket.putObject(obj)
+		if obj.body.file != oldBody.file {
+			st.removeBody(oldBody)
+		}
+		if obj.versionId != "" {
+			a.w.Header().Set("x-amz-version-id", obj.versionId)
+		}
 
 		objectLocation := fmt.Sprintf("http://%s/%s/%s", a.srv.listener.Addr().String(), objr.bucket.name, objr.name)
 
@@ -995,7 +1496,7 @@ func (objr objectResource) post(a *action) interface{} {
 			Location: objectLocation,
 			Bucket:   objr.bucket.name,
 			Key:      objr.name,
-			ETag:     uploadId,
+			ETag:     obj.etag(),
 		}
 	}
 
@@ -0,0 +1,356 @@
+package s3test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/AdRoll/goamz/s3"
+)
+
+// objectBody holds the contents of an object or of a multipart upload
+// part, either in memory or in a file of a disk store.
+type objectBody struct {
+	data []byte
+	file string
+	size int64
+}
+
+type readerAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+type nopReaderAtCloser struct {
+	io.ReaderAt
+}
+
+func (nopReaderAtCloser) Close() error {
+	return nil
+}
+
+func (body objectBody) open() (readerAtCloser, error) {
+	if body.file == "" {
+		return nopReaderAtCloser{bytes.NewReader(body.data)}, nil
+	}
+	return os.Open(body.file)
+}
+
+// store keeps buckets and objects on disk, under root:
+//
+//	<bucket>/bucket.json        bucket configuration
+//	<bucket>/objects/<id>.json  object or version metadata
+//	<bucket>/objects/<id>.body  object or version contents
+//	.uploads/                   parts of multipart uploads in progress
+//
+// A nil *store keeps everything in memory.
+type store struct {
+	root string
+}
+
+type bucketRecord struct {
+	ACL          s3.ACL
+	ACLDoc       []byte
+	Ctime        time.Time
+	Versioning   string
+	Subresources map[string][]byte
+}
+
+type objectRecord struct {
+	Name         string
+	VersionId    string
+	DeleteMarker bool
+	Seq          int64
+	Mtime        time.Time
+	Meta         http.Header
+	Checksum     []byte
+	Parts        int
+	Body         string // name of the body file, if any.
+	Size         int64
+	ACL          s3.ACL
+	ACLDoc       []byte
+}
+
+func (st *store) bucketDir(name string) string {
+	return filepath.Join(st.root, name)
+}
+
+func (st *store) objectsDir(name string) string {
+	if st == nil {
+		return ""
+	}
+	return filepath.Join(st.root, name, "objects")
+}
+
+func (st *store) uploadsDir() string {
+	if st == nil {
+		return ""
+	}
+	return filepath.Join(st.root, ".uploads")
+}
+
+// newBody reads r until EOF into a new body, stored in dir, and returns it
+// with its MD5 sum.
+func (st *store) newBody(dir string, r io.Reader) (objectBody, []byte, error) {
+	sum := md5.New()
+	if st == nil {
+		data, err := ioutil.ReadAll(io.TeeReader(r, sum))
+		return objectBody{data: data, size: int64(len(data))}, sum.Sum(nil), err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return objectBody{}, nil, err
+	}
+	f, err := os.Create(filepath.Join(dir, newId()+".body"))
+	if err != nil {
+		return objectBody{}, nil, err
+	}
+	body := objectBody{file: f.Name()}
+	body.size, err = io.Copy(io.MultiWriter(f, sum), r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(body.file)
+		return objectBody{}, nil, err
+	}
+	return body, sum.Sum(nil), nil
+}
+
+// copyBody returns a copy of body stored in dir, with its MD5 sum.
+func (st *store) copyBody(dir string, body objectBody) (objectBody, []byte, error) {
+	r, err := body.open()
+	if err != nil {
+		return objectBody{}, nil, err
+	}
+	defer r.Close()
+	return st.newBody(dir, io.NewSectionReader(r, 0, body.size))
+}
+
+func (st *store) removeBody(body objectBody) {
+	if body.file != "" {
+		os.Remove(body.file)
+	}
+}
+
+func (st *store) saveBucket(b *bucket) error {
+	if st == nil {
+		return nil
+	}
+	data, err := json.Marshal(&bucketRecord{
+		ACL:          b.acl,
+		ACLDoc:       b.aclDoc,
+		Ctime:        b.ctime,
+		Versioning:   b.versioning,
+		Subresources: b.subresources,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(st.objectsDir(b.name), 0777); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(st.bucketDir(b.name), "bucket.json"), data)
+}
+
+func (st *store) removeBucket(b *bucket) error {
+	if st == nil {
+		return nil
+	}
+	return os.RemoveAll(st.bucketDir(b.name))
+}
+
+func (st *store) saveObject(b *bucket, obj *object) error {
+	if st == nil {
+		return nil
+	}
+	if obj.id == "" {
+		obj.id = newId()
+	}
+	var body string
+	if obj.body.file != "" {
+		body = filepath.Base(obj.body.file)
+	}
+	data, err := json.Marshal(&objectRecord{
+		Name:         obj.name,
+		VersionId:    obj.versionId,
+		DeleteMarker: obj.deleteMarker,
+		Seq:          obj.seq,
+		Mtime:        obj.mtime,
+		Meta:         obj.meta,
+		Checksum:     obj.checksum,
+		Parts:        obj.parts,
+		Body:         body,
+		Size:         obj.body.size,
+		ACL:          obj.acl,
+		ACLDoc:       obj.aclDoc,
+	})
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(st.objectsDir(b.name), obj.id+".json"), data)
+}
+
+func (st *store) removeObject(b *bucket, obj *object) error {
+	if st == nil {
+		return nil
+	}
+	st.removeBody(obj.body)
+	if obj.id == "" {
+		return nil
+	}
+	err := os.Remove(filepath.Join(st.objectsDir(b.name), obj.id+".json"))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
+
+// load reads the buckets kept under root, and discards the parts of
+// multipart uploads left by a previous server.
+func (st *store) load() (map[string]*bucket, error) {
+	if err := os.RemoveAll(st.uploadsDir()); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(st.root, 0777); err != nil {
+		return nil, err
+	}
+	dirs, err := ioutil.ReadDir(st.root)
+	if err != nil {
+		return nil, err
+	}
+	buckets := make(map[string]*bucket)
+	for _, dir := range dirs {
+		if !dir.IsDir() || !validBucketName(dir.Name()) {
+			continue
+		}
+		b, err := st.loadBucket(dir.Name())
+		if err != nil {
+			return nil, fmt.Errorf("cannot load bucket %q: %v", dir.Name(), err)
+		}
+		buckets[b.name] = b
+	}
+	return buckets, nil
+}
+
+func (st *store) loadBucket(name string) (*bucket, error) {
+	var rec bucketRecord
+	if err := readJSON(filepath.Join(st.bucketDir(name), "bucket.json"), &rec); err != nil {
+		return nil, err
+	}
+	b := newBucket(name, st)
+	b.acl = rec.ACL
+	b.aclDoc = rec.ACLDoc
+	b.ctime = rec.Ctime
+	b.versioning = rec.Versioning
+	for k, v := range rec.Subresources {
+		b.subresources[k] = v
+	}
+
+	files, err := filepath.Glob(filepath.Join(st.objectsDir(name), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var objs []*object
+	bodies := make(map[string]bool)
+	for _, file := range files {
+		var rec objectRecord
+		if err := readJSON(file, &rec); err != nil {
+			return nil, err
+		}
+		id := filepath.Base(file)
+		id = id[:len(id)-len(".json")]
+		obj := &object{
+			id:           id,
+			name:         rec.Name,
+			versionId:    rec.VersionId,
+			deleteMarker: rec.DeleteMarker,
+			seq:          rec.Seq,
+			mtime:        rec.Mtime,
+			meta:         rec.Meta,
+			checksum:     rec.Checksum,
+			parts:        rec.Parts,
+			acl:          rec.ACL,
+			aclDoc:       rec.ACLDoc,
+		}
+		if obj.meta == nil {
+			obj.meta = make(http.Header)
+		}
+		if rec.Body != "" {
+			obj.body = objectBody{file: filepath.Join(st.objectsDir(name), rec.Body), size: rec.Size}
+			bodies[obj.body.file] = true
+		}
+		objs = append(objs, obj)
+	}
+	// Remove the bodies left by writes that did not complete.
+	files, err = filepath.Glob(filepath.Join(st.objectsDir(name), "*.body"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if !bodies[file] {
+			os.Remove(file)
+		}
+	}
+
+	// Replay the versions of each key in the order they were written.
+	sort.Sort(objectsBySeq(objs))
+	for _, obj := range objs {
+		if obj.seq > b.seq {
+			b.seq = obj.seq
+		}
+		if b.versioning != "" {
+			b.versions[obj.name] = append(b.versions[obj.name], obj)
+		}
+		if obj.deleteMarker {
+			delete(b.objects, obj.name)
+		} else {
+			b.objects[obj.name] = obj
+		}
+	}
+	return b, nil
+}
+
+type objectsBySeq []*object
+
+func (s objectsBySeq) Len() int           { return len(s) }
+func (s objectsBySeq) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s objectsBySeq) Less(i, j int) bool { return s[i].seq < s[j].seq }
+
+// writeFile replaces the file name with data atomically, so that a crash
+// never leaves a partially written file behind.
+func writeFile(name string, data []byte) error {
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func readJSON(name string, v interface{}) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// newId returns a random id, for the files of the objects and for version
+// ids. It uses crypto/rand rather than math/rand, whose unseeded sequence
+// would repeat the ids of a previous server on the same root.
+func newId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,376 @@
+package s3test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AdRoll/goamz/s3"
+)
+
+// owner owns all buckets and objects of the server.
+var owner = s3.Owner{
+	ID:          "bcaf1ffd86f41161ca5fb16fd081034f",
+	DisplayName: "s3test",
+}
+
+type subresource struct {
+	// code and message make up the error returned when the document is
+	// not set, unless empty holds the document returned instead.
+	code    string
+	message string
+	empty   string
+}
+
+// bucketSubresources holds the bucket subresources the server implements.
+// Except for acl and versioning, their documents are stored as they were
+// put, and returned verbatim.
+var bucketSubresources = map[string]*subresource{
+	"acl":        {},
+	"versioning": {},
+	"cors":       {code: "NoSuchCORSConfiguration", message: "The CORS configuration does not exist"},
+	"encryption": {code: "ServerSideEncryptionConfigurationNotFoundError", message: "The server side encryption configuration was not found"},
+	"lifecycle":  {code: "NoSuchLifecycleConfiguration", message: "The lifecycle configuration does not exist"},
+	"policy":     {code: "NoSuchBucketPolicy", message: "The bucket policy does not exist"},
+	"tagging":    {code: "NoSuchTagSet", message: "The TagSet does not exist"},
+	"website":    {code: "NoSuchWebsiteConfiguration", message: "The specified bucket does not have a website configuration"},
+	"logging": {
+		empty: `<BucketLoggingStatus xmlns="http://s3.amazonaws.com/doc/2006-03-01/"/>`,
+	},
+	"notification": {
+		empty: `<NotificationConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"/>`,
+	},
+	"requestPayment": {
+		empty: `<RequestPaymentConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Payer>BucketOwner</Payer></RequestPaymentConfiguration>`,
+	},
+}
+
+// bucketSubresource is a configuration document of a bucket, addressed by
+// a query parameter such as ?lifecycle.
+type bucketSubresource struct {
+	name   string
+	bucket *bucket // always non-nil.
+}
+
+func (r bucketSubresource) get(a *action) interface{} {
+	a.w.Header().Set("Content-Type", "application/xml")
+	switch r.name {
+	case "acl":
+		return aclResponse(a, r.bucket.acl, r.bucket.aclDoc)
+	case "versioning":
+		return &s3.VersioningConfiguration{Status: s3.VersioningStatus(r.bucket.versioning)}
+	}
+	doc := r.bucket.subresources[r.name]
+	if doc == nil {
+		info := bucketSubresources[r.name]
+		if info.empty == "" {
+			fatalf(404, info.code, info.message)
+		}
+		doc = []byte(info.empty)
+	}
+	if r.name == "policy" {
+		a.w.Header().Set("Content-Type", "application/json")
+	}
+	if a.req.Method != "HEAD" {
+		a.w.Write(doc)
+	}
+	return nil
+}
+
+func (r bucketSubresource) put(a *action) interface{} {
+	if r.name == "acl" {
+		r.bucket.acl, r.bucket.aclDoc = putACL(a)
+		r.bucket.save()
+		return nil
+	}
+	data, err := ioutil.ReadAll(a.req.Body)
+	if err != nil {
+		fatalf(400, "TODO", "read error")
+	}
+	switch r.name {
+	case "versioning":
+		var conf struct {
+			Status string
+		}
+		if err := xml.Unmarshal(data, &conf); err != nil {
+			fatalf(400, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+		}
+		switch conf.Status {
+		case "Enabled", "Suspended":
+		default:
+			fatalf(400, "IllegalVersioningConfigurationException", "The Versioning element must be specified")
+		}
+		r.bucket.setVersioning(conf.Status)
+		r.bucket.save()
+		return nil
+	case "policy":
+		if !json.Valid(data) {
+			fatalf(400, "MalformedPolicy", "Policies must be valid JSON and the first byte must be '{'")
+		}
+	default:
+		if err := xml.Unmarshal(data, new(struct{})); err != nil {
+			fatalf(400, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+		}
+	}
+	r.bucket.subresources[r.name] = data
+	r.bucket.save()
+	return nil
+}
+
+func (r bucketSubresource) delete(a *action) interface{} {
+	switch r.name {
+	case "acl", "versioning", "notification", "requestPayment":
+		return notAllowed()
+	}
+	delete(r.bucket.subresources, r.name)
+	r.bucket.save()
+	a.w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (r bucketSubresource) post(a *action) interface{} {
+	return notAllowed()
+}
+
+type aclGrantee struct {
+	XMLNS       string `xml:"xmlns:xsi,attr"`
+	Type        string `xml:"xsi:type,attr"`
+	ID          string `xml:",omitempty"`
+	DisplayName string `xml:",omitempty"`
+	URI         string `xml:",omitempty"`
+}
+
+type aclGrant struct {
+	Grantee    aclGrantee
+	Permission string
+}
+
+type accessControlPolicy struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ AccessControlPolicy"`
+	Owner   s3.Owner
+	Grants  []aclGrant `xml:"AccessControlList>Grant"`
+}
+
+const (
+	allUsersURI           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	authenticatedUsersURI = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+	xsiNamespace          = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// cannedGrants returns the grants that make up the canned ACL acl.
+func cannedGrants(acl s3.ACL) []aclGrant {
+	group := func(uri, permission string) aclGrant {
+		return aclGrant{aclGrantee{XMLNS: xsiNamespace, Type: "Group", URI: uri}, permission}
+	}
+	grants := []aclGrant{{
+		aclGrantee{XMLNS: xsiNamespace, Type: "CanonicalUser", ID: owner.ID, DisplayName: owner.DisplayName},
+		"FULL_CONTROL",
+	}}
+	switch acl {
+	case s3.PublicRead:
+		grants = append(grants, group(allUsersURI, "READ"))
+	case s3.PublicReadWrite:
+		grants = append(grants, group(allUsersURI, "READ"), group(allUsersURI, "WRITE"))
+	case s3.AuthenticatedRead:
+		grants = append(grants, group(authenticatedUsersURI, "READ"))
+	}
+	return grants
+}
+
+// aclResponse returns the ACL document of a bucket or object with the
+// canned ACL acl, or writes doc if the ACL was put as a document.
+func aclResponse(a *action, acl s3.ACL, doc []byte) interface{} {
+	if doc != nil {
+		a.w.Header().Set("Content-Type", "application/xml")
+		a.w.Write(doc)
+		return nil
+	}
+	return &accessControlPolicy{Owner: owner, Grants: cannedGrants(acl)}
+}
+
+// putACL returns the canned ACL given in the x-amz-acl header of a PUT
+// ?acl request, or else the ACL document in its body.
+func putACL(a *action) (s3.ACL, []byte) {
+	if acl := a.req.Header.Get("x-amz-acl"); acl != "" {
+		return s3.ACL(acl), nil
+	}
+	data, err := ioutil.ReadAll(a.req.Body)
+	if err != nil {
+		fatalf(400, "TODO", "read error")
+	}
+	var policy s3.AccessControlList
+	if err := xml.Unmarshal(data, &policy); err != nil || len(policy.Grants.Grant) == 0 {
+		fatalf(400, "MalformedACLError", "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	return "", data
+}
+
+func objectACL(a *action, obj *object) interface{} {
+	return aclResponse(a, obj.acl, obj.aclDoc)
+}
+
+// keyVersions returns the versions of the key name, newest first.
+func (b *bucket) keyVersions(name string) []*object {
+	if b.versioning == "" {
+		if obj := b.objects[name]; obj != nil {
+			return []*object{obj}
+		}
+		return nil
+	}
+	versions := b.versions[name]
+	newestFirst := make([]*object, len(versions))
+	for i, obj := range versions {
+		newestFirst[len(versions)-1-i] = obj
+	}
+	return newestFirst
+}
+
+// GET on a bucket with the versions subresource lists the versions of the
+// objects in the bucket, including delete markers.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/RESTBucketGETVersion.html
+func (r bucketResource) listVersions(a *action) interface{} {
+	prefix := a.req.Form.Get("prefix")
+	delimiter := a.req.Form.Get("delimiter")
+	keyMarker := a.req.Form.Get("key-marker")
+	versionIdMarker := a.req.Form.Get("version-id-marker")
+	maxKeys := 1000
+	if s := a.req.Form.Get("max-keys"); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 0 {
+			fatalf(400, "InvalidArgument", "invalid value for max-keys: %q", s)
+		}
+		if i > 0 && i < maxKeys {
+			maxKeys = i
+		}
+	}
+	a.w.Header().Set("Content-Type", "application/xml")
+
+	if a.req.Method == "HEAD" {
+		return nil
+	}
+
+	type versionEntry struct {
+		XMLName xml.Name `xml:"Version"`
+		s3.Version
+	}
+
+	type deleteMarkerEntry struct {
+		XMLName xml.Name `xml:"DeleteMarker"`
+		s3.DeleteMarker
+	}
+
+	type commonPrefix struct {
+		Prefix string
+	}
+
+	type serverVersionsResponse struct {
+		XMLName             xml.Name `xml:"ListVersionsResult"`
+		Name                string
+		Prefix              string
+		KeyMarker           string
+		VersionIdMarker     string
+		MaxKeys             int
+		Delimiter           string
+		IsTruncated         bool
+		NextKeyMarker       string `xml:",omitempty"`
+		NextVersionIdMarker string `xml:",omitempty"`
+		Entries             []interface{}
+		CommonPrefixes      []commonPrefix
+	}
+
+	b := r.bucket
+	var names []string
+	seen := make(map[string]bool)
+	for name := range b.objects {
+		seen[name] = true
+	}
+	for name := range b.versions {
+		seen[name] = true
+	}
+	for name := range seen {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	resp := &serverVersionsResponse{
+		Name:            b.name,
+		Prefix:          prefix,
+		KeyMarker:       keyMarker,
+		VersionIdMarker: versionIdMarker,
+		MaxKeys:         maxKeys,
+		Delimiter:       delimiter,
+	}
+	n := 0
+	var nextKeyMarker, nextVersionIdMarker string
+names:
+	for _, name := range names {
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				p := name[:len(prefix)+i+len(delimiter)]
+				if p <= keyMarker || len(resp.CommonPrefixes) > 0 && resp.CommonPrefixes[len(resp.CommonPrefixes)-1].Prefix == p {
+					continue
+				}
+				if n >= maxKeys {
+					resp.IsTruncated = true
+					break
+				}
+				resp.CommonPrefixes = append(resp.CommonPrefixes, commonPrefix{p})
+				nextKeyMarker, nextVersionIdMarker = p, ""
+				n++
+				continue
+			}
+		}
+		if name < keyMarker || name == keyMarker && versionIdMarker == "" {
+			continue
+		}
+		skipping := name == keyMarker
+		for i, obj := range b.keyVersions(name) {
+			versionId := obj.versionId
+			if versionId == "" {
+				versionId = "null"
+			}
+			if skipping {
+				skipping = versionId != versionIdMarker
+				continue
+			}
+			if n >= maxKeys {
+				resp.IsTruncated = true
+				break names
+			}
+			lastModified := obj.mtime.UTC().Format(timeFormat)
+			if obj.deleteMarker {
+				resp.Entries = append(resp.Entries, &deleteMarkerEntry{DeleteMarker: s3.DeleteMarker{
+					Key:          name,
+					VersionId:    versionId,
+					IsLatest:     i == 0,
+					LastModified: lastModified,
+					Owner:        owner,
+				}})
+			} else {
+				resp.Entries = append(resp.Entries, &versionEntry{Version: s3.Version{
+					Key:          name,
+					VersionId:    versionId,
+					IsLatest:     i == 0,
+					LastModified: lastModified,
+					ETag:         obj.etag(),
+					Size:         obj.body.size,
+					Owner:        owner,
+					StorageClass: "STANDARD",
+				}})
+			}
+			nextKeyMarker, nextVersionIdMarker = name, versionId
+			n++
+		}
+	}
+	if resp.IsTruncated {
+		resp.NextKeyMarker = nextKeyMarker
+		resp.NextVersionIdMarker = nextVersionIdMarker
+	}
+	return resp
+}
@@ -37,6 +37,8 @@ var s3ParamsToSign = map[string]bool{
 	"response-content-encoding":    true,
 	"website":                      true,
 	"delete":                       true,
+	"restore":                      true,
+	"tagging":                      true,
 }
 
 func sign(auth aws.Auth, method, canonicalPath string, params, headers map[string][]string) {
@@ -0,0 +1,117 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"net/url"
+	"strconv"
+)
+
+// Implements an interface for s3 object tagging.
+// See http://docs.aws.amazon.com/AmazonS3/latest/dev/object-tagging.html
+// for details.
+
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  []Tag    `xml:"TagSet>Tag"`
+}
+
+// encodeTags returns tags in the URL query form expected by the
+// x-amz-tagging header.
+func encodeTags(tags []Tag) string {
+	buf := make([]byte, 0, 64)
+	for i, t := range tags {
+		if i > 0 {
+			buf = append(buf, '&')
+		}
+		buf = append(buf, url.QueryEscape(t.Key)...)
+		buf = append(buf, '=')
+		buf = append(buf, url.QueryEscape(t.Value)...)
+	}
+	return string(buf)
+}
+
+func taggingParams(versionId string) url.Values {
+	params := url.Values{"tagging": {""}}
+	if versionId != "" {
+		params.Set("versionId", versionId)
+	}
+	return params
+}
+
+// GetTagging returns the tag set of the object at path. An empty versionId
+// addresses the latest version of the object.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectGETtagging.html
+// for details.
+func (b *Bucket) GetTagging(path, versionId string) ([]Tag, error) {
+	req := &request{
+		method: "GET",
+		bucket: b.Name,
+		path:   path,
+		params: taggingParams(versionId),
+	}
+	var err error
+	resp := &Tagging{}
+	for attempt := attempts.Start(); attempt.Next(); {
+		err = b.S3.query(req, resp)
+		if !shouldRetry(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.TagSet, nil
+}
+
+// PutTagging replaces the tag set of the object at path. An empty versionId
+// addresses the latest version of the object.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectPUTtagging.html
+// for details.
+func (b *Bucket) PutTagging(path, versionId string, tags []Tag) error {
+	doc, err := xml.Marshal(Tagging{TagSet: tags})
+	if err != nil {
+		return err
+	}
+
+	buf := makeXmlBuffer(doc)
+	digest := md5.New()
+	size, err := digest.Write(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	headers := map[string][]string{
+		"Content-Length": {strconv.FormatInt(int64(size), 10)},
+		"Content-MD5":    {base64.StdEncoding.EncodeToString(digest.Sum(nil))},
+	}
+	req := &request{
+		method:  "PUT",
+		bucket:  b.Name,
+		path:    path,
+		headers: headers,
+		params:  taggingParams(versionId),
+		payload: buf,
+	}
+	return b.S3.query(req, nil)
+}
+
+// DelTagging removes all tags from the object at path. An empty versionId
+// addresses the latest version of the object.
+func (b *Bucket) DelTagging(path, versionId string) error {
+	req := &request{
+		method: "DELETE",
+		bucket: b.Name,
+		path:   path,
+		params: taggingParams(versionId),
+	}
+	return b.S3.query(req, nil)
+}
@@ -0,0 +1,60 @@
+package s3_test
+
+import (
+	"io/ioutil"
+
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestGetTagging(c *check.C) {
+	testServer.Response(200, nil, GetTaggingDump)
+
+	b := s.s3.Bucket("bucket")
+	tags, err := b.GetTagging("name", "")
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.URL.RawQuery, check.Equals, "tagging=")
+
+	c.Assert(tags, check.DeepEquals, []s3.Tag{
+		{Key: "project", Value: "goamz"},
+		{Key: "owner", Value: "ops"},
+	})
+}
+
+func (s *S) TestPutTagging(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.PutTagging("name", "v1", []s3.Tag{{Key: "project", Value: "goamz"}})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.Form["tagging"], check.DeepEquals, []string{""})
+	c.Assert(req.Form["versionId"], check.DeepEquals, []string{"v1"})
+	c.Assert(req.Header["Content-Md5"], check.HasLen, 1)
+
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<Tagging><TagSet><Tag><Key>project</Key><Value>goamz</Value></Tag></TagSet></Tagging>`)
+}
+
+func (s *S) TestDelTagging(c *check.C) {
+	testServer.Response(204, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.DelTagging("name", "")
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "DELETE")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.URL.RawQuery, check.Equals, "tagging=")
+}
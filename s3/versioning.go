@@ -0,0 +1,59 @@
+package s3
+
+import (
+	"encoding/xml"
+	"net/url"
+)
+
+// VersioningStatus is the versioning state of a bucket. The zero value
+// means versioning has never been configured on the bucket; once enabled,
+// it can only be suspended.
+type VersioningStatus string
+
+const (
+	VersioningEnabled   = VersioningStatus("Enabled")
+	VersioningSuspended = VersioningStatus("Suspended")
+)
+
+type VersioningConfiguration struct {
+	XMLName   xml.Name         `xml:"http://s3.amazonaws.com/doc/2006-03-01/ VersioningConfiguration"`
+	Status    VersioningStatus `xml:"Status,omitempty"`
+	MfaDelete string           `xml:"MfaDelete,omitempty"`
+}
+
+// PutVersioning enables or suspends versioning on the bucket.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/API/RESTBucketPUTVersioningStatus.html for details.
+func (b *Bucket) PutVersioning(status VersioningStatus) error {
+	doc, err := xml.Marshal(VersioningConfiguration{Status: status})
+	if err != nil {
+		return err
+	}
+
+	buf := makeXmlBuffer(doc)
+
+	return b.PutBucketSubresource("versioning", buf, int64(buf.Len()))
+}
+
+// GetVersioning returns the versioning state of the bucket.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/API/RESTBucketGETversioningStatus.html for details.
+func (b *Bucket) GetVersioning() (VersioningStatus, error) {
+	req := &request{
+		bucket: b.Name,
+		path:   "/",
+		params: url.Values{"versioning": {""}},
+	}
+	conf := &VersioningConfiguration{}
+	var err error
+	for attempt := attempts.Start(); attempt.Next(); {
+		err = b.S3.query(req, conf)
+		if !shouldRetry(err) {
+			break
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return conf.Status, nil
+}
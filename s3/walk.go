@@ -0,0 +1,82 @@
+package s3
+
+import (
+	"errors"
+)
+
+// WalkFunc is the type of the function called by Walk and WalkDelim for
+// each key visited. If it returns an error, the walk stops and that error
+// is returned by Walk, except for SkipPrefix.
+type WalkFunc func(key Key) error
+
+// SkipPrefix may be returned by a WalkFunc called for a common prefix by
+// WalkDelim to skip the keys under that prefix. Returned for a key, it
+// skips the remaining keys and common prefixes of the level of that key,
+// which is the whole walk for Walk, as filepath.SkipDir does for files. It
+// is not returned as an error by any function.
+var SkipPrefix = errors.New("skip this prefix")
+
+// That's the default. Here just for testing.
+var walkMax = 1000
+
+// Walk calls fn for every key in b that begins with prefix, in
+// alphabetical order. Results are paged through with ListV2
+// transparently, so the keys under prefix never need to be held in
+// memory at once.
+func (b *Bucket) Walk(prefix string, fn WalkFunc) error {
+	return b.walk(prefix, "", fn)
+}
+
+// WalkDelim is like Walk, but lists the keys under prefix one level at a
+// time, using delim to split keys into directories. For each common prefix
+// found, fn is first called with a Key holding only the common prefix
+// (ending in delim) as its name. WalkDelim then descends into it unless fn
+// returned SkipPrefix.
+func (b *Bucket) WalkDelim(prefix, delim string, fn WalkFunc) error {
+	if delim == "" {
+		return errors.New("s3: WalkDelim requires a delimiter")
+	}
+	return b.walk(prefix, delim, fn)
+}
+
+func (b *Bucket) walk(prefix, delim string, fn WalkFunc) error {
+	token := ""
+	for {
+		resp, err := b.ListV2(prefix, delim, token, "", walkMax, false)
+		if err != nil {
+			return err
+		}
+		// Contents and CommonPrefixes are each sorted; merge them so
+		// that fn sees keys in alphabetical order.
+		keys, prefixes := resp.Contents, resp.CommonPrefixes
+		for len(keys) > 0 || len(prefixes) > 0 {
+			if len(prefixes) == 0 || len(keys) > 0 && keys[0].Key < prefixes[0] {
+				err := fn(keys[0])
+				if err == SkipPrefix {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				keys = keys[1:]
+				continue
+			}
+			p := prefixes[0]
+			prefixes = prefixes[1:]
+			err := fn(Key{Key: p})
+			if err == SkipPrefix {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := b.walk(p, delim, fn); err != nil {
+				return err
+			}
+		}
+		if !resp.IsTruncated || resp.NextContinuationToken == "" {
+			return nil
+		}
+		token = resp.NextContinuationToken
+	}
+}